@@ -25,6 +25,7 @@ func Generate(db *database.Database, cert *certificate.Certificate) (
 	logrus.WithFields(logrus.Fields{
 		"certificate": cert.Name,
 		"domains":     cert.AcmeDomains,
+		"directory":   getDirectory(cert),
 	}).Info("acme: Generating acme certificate")
 
 	if cert.AcmeDomains == nil || len(cert.AcmeDomains) == 0 {
@@ -73,10 +74,17 @@ func Generate(db *database.Database, cert *certificate.Certificate) (
 		}
 	}
 
-	acct := &acme.Account{}
+	eab, err := getEab(cert)
+	if err != nil {
+		return
+	}
+
+	acct := &acme.Account{
+		ExternalAccountBinding: eab,
+	}
 
 	client := &acme.Client{
-		DirectoryURL: AcmeDirectory,
+		DirectoryURL: getDirectory(cert),
 		Key:          acctKey,
 	}
 
@@ -127,9 +135,14 @@ func Generate(db *database.Database, cert *certificate.Certificate) (
 			continue
 		}
 
+		chalType := "http-01"
+		if cert.AcmeType == certificate.AcmeDns {
+			chalType = "dns-01"
+		}
+
 		var authzChal *acme.Challenge
 		for _, c := range authz.Challenges {
-			if c.Type == "http-01" {
+			if c.Type == chalType {
 				authzChal = c
 				break
 			}
@@ -139,58 +152,21 @@ func Generate(db *database.Database, cert *certificate.Certificate) (
 			revoke(client, authzUrls)
 
 			err = &errortypes.RequestError{
-				errors.New(
-					"acme: Authorization HTTP challenge not available"),
-			}
-			return
-		}
-
-		resp, e := client.HTTP01ChallengeResponse(authzChal.Token)
-		if e != nil {
-			revoke(client, authzUrls)
-
-			err = &errortypes.RequestError{
-				errors.Wrap(e, "acme: Challenge response failed"),
-			}
-			return
-		}
-
-		chal := &Challenge{
-			Id:        authzChal.Token,
-			Resource:  resp,
-			Timestamp: time.Now(),
-		}
-
-		err = chal.Insert(db)
-		if err != nil {
-			return
-		}
-
-		_, err = client.Accept(context.Background(), authzChal)
-		if err != nil {
-			revoke(client, authzUrls)
-
-			err = &errortypes.RequestError{
-				errors.Wrap(err, "acme: Authorization accept failed"),
+				errors.Newf(
+					"acme: Authorization %s challenge not available",
+					chalType,
+				),
 			}
 			return
 		}
 
-		_, err = client.WaitAuthorization(
-			context.Background(), authzChal.URI)
-		if err != nil {
-			revoke(client, authzUrls)
-
-			err = &errortypes.RequestError{
-				errors.Wrap(err, "acme: Authorization wait failed"),
-			}
-			return
+		if chalType == "dns-01" {
+			err = dnsChallenge(db, cert, client, authz, authzChal)
+		} else {
+			err = httpChallenge(db, client, authzChal)
 		}
-
-		err = chal.Remove(db)
 		if err != nil {
 			revoke(client, authzUrls)
-
 			return
 		}
 	}
@@ -220,7 +196,7 @@ func Generate(db *database.Database, cert *certificate.Certificate) (
 		}
 	}
 
-	derChain, _, err := client.CreateOrderCert(
+	derChain, certUrl, err := client.CreateOrderCert(
 		context.Background(),
 		order.FinalizeURL,
 		csr,
@@ -233,6 +209,11 @@ func Generate(db *database.Database, cert *certificate.Certificate) (
 		return
 	}
 
+	derChain, err = selectChain(client, cert, certUrl, derChain)
+	if err != nil {
+		return
+	}
+
 	certPem := ""
 
 	for _, der := range derChain {
@@ -265,6 +246,57 @@ func Generate(db *database.Database, cert *certificate.Certificate) (
 	return
 }
 
+func httpChallenge(db *database.Database, client *acme.Client,
+	authzChal *acme.Challenge) (err error) {
+
+	resp, err := client.HTTP01ChallengeResponse(authzChal.Token)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "acme: Challenge response failed"),
+		}
+		return
+	}
+
+	chal := &Challenge{
+		Id:        authzChal.Token,
+		Resource:  resp,
+		Timestamp: time.Now(),
+	}
+
+	err = chal.Insert(db)
+	if err != nil {
+		return
+	}
+
+	_, err = client.Accept(context.Background(), authzChal)
+	if err != nil {
+		chal.Remove(db)
+
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "acme: Authorization accept failed"),
+		}
+		return
+	}
+
+	_, err = client.WaitAuthorization(
+		context.Background(), authzChal.URI)
+	if err != nil {
+		chal.Remove(db)
+
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "acme: Authorization wait failed"),
+		}
+		return
+	}
+
+	err = chal.Remove(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func Update(db *database.Database, cert *certificate.Certificate) (err error) {
 	if cert.Type != certificate.LetsEncrypt {
 		return
@@ -0,0 +1,349 @@
+package acme
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/balancer"
+	"github.com/pritunl/pritunl-cloud/certificate"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/publicsuffix"
+)
+
+type failureEvent struct {
+	Organization primitive.ObjectID `bson:"organization" json:"organization"`
+	Balancer     primitive.ObjectID `bson:"balancer" json:"balancer"`
+	Certificate  primitive.ObjectID `bson:"certificate" json:"certificate"`
+	Domains      []string           `bson:"domains" json:"domains"`
+	Failures     int                `bson:"failures" json:"failures"`
+	Error        string             `bson:"error" json:"error"`
+}
+
+type domainGroup struct {
+	Key     string
+	Domains []string
+}
+
+func groupDomains(domains []string) (groups []*domainGroup) {
+	groups = []*domainGroup{}
+	bases := map[string][]string{}
+	added := set.NewSet()
+
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+		if domain == "" || strings.HasPrefix(domain, "*.") ||
+			net.ParseIP(domain) != nil || added.Contains(domain) {
+
+			continue
+		}
+		added.Add(domain)
+
+		base, err := publicsuffix.EffectiveTLDPlusOne(domain)
+		if err != nil {
+			base = domain
+		}
+
+		bases[base] = append(bases[base], domain)
+	}
+
+	baseKeys := []string{}
+	for base := range bases {
+		baseKeys = append(baseKeys, base)
+	}
+	sort.Strings(baseKeys)
+
+	for _, base := range baseKeys {
+		names := bases[base]
+		sort.Strings(names)
+
+		for i := 0; i*AutoMaxNames < len(names); i++ {
+			end := (i + 1) * AutoMaxNames
+			if end > len(names) {
+				end = len(names)
+			}
+
+			key := base
+			if i > 0 {
+				key = fmt.Sprintf("%s-%d", base, i)
+			}
+
+			groups = append(groups, &domainGroup{
+				Key:     key,
+				Domains: names[i*AutoMaxNames : end],
+			})
+		}
+	}
+
+	return
+}
+
+func autoBackoff(failures int) (backoff time.Duration) {
+	backoff = AutoBackoff
+	for i := 1; i < failures; i++ {
+		backoff *= 2
+		if backoff >= AutoBackoffMax {
+			return AutoBackoffMax
+		}
+	}
+	return
+}
+
+func autoIssue(db *database.Database, balnc *balancer.Balancer,
+	cert *certificate.Certificate, orders *int) (err error) {
+
+	expiring := cert.Info != nil && !cert.Info.ExpiresOn.IsZero() &&
+		time.Until(cert.Info.ExpiresOn) < 168*time.Hour
+	if cert.AcmeHash == cert.Hash() && cert.Certificate != "" && !expiring {
+		return
+	}
+
+	if cert.AcmeFailures > 0 &&
+		time.Since(cert.AcmeAttempt) < autoBackoff(cert.AcmeFailures) {
+
+		return
+	}
+
+	if *orders >= AutoMaxOrders {
+		return
+	}
+	*orders += 1
+
+	cert.AcmeAttempt = time.Now()
+
+	e := Generate(db, cert)
+	if e != nil {
+		cert.AcmeFailures += 1
+		cert.AcmeError = e.Error()
+
+		logrus.WithFields(logrus.Fields{
+			"balancer_id":      balnc.Id.Hex(),
+			"balancer_name":    balnc.Name,
+			"certificate_id":   cert.Id.Hex(),
+			"certificate_name": cert.Name,
+			"domains":          cert.AcmeDomains,
+			"failures":         cert.AcmeFailures,
+			"error":            e,
+		}).Error("acme: Failed to issue balancer certificate")
+
+		event.Publish(db, "acme.failure", &failureEvent{
+			Organization: balnc.Organization,
+			Balancer:     balnc.Id,
+			Certificate:  cert.Id,
+			Domains:      cert.AcmeDomains,
+			Failures:     cert.AcmeFailures,
+			Error:        cert.AcmeError,
+		})
+	} else {
+		cert.AcmeFailures = 0
+		cert.AcmeError = ""
+	}
+
+	err = cert.CommitFields(db, set.NewSet(
+		"acme_error", "acme_failures", "acme_attempt"))
+	if err != nil {
+		return
+	}
+
+	event.PublishDispatch(db, "certificate.change")
+
+	return
+}
+
+func SyncBalancer(db *database.Database, balnc *balancer.Balancer,
+	orders *int) (err error) {
+
+	groups := []*domainGroup{}
+	if balnc.AutoCert && balnc.Type == balancer.Http {
+		domains := []string{}
+		for _, domain := range balnc.Domains {
+			domains = append(domains, domain.Domain)
+		}
+		groups = groupDomains(domains)
+	}
+
+	certs, err := certificate.GetAllBalancer(db, balnc.Id)
+	if err != nil {
+		return
+	}
+
+	acctKey := ""
+	ownedIds := set.NewSet()
+	certsGroup := map[string]*certificate.Certificate{}
+	for _, cert := range certs {
+		ownedIds.Add(cert.Id)
+		certsGroup[cert.AcmeGroup] = cert
+		if acctKey == "" {
+			acctKey = cert.AcmeAccount
+		}
+	}
+
+	certIds := []primitive.ObjectID{}
+	for _, certId := range balnc.Certificates {
+		if !ownedIds.Contains(certId) {
+			certIds = append(certIds, certId)
+		}
+	}
+
+	groupKeys := set.NewSet()
+	for _, group := range groups {
+		groupKeys.Add(group.Key)
+
+		cert := certsGroup[group.Key]
+		if cert == nil {
+			cert = &certificate.Certificate{
+				Name:         fmt.Sprintf("%s-%s", balnc.Name, group.Key),
+				Comment:      "Managed by load balancer",
+				Organization: balnc.Organization,
+				Type:         certificate.LetsEncrypt,
+				AcmeAccount:  acctKey,
+				AcmeDomains:  group.Domains,
+				AcmeGroup:    group.Key,
+				Balancer:     balnc.Id,
+			}
+
+			errData, e := cert.Validate(db)
+			if e != nil {
+				err = e
+				return
+			}
+			if errData != nil {
+				err = &errortypes.VerificationError{
+					errors.Newf("acme: Invalid balancer certificate, %s",
+						errData.Message),
+				}
+				return
+			}
+
+			err = cert.Insert(db)
+			if err != nil {
+				return
+			}
+
+			event.PublishDispatch(db, "certificate.change")
+		} else if strings.Join(cert.AcmeDomains, ",") !=
+			strings.Join(group.Domains, ",") {
+
+			cert.AcmeDomains = group.Domains
+			cert.AcmeFailures = 0
+			cert.AcmeError = ""
+
+			err = cert.CommitFields(db, set.NewSet(
+				"acme_domains", "acme_failures", "acme_error"))
+			if err != nil {
+				return
+			}
+		}
+
+		err = autoIssue(db, balnc, cert, orders)
+		if err != nil {
+			return
+		}
+
+		if acctKey == "" {
+			acctKey = cert.AcmeAccount
+		}
+
+		if cert.Certificate != "" {
+			certIds = append(certIds, cert.Id)
+		}
+	}
+
+	for _, cert := range certs {
+		if groupKeys.Contains(cert.AcmeGroup) {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"balancer_id":      balnc.Id.Hex(),
+			"certificate_id":   cert.Id.Hex(),
+			"certificate_name": cert.Name,
+		}).Info("acme: Removing unused balancer certificate")
+
+		err = certificate.Remove(db, cert.Id)
+		if err != nil {
+			return
+		}
+
+		event.PublishDispatch(db, "certificate.change")
+	}
+
+	changed := len(certIds) != len(balnc.Certificates)
+	if !changed {
+		for i, certId := range certIds {
+			if balnc.Certificates[i] != certId {
+				changed = true
+				break
+			}
+		}
+	}
+
+	if changed {
+		balnc.Certificates = certIds
+		err = balnc.CommitFields(db, set.NewSet("certificates"))
+		if err != nil {
+			return
+		}
+
+		event.PublishDispatch(db, "balancer.change")
+	}
+
+	return
+}
+
+func SyncBalancers(db *database.Database) (err error) {
+	balncs, err := balancer.GetAll(db, &bson.M{})
+	if err != nil {
+		return
+	}
+
+	orders := 0
+	balncIds := set.NewSet()
+	for _, balnc := range balncs {
+		balncIds.Add(balnc.Id)
+
+		e := SyncBalancer(db, balnc, &orders)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"balancer_id":   balnc.Id.Hex(),
+				"balancer_name": balnc.Name,
+				"error":         e,
+			}).Error("acme: Failed to sync balancer certificates")
+
+			event.Publish(db, "acme.failure", &failureEvent{
+				Organization: balnc.Organization,
+				Balancer:     balnc.Id,
+				Error:        e.Error(),
+			})
+		}
+	}
+
+	certs, err := certificate.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	for _, cert := range certs {
+		if cert.Balancer.IsZero() || balncIds.Contains(cert.Balancer) {
+			continue
+		}
+
+		err = certificate.Remove(db, cert.Id)
+		if err != nil {
+			return
+		}
+
+		event.PublishDispatch(db, "certificate.change")
+	}
+
+	return
+}
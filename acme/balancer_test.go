@@ -0,0 +1,96 @@
+package acme
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func formatGroups(groups []*domainGroup) string {
+	parts := []string{}
+	for _, group := range groups {
+		parts = append(parts, fmt.Sprintf("%s=%s",
+			group.Key, strings.Join(group.Domains, ",")))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestGroupDomainsBase(t *testing.T) {
+	groups := groupDomains([]string{
+		"www.example.org",
+		"www.example.com",
+		"a.example.org",
+		"example.com",
+	})
+
+	expected := "example.com=example.com,www.example.com " +
+		"example.org=a.example.org,www.example.org"
+	if formatGroups(groups) != expected {
+		t.Errorf("groups %s expected %s", formatGroups(groups), expected)
+	}
+
+	groups = groupDomains([]string{
+		"a.example.co.uk",
+		"b.example.co.uk",
+		"c.other.co.uk",
+	})
+
+	expected = "example.co.uk=a.example.co.uk,b.example.co.uk " +
+		"other.co.uk=c.other.co.uk"
+	if formatGroups(groups) != expected {
+		t.Errorf("public suffix groups %s expected %s",
+			formatGroups(groups), expected)
+	}
+}
+
+func TestGroupDomainsFilter(t *testing.T) {
+	groups := groupDomains([]string{
+		"WWW.Example.com.",
+		"www.example.com",
+		"",
+		"*.example.com",
+		"10.0.0.1",
+		"fd00::1",
+	})
+
+	expected := "example.com=www.example.com"
+	if formatGroups(groups) != expected {
+		t.Errorf("groups %s expected %s", formatGroups(groups), expected)
+	}
+
+	groups = groupDomains([]string{})
+	if groups == nil || len(groups) != 0 {
+		t.Errorf("empty domains returned %v", groups)
+	}
+}
+
+func TestGroupDomainsSplit(t *testing.T) {
+	domains := []string{}
+	for i := AutoMaxNames + 4; i >= 0; i-- {
+		domains = append(domains, fmt.Sprintf("host%03d.example.com", i))
+	}
+
+	groups := groupDomains(domains)
+	if len(groups) != 2 {
+		t.Fatalf("split into %d groups expected 2", len(groups))
+	}
+
+	if groups[0].Key != "example.com" ||
+		len(groups[0].Domains) != AutoMaxNames {
+
+		t.Errorf("first group %s with %d domains",
+			groups[0].Key, len(groups[0].Domains))
+	}
+	if groups[1].Key != "example.com-1" || len(groups[1].Domains) != 5 {
+		t.Errorf("second group %s with %d domains",
+			groups[1].Key, len(groups[1].Domains))
+	}
+
+	// Sorted names keep each certificate stable as domains are added
+	if groups[0].Domains[0] != "host000.example.com" ||
+		groups[1].Domains[4] != fmt.Sprintf(
+			"host%03d.example.com", AutoMaxNames+4) {
+
+		t.Errorf("domains not sorted across groups")
+	}
+}
@@ -0,0 +1,116 @@
+package acme
+
+import (
+	"context"
+	"crypto/x509"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/certificate"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme"
+)
+
+func getDirectory(cert *certificate.Certificate) string {
+	if cert.AcmeUrl != "" {
+		return cert.AcmeUrl
+	}
+
+	if settings.Acme.Directory != "" {
+		return settings.Acme.Directory
+	}
+
+	return AcmeDirectory
+}
+
+func getEab(cert *certificate.Certificate) (
+	eab *acme.ExternalAccountBinding, err error) {
+
+	keyId := cert.AcmeEabId
+	key := cert.AcmeEabKey
+	if keyId == "" && cert.AcmeUrl == "" {
+		keyId = settings.Acme.EabId
+		key = settings.Acme.EabKey
+	}
+
+	if keyId == "" || key == "" {
+		return
+	}
+
+	hmacKey, err := certificate.ParseEabKey(key)
+	if err != nil {
+		return
+	}
+
+	eab = &acme.ExternalAccountBinding{
+		KID: keyId,
+		Key: hmacKey,
+	}
+
+	return
+}
+
+func getChain(cert *certificate.Certificate) string {
+	if cert.AcmeChain != "" {
+		return cert.AcmeChain
+	}
+	return settings.Acme.PreferredChain
+}
+
+func chainIssuer(derChain [][]byte) string {
+	if len(derChain) == 0 {
+		return ""
+	}
+
+	top, err := x509.ParseCertificate(derChain[len(derChain)-1])
+	if err != nil {
+		return ""
+	}
+
+	return top.Issuer.CommonName
+}
+
+func selectChain(client *acme.Client, cert *certificate.Certificate,
+	certUrl string, derChain [][]byte) (chain [][]byte, err error) {
+
+	chain = derChain
+
+	preferred := getChain(cert)
+	if preferred == "" || certUrl == "" ||
+		chainIssuer(derChain) == preferred {
+
+		return
+	}
+
+	altUrls, err := client.ListCertAlternates(context.Background(), certUrl)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "acme: Failed to list alternate chains"),
+		}
+		return
+	}
+
+	for _, altUrl := range altUrls {
+		altChain, e := client.FetchCert(context.Background(), altUrl, true)
+		if e != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(e, "acme: Failed to fetch alternate chain"),
+			}
+			return
+		}
+
+		if chainIssuer(altChain) == preferred {
+			chain = altChain
+			return
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"certificate":     cert.Name,
+		"preferred_chain": preferred,
+		"default_chain":   chainIssuer(derChain),
+	}).Warn("acme: Preferred chain not available, using default")
+
+	return
+}
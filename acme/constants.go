@@ -1,6 +1,18 @@
 package acme
 
+import (
+	"time"
+)
+
 const (
 	AcmeDirectory = "https://acme-v02.api.letsencrypt.org/directory"
 	AcmePath      = "/.well-known/acme-challenge/"
+
+	DnsPropagationTimeout  = 3 * time.Minute
+	DnsPropagationInterval = 5 * time.Second
+
+	AutoMaxNames   = 100
+	AutoMaxOrders  = 5
+	AutoBackoff    = 1 * time.Hour
+	AutoBackoffMax = 24 * time.Hour
 )
@@ -0,0 +1,158 @@
+package acme
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/certificate"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/domain"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/acme"
+)
+
+func lookupTxt(nameserver, fqdn string) (values []string, err error) {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (
+			net.Conn, error) {
+
+			dialer := &net.Dialer{
+				Timeout: 5 * time.Second,
+			}
+			return dialer.DialContext(ctx, network,
+				net.JoinHostPort(nameserver, "53"))
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	values, err = resolver.LookupTXT(ctx, fqdn)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "acme: Failed to lookup TXT record"),
+		}
+		return
+	}
+
+	return
+}
+
+func waitPropagation(zone, fqdn, value string) (err error) {
+	nameservers, err := net.LookupNS(zone)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "acme: Failed to lookup zone nameservers"),
+		}
+		return
+	}
+
+	pending := map[string]bool{}
+	for _, ns := range nameservers {
+		pending[ns.Host] = true
+	}
+
+	start := time.Now()
+	for {
+		for nameserver := range pending {
+			values, e := lookupTxt(nameserver, fqdn)
+			if e != nil {
+				continue
+			}
+
+			for _, val := range values {
+				if val == value {
+					delete(pending, nameserver)
+					break
+				}
+			}
+		}
+
+		if len(pending) == 0 {
+			return
+		}
+
+		if time.Since(start) > DnsPropagationTimeout {
+			err = &errortypes.TimeoutError{
+				errors.Newf(
+					"acme: Timeout waiting for TXT record '%s' to propagate",
+					fqdn,
+				),
+			}
+			return
+		}
+
+		time.Sleep(DnsPropagationInterval)
+	}
+}
+
+func dnsChallenge(db *database.Database, cert *certificate.Certificate,
+	client *acme.Client, authz *acme.Authorization,
+	authzChal *acme.Challenge) (err error) {
+
+	fqdn := "_acme-challenge." + authz.Identifier.Value
+
+	domn, name, err := domain.FindOrg(db, cert.Organization, fqdn)
+	if err != nil {
+		return
+	}
+
+	value, err := client.DNS01ChallengeRecord(authzChal.Token)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "acme: Challenge record failed"),
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"certificate": cert.Name,
+		"domain":      domn.Name,
+		"record":      fqdn,
+	}).Info("acme: Creating DNS challenge record")
+
+	err = domn.UpsertTxt(name, []string{value})
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		e := domn.RemoveTxt(name)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"certificate": cert.Name,
+				"domain":      domn.Name,
+				"record":      fqdn,
+				"error":       e,
+			}).Error("acme: Failed to remove DNS challenge record")
+		}
+	}()
+
+	err = waitPropagation(domn.Name, fqdn, value)
+	if err != nil {
+		return
+	}
+
+	_, err = client.Accept(context.Background(), authzChal)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "acme: Authorization accept failed"),
+		}
+		return
+	}
+
+	_, err = client.WaitAuthorization(
+		context.Background(), authzChal.URI)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "acme: Authorization wait failed"),
+		}
+		return
+	}
+
+	return
+}
@@ -28,6 +28,8 @@ type authorityData struct {
 	Key          string             `json:"key"`
 	Roles        []string           `json:"roles"`
 	Certificate  string             `json:"certificate"`
+	Signing      bool               `json:"signing"`
+	Expire       int                `json:"expire"`
 }
 
 type authoritiesData struct {
@@ -72,6 +74,8 @@ func authorityPut(c *gin.Context) {
 	fire.Key = data.Key
 	fire.Roles = data.Roles
 	fire.Certificate = data.Certificate
+	fire.Signing = data.Signing
+	fire.Expire = data.Expire
 
 	fields := set.NewSet(
 		"name",
@@ -82,6 +86,9 @@ func authorityPut(c *gin.Context) {
 		"key",
 		"roles",
 		"certificate",
+		"signing",
+		"expire",
+		"private_key",
 	)
 
 	errData, err := fire.Validate(db)
@@ -134,6 +141,8 @@ func authorityPost(c *gin.Context) {
 		Key:          data.Key,
 		Roles:        data.Roles,
 		Certificate:  data.Certificate,
+		Signing:      data.Signing,
+		Expire:       data.Expire,
 	}
 
 	errData, err := fire.Validate(db)
@@ -23,6 +23,11 @@ type certificateData struct {
 	Key          string             `json:"key"`
 	Certificate  string             `json:"certificate"`
 	AcmeDomains  []string           `json:"acme_domains"`
+	AcmeType     string             `json:"acme_type"`
+	AcmeUrl      string             `json:"acme_url"`
+	AcmeEabId    string             `json:"acme_eab_id"`
+	AcmeEabKey   string             `json:"acme_eab_key"`
+	AcmeChain    string             `json:"acme_chain"`
 }
 
 func certificatePut(c *gin.Context) {
@@ -59,6 +64,11 @@ func certificatePut(c *gin.Context) {
 	cert.Organization = data.Organization
 	cert.Type = data.Type
 	cert.AcmeDomains = data.AcmeDomains
+	cert.AcmeType = data.AcmeType
+	cert.AcmeUrl = data.AcmeUrl
+	cert.AcmeEabId = data.AcmeEabId
+	cert.AcmeEabKey = data.AcmeEabKey
+	cert.AcmeChain = data.AcmeChain
 
 	fields := set.NewSet(
 		"name",
@@ -66,6 +76,11 @@ func certificatePut(c *gin.Context) {
 		"organization",
 		"type",
 		"acme_domains",
+		"acme_type",
+		"acme_url",
+		"acme_eab_id",
+		"acme_eab_key",
+		"acme_chain",
 		"info",
 	)
 
@@ -137,6 +152,11 @@ func certificatePost(c *gin.Context) {
 		Organization: data.Organization,
 		Type:         data.Type,
 		AcmeDomains:  data.AcmeDomains,
+		AcmeType:     data.AcmeType,
+		AcmeUrl:      data.AcmeUrl,
+		AcmeEabId:    data.AcmeEabId,
+		AcmeEabKey:   data.AcmeEabKey,
+		AcmeChain:    data.AcmeChain,
 	}
 
 	if cert.Type != certificate.LetsEncrypt {
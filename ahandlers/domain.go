@@ -17,13 +17,24 @@ import (
 )
 
 type domainData struct {
-	Id           primitive.ObjectID `json:"id"`
-	Name         string             `json:"name"`
-	Comment      string             `json:"comment"`
-	Organization primitive.ObjectID `json:"organization"`
-	Type         string             `json:"type"`
-	AwsId        string             `json:"aws_id"`
-	AwsSecret    string             `json:"aws_secret"`
+	Id               primitive.ObjectID     `json:"id"`
+	Name             string                 `json:"name"`
+	Comment          string                 `json:"comment"`
+	Organization     primitive.ObjectID     `json:"organization"`
+	Type             string                 `json:"type"`
+	AwsId            string                 `json:"aws_id"`
+	AwsSecret        string                 `json:"aws_secret"`
+	CloudflareToken  string                 `json:"cloudflare_token"`
+	GoogleProject    string                 `json:"google_project"`
+	GoogleKey        string                 `json:"google_key"`
+	PowerDnsUrl      string                 `json:"powerdns_url"`
+	PowerDnsKey      string                 `json:"powerdns_key"`
+	PowerDnsServer   string                 `json:"powerdns_server"`
+	Rfc2136Server    string                 `json:"rfc2136_server"`
+	Rfc2136KeyName   string                 `json:"rfc2136_key_name"`
+	Rfc2136Secret    string                 `json:"rfc2136_secret"`
+	Rfc2136Algorithm string                 `json:"rfc2136_algorithm"`
+	Records          []*domain.StaticRecord `json:"records"`
 }
 
 type domainsData struct {
@@ -63,6 +74,17 @@ func domainPut(c *gin.Context) {
 	domn.Type = data.Type
 	domn.AwsId = data.AwsId
 	domn.AwsSecret = data.AwsSecret
+	domn.CloudflareToken = data.CloudflareToken
+	domn.GoogleProject = data.GoogleProject
+	domn.GoogleKey = data.GoogleKey
+	domn.PowerDnsUrl = data.PowerDnsUrl
+	domn.PowerDnsKey = data.PowerDnsKey
+	domn.PowerDnsServer = data.PowerDnsServer
+	domn.Rfc2136Server = data.Rfc2136Server
+	domn.Rfc2136KeyName = data.Rfc2136KeyName
+	domn.Rfc2136Secret = data.Rfc2136Secret
+	domn.Rfc2136Algorithm = data.Rfc2136Algorithm
+	domn.Records = data.Records
 
 	fields := set.NewSet(
 		"name",
@@ -71,6 +93,18 @@ func domainPut(c *gin.Context) {
 		"type",
 		"aws_id",
 		"aws_secret",
+		"cloudflare_token",
+		"google_project",
+		"google_key",
+		"powerdns_url",
+		"powerdns_key",
+		"powerdns_server",
+		"rfc2136_server",
+		"rfc2136_key_name",
+		"rfc2136_secret",
+		"rfc2136_algorithm",
+		"records",
+		"records_checked",
 	)
 
 	errData, err := domn.Validate(db)
@@ -112,12 +146,23 @@ func domainPost(c *gin.Context) {
 	}
 
 	domn := &domain.Domain{
-		Name:         data.Name,
-		Comment:      data.Comment,
-		Organization: data.Organization,
-		Type:         data.Type,
-		AwsId:        data.AwsId,
-		AwsSecret:    data.AwsSecret,
+		Name:             data.Name,
+		Comment:          data.Comment,
+		Organization:     data.Organization,
+		Type:             data.Type,
+		AwsId:            data.AwsId,
+		AwsSecret:        data.AwsSecret,
+		CloudflareToken:  data.CloudflareToken,
+		GoogleProject:    data.GoogleProject,
+		GoogleKey:        data.GoogleKey,
+		PowerDnsUrl:      data.PowerDnsUrl,
+		PowerDnsKey:      data.PowerDnsKey,
+		PowerDnsServer:   data.PowerDnsServer,
+		Rfc2136Server:    data.Rfc2136Server,
+		Rfc2136KeyName:   data.Rfc2136KeyName,
+		Rfc2136Secret:    data.Rfc2136Secret,
+		Rfc2136Algorithm: data.Rfc2136Algorithm,
+		Records:          data.Records,
 	}
 
 	errData, err := domn.Validate(db)
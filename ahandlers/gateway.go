@@ -0,0 +1,304 @@
+package ahandlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/gateway"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type gatewayData struct {
+	Id           primitive.ObjectID   `json:"id"`
+	Name         string               `json:"name"`
+	Comment      string               `json:"comment"`
+	Organization primitive.ObjectID   `json:"organization"`
+	Zone         primitive.ObjectID   `json:"zone"`
+	Vpc          primitive.ObjectID   `json:"vpc"`
+	Subnet       primitive.ObjectID   `json:"subnet"`
+	Subnets      []primitive.ObjectID `json:"subnets"`
+	Block        primitive.ObjectID   `json:"block"`
+	AddressCount int                  `json:"address_count"`
+}
+
+type gatewaysData struct {
+	Gateways []*gateway.Gateway `json:"gateways"`
+	Count    int64              `json:"count"`
+}
+
+func gatewayPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &gatewayData{}
+
+	gatewayId, ok := utils.ParseObjectId(c.Param("gateway_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	gw, err := gateway.Get(db, gatewayId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	curVpc, curSubnet := gw.PreCommit()
+
+	gw.Name = data.Name
+	gw.Comment = data.Comment
+	gw.Organization = data.Organization
+	gw.Zone = data.Zone
+	gw.Vpc = data.Vpc
+	gw.Subnet = data.Subnet
+	gw.Subnets = data.Subnets
+	gw.Block = data.Block
+	gw.AddressCount = data.AddressCount
+
+	fields := set.NewSet(
+		"name",
+		"comment",
+		"organization",
+		"datacenter",
+		"zone",
+		"vpc",
+		"subnet",
+		"subnets",
+		"block",
+		"address_count",
+		"private_ip",
+	)
+
+	errData, err := gw.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = gw.PostCommit(db, curVpc, curSubnet)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = gw.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "gateway.change")
+
+	gw.Json()
+
+	c.JSON(200, gw)
+}
+
+func gatewayPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &gatewayData{
+		Name:         "New Gateway",
+		AddressCount: 1,
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	gw := &gateway.Gateway{
+		Name:         data.Name,
+		Comment:      data.Comment,
+		Organization: data.Organization,
+		Zone:         data.Zone,
+		Vpc:          data.Vpc,
+		Subnet:       data.Subnet,
+		Subnets:      data.Subnets,
+		Block:        data.Block,
+		AddressCount: data.AddressCount,
+	}
+
+	errData, err := gw.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = gw.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "gateway.change")
+
+	gw.Json()
+
+	c.JSON(200, gw)
+}
+
+func gatewayDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	gatewayId, ok := utils.ParseObjectId(c.Param("gateway_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := gateway.Remove(db, gatewayId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "gateway.change")
+
+	c.JSON(200, nil)
+}
+
+func gatewaysDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := []primitive.ObjectID{}
+
+	err := c.Bind(&data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = gateway.RemoveMulti(db, data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "gateway.change")
+
+	c.JSON(200, nil)
+}
+
+func gatewayGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	gatewayId, ok := utils.ParseObjectId(c.Param("gateway_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	gw, err := gateway.Get(db, gatewayId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	gw.Json()
+
+	c.JSON(200, gw)
+}
+
+func gatewaysGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{}
+
+	gatewayId, ok := utils.ParseObjectId(c.Query("id"))
+	if ok {
+		query["_id"] = gatewayId
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" {
+		query["name"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", name),
+			"$options": "i",
+		}
+	}
+
+	organization, ok := utils.ParseObjectId(c.Query("organization"))
+	if ok {
+		query["organization"] = organization
+	}
+
+	vc, ok := utils.ParseObjectId(c.Query("vpc"))
+	if ok {
+		query["vpc"] = vc
+	}
+
+	zne, ok := utils.ParseObjectId(c.Query("zone"))
+	if ok {
+		query["zone"] = zne
+	}
+
+	gws, count, err := gateway.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	for _, gw := range gws {
+		gw.Json()
+	}
+
+	data := &gatewaysData{
+		Gateways: gws,
+		Count:    count,
+	}
+
+	c.JSON(200, data)
+}
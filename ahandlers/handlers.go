@@ -115,6 +115,20 @@ func Register(engine *gin.Engine) {
 	csrfGroup.DELETE("/firewall", firewallsDelete)
 	csrfGroup.DELETE("/firewall/:firewall_id", firewallDelete)
 
+	csrfGroup.GET("/floating_ip", floatingIpsGet)
+	csrfGroup.GET("/floating_ip/:floating_ip_id", floatingIpGet)
+	csrfGroup.PUT("/floating_ip/:floating_ip_id", floatingIpPut)
+	csrfGroup.POST("/floating_ip", floatingIpPost)
+	csrfGroup.DELETE("/floating_ip", floatingIpsDelete)
+	csrfGroup.DELETE("/floating_ip/:floating_ip_id", floatingIpDelete)
+
+	csrfGroup.GET("/gateway", gatewaysGet)
+	csrfGroup.GET("/gateway/:gateway_id", gatewayGet)
+	csrfGroup.PUT("/gateway/:gateway_id", gatewayPut)
+	csrfGroup.POST("/gateway", gatewayPost)
+	csrfGroup.DELETE("/gateway", gatewaysDelete)
+	csrfGroup.DELETE("/gateway/:gateway_id", gatewayDelete)
+
 	csrfGroup.GET("/image", imagesGet)
 	csrfGroup.GET("/image/:image_id", imageGet)
 	csrfGroup.PUT("/image/:image_id", imagePut)
@@ -125,6 +139,8 @@ func Register(engine *gin.Engine) {
 	csrfGroup.PUT("/instance", instancesPut)
 	csrfGroup.GET("/instance/:instance_id", instanceGet)
 	csrfGroup.GET("/instance/:instance_id/vnc", instanceVncGet)
+	csrfGroup.GET("/instance/:instance_id/serial", instanceSerialGet)
+	csrfGroup.GET("/instance/:instance_id/console-log", instanceConsoleLogGet)
 	csrfGroup.PUT("/instance/:instance_id", instancePut)
 	csrfGroup.POST("/instance", instancePost)
 	csrfGroup.DELETE("/instance", instancesDelete)
@@ -147,6 +163,8 @@ func Register(engine *gin.Engine) {
 	csrfGroup.POST("/organization", organizationPost)
 	csrfGroup.DELETE("/organization/:org_id", organizationDelete)
 
+	csrfGroup.GET("/pki/root", pkiRootGet)
+
 	csrfGroup.GET("/policy", policiesGet)
 	csrfGroup.GET("/policy/:policy_id", policyGet)
 	csrfGroup.PUT("/policy/:policy_id", policyPut)
@@ -186,6 +204,10 @@ func Register(engine *gin.Engine) {
 	csrfGroup.DELETE("/vpc", vpcsDelete)
 	csrfGroup.DELETE("/vpc/:vpc_id", vpcDelete)
 
+	csrfGroup.GET("/vpn_client", vpnClientsGet)
+	csrfGroup.GET("/vpn_client/:client_id", vpnClientGet)
+	csrfGroup.DELETE("/vpn_client/:client_id", vpnClientDelete)
+
 	csrfGroup.GET("/zone", zonesGet)
 	csrfGroup.GET("/zone/:zone_id", zoneGet)
 	csrfGroup.PUT("/zone/:zone_id", zonePut)
@@ -50,6 +50,13 @@ type nodeData struct {
 	NetworkRoles         []string                `json:"network_roles"`
 	OracleUser           string                  `json:"oracle_user"`
 	OracleHostRoute      bool                    `json:"oracle_host_route"`
+	BgpAsn               uint32                  `json:"bgp_asn"`
+	BgpRouterId          string                  `json:"bgp_router_id"`
+	BgpNextHop           string                  `json:"bgp_next_hop"`
+	BgpNextHop6          string                  `json:"bgp_next_hop6"`
+	BgpLocalPref         uint32                  `json:"bgp_local_pref"`
+	BgpCommunities       []string                `json:"bgp_communities"`
+	BgpPeers             []*node.BgpPeer         `json:"bgp_peers"`
 }
 
 type nodesData struct {
@@ -112,6 +119,13 @@ func nodePut(c *gin.Context) {
 	nde.NetworkRoles = data.NetworkRoles
 	nde.OracleUser = data.OracleUser
 	nde.OracleHostRoute = data.OracleHostRoute
+	nde.BgpAsn = data.BgpAsn
+	nde.BgpRouterId = data.BgpRouterId
+	nde.BgpNextHop = data.BgpNextHop
+	nde.BgpNextHop6 = data.BgpNextHop6
+	nde.BgpLocalPref = data.BgpLocalPref
+	nde.BgpCommunities = data.BgpCommunities
+	nde.SetBgpPeers(data.BgpPeers)
 
 	fields := set.NewSet(
 		"name",
@@ -144,6 +158,13 @@ func nodePut(c *gin.Context) {
 		"network_roles",
 		"oracle_user",
 		"oracle_host_route",
+		"bgp_asn",
+		"bgp_router_id",
+		"bgp_next_hop",
+		"bgp_next_hop6",
+		"bgp_local_pref",
+		"bgp_communities",
+		"bgp_peers",
 	)
 
 	if !data.Zone.IsZero() && data.Zone != nde.Zone {
@@ -0,0 +1,25 @@
+package ahandlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/pki"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func pkiRootGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	certPem, err := pki.Root(db)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			utils.AbortWithStatus(c, 404)
+			return
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=\"ca.pem\"")
+	c.Data(200, "application/x-pem-file", []byte(certPem))
+}
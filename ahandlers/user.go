@@ -15,6 +15,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/user"
 	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vpnclient"
 )
 
 type userData struct {
@@ -101,6 +102,16 @@ func userPut(c *gin.Context) {
 		}
 	}
 
+	curRoles := set.NewSet()
+	for _, role := range usr.Roles {
+		curRoles.Add(role)
+	}
+	newRoles := set.NewSet()
+	for _, role := range data.Roles {
+		newRoles.Add(role)
+	}
+	rolesChanged := !curRoles.IsEqual(newRoles)
+
 	usr.Type = data.Type
 	usr.Username = data.Username
 	usr.Comment = data.Comment
@@ -173,6 +184,16 @@ func userPut(c *gin.Context) {
 		return
 	}
 
+	if usr.Disabled || rolesChanged {
+		err = vpnclient.RemoveUsers(db, []primitive.ObjectID{usr.Id})
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		event.PublishDispatch(db, "vpn_client.change")
+	}
+
 	event.PublishDispatch(db, "user.change")
 
 	if !showSecret {
@@ -349,7 +370,14 @@ func usersDelete(c *gin.Context) {
 		return
 	}
 
+	err = vpnclient.RemoveUsers(db, data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
 	event.PublishDispatch(db, "user.change")
+	event.PublishDispatch(db, "vpn_client.change")
 
 	c.JSON(200, nil)
 }
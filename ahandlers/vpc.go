@@ -16,18 +16,26 @@ import (
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/pritunl/pritunl-cloud/vpnclient"
 )
 
 type vpcData struct {
-	Id           primitive.ObjectID `json:"id"`
-	Name         string             `json:"name"`
-	Comment      string             `json:"comment"`
-	Network      string             `json:"network"`
-	Subnets      []*vpc.Subnet      `json:"subnets"`
-	Organization primitive.ObjectID `json:"organization"`
-	Datacenter   primitive.ObjectID `json:"datacenter"`
-	Routes       []*vpc.Route       `json:"routes"`
-	LinkUris     []string           `json:"link_uris"`
+	Id             primitive.ObjectID   `json:"id"`
+	Name           string               `json:"name"`
+	Comment        string               `json:"comment"`
+	Network        string               `json:"network"`
+	Subnets        []*vpc.Subnet        `json:"subnets"`
+	Organization   primitive.ObjectID   `json:"organization"`
+	Datacenter     primitive.ObjectID   `json:"datacenter"`
+	Routes         []*vpc.Route         `json:"routes"`
+	LinkUris       []string             `json:"link_uris"`
+	VpnConnections []*vpc.VpnConnection `json:"vpn_connections"`
+	WgPort         int                  `json:"wg_port"`
+	WgPeers        []*vpc.WgPeer        `json:"wg_peers"`
+	ClientPool     string               `json:"client_pool"`
+	ClientRoles    []string             `json:"client_roles"`
+	DnsServers     []string             `json:"dns_servers"`
+	BgpPrefixes    []string             `json:"bgp_prefixes"`
 }
 
 type vpcsData struct {
@@ -68,6 +76,13 @@ func vpcPut(c *gin.Context) {
 	vc.Routes = data.Routes
 	vc.Subnets = data.Subnets
 	vc.LinkUris = data.LinkUris
+	vc.SetVpnConnections(data.VpnConnections)
+	vc.WgPort = data.WgPort
+	vc.SetWgPeers(data.WgPeers)
+	vc.ClientPool = data.ClientPool
+	vc.ClientRoles = data.ClientRoles
+	vc.DnsServers = data.DnsServers
+	vc.BgpPrefixes = data.BgpPrefixes
 
 	fields := set.NewSet(
 		"name",
@@ -75,6 +90,15 @@ func vpcPut(c *gin.Context) {
 		"routes",
 		"subnets",
 		"link_uris",
+		"vpn_connections",
+		"wg_private_key",
+		"wg_public_key",
+		"wg_port",
+		"wg_peers",
+		"client_pool",
+		"client_roles",
+		"dns_servers",
+		"bgp_prefixes",
 	)
 
 	errData, err := vc.Validate(db)
@@ -105,6 +129,16 @@ func vpcPut(c *gin.Context) {
 		return
 	}
 
+	removed, err := vpnclient.RemoveDenied(db, vc)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if removed {
+		event.PublishDispatch(db, "vpn_client.change")
+	}
+
 	event.PublishDispatch(db, "vpc.change")
 
 	vc.Json()
@@ -140,8 +174,16 @@ func vpcPost(c *gin.Context) {
 		Datacenter:   data.Datacenter,
 		Routes:       data.Routes,
 		LinkUris:     data.LinkUris,
+		WgPort:       data.WgPort,
+		ClientPool:   data.ClientPool,
+		ClientRoles:  data.ClientRoles,
+		DnsServers:   data.DnsServers,
+		BgpPrefixes:  data.BgpPrefixes,
 	}
 
+	vc.SetVpnConnections(data.VpnConnections)
+	vc.SetWgPeers(data.WgPeers)
+
 	vc.InitVpc()
 
 	errData, err := vc.Validate(db)
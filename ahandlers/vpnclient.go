@@ -0,0 +1,122 @@
+package ahandlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vpnclient"
+)
+
+type vpnClientsData struct {
+	VpnClients []*vpnclient.VpnClient `json:"vpn_clients"`
+	Count      int64                  `json:"count"`
+}
+
+func vpnClientGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	clientId, ok := utils.ParseObjectId(c.Param("client_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	clnt, err := vpnclient.Get(db, clientId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, clnt)
+}
+
+func vpnClientsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{}
+
+	clientId, ok := utils.ParseObjectId(c.Query("id"))
+	if ok {
+		query["_id"] = clientId
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" {
+		query["name"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", name),
+			"$options": "i",
+		}
+	}
+
+	username := strings.TrimSpace(c.Query("username"))
+	if username != "" {
+		query["username"] = username
+	}
+
+	status := strings.TrimSpace(c.Query("status"))
+	if status != "" {
+		query["status"] = status
+	}
+
+	organization, ok := utils.ParseObjectId(c.Query("organization"))
+	if ok {
+		query["organization"] = organization
+	}
+
+	vpcId, ok := utils.ParseObjectId(c.Query("vpc"))
+	if ok {
+		query["vpc"] = vpcId
+	}
+
+	userId, ok := utils.ParseObjectId(c.Query("user"))
+	if ok {
+		query["user"] = userId
+	}
+
+	clnts, count, err := vpnclient.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &vpnClientsData{
+		VpnClients: clnts,
+		Count:      count,
+	}
+
+	c.JSON(200, data)
+}
+
+func vpnClientDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	clientId, ok := utils.ParseObjectId(c.Param("client_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := vpnclient.Remove(db, clientId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "vpn_client.change")
+
+	c.JSON(200, nil)
+}
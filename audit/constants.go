@@ -10,6 +10,7 @@ const (
 	AdminDeviceApprove         = "admin_device_approve"
 	AdminDeviceRegisterRequest = "admin_device_register_request"
 	AdminDeviceRegister        = "admin_device_register"
+	AdminSerialConsole         = "admin_serial_console"
 
 	ProxyLogin                 = "proxy_login"
 	ProxyLoginFailed           = "proxy_login_failed"
@@ -32,6 +33,8 @@ const (
 	UserDeviceRegisterRequest = "user_device_register_request"
 	UserDeviceRegister        = "user_device_register"
 	UserAccountDisable        = "user_account_disable"
+	UserSshCertificate        = "user_ssh_certificate"
+	UserSerialConsole         = "user_serial_console"
 
 	DeviceRegister       = "device_register"
 	DeviceRegisterFailed = "device_register_failed"
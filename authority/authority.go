@@ -1,11 +1,15 @@
 package authority
 
 import (
+	"strings"
+
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/pki"
+	"golang.org/x/crypto/ssh"
 )
 
 type Authority struct {
@@ -18,6 +22,9 @@ type Authority struct {
 	Key          string             `bson:"key" json:"key"`
 	Roles        []string           `bson:"roles" json:"roles"`
 	Certificate  string             `bson:"certificate" json:"certificate"`
+	Signing      bool               `bson:"signing" json:"signing"`
+	Expire       int                `bson:"expire" json:"expire"`
+	PrivateKey   string             `bson:"private_key" json:"-"`
 }
 
 func (f *Authority) Validate(db *database.Database) (
@@ -35,12 +42,54 @@ func (f *Authority) Validate(db *database.Database) (
 	case SshKey:
 		f.Roles = []string{}
 		f.Certificate = ""
+		f.Signing = false
 		break
 	case SshCertificate:
 		f.Key = ""
 		break
 	}
 
+	if !f.Signing {
+		f.Expire = 0
+		f.PrivateKey = ""
+		return
+	}
+
+	if !pki.Enabled() {
+		errData = &errortypes.ErrorData{
+			Error:   "signing_unavailable",
+			Message: "Certificate signing requires a configured pki key",
+		}
+		return
+	}
+
+	if f.Expire == 0 {
+		f.Expire = DefaultExpire
+	}
+	if f.Expire < 1 || f.Expire > MaxExpire {
+		errData = &errortypes.ErrorData{
+			Error:   "expire_invalid",
+			Message: "Certificate expire must be between 1 and 168 hours",
+		}
+		return
+	}
+
+	if f.PrivateKey == "" {
+		err = f.generateKey()
+		if err != nil {
+			return
+		}
+	} else {
+		signer, e := f.getSigner()
+		if e != nil {
+			err = e
+			return
+		}
+
+		f.Certificate = strings.TrimSpace(
+			string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+	}
+
 	return
 }
 
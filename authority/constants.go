@@ -3,4 +3,7 @@ package authority
 const (
 	SshKey         = "ssh_key"
 	SshCertificate = "ssh_certificate"
+
+	DefaultExpire = 8
+	MaxExpire     = 168
 )
@@ -0,0 +1,206 @@
+package authority
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/pki"
+	"golang.org/x/crypto/ssh"
+)
+
+type Certificate struct {
+	Certificate string    `json:"certificate"`
+	Serial      uint64    `json:"serial"`
+	Fingerprint string    `json:"fingerprint"`
+	Principals  []string  `json:"principals"`
+	Expires     time.Time `json:"expires"`
+}
+
+func (f *Authority) generateKey() (err error) {
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "authority: Failed to generate private key"),
+		}
+		return
+	}
+
+	keyByt, err := x509.MarshalPKCS8PrivateKey(privKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to marshal private key"),
+		}
+		return
+	}
+
+	sshPubKey, err := ssh.NewPublicKey(pubKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to parse public key"),
+		}
+		return
+	}
+
+	keyEnc, err := pki.Encrypt(pem.EncodeToMemory(&pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: keyByt,
+	}))
+	if err != nil {
+		return
+	}
+
+	f.PrivateKey = keyEnc
+	f.Certificate = strings.TrimSpace(
+		string(ssh.MarshalAuthorizedKey(sshPubKey)))
+
+	return
+}
+
+func (f *Authority) getSigner() (signer ssh.Signer, err error) {
+	keyPem, err := pki.Decrypt(f.PrivateKey)
+	if err != nil {
+		return
+	}
+
+	block, _ := pem.Decode(keyPem)
+	if block == nil {
+		err = &errortypes.ParseError{
+			errors.New("authority: Failed to decode private key"),
+		}
+		return
+	}
+
+	privKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to parse private key"),
+		}
+		return
+	}
+
+	signer, err = ssh.NewSignerFromKey(privKey)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "authority: Failed to load private key"),
+		}
+		return
+	}
+
+	return
+}
+
+func (f *Authority) Sign(keyId string, roles []string, publicKey string) (
+	cert *Certificate, errData *errortypes.ErrorData, err error) {
+
+	if f.Type != SshCertificate || !f.Signing || f.PrivateKey == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "authority_signing_disabled",
+			Message: "Authority does not sign certificates",
+		}
+		return
+	}
+
+	authrRoles := set.NewSet()
+	for _, role := range f.Roles {
+		authrRoles.Add(role)
+	}
+
+	principals := []string{}
+	for _, role := range roles {
+		if authrRoles.Contains(role) {
+			principals = append(principals, role)
+		}
+	}
+
+	if len(principals) == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "authority_roles_mismatch",
+			Message: "User does not have any authority roles",
+		}
+		return
+	}
+
+	pubKey, _, _, _, e := ssh.ParseAuthorizedKey(
+		[]byte(strings.TrimSpace(publicKey)))
+	if e != nil {
+		errData = &errortypes.ErrorData{
+			Error:   "public_key_invalid",
+			Message: "Invalid SSH public key",
+		}
+		return
+	}
+
+	if _, ok := pubKey.(*ssh.Certificate); ok {
+		errData = &errortypes.ErrorData{
+			Error:   "public_key_invalid",
+			Message: "SSH public key cannot be a certificate",
+		}
+		return
+	}
+
+	signer, err := f.getSigner()
+	if err != nil {
+		return
+	}
+
+	serialByt := make([]byte, 8)
+	_, err = rand.Read(serialByt)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "authority: Failed to generate serial"),
+		}
+		return
+	}
+
+	expire := f.Expire
+	if expire == 0 {
+		expire = DefaultExpire
+	}
+	expires := time.Now().Add(time.Duration(expire) * time.Hour)
+
+	sshCert := &ssh.Certificate{
+		Key:             pubKey,
+		Serial:          binary.BigEndian.Uint64(serialByt),
+		CertType:        ssh.UserCert,
+		KeyId:           keyId,
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(time.Now().Add(-5 * time.Minute).Unix()),
+		ValidBefore:     uint64(expires.Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-X11-forwarding":   "",
+				"permit-agent-forwarding": "",
+				"permit-port-forwarding":  "",
+				"permit-pty":              "",
+				"permit-user-rc":          "",
+			},
+		},
+	}
+
+	err = sshCert.SignCert(rand.Reader, signer)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "authority: Failed to sign certificate"),
+		}
+		return
+	}
+
+	cert = &Certificate{
+		Certificate: strings.TrimSpace(
+			string(ssh.MarshalAuthorizedKey(sshCert))),
+		Serial:      sshCert.Serial,
+		Fingerprint: ssh.FingerprintSHA256(pubKey),
+		Principals:  principals,
+		Expires:     expires,
+	}
+
+	return
+}
@@ -0,0 +1,211 @@
+package authority
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pritunl/pritunl-cloud/config"
+	"github.com/pritunl/pritunl-cloud/pki"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestAuthority(t *testing.T, roles ...string) (authr *Authority) {
+	key, err := pki.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Config.PkiKey = key
+
+	authr = &Authority{
+		Type:    SshCertificate,
+		Roles:   roles,
+		Signing: true,
+	}
+
+	err = authr.generateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return
+}
+
+func newTestPublicKey(t *testing.T) string {
+	pubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sshPubKey, err := ssh.NewPublicKey(pubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(ssh.MarshalAuthorizedKey(sshPubKey))
+}
+
+func parseTestCertificate(t *testing.T, cert *Certificate) *ssh.Certificate {
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cert.Certificate))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sshCert, ok := pubKey.(*ssh.Certificate)
+	if !ok {
+		t.Fatal("signed key is not a certificate")
+	}
+
+	return sshCert
+}
+
+func TestSignPrincipals(t *testing.T) {
+	authr := newTestAuthority(t, "admin", "deploy", "web")
+	pubKey := newTestPublicKey(t)
+
+	cert, errData, err := authr.Sign("user",
+		[]string{"web", "db", "deploy"}, pubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errData != nil {
+		t.Fatalf("sign error: %s", errData.Message)
+	}
+
+	principals := strings.Join(cert.Principals, ",")
+	if principals != "web,deploy" {
+		t.Errorf("principals %s expected web,deploy", principals)
+	}
+
+	sshCert := parseTestCertificate(t, cert)
+	principals = strings.Join(sshCert.ValidPrincipals, ",")
+	if principals != "web,deploy" {
+		t.Errorf("certificate principals %s expected web,deploy",
+			principals)
+	}
+
+	for _, roles := range [][]string{
+		{"db", "cache"},
+		{"Admin"},
+		{},
+	} {
+		_, errData, err = authr.Sign("user", roles, pubKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errData == nil || errData.Error != "authority_roles_mismatch" {
+			t.Errorf("roles %v without authority roles signed", roles)
+		}
+	}
+}
+
+func TestSignCertificate(t *testing.T) {
+	authr := newTestAuthority(t, "admin", "web")
+	pubKey := newTestPublicKey(t)
+
+	if strings.Contains(authr.PrivateKey, "PRIVATE KEY") {
+		t.Errorf("authority private key not encrypted")
+	}
+
+	authrKey, _, _, _, err := ssh.ParseAuthorizedKey(
+		[]byte(authr.Certificate))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, errData, err := authr.Sign("user", []string{"web"}, pubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errData != nil {
+		t.Fatalf("sign error: %s", errData.Message)
+	}
+
+	sshCert := parseTestCertificate(t, cert)
+	if sshCert.CertType != ssh.UserCert || sshCert.KeyId != "user" {
+		t.Errorf("certificate type %d key id %s",
+			sshCert.CertType, sshCert.KeyId)
+	}
+	if sshCert.Serial != cert.Serial {
+		t.Errorf("certificate serial %d expected %d",
+			sshCert.Serial, cert.Serial)
+	}
+
+	if string(sshCert.SignatureKey.Marshal()) !=
+		string(authrKey.Marshal()) {
+
+		t.Errorf("certificate not signed by authority")
+	}
+
+	expires := time.Until(time.Unix(int64(sshCert.ValidBefore), 0))
+	if expires > DefaultExpire*time.Hour ||
+		expires < DefaultExpire*time.Hour-time.Minute {
+
+		t.Errorf("certificate expires in %s expected %dh",
+			expires, DefaultExpire)
+	}
+
+	checker := &ssh.CertChecker{}
+	err = checker.CheckCert("web", sshCert)
+	if err != nil {
+		t.Errorf("certificate rejected for web: %s", err)
+	}
+	err = checker.CheckCert("admin", sshCert)
+	if err == nil {
+		t.Errorf("certificate valid for unassigned principal")
+	}
+}
+
+func TestSignRejected(t *testing.T) {
+	authr := newTestAuthority(t, "admin")
+	pubKey := newTestPublicKey(t)
+
+	cert, _, err := authr.Sign("user", []string{"admin"}, pubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{
+		"",
+		"ssh-ed25519 invalid",
+		cert.Certificate,
+	} {
+		_, errData, err := authr.Sign("user", []string{"admin"}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errData == nil || errData.Error != "public_key_invalid" {
+			t.Errorf("public key %q signed", key)
+		}
+	}
+
+	disabled := []*Authority{
+		{
+			Type:       SshCertificate,
+			Roles:      authr.Roles,
+			PrivateKey: authr.PrivateKey,
+		},
+		{
+			Type:       SshKey,
+			Roles:      authr.Roles,
+			Signing:    true,
+			PrivateKey: authr.PrivateKey,
+		},
+		{
+			Type:    SshCertificate,
+			Roles:   authr.Roles,
+			Signing: true,
+		},
+	}
+	for _, authr := range disabled {
+		_, errData, err := authr.Sign("user", []string{"admin"}, pubKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errData == nil || errData.Error != "authority_signing_disabled" {
+			t.Errorf("disabled %s authority signed certificate", authr.Type)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package balancer
+
+import (
+	"net"
+	"net/url"
+
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+func (b *Balancer) validateAccessLog() (errData *errortypes.ErrorData) {
+	if b.Type != Http {
+		b.AccessLog = ""
+		b.AccessLogServer = ""
+		return
+	}
+
+	switch b.AccessLog {
+	case "", AccessLogFile:
+		b.AccessLogServer = ""
+		break
+	case AccessLogSyslog:
+		if b.AccessLogServer == "" {
+			break
+		}
+
+		u, err := url.Parse(b.AccessLogServer)
+		if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") {
+			errData = &errortypes.ErrorData{
+				Error: "access_log_server_invalid",
+				Message: "Invalid access log syslog server, " +
+					"must be udp://host:port or tcp://host:port",
+			}
+			return
+		}
+
+		_, _, err = net.SplitHostPort(u.Host)
+		if err != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "access_log_server_invalid",
+				Message: "Access log syslog server missing port",
+			}
+			return
+		}
+
+		b.AccessLogServer = u.Scheme + "://" + u.Host
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "access_log_invalid",
+			Message: "Invalid balancer access log type",
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,76 @@
+package balancer
+
+import (
+	"strings"
+
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+func (b *Balancer) GetHashKey() string {
+	if b.HashKey == "" && b.Algorithm == Sticky {
+		return DefaultStickyCookie
+	}
+	return b.HashKey
+}
+
+func (b *Balancer) validateAlgorithm() (errData *errortypes.ErrorData) {
+	if b.Algorithm == "" {
+		b.Algorithm = Random
+	}
+
+	b.HashKey = strings.TrimSpace(b.HashKey)
+
+	switch b.Algorithm {
+	case Random, RoundRobin, Weighted, LeastConn:
+		b.HashSource = ""
+		b.HashKey = ""
+		break
+	case ConsistentHash:
+		if b.Type != Http {
+			errData = &errortypes.ErrorData{
+				Error:   "algorithm_invalid",
+				Message: "Consistent hash requires an HTTP balancer",
+			}
+			return
+		}
+
+		if b.HashSource != HashHeader && b.HashSource != HashCookie {
+			errData = &errortypes.ErrorData{
+				Error:   "hash_source_invalid",
+				Message: "Invalid balancer hash source",
+			}
+			return
+		}
+
+		if b.HashKey == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "hash_key_required",
+				Message: "Missing required balancer hash key",
+			}
+			return
+		}
+		break
+	case Sticky:
+		if b.Type != Http {
+			errData = &errortypes.ErrorData{
+				Error:   "algorithm_invalid",
+				Message: "Sticky sessions require an HTTP balancer",
+			}
+			return
+		}
+
+		b.HashSource = HashCookie
+		if b.HashKey == "" {
+			b.HashKey = DefaultStickyCookie
+		}
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "algorithm_invalid",
+			Message: "Invalid balancer algorithm",
+		}
+		return
+	}
+
+	return
+}
@@ -1,6 +1,7 @@
 package balancer
 
 import (
+	"strings"
 	"time"
 
 	"github.com/dropbox/godropbox/container/set"
@@ -10,6 +11,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/utils"
 )
 
 type Domain struct {
@@ -21,6 +23,43 @@ type Backend struct {
 	Protocol string `bson:"protocol" json:"protocol"`
 	Hostname string `bson:"hostname" json:"hostname"`
 	Port     int    `bson:"port" json:"port"`
+	Weight   int    `bson:"weight" json:"weight"`
+	Draining bool   `bson:"draining" json:"draining"`
+	Pool     string `bson:"pool" json:"pool"`
+}
+
+type RateLimit struct {
+	Source string `bson:"source" json:"source"`
+	Header string `bson:"header" json:"header"`
+	Rate   int    `bson:"rate" json:"rate"`
+	Burst  int    `bson:"burst" json:"burst"`
+}
+
+type Header struct {
+	Key   string `bson:"key" json:"key"`
+	Value string `bson:"value" json:"value"`
+}
+
+type Rule struct {
+	Match         string    `bson:"match" json:"match"`
+	Key           string    `bson:"key" json:"key"`
+	Value         string    `bson:"value" json:"value"`
+	Action        string    `bson:"action" json:"action"`
+	Pool          string    `bson:"pool" json:"pool"`
+	RewritePath   string    `bson:"rewrite_path" json:"rewrite_path"`
+	AddHeaders    []*Header `bson:"add_headers" json:"add_headers"`
+	RemoveHeaders []string  `bson:"remove_headers" json:"remove_headers"`
+	Status        int       `bson:"status" json:"status"`
+	ContentType   string    `bson:"content_type" json:"content_type"`
+	Body          string    `bson:"body" json:"body"`
+}
+
+type Listener struct {
+	Protocol      string `bson:"protocol" json:"protocol"`
+	Port          int    `bson:"port" json:"port"`
+	BackendPort   int    `bson:"backend_port" json:"backend_port"`
+	Mode          string `bson:"mode" json:"mode"`
+	ProxyProtocol string `bson:"proxy_protocol" json:"proxy_protocol"`
 }
 
 type State struct {
@@ -33,6 +72,11 @@ type State struct {
 	UnknownMid  []string  `bson:"unknown_mid" json:"unknown_mid"`
 	UnknownLow  []string  `bson:"unknown_low" json:"unknown_low"`
 	Offline     []string  `bson:"offline" json:"offline"`
+	Checks      []*Check  `bson:"checks" json:"checks"`
+	Metrics     []*Metric `bson:"metrics" json:"metrics"`
+	RateLimited int       `bson:"rate_limited" json:"rate_limited"`
+	Blocked     int       `bson:"blocked" json:"blocked"`
+	TooLarge    int       `bson:"too_large" json:"too_large"`
 }
 
 type Balancer struct {
@@ -44,12 +88,35 @@ type Balancer struct {
 	Organization    primitive.ObjectID   `bson:"organization,omitempty" json:"organization"`
 	Datacenter      primitive.ObjectID   `bson:"datacenter,omitempty" json:"datacenter"`
 	Certificates    []primitive.ObjectID `bson:"certificates" json:"certificates"`
+	AutoCert        bool                 `bson:"auto_cert" json:"auto_cert"`
 	ClientAuthority primitive.ObjectID   `bson:"client_authority" json:"client_authority"`
 	WebSockets      bool                 `bson:"websockets" json:"websockets"`
+	BackendMtls     bool                 `bson:"backend_mtls" json:"backend_mtls"`
 	Domains         []*Domain            `bson:"domains" json:"domains"`
 	Backends        []*Backend           `bson:"backends" json:"backends"`
+	Listeners       []*Listener          `bson:"listeners" json:"listeners"`
+	Rules           []*Rule              `bson:"rules" json:"rules"`
 	States          map[string]*State    `bson:"states" json:"states"`
+	CheckType       string               `bson:"check_type" json:"check_type"`
 	CheckPath       string               `bson:"check_path" json:"check_path"`
+	CheckInterval   int                  `bson:"check_interval" json:"check_interval"`
+	CheckTimeout    int                  `bson:"check_timeout" json:"check_timeout"`
+	CheckHealthy    int                  `bson:"check_healthy" json:"check_healthy"`
+	CheckUnhealthy  int                  `bson:"check_unhealthy" json:"check_unhealthy"`
+	CheckCodes      []int                `bson:"check_codes" json:"check_codes"`
+	CheckBody       string               `bson:"check_body" json:"check_body"`
+	CheckHost       string               `bson:"check_host" json:"check_host"`
+	Algorithm       string               `bson:"algorithm" json:"algorithm"`
+	HashSource      string               `bson:"hash_source" json:"hash_source"`
+	HashKey         string               `bson:"hash_key" json:"hash_key"`
+	AccessLog       string               `bson:"access_log" json:"access_log"`
+	AccessLogServer string               `bson:"access_log_server" json:"access_log_server"`
+	RateLimits      []*RateLimit         `bson:"rate_limits" json:"rate_limits"`
+	AllowCidrs      []string             `bson:"allow_cidrs" json:"allow_cidrs"`
+	DenyCidrs       []string             `bson:"deny_cidrs" json:"deny_cidrs"`
+	AllowCountries  []string             `bson:"allow_countries" json:"allow_countries"`
+	DenyCountries   []string             `bson:"deny_countries" json:"deny_countries"`
+	MaxBodySize     int64                `bson:"max_body_size" json:"max_body_size"`
 }
 
 func (b *Balancer) Validate(db *database.Database) (
@@ -67,6 +134,14 @@ func (b *Balancer) Validate(db *database.Database) (
 		b.Backends = []*Backend{}
 	}
 
+	if b.Listeners == nil {
+		b.Listeners = []*Listener{}
+	}
+
+	if b.Rules == nil {
+		b.Rules = []*Rule{}
+	}
+
 	if b.Certificates == nil {
 		b.Certificates = []primitive.ObjectID{}
 	}
@@ -75,8 +150,29 @@ func (b *Balancer) Validate(db *database.Database) (
 		b.States = map[string]*State{}
 	}
 
+	switch b.Type {
+	case Http:
+		b.Listeners = []*Listener{}
+		break
+	case L4:
+		b.BackendMtls = false
+		errData = b.validateListeners()
+		if errData != nil {
+			return
+		}
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "balancer_type_invalid",
+			Message: "Invalid balancer type",
+		}
+		return
+	}
+
 	for _, backend := range b.Backends {
-		if backend.Protocol != "http" && backend.Protocol != "https" {
+		if b.Type == L4 {
+			backend.Protocol = Tcp
+		} else if backend.Protocol != "http" && backend.Protocol != "https" {
 			errData = &errortypes.ErrorData{
 				Error:   "balancer_protocol_invalid",
 				Message: "Invalid balancer backend protocol",
@@ -99,6 +195,50 @@ func (b *Balancer) Validate(db *database.Database) (
 			}
 			return
 		}
+
+		if backend.Weight == 0 {
+			backend.Weight = DefaultWeight
+		}
+
+		if backend.Weight < 1 || backend.Weight > MaxWeight {
+			errData = &errortypes.ErrorData{
+				Error:   "balancer_weight_invalid",
+				Message: "Invalid balancer backend weight",
+			}
+			return
+		}
+
+		if b.Type == L4 {
+			backend.Pool = ""
+		} else {
+			backend.Pool = utils.FilterStr(
+				strings.TrimSpace(backend.Pool), 64)
+		}
+	}
+
+	errData = b.validateRules()
+	if errData != nil {
+		return
+	}
+
+	errData = b.validateAlgorithm()
+	if errData != nil {
+		return
+	}
+
+	errData = b.validateCheck()
+	if errData != nil {
+		return
+	}
+
+	errData = b.validateAccessLog()
+	if errData != nil {
+		return
+	}
+
+	errData = b.validateFilter()
+	if errData != nil {
+		return
 	}
 
 	if b.State {
@@ -118,6 +258,39 @@ func (b *Balancer) Validate(db *database.Database) (
 			return
 		}
 
+		if b.Type == L4 {
+			if b.Backends == nil || len(b.Backends) == 0 {
+				errData = &errortypes.ErrorData{
+					Error:   "backend_required",
+					Message: "Missing required backend",
+				}
+				return
+			}
+
+			if b.CheckType == CheckHttp && b.CheckPath == "" {
+				errData = &errortypes.ErrorData{
+					Error:   "check_path_required",
+					Message: "Missing required health check path",
+				}
+				return
+			}
+
+			if len(b.Listeners) == 0 {
+				errData = &errortypes.ErrorData{
+					Error:   "listener_required",
+					Message: "Missing required listener",
+				}
+				return
+			}
+
+			errData, err = b.validateListenerConflict(db)
+			if err != nil || errData != nil {
+				return
+			}
+
+			return
+		}
+
 		if b.Domains == nil || len(b.Domains) == 0 {
 			errData = &errortypes.ErrorData{
 				Error:   "domain_required",
@@ -126,7 +299,7 @@ func (b *Balancer) Validate(db *database.Database) (
 			return
 		}
 
-		if b.CheckPath == "" {
+		if b.CheckType == CheckHttp && b.CheckPath == "" {
 			errData = &errortypes.ErrorData{
 				Error:   "check_path_required",
 				Message: "Missing required health check path",
@@ -0,0 +1,179 @@
+package balancer
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type CheckResult struct {
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+	Success   bool      `bson:"success" json:"success"`
+	Status    int       `bson:"status" json:"status"`
+	Latency   int64     `bson:"latency" json:"latency"`
+	Message   string    `bson:"message" json:"message"`
+}
+
+type Check struct {
+	Key       string         `bson:"key" json:"key"`
+	Successes int            `bson:"successes" json:"successes"`
+	Failures  int            `bson:"failures" json:"failures"`
+	History   []*CheckResult `bson:"history" json:"history"`
+}
+
+func (c *Check) Add(result *CheckResult) {
+	if result.Success {
+		c.Successes += 1
+		c.Failures = 0
+	} else {
+		c.Failures += 1
+		c.Successes = 0
+	}
+
+	c.History = append(c.History, result)
+	if len(c.History) > CheckHistoryLength {
+		c.History = c.History[len(c.History)-CheckHistoryLength:]
+	}
+}
+
+func (c *Check) Copy() *Check {
+	return &Check{
+		Key:       c.Key,
+		Successes: c.Successes,
+		Failures:  c.Failures,
+		History:   append([]*CheckResult{}, c.History...),
+	}
+}
+
+func (b *Balancer) GetCheckInterval() time.Duration {
+	if b.CheckInterval == 0 {
+		return DefaultCheckInterval * time.Second
+	}
+	return time.Duration(b.CheckInterval) * time.Second
+}
+
+func (b *Balancer) GetCheckTimeout() time.Duration {
+	if b.CheckTimeout == 0 {
+		return DefaultCheckTimeout * time.Second
+	}
+	return time.Duration(b.CheckTimeout) * time.Second
+}
+
+func (b *Balancer) GetCheckHealthy() int {
+	if b.CheckHealthy == 0 {
+		return DefaultCheckHealthy
+	}
+	return b.CheckHealthy
+}
+
+func (b *Balancer) GetCheckUnhealthy() int {
+	if b.CheckUnhealthy == 0 {
+		return DefaultCheckUnhealthy
+	}
+	return b.CheckUnhealthy
+}
+
+func (b *Balancer) CheckCodeValid(code int) bool {
+	if len(b.CheckCodes) == 0 {
+		return code >= 200 && code < 300
+	}
+
+	for _, checkCode := range b.CheckCodes {
+		if code == checkCode {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Balancer) validateCheck() (errData *errortypes.ErrorData) {
+	if b.CheckType == "" {
+		if b.Type == L4 {
+			b.CheckType = CheckTcp
+		} else {
+			b.CheckType = CheckHttp
+		}
+	}
+
+	switch b.CheckType {
+	case CheckHttp:
+		break
+	case CheckTcp:
+		b.CheckPath = ""
+		b.CheckCodes = []int{}
+		b.CheckBody = ""
+		b.CheckHost = ""
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "check_type_invalid",
+			Message: "Invalid health check type",
+		}
+		return
+	}
+
+	if b.CheckInterval == 0 {
+		b.CheckInterval = DefaultCheckInterval
+	}
+	if b.CheckTimeout == 0 {
+		b.CheckTimeout = DefaultCheckTimeout
+	}
+	if b.CheckHealthy == 0 {
+		b.CheckHealthy = DefaultCheckHealthy
+	}
+	if b.CheckUnhealthy == 0 {
+		b.CheckUnhealthy = DefaultCheckUnhealthy
+	}
+
+	if b.CheckInterval < 1 || b.CheckInterval > 3600 {
+		errData = &errortypes.ErrorData{
+			Error:   "check_interval_invalid",
+			Message: "Health check interval must be 1-3600 seconds",
+		}
+		return
+	}
+
+	if b.CheckTimeout < 1 || b.CheckTimeout > b.CheckInterval {
+		errData = &errortypes.ErrorData{
+			Error: "check_timeout_invalid",
+			Message: "Health check timeout must be at least one " +
+				"second and not exceed the interval",
+		}
+		return
+	}
+
+	if b.CheckHealthy < 1 || b.CheckHealthy > 100 {
+		errData = &errortypes.ErrorData{
+			Error:   "check_healthy_invalid",
+			Message: "Health check healthy threshold must be 1-100",
+		}
+		return
+	}
+
+	if b.CheckUnhealthy < 1 || b.CheckUnhealthy > 100 {
+		errData = &errortypes.ErrorData{
+			Error:   "check_unhealthy_invalid",
+			Message: "Health check unhealthy threshold must be 1-100",
+		}
+		return
+	}
+
+	if b.CheckCodes == nil {
+		b.CheckCodes = []int{}
+	}
+
+	for _, code := range b.CheckCodes {
+		if code < 100 || code > 599 {
+			errData = &errortypes.ErrorData{
+				Error:   "check_code_invalid",
+				Message: "Invalid health check status code",
+			}
+			return
+		}
+	}
+
+	b.CheckHost = strings.TrimSpace(b.CheckHost)
+
+	return
+}
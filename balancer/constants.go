@@ -2,4 +2,53 @@ package balancer
 
 const (
 	Http = "http"
+	L4   = "l4"
+
+	Tcp = "tcp"
+	Udp = "udp"
+
+	Passthrough = "passthrough"
+	Terminate   = "terminate"
+
+	ProxyProtocolV1 = "v1"
+	ProxyProtocolV2 = "v2"
+
+	Random         = "random"
+	RoundRobin     = "round_robin"
+	Weighted       = "weighted"
+	LeastConn      = "least_conn"
+	ConsistentHash = "consistent_hash"
+	Sticky         = "sticky"
+
+	HashHeader = "header"
+	HashCookie = "cookie"
+
+	CheckHttp = "http"
+	CheckTcp  = "tcp"
+
+	DefaultCheckInterval  = 5
+	DefaultCheckTimeout   = 5
+	DefaultCheckHealthy   = 1
+	DefaultCheckUnhealthy = 1
+	CheckHistoryLength    = 10
+
+	MatchPathPrefix = "path_prefix"
+	MatchPathRegex  = "path_regex"
+	MatchHeader     = "header"
+	MatchMethod     = "method"
+
+	ActionForward       = "forward"
+	ActionRedirectHttps = "redirect_https"
+	ActionFixed         = "fixed"
+
+	AccessLogFile   = "file"
+	AccessLogSyslog = "syslog"
+
+	LimitIp     = "ip"
+	LimitHeader = "header"
+	LimitPath   = "path"
+
+	DefaultStickyCookie = "pritunl-cloud-backend"
+	DefaultWeight       = 1
+	MaxWeight           = 1000
 )
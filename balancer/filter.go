@@ -0,0 +1,200 @@
+package balancer
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/settings"
+)
+
+func validateCidrs(cidrs []string) (parsed []string, valid bool) {
+	parsed = []string{}
+	parsedSet := set.NewSet()
+
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return
+			}
+
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return
+		}
+
+		cidr = network.String()
+		if parsedSet.Contains(cidr) {
+			continue
+		}
+		parsedSet.Add(cidr)
+
+		parsed = append(parsed, cidr)
+	}
+
+	valid = true
+	return
+}
+
+func validateCountries(countries []string) (parsed []string, valid bool) {
+	parsed = []string{}
+	parsedSet := set.NewSet()
+
+	for _, country := range countries {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if country == "" {
+			continue
+		}
+
+		if len(country) != 2 {
+			return
+		}
+
+		if parsedSet.Contains(country) {
+			continue
+		}
+		parsedSet.Add(country)
+
+		parsed = append(parsed, country)
+	}
+
+	valid = true
+	return
+}
+
+func (b *Balancer) validateFilter() (errData *errortypes.ErrorData) {
+	if b.Type != Http {
+		b.RateLimits = []*RateLimit{}
+		b.AllowCidrs = []string{}
+		b.DenyCidrs = []string{}
+		b.AllowCountries = []string{}
+		b.DenyCountries = []string{}
+		b.MaxBodySize = 0
+		return
+	}
+
+	if b.RateLimits == nil {
+		b.RateLimits = []*RateLimit{}
+	}
+
+	for _, limit := range b.RateLimits {
+		limit.Header = strings.TrimSpace(limit.Header)
+
+		switch limit.Source {
+		case LimitIp, LimitPath:
+			limit.Header = ""
+			break
+		case LimitHeader:
+			if limit.Header == "" {
+				errData = &errortypes.ErrorData{
+					Error:   "rate_limit_header_required",
+					Message: "Missing required rate limit header name",
+				}
+				return
+			}
+			limit.Header = http.CanonicalHeaderKey(limit.Header)
+			break
+		default:
+			errData = &errortypes.ErrorData{
+				Error:   "rate_limit_source_invalid",
+				Message: "Invalid rate limit source",
+			}
+			return
+		}
+
+		if limit.Rate < 1 {
+			errData = &errortypes.ErrorData{
+				Error:   "rate_limit_rate_invalid",
+				Message: "Rate limit must allow at least one request",
+			}
+			return
+		}
+
+		if limit.Burst == 0 {
+			limit.Burst = limit.Rate
+		}
+
+		if limit.Burst < 1 {
+			errData = &errortypes.ErrorData{
+				Error:   "rate_limit_burst_invalid",
+				Message: "Invalid rate limit burst",
+			}
+			return
+		}
+	}
+
+	allowCidrs, valid := validateCidrs(b.AllowCidrs)
+	if !valid {
+		errData = &errortypes.ErrorData{
+			Error:   "allow_cidrs_invalid",
+			Message: "Invalid allowed network",
+		}
+		return
+	}
+	b.AllowCidrs = allowCidrs
+
+	denyCidrs, valid := validateCidrs(b.DenyCidrs)
+	if !valid {
+		errData = &errortypes.ErrorData{
+			Error:   "deny_cidrs_invalid",
+			Message: "Invalid denied network",
+		}
+		return
+	}
+	b.DenyCidrs = denyCidrs
+
+	allowCountries, valid := validateCountries(b.AllowCountries)
+	if !valid {
+		errData = &errortypes.ErrorData{
+			Error:   "allow_countries_invalid",
+			Message: "Allowed countries must be two letter country codes",
+		}
+		return
+	}
+	b.AllowCountries = allowCountries
+
+	denyCountries, valid := validateCountries(b.DenyCountries)
+	if !valid {
+		errData = &errortypes.ErrorData{
+			Error:   "deny_countries_invalid",
+			Message: "Denied countries must be two letter country codes",
+		}
+		return
+	}
+	b.DenyCountries = denyCountries
+
+	if (len(b.AllowCountries) > 0 || len(b.DenyCountries) > 0) &&
+		settings.System.License == "" {
+
+		errData = &errortypes.ErrorData{
+			Error:   "countries_unavailable",
+			Message: "Country filters require a subscription",
+		}
+		return
+	}
+
+	if b.MaxBodySize < 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "max_body_size_invalid",
+			Message: "Invalid maximum request body size",
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,206 @@
+package balancer
+
+import (
+	"fmt"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/zone"
+)
+
+func (l *Listener) Key() string {
+	return fmt.Sprintf("%s:%d", l.Protocol, l.Port)
+}
+
+func (b *Balancer) validateListeners() (errData *errortypes.ErrorData) {
+	b.Domains = []*Domain{}
+	b.WebSockets = false
+	b.AutoCert = false
+
+	listenerKeys := set.NewSet()
+	for _, listener := range b.Listeners {
+		switch listener.Protocol {
+		case Tcp, Udp:
+			break
+		default:
+			errData = &errortypes.ErrorData{
+				Error:   "listener_protocol_invalid",
+				Message: "Invalid balancer listener protocol",
+			}
+			return
+		}
+
+		if listener.Port < 1 || listener.Port > 65535 {
+			errData = &errortypes.ErrorData{
+				Error:   "listener_port_invalid",
+				Message: "Invalid balancer listener port",
+			}
+			return
+		}
+
+		if listener.BackendPort < 0 || listener.BackendPort > 65535 {
+			errData = &errortypes.ErrorData{
+				Error:   "listener_backend_port_invalid",
+				Message: "Invalid balancer listener backend port",
+			}
+			return
+		}
+
+		if listener.Mode == "" {
+			listener.Mode = Passthrough
+		}
+
+		switch listener.Mode {
+		case Passthrough:
+			break
+		case Terminate:
+			if listener.Protocol != Tcp {
+				errData = &errortypes.ErrorData{
+					Error:   "listener_mode_invalid",
+					Message: "TLS termination requires a TCP listener",
+				}
+				return
+			}
+
+			if len(b.Certificates) == 0 {
+				errData = &errortypes.ErrorData{
+					Error:   "listener_certificate_required",
+					Message: "TLS termination requires a certificate",
+				}
+				return
+			}
+			break
+		default:
+			errData = &errortypes.ErrorData{
+				Error:   "listener_mode_invalid",
+				Message: "Invalid balancer listener mode",
+			}
+			return
+		}
+
+		switch listener.ProxyProtocol {
+		case "", ProxyProtocolV2:
+			break
+		case ProxyProtocolV1:
+			if listener.Protocol != Tcp {
+				errData = &errortypes.ErrorData{
+					Error:   "listener_proxy_protocol_invalid",
+					Message: "PROXY protocol v1 requires a TCP listener",
+				}
+				return
+			}
+			break
+		default:
+			errData = &errortypes.ErrorData{
+				Error:   "listener_proxy_protocol_invalid",
+				Message: "Invalid balancer listener PROXY protocol",
+			}
+			return
+		}
+
+		key := listener.Key()
+		if listenerKeys.Contains(key) {
+			errData = &errortypes.ErrorData{
+				Error:   "listener_duplicate",
+				Message: "Duplicate balancer listener port",
+			}
+			return
+		}
+		listenerKeys.Add(key)
+	}
+
+	return
+}
+
+func (b *Balancer) validateNodePorts(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	zones, err := zone.GetAllDatacenter(db, b.Datacenter)
+	if err != nil {
+		return
+	}
+
+	zoneIds := set.NewSet()
+	for _, zne := range zones {
+		zoneIds.Add(zne.Id)
+	}
+
+	ndes, err := node.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	nodePorts := set.NewSet(settings.Hypervisor.SerialPort)
+	for _, nde := range ndes {
+		if !zoneIds.Contains(nde.Zone) {
+			continue
+		}
+
+		nodePorts.Add(nde.Port)
+		if !nde.NoRedirectServer {
+			nodePorts.Add(80)
+		}
+	}
+
+	for _, listener := range b.Listeners {
+		if listener.Protocol == Tcp && nodePorts.Contains(listener.Port) {
+			errData = &errortypes.ErrorData{
+				Error:   "listener_port_reserved",
+				Message: "Balancer listener port reserved by node",
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func (b *Balancer) validateListenerConflict(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	errData, err = b.validateNodePorts(db)
+	if err != nil || errData != nil {
+		return
+	}
+
+	listeners := []*bson.M{}
+	for _, listener := range b.Listeners {
+		listeners = append(listeners, &bson.M{
+			"listeners": &bson.M{
+				"$elemMatch": &bson.M{
+					"protocol": listener.Protocol,
+					"port":     listener.Port,
+				},
+			},
+		})
+	}
+
+	coll := db.Balancers()
+	count, err := coll.CountDocuments(db, &bson.M{
+		"_id": &bson.M{
+			"$ne": b.Id,
+		},
+		"state":      true,
+		"datacenter": b.Datacenter,
+		"$or":        listeners,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if count > 0 {
+		errData = &errortypes.ErrorData{
+			Error: "listener_conflict",
+			Message: "Listener port conflicts with another " +
+				"load balancer in same datacenter",
+		}
+		return
+	}
+
+	return
+}
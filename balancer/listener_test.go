@@ -0,0 +1,178 @@
+package balancer
+
+import (
+	"testing"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+)
+
+func TestListenerDefaults(t *testing.T) {
+	balnc := &Balancer{
+		Type: L4,
+		Domains: []*Domain{
+			{Domain: "example.com"},
+		},
+		WebSockets: true,
+		AutoCert:   true,
+		Listeners: []*Listener{
+			{Protocol: Tcp, Port: 443},
+			{Protocol: Udp, Port: 53, ProxyProtocol: ProxyProtocolV2},
+		},
+	}
+
+	errData := balnc.validateListeners()
+	if errData != nil {
+		t.Fatalf("validate error: %s", errData.Message)
+	}
+
+	if len(balnc.Domains) != 0 {
+		t.Errorf("layer-4 balancer domains not cleared")
+	}
+	if balnc.WebSockets {
+		t.Errorf("layer-4 balancer websockets not disabled")
+	}
+	if balnc.AutoCert {
+		t.Errorf("layer-4 balancer automatic certificates not disabled")
+	}
+
+	for _, listener := range balnc.Listeners {
+		if listener.Mode != Passthrough {
+			t.Errorf("listener %s mode %s expected %s",
+				listener.Key(), listener.Mode, Passthrough)
+		}
+	}
+}
+
+func TestListenerProtocolPorts(t *testing.T) {
+	balnc := &Balancer{
+		Type: L4,
+		Listeners: []*Listener{
+			{Protocol: Tcp, Port: 53},
+			{Protocol: Udp, Port: 53},
+		},
+	}
+
+	errData := balnc.validateListeners()
+	if errData != nil {
+		t.Errorf("tcp and udp on same port rejected: %s", errData.Message)
+	}
+
+	balnc.Listeners = append(balnc.Listeners, &Listener{
+		Protocol:    Tcp,
+		Port:        53,
+		BackendPort: 5353,
+	})
+
+	errData = balnc.validateListeners()
+	if errData == nil || errData.Error != "listener_duplicate" {
+		t.Errorf("duplicate tcp listener accepted")
+	}
+}
+
+func TestListenerPortRange(t *testing.T) {
+	for _, port := range []int{0, -1, 65536} {
+		balnc := &Balancer{
+			Type: L4,
+			Listeners: []*Listener{
+				{Protocol: Tcp, Port: port},
+			},
+		}
+
+		errData := balnc.validateListeners()
+		if errData == nil || errData.Error != "listener_port_invalid" {
+			t.Errorf("listener port %d accepted", port)
+		}
+	}
+
+	balnc := &Balancer{
+		Type: L4,
+		Listeners: []*Listener{
+			{Protocol: Tcp, Port: 443, BackendPort: 65536},
+		},
+	}
+
+	errData := balnc.validateListeners()
+	if errData == nil || errData.Error != "listener_backend_port_invalid" {
+		t.Errorf("listener backend port 65536 accepted")
+	}
+
+	balnc.Listeners[0].BackendPort = 0
+	errData = balnc.validateListeners()
+	if errData != nil {
+		t.Errorf("listener without backend port rejected: %s",
+			errData.Message)
+	}
+}
+
+func TestListenerTerminate(t *testing.T) {
+	balnc := &Balancer{
+		Type: L4,
+		Listeners: []*Listener{
+			{Protocol: Tcp, Port: 443, Mode: Terminate, BackendPort: 8080},
+		},
+	}
+
+	errData := balnc.validateListeners()
+	if errData == nil || errData.Error != "listener_certificate_required" {
+		t.Errorf("termination without certificate accepted")
+	}
+
+	balnc.Certificates = []primitive.ObjectID{primitive.NewObjectID()}
+	errData = balnc.validateListeners()
+	if errData != nil {
+		t.Errorf("termination with certificate rejected: %s",
+			errData.Message)
+	}
+
+	balnc.Listeners[0].Protocol = Udp
+	errData = balnc.validateListeners()
+	if errData == nil || errData.Error != "listener_mode_invalid" {
+		t.Errorf("udp termination accepted")
+	}
+
+	balnc.Listeners[0].Protocol = Tcp
+	balnc.Listeners[0].Mode = "inspect"
+	errData = balnc.validateListeners()
+	if errData == nil || errData.Error != "listener_mode_invalid" {
+		t.Errorf("unknown listener mode accepted")
+	}
+}
+
+func TestListenerProxyProtocol(t *testing.T) {
+	balnc := &Balancer{
+		Type: L4,
+		Listeners: []*Listener{
+			{Protocol: Tcp, Port: 443, ProxyProtocol: ProxyProtocolV1},
+		},
+	}
+
+	errData := balnc.validateListeners()
+	if errData != nil {
+		t.Errorf("tcp proxy protocol v1 rejected: %s", errData.Message)
+	}
+
+	balnc.Listeners[0].Protocol = Udp
+	errData = balnc.validateListeners()
+	if errData == nil || errData.Error != "listener_proxy_protocol_invalid" {
+		t.Errorf("udp proxy protocol v1 accepted")
+	}
+
+	balnc.Listeners[0].ProxyProtocol = ProxyProtocolV2
+	errData = balnc.validateListeners()
+	if errData != nil {
+		t.Errorf("udp proxy protocol v2 rejected: %s", errData.Message)
+	}
+
+	balnc.Listeners[0].ProxyProtocol = "v3"
+	errData = balnc.validateListeners()
+	if errData == nil || errData.Error != "listener_proxy_protocol_invalid" {
+		t.Errorf("unknown proxy protocol accepted")
+	}
+
+	balnc.Listeners[0].Protocol = "sctp"
+	balnc.Listeners[0].ProxyProtocol = ""
+	errData = balnc.validateListeners()
+	if errData == nil || errData.Error != "listener_protocol_invalid" {
+		t.Errorf("unknown listener protocol accepted")
+	}
+}
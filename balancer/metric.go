@@ -0,0 +1,66 @@
+package balancer
+
+var LatencyBuckets = []int64{
+	5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000,
+}
+
+type Metric struct {
+	Key        string  `bson:"key" json:"key"`
+	Requests   int     `bson:"requests" json:"requests"`
+	Errors     int     `bson:"errors" json:"errors"`
+	ErrorRate  float64 `bson:"error_rate" json:"error_rate"`
+	Latency    int64   `bson:"latency" json:"latency"`
+	LatencySum int64   `bson:"latency_sum" json:"latency_sum"`
+	Histogram  []int   `bson:"histogram" json:"histogram"`
+}
+
+func (m *Metric) Add(latency int64, failed bool) {
+	m.Requests += 1
+	if failed {
+		m.Errors += 1
+	}
+	m.LatencySum += latency
+
+	bucket := len(LatencyBuckets)
+	for i, bound := range LatencyBuckets {
+		if latency <= bound {
+			bucket = i
+			break
+		}
+	}
+	m.Histogram[bucket] += 1
+
+	m.update()
+}
+
+func (m *Metric) Merge(other *Metric) {
+	m.Requests += other.Requests
+	m.Errors += other.Errors
+	m.LatencySum += other.LatencySum
+
+	for i := range m.Histogram {
+		if i < len(other.Histogram) {
+			m.Histogram[i] += other.Histogram[i]
+		}
+	}
+
+	m.update()
+}
+
+func (m *Metric) update() {
+	if m.Requests == 0 {
+		m.ErrorRate = 0
+		m.Latency = 0
+		return
+	}
+
+	m.ErrorRate = float64(m.Errors) / float64(m.Requests)
+	m.Latency = m.LatencySum / int64(m.Requests)
+}
+
+func NewMetric(key string) *Metric {
+	return &Metric{
+		Key:       key,
+		Histogram: make([]int, len(LatencyBuckets)+1),
+	}
+}
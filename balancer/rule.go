@@ -0,0 +1,210 @@
+package balancer
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+var ruleMethods = set.NewSet(
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+)
+
+func (b *Balancer) validateRules() (errData *errortypes.ErrorData) {
+	if b.Type != Http {
+		b.Rules = []*Rule{}
+		return
+	}
+
+	pools := set.NewSet()
+	for _, backend := range b.Backends {
+		pools.Add(backend.Pool)
+	}
+
+	for _, rule := range b.Rules {
+		rule.Key = strings.TrimSpace(rule.Key)
+
+		switch rule.Match {
+		case MatchPathPrefix:
+			rule.Key = ""
+			if !strings.HasPrefix(rule.Value, "/") {
+				errData = &errortypes.ErrorData{
+					Error:   "rule_value_invalid",
+					Message: "Rule path prefix must start with a slash",
+				}
+				return
+			}
+			break
+		case MatchPathRegex:
+			rule.Key = ""
+			_, err := regexp.Compile(rule.Value)
+			if err != nil || rule.Value == "" {
+				errData = &errortypes.ErrorData{
+					Error:   "rule_value_invalid",
+					Message: "Invalid rule path regular expression",
+				}
+				return
+			}
+			break
+		case MatchHeader:
+			if rule.Key == "" {
+				errData = &errortypes.ErrorData{
+					Error:   "rule_key_required",
+					Message: "Missing required rule header name",
+				}
+				return
+			}
+			rule.Key = http.CanonicalHeaderKey(rule.Key)
+			break
+		case MatchMethod:
+			rule.Key = ""
+			rule.Value = strings.ToUpper(strings.TrimSpace(rule.Value))
+			if !ruleMethods.Contains(rule.Value) {
+				errData = &errortypes.ErrorData{
+					Error:   "rule_value_invalid",
+					Message: "Invalid rule request method",
+				}
+				return
+			}
+			break
+		default:
+			errData = &errortypes.ErrorData{
+				Error:   "rule_match_invalid",
+				Message: "Invalid rule match type",
+			}
+			return
+		}
+
+		if rule.Action == "" {
+			rule.Action = ActionForward
+		}
+
+		switch rule.Action {
+		case ActionForward:
+			rule.Status = 0
+			rule.ContentType = ""
+			rule.Body = ""
+
+			rule.Pool = utils.FilterStr(strings.TrimSpace(rule.Pool), 64)
+			if !pools.Contains(rule.Pool) {
+				errData = &errortypes.ErrorData{
+					Error:   "rule_pool_invalid",
+					Message: "Rule backend pool does not exist",
+				}
+				return
+			}
+
+			if rule.RewritePath != "" {
+				if rule.Match != MatchPathPrefix &&
+					rule.Match != MatchPathRegex {
+
+					errData = &errortypes.ErrorData{
+						Error: "rule_rewrite_path_invalid",
+						Message: "Path rewrite requires a path " +
+							"prefix or path regex rule",
+					}
+					return
+				}
+
+				if rule.Match == MatchPathPrefix &&
+					!strings.HasPrefix(rule.RewritePath, "/") {
+
+					errData = &errortypes.ErrorData{
+						Error:   "rule_rewrite_path_invalid",
+						Message: "Rule rewrite path must start with a slash",
+					}
+					return
+				}
+			}
+			break
+		case ActionRedirectHttps:
+			rule.Pool = ""
+			rule.RewritePath = ""
+			rule.ContentType = ""
+			rule.Body = ""
+
+			if rule.Status == 0 {
+				rule.Status = http.StatusMovedPermanently
+			}
+
+			switch rule.Status {
+			case http.StatusMovedPermanently, http.StatusFound,
+				http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+
+				break
+			default:
+				errData = &errortypes.ErrorData{
+					Error:   "rule_status_invalid",
+					Message: "Invalid rule redirect status code",
+				}
+				return
+			}
+			break
+		case ActionFixed:
+			rule.Pool = ""
+			rule.RewritePath = ""
+
+			if rule.Status == 0 {
+				rule.Status = http.StatusOK
+			}
+
+			if rule.Status < 100 || rule.Status > 599 {
+				errData = &errortypes.ErrorData{
+					Error:   "rule_status_invalid",
+					Message: "Invalid rule response status code",
+				}
+				return
+			}
+
+			if rule.ContentType == "" {
+				rule.ContentType = "text/plain; charset=utf-8"
+			}
+			break
+		default:
+			errData = &errortypes.ErrorData{
+				Error:   "rule_action_invalid",
+				Message: "Invalid rule action",
+			}
+			return
+		}
+
+		if rule.AddHeaders == nil {
+			rule.AddHeaders = []*Header{}
+		}
+		for _, header := range rule.AddHeaders {
+			header.Key = http.CanonicalHeaderKey(
+				strings.TrimSpace(header.Key))
+			if header.Key == "" {
+				errData = &errortypes.ErrorData{
+					Error:   "rule_header_invalid",
+					Message: "Invalid rule header name",
+				}
+				return
+			}
+		}
+
+		removeHeaders := []string{}
+		for _, header := range rule.RemoveHeaders {
+			header = http.CanonicalHeaderKey(strings.TrimSpace(header))
+			if header == "" {
+				continue
+			}
+			removeHeaders = append(removeHeaders, header)
+		}
+		rule.RemoveHeaders = removeHeaders
+	}
+
+	return
+}
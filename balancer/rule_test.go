@@ -0,0 +1,269 @@
+package balancer
+
+import (
+	"net/http"
+	"testing"
+)
+
+func testRuleBalancer(rules ...*Rule) *Balancer {
+	return &Balancer{
+		Type: Http,
+		Backends: []*Backend{
+			{Protocol: "http", Hostname: "10.0.0.2", Port: 80},
+			{Protocol: "http", Hostname: "10.0.0.4", Port: 80, Pool: "api"},
+		},
+		Rules: rules,
+	}
+}
+
+func TestRuleNormalize(t *testing.T) {
+	prefix := &Rule{
+		Match:       MatchPathPrefix,
+		Key:         "X-Ignored",
+		Value:       "/api",
+		Pool:        " api ",
+		RewritePath: "/",
+		Status:      http.StatusFound,
+		Body:        "unused",
+	}
+	header := &Rule{
+		Match: MatchHeader,
+		Key:   " x-tenant ",
+		Value: "a",
+		AddHeaders: []*Header{
+			{Key: "x-forwarded-pool", Value: "default"},
+		},
+		RemoveHeaders: []string{" x-debug", ""},
+	}
+	method := &Rule{
+		Match: MatchMethod,
+		Value: " post ",
+	}
+
+	balnc := testRuleBalancer(prefix, header, method)
+	errData := balnc.validateRules()
+	if errData != nil {
+		t.Fatalf("validate error: %s", errData.Message)
+	}
+
+	if prefix.Key != "" {
+		t.Errorf("path prefix key not cleared: %s", prefix.Key)
+	}
+	if prefix.Action != ActionForward {
+		t.Errorf("default action %s expected %s",
+			prefix.Action, ActionForward)
+	}
+	if prefix.Pool != "api" {
+		t.Errorf("pool %q expected %q", prefix.Pool, "api")
+	}
+	if prefix.Status != 0 || prefix.Body != "" {
+		t.Errorf("forward rule response fields not cleared")
+	}
+
+	if header.Key != "X-Tenant" {
+		t.Errorf("header key %q expected %q", header.Key, "X-Tenant")
+	}
+	if header.AddHeaders[0].Key != "X-Forwarded-Pool" {
+		t.Errorf("add header key %q not canonical",
+			header.AddHeaders[0].Key)
+	}
+	if len(header.RemoveHeaders) != 1 ||
+		header.RemoveHeaders[0] != "X-Debug" {
+
+		t.Errorf("remove headers %v expected [X-Debug]",
+			header.RemoveHeaders)
+	}
+
+	if method.Value != http.MethodPost {
+		t.Errorf("method %q expected %q", method.Value, http.MethodPost)
+	}
+	if method.AddHeaders == nil {
+		t.Errorf("add headers not initialized")
+	}
+}
+
+func TestRuleMatch(t *testing.T) {
+	balnc := testRuleBalancer(&Rule{
+		Match: MatchPathPrefix,
+		Value: "api",
+	})
+	errData := balnc.validateRules()
+	if errData == nil || errData.Error != "rule_value_invalid" {
+		t.Errorf("relative path prefix accepted")
+	}
+
+	balnc = testRuleBalancer(&Rule{
+		Match:       MatchPathRegex,
+		Value:       "^/v[0-9]+/",
+		RewritePath: "/",
+	})
+	errData = balnc.validateRules()
+	if errData != nil {
+		t.Errorf("path regex rejected: %s", errData.Message)
+	}
+
+	for _, value := range []string{"^/v[0-9+/", ""} {
+		balnc = testRuleBalancer(&Rule{
+			Match: MatchPathRegex,
+			Value: value,
+		})
+		errData = balnc.validateRules()
+		if errData == nil || errData.Error != "rule_value_invalid" {
+			t.Errorf("path regex %q accepted", value)
+		}
+	}
+
+	balnc = testRuleBalancer(&Rule{
+		Match: MatchHeader,
+		Value: "a",
+	})
+	errData = balnc.validateRules()
+	if errData == nil || errData.Error != "rule_key_required" {
+		t.Errorf("header rule without name accepted")
+	}
+
+	balnc = testRuleBalancer(&Rule{
+		Match: MatchMethod,
+		Value: "FETCH",
+	})
+	errData = balnc.validateRules()
+	if errData == nil || errData.Error != "rule_value_invalid" {
+		t.Errorf("unknown method accepted")
+	}
+
+	balnc = testRuleBalancer(&Rule{
+		Match: "query",
+		Value: "a",
+	})
+	errData = balnc.validateRules()
+	if errData == nil || errData.Error != "rule_match_invalid" {
+		t.Errorf("unknown match type accepted")
+	}
+}
+
+func TestRuleForward(t *testing.T) {
+	balnc := testRuleBalancer(&Rule{
+		Match: MatchPathPrefix,
+		Value: "/",
+		Pool:  "web",
+	})
+	errData := balnc.validateRules()
+	if errData == nil || errData.Error != "rule_pool_invalid" {
+		t.Errorf("missing backend pool accepted")
+	}
+
+	balnc = testRuleBalancer(&Rule{
+		Match:       MatchHeader,
+		Key:         "Host",
+		Value:       "a",
+		RewritePath: "/",
+	})
+	errData = balnc.validateRules()
+	if errData == nil || errData.Error != "rule_rewrite_path_invalid" {
+		t.Errorf("path rewrite on header rule accepted")
+	}
+
+	balnc = testRuleBalancer(&Rule{
+		Match:       MatchPathPrefix,
+		Value:       "/api",
+		Pool:        "api",
+		RewritePath: "v1",
+	})
+	errData = balnc.validateRules()
+	if errData == nil || errData.Error != "rule_rewrite_path_invalid" {
+		t.Errorf("relative prefix rewrite path accepted")
+	}
+}
+
+func TestRuleResponse(t *testing.T) {
+	redirect := &Rule{
+		Match:       MatchPathPrefix,
+		Value:       "/",
+		Action:      ActionRedirectHttps,
+		Pool:        "api",
+		RewritePath: "/",
+	}
+	fixed := &Rule{
+		Match:  MatchPathPrefix,
+		Value:  "/health",
+		Action: ActionFixed,
+		Pool:   "api",
+		Body:   "ok",
+	}
+
+	balnc := testRuleBalancer(redirect, fixed)
+	errData := balnc.validateRules()
+	if errData != nil {
+		t.Fatalf("validate error: %s", errData.Message)
+	}
+
+	if redirect.Status != http.StatusMovedPermanently {
+		t.Errorf("redirect status %d expected %d",
+			redirect.Status, http.StatusMovedPermanently)
+	}
+	if redirect.Pool != "" || redirect.RewritePath != "" {
+		t.Errorf("redirect forward fields not cleared")
+	}
+
+	if fixed.Status != http.StatusOK {
+		t.Errorf("fixed status %d expected %d",
+			fixed.Status, http.StatusOK)
+	}
+	if fixed.ContentType == "" {
+		t.Errorf("fixed content type not defaulted")
+	}
+	if fixed.Pool != "" || fixed.Body != "ok" {
+		t.Errorf("fixed rule fields %q %q", fixed.Pool, fixed.Body)
+	}
+
+	redirect.Status = http.StatusOK
+	errData = balnc.validateRules()
+	if errData == nil || errData.Error != "rule_status_invalid" {
+		t.Errorf("redirect status 200 accepted")
+	}
+
+	redirect.Status = 0
+	fixed.Status = 600
+	errData = balnc.validateRules()
+	if errData == nil || errData.Error != "rule_status_invalid" {
+		t.Errorf("fixed status 600 accepted")
+	}
+
+	fixed.Status = 0
+	fixed.Action = "drop"
+	errData = balnc.validateRules()
+	if errData == nil || errData.Error != "rule_action_invalid" {
+		t.Errorf("unknown action accepted")
+	}
+}
+
+func TestRuleHeaders(t *testing.T) {
+	balnc := testRuleBalancer(&Rule{
+		Match: MatchPathPrefix,
+		Value: "/",
+		AddHeaders: []*Header{
+			{Key: " ", Value: "a"},
+		},
+	})
+	errData := balnc.validateRules()
+	if errData == nil || errData.Error != "rule_header_invalid" {
+		t.Errorf("blank add header name accepted")
+	}
+}
+
+func TestRuleLayer4(t *testing.T) {
+	balnc := &Balancer{
+		Type: L4,
+		Rules: []*Rule{
+			{Match: "query"},
+		},
+	}
+
+	errData := balnc.validateRules()
+	if errData != nil {
+		t.Errorf("layer-4 rules rejected: %s", errData.Message)
+	}
+	if len(balnc.Rules) != 0 {
+		t.Errorf("layer-4 balancer rules not cleared")
+	}
+}
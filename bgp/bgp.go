@@ -0,0 +1,383 @@
+package bgp
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/osrg/gobgp/v3/pkg/server"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/sirupsen/logrus"
+)
+
+type pathState struct {
+	hash   string
+	family *api.Family
+	uuid   []byte
+}
+
+var (
+	srv        *server.BgpServer
+	srvHash    string
+	peerHashes = map[string]string{}
+	pathStates = map[string]*pathState{}
+	lock       = sync.Mutex{}
+)
+
+func globalHash(nde *node.Node) string {
+	hash := md5.New()
+
+	io.WriteString(hash, strconv.FormatUint(uint64(nde.BgpAsn), 10))
+	io.WriteString(hash, nde.GetBgpRouterId())
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func start(nde *node.Node) (err error) {
+	routerId := nde.GetBgpRouterId()
+
+	logrus.WithFields(logrus.Fields{
+		"asn":       nde.BgpAsn,
+		"router_id": routerId,
+	}).Info("bgp: Starting bgp speaker")
+
+	newSrv := server.NewBgpServer()
+	go newSrv.Serve()
+
+	err = newSrv.StartBgp(context.Background(), &api.StartBgpRequest{
+		Global: &api.Global{
+			Asn:        nde.BgpAsn,
+			RouterId:   routerId,
+			ListenPort: -1,
+		},
+	})
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "bgp: Failed to start bgp speaker"),
+		}
+		return
+	}
+
+	srv = newSrv
+	srvHash = globalHash(nde)
+	peerHashes = map[string]string{}
+	pathStates = map[string]*pathState{}
+
+	return
+}
+
+func stop() (err error) {
+	if srv == nil {
+		return
+	}
+
+	logrus.Info("bgp: Stopping bgp speaker")
+
+	curSrv := srv
+	srv = nil
+	srvHash = ""
+	peerHashes = map[string]string{}
+	pathStates = map[string]*pathState{}
+
+	err = curSrv.StopBgp(context.Background(), &api.StopBgpRequest{})
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "bgp: Failed to stop bgp speaker"),
+		}
+		return
+	}
+
+	return
+}
+
+func addPeer(peer *node.BgpPeer) (err error) {
+	apiPeer := &api.Peer{
+		Conf: &api.PeerConf{
+			NeighborAddress: peer.Address,
+			PeerAsn:         peer.Asn,
+			AuthPassword:    peer.Password,
+		},
+		AfiSafis: []*api.AfiSafi{
+			{
+				Config: &api.AfiSafiConfig{
+					Family:  family4,
+					Enabled: true,
+				},
+			},
+			{
+				Config: &api.AfiSafiConfig{
+					Family:  family6,
+					Enabled: true,
+				},
+			},
+		},
+	}
+
+	if peer.Multihop > 0 {
+		apiPeer.EbgpMultihop = &api.EbgpMultihop{
+			Enabled:     true,
+			MultihopTtl: uint32(peer.Multihop),
+		}
+	}
+
+	err = srv.AddPeer(context.Background(), &api.AddPeerRequest{
+		Peer: apiPeer,
+	})
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "bgp: Failed to add bgp peer"),
+		}
+		return
+	}
+
+	return
+}
+
+func deletePeer(address string) (err error) {
+	err = srv.DeletePeer(context.Background(), &api.DeletePeerRequest{
+		Address: address,
+	})
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "bgp: Failed to delete bgp peer"),
+		}
+		return
+	}
+
+	return
+}
+
+func syncPeers(nde *node.Node) (err error) {
+	curPeers := map[string]string{}
+
+	for _, peer := range nde.BgpPeers {
+		hash := peer.Hash()
+		curPeers[peer.Address] = hash
+
+		curHash, ok := peerHashes[peer.Address]
+		if ok && curHash == hash {
+			continue
+		}
+
+		if ok {
+			err = deletePeer(peer.Address)
+			if err != nil {
+				return
+			}
+			delete(peerHashes, peer.Address)
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"address": peer.Address,
+			"asn":     peer.Asn,
+		}).Info("bgp: Adding bgp peer")
+
+		err = addPeer(peer)
+		if err != nil {
+			return
+		}
+		peerHashes[peer.Address] = hash
+	}
+
+	for address := range peerHashes {
+		if _, ok := curPeers[address]; ok {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"address": address,
+		}).Info("bgp: Removing bgp peer")
+
+		err = deletePeer(address)
+		if err != nil {
+			return
+		}
+		delete(peerHashes, address)
+	}
+
+	return
+}
+
+func addPath(pth *Path) (err error) {
+	apiPath, err := pth.apiPath()
+	if err != nil {
+		return
+	}
+
+	resp, err := srv.AddPath(context.Background(), &api.AddPathRequest{
+		TableType: api.TableType_GLOBAL,
+		Path:      apiPath,
+	})
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "bgp: Failed to announce prefix"),
+		}
+		return
+	}
+
+	pathStates[pth.Prefix] = &pathState{
+		hash:   pth.Hash(),
+		family: pth.Family(),
+		uuid:   resp.Uuid,
+	}
+
+	return
+}
+
+func deletePath(prefix string) (err error) {
+	state := pathStates[prefix]
+	if state == nil {
+		return
+	}
+
+	err = srv.DeletePath(context.Background(), &api.DeletePathRequest{
+		TableType: api.TableType_GLOBAL,
+		Family:    state.family,
+		Uuid:      state.uuid,
+	})
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "bgp: Failed to withdraw prefix"),
+		}
+		return
+	}
+
+	delete(pathStates, prefix)
+
+	return
+}
+
+func syncPaths(paths []*Path) (err error) {
+	curPaths := map[string]*Path{}
+
+	for _, pth := range paths {
+		curPaths[pth.Prefix] = pth
+
+		state := pathStates[pth.Prefix]
+		if state != nil && state.hash == pth.Hash() {
+			continue
+		}
+
+		if state != nil {
+			err = deletePath(pth.Prefix)
+			if err != nil {
+				return
+			}
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"prefix":   pth.Prefix,
+			"next_hop": pth.NextHop,
+		}).Info("bgp: Announcing prefix")
+
+		err = addPath(pth)
+		if err != nil {
+			return
+		}
+	}
+
+	for prefix := range pathStates {
+		if _, ok := curPaths[prefix]; ok {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"prefix": prefix,
+		}).Info("bgp: Withdrawing prefix")
+
+		err = deletePath(prefix)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func ApplyState(nde *node.Node, prefixes []string) (err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if !nde.IsBgp() {
+		err = stop()
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	if nde.GetBgpRouterId() == "" {
+		logrus.Warn("bgp: Missing bgp router ID, skipping bgp speaker")
+		err = stop()
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	if srv != nil && srvHash != globalHash(nde) {
+		err = stop()
+		if err != nil {
+			return
+		}
+	}
+
+	if srv == nil {
+		err = start(nde)
+		if err != nil {
+			return
+		}
+	}
+
+	err = syncPeers(nde)
+	if err != nil {
+		return
+	}
+
+	communities := []uint32{}
+	for _, community := range nde.BgpCommunities {
+		val, e := node.ParseCommunity(community)
+		if e != nil {
+			err = e
+			return
+		}
+		communities = append(communities, val)
+	}
+
+	nextHop := nde.GetBgpNextHop()
+	nextHop6 := nde.GetBgpNextHop6()
+
+	paths := []*Path{}
+	for _, prefix := range prefixes {
+		pth := &Path{
+			Prefix:      prefix,
+			Communities: communities,
+			LocalPref:   nde.BgpLocalPref,
+		}
+
+		if pth.IsIpv6() {
+			pth.NextHop = nextHop6
+		} else {
+			pth.NextHop = nextHop
+		}
+
+		if pth.NextHop == "" {
+			continue
+		}
+
+		paths = append(paths, pth)
+	}
+
+	err = syncPaths(paths)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,156 @@
+package bgp
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/dropbox/godropbox/errors"
+	api "github.com/osrg/gobgp/v3/api"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	apb "google.golang.org/protobuf/types/known/anypb"
+)
+
+var (
+	family4 = &api.Family{
+		Afi:  api.Family_AFI_IP,
+		Safi: api.Family_SAFI_UNICAST,
+	}
+	family6 = &api.Family{
+		Afi:  api.Family_AFI_IP6,
+		Safi: api.Family_SAFI_UNICAST,
+	}
+)
+
+type Path struct {
+	Prefix      string
+	NextHop     string
+	Communities []uint32
+	LocalPref   uint32
+}
+
+func (p *Path) IsIpv6() bool {
+	ip, _, err := net.ParseCIDR(p.Prefix)
+	if err != nil {
+		return false
+	}
+	return ip.To4() == nil
+}
+
+func (p *Path) Hash() string {
+	hash := md5.New()
+
+	io.WriteString(hash, p.Prefix)
+	io.WriteString(hash, p.NextHop)
+	for _, community := range p.Communities {
+		io.WriteString(hash, strconv.FormatUint(uint64(community), 10))
+	}
+	io.WriteString(hash, strconv.FormatUint(uint64(p.LocalPref), 10))
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (p *Path) Family() *api.Family {
+	if p.IsIpv6() {
+		return family6
+	}
+	return family4
+}
+
+func (p *Path) apiPath() (pth *api.Path, err error) {
+	_, prefixNet, err := net.ParseCIDR(p.Prefix)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "bgp: Failed to parse prefix"),
+		}
+		return
+	}
+
+	prefixLen, _ := prefixNet.Mask.Size()
+
+	nlri, err := apb.New(&api.IPAddressPrefix{
+		Prefix:    prefixNet.IP.String(),
+		PrefixLen: uint32(prefixLen),
+	})
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "bgp: Failed to marshal prefix"),
+		}
+		return
+	}
+
+	origin, err := apb.New(&api.OriginAttribute{
+		Origin: 0,
+	})
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "bgp: Failed to marshal origin"),
+		}
+		return
+	}
+
+	pattrs := []*apb.Any{origin}
+
+	family := p.Family()
+	if family == family6 {
+		mpReach, e := apb.New(&api.MpReachNLRIAttribute{
+			Family:   family6,
+			NextHops: []string{p.NextHop},
+			Nlris:    []*apb.Any{nlri},
+		})
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "bgp: Failed to marshal next hop"),
+			}
+			return
+		}
+		pattrs = append(pattrs, mpReach)
+	} else {
+		nextHop, e := apb.New(&api.NextHopAttribute{
+			NextHop: p.NextHop,
+		})
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "bgp: Failed to marshal next hop"),
+			}
+			return
+		}
+		pattrs = append(pattrs, nextHop)
+	}
+
+	if p.LocalPref != 0 {
+		localPref, e := apb.New(&api.LocalPrefAttribute{
+			LocalPref: p.LocalPref,
+		})
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "bgp: Failed to marshal local pref"),
+			}
+			return
+		}
+		pattrs = append(pattrs, localPref)
+	}
+
+	if len(p.Communities) > 0 {
+		communities, e := apb.New(&api.CommunitiesAttribute{
+			Communities: p.Communities,
+		})
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "bgp: Failed to marshal communities"),
+			}
+			return
+		}
+		pattrs = append(pattrs, communities)
+	}
+
+	pth = &api.Path{
+		Family: family,
+		Nlri:   nlri,
+		Pattrs: pattrs,
+	}
+
+	return
+}
@@ -3,6 +3,8 @@ package block
 const (
 	External = "external"
 	Host     = "host"
+	Gateway  = "gateway"
+	Floating = "floating"
 	IPv4     = "ipv4"
 	IPv6     = "ipv6"
 )
@@ -89,6 +89,50 @@ func GetInstanceIp(db *database.Database, instId primitive.ObjectID,
 	return
 }
 
+func GetInstanceIps(db *database.Database, instId primitive.ObjectID,
+	typ string) (blckIps []*BlockIp, err error) {
+
+	coll := db.BlocksIp()
+	blckIps = []*BlockIp{}
+
+	cursor, err := coll.Find(
+		db,
+		&bson.M{
+			"instance": instId,
+			"type":     typ,
+		},
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"ip", 1},
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		blckIp := &BlockIp{}
+		err = cursor.Decode(blckIp)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		blckIps = append(blckIps, blckIp)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
 func Remove(db *database.Database, blockId primitive.ObjectID) (err error) {
 	coll := db.Blocks()
 	ipColl := db.BlocksIp()
@@ -6,6 +6,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"io"
+	"net/url"
 	"strings"
 	"time"
 
@@ -39,6 +40,16 @@ type Certificate struct {
 	AcmeHash     string             `bson:"acme_hash" json:"acme_hash"`
 	AcmeAccount  string             `bson:"acme_account" json:"acme_account"`
 	AcmeDomains  []string           `bson:"acme_domains" json:"acme_domains"`
+	AcmeType     string             `bson:"acme_type" json:"acme_type"`
+	AcmeUrl      string             `bson:"acme_url" json:"acme_url"`
+	AcmeEabId    string             `bson:"acme_eab_id" json:"acme_eab_id"`
+	AcmeEabKey   string             `bson:"acme_eab_key" json:"acme_eab_key"`
+	AcmeChain    string             `bson:"acme_chain" json:"acme_chain"`
+	AcmeGroup    string             `bson:"acme_group" json:"acme_group"`
+	AcmeError    string             `bson:"acme_error" json:"acme_error"`
+	AcmeFailures int                `bson:"acme_failures" json:"acme_failures"`
+	AcmeAttempt  time.Time          `bson:"acme_attempt" json:"acme_attempt"`
+	Balancer     primitive.ObjectID `bson:"balancer,omitempty" json:"balancer"`
 }
 
 func (c *Certificate) Validate(db *database.Database) (
@@ -51,6 +62,13 @@ func (c *Certificate) Validate(db *database.Database) (
 	if c.Type != LetsEncrypt {
 		c.AcmeAccount = ""
 		c.AcmeDomains = []string{}
+		c.AcmeType = ""
+		c.AcmeUrl = ""
+		c.AcmeEabId = ""
+		c.AcmeEabKey = ""
+		c.AcmeChain = ""
+	} else if c.AcmeType == "" {
+		c.AcmeType = AcmeHttp
 	}
 
 	if c.AcmeDomains == nil {
@@ -71,6 +89,64 @@ func (c *Certificate) Validate(db *database.Database) (
 		return
 	}
 
+	switch c.AcmeType {
+	case "", AcmeDns:
+		break
+	case AcmeHttp:
+		for _, domain := range c.AcmeDomains {
+			if strings.HasPrefix(domain, "*.") {
+				errData = &errortypes.ErrorData{
+					Error: "acme_wildcard_invalid",
+					Message: "Wildcard domains require " +
+						"DNS challenge type",
+				}
+				return
+			}
+		}
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "acme_type_invalid",
+			Message: "Invalid ACME challenge type",
+		}
+		return
+	}
+
+	c.AcmeUrl = strings.TrimSpace(c.AcmeUrl)
+	if c.AcmeUrl != "" {
+		acmeUrl, e := url.Parse(c.AcmeUrl)
+		if e != nil || acmeUrl.Scheme != "https" || acmeUrl.Host == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "acme_url_invalid",
+				Message: "ACME directory must be a HTTPS URL",
+			}
+			return
+		}
+	}
+
+	c.AcmeEabId = strings.TrimSpace(c.AcmeEabId)
+	c.AcmeEabKey = strings.TrimSpace(c.AcmeEabKey)
+	if (c.AcmeEabId == "") != (c.AcmeEabKey == "") {
+		errData = &errortypes.ErrorData{
+			Error:   "acme_eab_invalid",
+			Message: "ACME external account requires key ID and HMAC key",
+		}
+		return
+	}
+
+	if c.AcmeEabKey != "" {
+		_, e := ParseEabKey(c.AcmeEabKey)
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "acme_eab_key_invalid",
+				Message: "ACME external account HMAC key must be base64",
+			}
+			return
+		}
+	}
+
+	c.AcmeChain = strings.TrimSpace(c.AcmeChain)
+
 	err = c.UpdateInfo()
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
@@ -196,6 +272,8 @@ func (c *Certificate) Hash() string {
 	hash.Write([]byte(c.Key))
 	hash.Write([]byte(c.Certificate))
 	hash.Write([]byte(c.AcmeAccount))
+	hash.Write([]byte(c.AcmeUrl))
+	hash.Write([]byte(c.AcmeChain))
 	if c.AcmeDomains != nil {
 		for _, domain := range c.AcmeDomains {
 			io.WriteString(hash, domain)
@@ -3,4 +3,7 @@ package certificate
 const (
 	Text        = "text"
 	LetsEncrypt = "lets_encrypt"
+
+	AcmeHttp = "acme_http"
+	AcmeDns  = "acme_dns"
 )
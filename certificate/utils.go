@@ -1,9 +1,14 @@
 package certificate
 
 import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
 )
 
 func Get(db *database.Database, certId primitive.ObjectID) (
@@ -38,6 +43,28 @@ func GetOrg(db *database.Database, orgId, certId primitive.ObjectID) (
 	return
 }
 
+func ExistsOrg(db *database.Database, orgId, certId primitive.ObjectID) (
+	exists bool, err error) {
+
+	coll := db.Certificates()
+	n, err := coll.CountDocuments(
+		db,
+		&bson.M{
+			"_id":          certId,
+			"organization": orgId,
+		},
+	)
+	if err != nil {
+		return
+	}
+
+	if n > 0 {
+		exists = true
+	}
+
+	return
+}
+
 func GetAll(db *database.Database) (certs []*Certificate, err error) {
 	coll := db.Certificates()
 	certs = []*Certificate{}
@@ -68,6 +95,40 @@ func GetAll(db *database.Database) (certs []*Certificate, err error) {
 	return
 }
 
+func GetAllBalancer(db *database.Database, balncId primitive.ObjectID) (
+	certs []*Certificate, err error) {
+
+	coll := db.Certificates()
+	certs = []*Certificate{}
+
+	cursor, err := coll.Find(db, &bson.M{
+		"balancer": balncId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		cert := &Certificate{}
+		err = cursor.Decode(cert)
+		if err != nil {
+			return
+		}
+
+		certs = append(certs, cert)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
 func GetAllOrg(db *database.Database, orgId primitive.ObjectID) (
 	certs []*Certificate, err error) {
 
@@ -137,3 +198,20 @@ func RemoveOrg(db *database.Database, orgId, certId primitive.ObjectID) (
 
 	return
 }
+
+func ParseEabKey(key string) (hmacKey []byte, err error) {
+	key = strings.TrimRight(strings.TrimSpace(key), "=")
+
+	hmacKey, err = base64.RawURLEncoding.DecodeString(key)
+	if err != nil {
+		hmacKey, err = base64.RawStdEncoding.DecodeString(key)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "certificate: Failed to decode EAB key"),
+			}
+			return
+		}
+	}
+
+	return
+}
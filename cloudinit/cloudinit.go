@@ -20,6 +20,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/pki"
 	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
@@ -47,12 +48,25 @@ config:
         network: {{.Network}}
         gateway: {{.Gateway}}
         dns_nameservers:
+          - {{.Gateway}}
           - 8.8.8.8
           - 8.8.4.4
+        dns_search:
+          - {{.Domain}}
       - type: static
         address: {{.Address6}}
         gateway: {{.Gateway6}}
-`
+{{range .Interfaces}}  - type: physical
+    name: {{.Name}}
+    mac_address: {{.Mac}}{{$.Mtu}}
+    subnets:
+      - type: static
+        address: {{.Address}}
+        netmask: {{.Netmask}}
+        network: {{.Network}}
+      - type: static
+        address: {{.Address6}}
+{{end}}`
 
 const netMtu = `
     mtu: %d`
@@ -87,20 +101,33 @@ const teeTmpl = `sudo tee %s << EOF
 EOF
 `
 
+const guestCertDir = "/etc/pritunl-cloud"
+
 var (
 	cloudConfig = template.Must(template.New("cloud").Parse(cloudConfigTmpl))
 	netConfig   = template.Must(template.New("net").Parse(netConfigTmpl))
 )
 
 type netConfigData struct {
+	Mac        string
+	Mtu        string
+	Address    string
+	Netmask    string
+	Network    string
+	Gateway    string
+	Address6   string
+	Gateway6   string
+	Domain     string
+	Interfaces []*netConfigInterface
+}
+
+type netConfigInterface struct {
+	Name     string
 	Mac      string
-	Mtu      string
 	Address  string
 	Netmask  string
 	Network  string
-	Gateway  string
 	Address6 string
-	Gateway6 string
 }
 
 type cloudConfigData struct {
@@ -117,7 +144,7 @@ func getUserData(db *database.Database, inst *instance.Instance,
 		return
 	}
 
-	if len(authrs) == 0 {
+	if len(authrs) == 0 && !inst.GuestCertificate {
 		return
 	}
 
@@ -168,6 +195,15 @@ func getUserData(db *database.Database, inst *instance.Instance,
 		cloudScript += fmt.Sprintf(teeTmpl, "/etc/ssh/principals", principals)
 	}
 
+	if inst.GuestCertificate && pki.Enabled() {
+		guestScript, e := getGuestCert(db, inst)
+		if e != nil {
+			err = e
+			return
+		}
+		cloudScript += guestScript
+	}
+
 	if cloudScript != "" {
 		items = append(items, fmt.Sprintf(cloudScriptTmpl, cloudScript))
 	}
@@ -278,13 +314,48 @@ func getNetData(db *database.Database, inst *instance.Instance,
 	gatewayAddr6 := vc.GetIp6(gatewayAddr)
 
 	data := netConfigData{
-		Mac:      adapter.MacAddress,
-		Address:  addr.String(),
-		Netmask:  net.IP(vcNet.Mask).String(),
-		Network:  vcNet.IP.String(),
-		Gateway:  gatewayAddr.String(),
-		Address6: addr6.String(),
-		Gateway6: gatewayAddr6.String(),
+		Mac:        adapter.MacAddress,
+		Address:    addr.String(),
+		Netmask:    net.IP(vcNet.Mask).String(),
+		Network:    vcNet.IP.String(),
+		Gateway:    gatewayAddr.String(),
+		Address6:   addr6.String(),
+		Gateway6:   gatewayAddr6.String(),
+		Domain:     vc.GetDomain(),
+		Interfaces: []*netConfigInterface{},
+	}
+
+	for i, ifaceAdapter := range virt.NetworkAdapters {
+		if i == 0 {
+			continue
+		}
+
+		ifaceVc, e := vpc.Get(db, ifaceAdapter.Vpc)
+		if e != nil {
+			err = e
+			return
+		}
+
+		ifaceNet, e := ifaceVc.GetNetwork()
+		if e != nil {
+			err = e
+			return
+		}
+
+		ifaceAddr, _, e := ifaceVc.GetIp(db, ifaceAdapter.Subnet, inst.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		data.Interfaces = append(data.Interfaces, &netConfigInterface{
+			Name:     fmt.Sprintf("eth%d", i),
+			Mac:      ifaceAdapter.MacAddress,
+			Address:  ifaceAddr.String(),
+			Netmask:  net.IP(ifaceNet.Mask).String(),
+			Network:  ifaceNet.IP.String(),
+			Address6: ifaceVc.GetIp6(ifaceAddr).String(),
+		})
 	}
 
 	jumboFrames := node.Self.JumboFrames
@@ -0,0 +1,146 @@
+package cloudinit
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/pki"
+	"github.com/pritunl/pritunl-cloud/qga"
+)
+
+const guestRenewRetry = 5 * time.Minute
+
+var (
+	guestRenew     = map[primitive.ObjectID]time.Time{}
+	guestRenewLock sync.Mutex
+)
+
+func setGuestCertRenew(instId primitive.ObjectID, ttl time.Duration) {
+	guestRenewLock.Lock()
+	guestRenew[instId] = time.Now().Add(ttl)
+	guestRenewLock.Unlock()
+}
+
+func issueGuestCert(db *database.Database, inst *instance.Instance) (
+	caPem string, certPem, keyPem []byte, err error) {
+
+	caPem, err = pki.Authority(db, pki.ServiceCa)
+	if err != nil {
+		return
+	}
+
+	ips := []string{}
+	ips = append(ips, inst.PrivateIps...)
+	ips = append(ips, inst.PrivateIps6...)
+	ips = append(ips, inst.PublicIps...)
+	ips = append(ips, inst.PublicIps6...)
+
+	// Names are derived from the instance id to prevent a user controlled
+	// instance name from claiming the name of another service
+	name := inst.Id.Hex() + "." + pki.GuestDomain
+
+	certPem, keyPem, err = pki.Issue(db, &pki.Request{
+		Authority:  pki.GuestCa,
+		CommonName: name,
+		DnsNames:   []string{name},
+		Ips:        ips,
+		Server:     true,
+		Ttl:        pki.GuestTtl,
+	})
+	if err != nil {
+		return
+	}
+
+	setGuestCertRenew(inst.Id, pki.GuestTtl*2/3)
+
+	return
+}
+
+func getGuestCert(db *database.Database, inst *instance.Instance) (
+	script string, err error) {
+
+	caPem, certPem, keyPem, err := issueGuestCert(db, inst)
+	if err != nil {
+		return
+	}
+
+	keyPath := path.Join(guestCertDir, "instance.key")
+
+	script = fmt.Sprintf("sudo mkdir -p %s\n", guestCertDir)
+	script += fmt.Sprintf(teeTmpl, path.Join(guestCertDir, "ca.pem"),
+		strings.TrimSpace(caPem))
+	script += fmt.Sprintf(teeTmpl, path.Join(guestCertDir, "instance.pem"),
+		strings.TrimSpace(string(certPem)))
+	script += fmt.Sprintf("sudo install -m 600 /dev/null %s\n", keyPath)
+	script += fmt.Sprintf(teeTmpl, keyPath,
+		strings.TrimSpace(string(keyPem)))
+
+	return
+}
+
+// ClaimGuestCertRenew returns true when the guest certificate of a running
+// instance is due for renewal. Instances without a known issue time are
+// renewed once to recover the schedule after a restart.
+func ClaimGuestCertRenew(instId primitive.ObjectID) bool {
+	guestRenewLock.Lock()
+	defer guestRenewLock.Unlock()
+
+	renew, ok := guestRenew[instId]
+	if ok && time.Now().Before(renew) {
+		return false
+	}
+
+	guestRenew[instId] = time.Now().Add(guestRenewRetry)
+	return true
+}
+
+func ClearGuestCertRenew(instId primitive.ObjectID) {
+	guestRenewLock.Lock()
+	delete(guestRenew, instId)
+	guestRenewLock.Unlock()
+}
+
+// RenewGuestCert writes a newly issued certificate to a running instance
+// using the guest agent. The key is written before the certificate to
+// allow services to reload once the certificate changes.
+func RenewGuestCert(db *database.Database, inst *instance.Instance) (
+	err error) {
+
+	caPem, certPem, keyPem, err := issueGuestCert(db, inst)
+	if err != nil {
+		setGuestCertRenew(inst.Id, guestRenewRetry)
+		return
+	}
+
+	guestPath := paths.GetGuestPath(inst.Id)
+
+	err = qga.WriteFile(guestPath, path.Join(guestCertDir, "ca.pem"),
+		[]byte(caPem))
+	if err != nil {
+		setGuestCertRenew(inst.Id, guestRenewRetry)
+		return
+	}
+
+	err = qga.WriteFile(guestPath, path.Join(guestCertDir, "instance.key"),
+		keyPem)
+	if err != nil {
+		setGuestCertRenew(inst.Id, guestRenewRetry)
+		return
+	}
+
+	err = qga.WriteFile(guestPath, path.Join(guestCertDir, "instance.pem"),
+		certPem)
+	if err != nil {
+		setGuestCertRenew(inst.Id, guestRenewRetry)
+		return
+	}
+
+	return
+}
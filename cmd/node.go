@@ -14,6 +14,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/router"
+	"github.com/pritunl/pritunl-cloud/serial"
 	"github.com/pritunl/pritunl-cloud/setup"
 	"github.com/pritunl/pritunl-cloud/sync"
 	"github.com/pritunl/pritunl-cloud/task"
@@ -53,6 +54,8 @@ func Node() (err error) {
 
 	task.Init()
 
+	serial.Init()
+
 	go func() {
 		err = routr.Run()
 		if err != nil {
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/config"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/pki"
+	"github.com/sirupsen/logrus"
+)
+
+func PkiKey() (err error) {
+	key := flag.Arg(1)
+
+	err = config.Load()
+	if err != nil {
+		return
+	}
+
+	if config.Config.PkiKey != "" {
+		if key == "" || key == config.Config.PkiKey {
+			fmt.Println(config.Config.PkiKey)
+			return
+		}
+
+		err = &errortypes.WriteError{
+			errors.New("cmd: Internal certificate authority key already " +
+				"set, remove pki_key from the config to replace"),
+		}
+		return
+	}
+
+	if key == "" {
+		key, err = pki.GenerateKey()
+		if err != nil {
+			return
+		}
+	}
+
+	config.Config.PkiKey = key
+
+	db := database.GetDatabase()
+	defer db.Close()
+
+	err = pki.CheckKey(db)
+	if err != nil {
+		config.Config.PkiKey = ""
+		return
+	}
+
+	err = config.Save()
+	if err != nil {
+		return
+	}
+
+	logrus.Info("cmd: Set internal certificate authority key")
+
+	fmt.Println(key)
+
+	return
+}
+
+func PkiRoot() (err error) {
+	db := database.GetDatabase()
+	defer db.Close()
+
+	certPem, err := pki.Root(db)
+	if err != nil {
+		return
+	}
+
+	fmt.Print(certPem)
+
+	return
+}
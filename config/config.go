@@ -26,6 +26,7 @@ type ConfigData struct {
 	loaded   bool   `json:"-"`
 	MongoUri string `json:"mongo_uri"`
 	NodeId   string `json:"node_id"`
+	PkiKey   string `json:"pki_key"`
 }
 
 func (c *ConfigData) Save() (err error) {
@@ -116,6 +116,11 @@ func (d *Database) Nonces() (coll *Collection) {
 	return
 }
 
+func (d *Database) SerialTokens() (coll *Collection) {
+	coll = d.getCollection("serial_tokens")
+	return
+}
+
 func (d *Database) Rokeys() (coll *Collection) {
 	coll = d.getCollection("rokeys")
 	return
@@ -201,6 +206,21 @@ func (d *Database) VpcsIp() (coll *Collection) {
 	return
 }
 
+func (d *Database) Gateways() (coll *Collection) {
+	coll = d.getCollection("gateways")
+	return
+}
+
+func (d *Database) FloatingIps() (coll *Collection) {
+	coll = d.getCollection("floating_ips")
+	return
+}
+
+func (d *Database) VpnClients() (coll *Collection) {
+	coll = d.getCollection("vpn_clients")
+	return
+}
+
 func (d *Database) Authorities() (coll *Collection) {
 	coll = d.getCollection("authorities")
 	return
@@ -226,6 +246,11 @@ func (d *Database) AcmeChallenges() (coll *Collection) {
 	return
 }
 
+func (d *Database) Pki() (coll *Collection) {
+	coll = d.getCollection("pki")
+	return
+}
+
 func (d *Database) Logs() (coll *Collection) {
 	coll = d.getCollection("logs")
 	return
@@ -480,6 +505,18 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.SerialTokens(),
+		Keys: &bson.D{
+			{"timestamp", 1},
+		},
+		Expire: 5 * time.Minute,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Rokeys(),
 		Keys: &bson.D{
@@ -790,6 +827,85 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.Gateways(),
+		Keys: &bson.D{
+			{"name", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.Gateways(),
+		Keys: &bson.D{
+			{"organization", 1},
+			{"name", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.Gateways(),
+		Keys: &bson.D{
+			{"vpc", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.Gateways(),
+		Keys: &bson.D{
+			{"datacenter", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.FloatingIps(),
+		Keys: &bson.D{
+			{"organization", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.FloatingIps(),
+		Keys: &bson.D{
+			{"zone", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.FloatingIps(),
+		Keys: &bson.D{
+			{"instance", 1},
+		},
+		Partial: &bson.M{
+			"instance": &bson.M{
+				"$exists": true,
+			},
+		},
+		Unique: true,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Sessions(),
 		Keys: &bson.D{
@@ -801,6 +917,41 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.VpnClients(),
+		Keys: &bson.D{
+			{"vpc", 1},
+			{"address", 1},
+		},
+		Unique: true,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.VpnClients(),
+		Keys: &bson.D{
+			{"user", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.VpnClients(),
+		Keys: &bson.D{
+			{"organization", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Firewalls(),
 		Keys: &bson.D{
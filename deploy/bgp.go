@@ -0,0 +1,90 @@
+package deploy
+
+import (
+	"net"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-cloud/bgp"
+	"github.com/pritunl/pritunl-cloud/state"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+type Bgp struct {
+	stat *state.State
+}
+
+func (b *Bgp) Deploy() (err error) {
+	nodeSelf := b.stat.Node()
+
+	prefixes := []string{}
+	prefixesSet := set.NewSet()
+	addPrefix := func(prefix string) {
+		if !prefixesSet.Contains(prefix) {
+			prefixesSet.Add(prefix)
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	addAddr := func(addr string) {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return
+		}
+
+		if ip.To4() != nil {
+			addPrefix(ip.String() + "/32")
+		} else {
+			addPrefix(ip.String() + "/128")
+		}
+	}
+
+	if nodeSelf.IsBgp() {
+		vpcIds := set.NewSet()
+
+		for _, inst := range b.stat.Instances() {
+			virt := b.stat.GetVirt(inst.Id)
+			if virt == nil || virt.State != vm.Running {
+				continue
+			}
+
+			for _, addr := range inst.PublicIps {
+				addAddr(addr)
+			}
+			for _, addr := range inst.PublicIps6 {
+				addAddr(addr)
+			}
+
+			fip := b.stat.InstanceFloatingIp(inst.Id)
+			if fip != nil && fip.Ip != "" {
+				addAddr(fip.Ip)
+			}
+
+			vpcIds.Add(inst.Vpc)
+			for _, iface := range inst.NetworkInterfaces {
+				vpcIds.Add(iface.Vpc)
+			}
+		}
+
+		for _, vc := range b.stat.Vpcs() {
+			if !vpcIds.Contains(vc.Id) {
+				continue
+			}
+
+			for _, prefix := range vc.BgpPrefixes {
+				addPrefix(prefix)
+			}
+		}
+	}
+
+	err = bgp.ApplyState(nodeSelf, prefixes)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func NewBgp(stat *state.State) *Bgp {
+	return &Bgp{
+		stat: stat,
+	}
+}
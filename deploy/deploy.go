@@ -52,11 +52,29 @@ func Deploy(stat *state.State) (err error) {
 		return
 	}
 
-	//ispec := NewIpsec(stat)
-	//err = ispec.Deploy()
-	//if err != nil {
-	//	return
-	//}
+	gateways := NewGateways(stat)
+	err = gateways.Deploy()
+	if err != nil {
+		return
+	}
+
+	resolvers := NewResolvers(stat)
+	err = resolvers.Deploy()
+	if err != nil {
+		return
+	}
+
+	ispec := NewIpsec(stat)
+	err = ispec.Deploy()
+	if err != nil {
+		return
+	}
+
+	bgp := NewBgp(stat)
+	err = bgp.Deploy()
+	if err != nil {
+		return
+	}
 
 	return
 }
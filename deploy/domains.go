@@ -1,50 +1,133 @@
 package deploy
 
 import (
+	"strings"
 	"time"
 
-	"github.com/sirupsen/logrus"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/domain"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/state"
+	"github.com/sirupsen/logrus"
 )
 
 type Domains struct {
 	stat *state.State
 }
 
-func (d *Domains) create(db *database.Database, inst *instance.Instance) {
-	pubAddr6 := ""
+func (d *Domains) getAddrs(inst *instance.Instance) (pubAddr,
+	pubAddr6 string) {
+
+	fip := d.stat.InstanceFloatingIp(inst.Id)
+	if fip != nil {
+		pubAddr = fip.Ip
+	}
+
 	if inst.PublicIps6 != nil && len(inst.PublicIps6) > 0 {
 		pubAddr6 = inst.PublicIps6[0]
 	}
 
-	if pubAddr6 == "" {
-		return
-	}
+	return
+}
 
-	logrus.WithFields(logrus.Fields{
-		"instance": inst.Id.Hex(),
-		"address6": pubAddr6,
-	}).Info("deploy: Creating domain record")
+func (d *Domains) newRecord(inst *instance.Instance,
+	domnId primitive.ObjectID, name, recordType string,
+	values ...string) *domain.Record {
 
-	recrd := &domain.Record{
+	return &domain.Record{
 		Organization: inst.Organization,
-		Domain:       inst.Domain,
+		Domain:       domnId,
 		Node:         node.Self.Id,
-		Name:         inst.Name,
 		Instance:     inst.Id,
-		Timestamp:    time.Now(),
+		Name:         name,
+		Type:         recordType,
+		Ttl:          inst.GetDomainTtl(),
+		Values:       values,
 	}
+}
+
+func (d *Domains) getRecords(inst *instance.Instance) (
+	recrds []*domain.Record) {
 
-	err := recrd.Upsert(db, "", pubAddr6)
+	recrds = []*domain.Record{}
+
+	if inst.Domain.IsZero() {
+		return
+	}
+
+	domn := d.stat.Domain(inst.Domain)
+	if domn == nil {
+		return
+	}
+
+	pubAddr, pubAddr6 := d.getAddrs(inst)
+	if pubAddr == "" && pubAddr6 == "" {
+		return
+	}
+
+	names := inst.GetDomainNames()
+	for _, name := range names {
+		if pubAddr != "" {
+			recrds = append(recrds, d.newRecord(
+				inst, domn.Id, name, "A", pubAddr))
+		}
+		if pubAddr6 != "" {
+			recrds = append(recrds, d.newRecord(
+				inst, domn.Id, name, "AAAA", pubAddr6))
+		}
+	}
+
+	target := strings.ToLower(names[0] + "." + domn.Name + ".")
+
+	for _, alias := range inst.DomainAliases {
+		recrds = append(recrds, d.newRecord(
+			inst, domn.Id, alias, "CNAME", target))
+	}
+
+	if inst.DomainPtr {
+		for _, addr := range []string{pubAddr, pubAddr6} {
+			if addr == "" {
+				continue
+			}
+
+			fqdn, ok := domain.ReverseName(addr)
+			if !ok {
+				continue
+			}
+
+			revDomn, name := domain.Match(
+				d.stat.OrgDomains(inst.Organization), fqdn)
+			if revDomn == nil {
+				continue
+			}
+
+			recrds = append(recrds, d.newRecord(
+				inst, revDomn.Id, name, "PTR", target))
+		}
+	}
+
+	return
+}
+
+func (d *Domains) upsert(db *database.Database, recrd *domain.Record) {
+	logrus.WithFields(logrus.Fields{
+		"instance": recrd.Instance.Hex(),
+		"name":     recrd.Name,
+		"type":     recrd.Type,
+		"values":   recrd.Values,
+	}).Info("deploy: Updating domain record")
+
+	_, err := recrd.Upsert(db)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"instance": recrd.Instance.Hex(),
+			"name":     recrd.Name,
+			"type":     recrd.Type,
 			"error":    err,
-		}).Error("deploy: Failed to create domain record")
+		}).Error("deploy: Failed to update domain record")
 
 		return
 	}
@@ -52,27 +135,30 @@ func (d *Domains) create(db *database.Database, inst *instance.Instance) {
 	return
 }
 
-func (d *Domains) update(db *database.Database, recrd *domain.Record,
-	addr, addr6 string) {
-
-	logrus.WithFields(logrus.Fields{
-		"record":       recrd.Id.Hex(),
-		"instance":     recrd.Instance.Hex(),
-		"cur_address6": recrd.Address,
-		"new_address6": addr6,
-	}).Info("deploy: Updating domain record")
-
-	err := recrd.Upsert(db, addr, addr6)
+func (d *Domains) check(db *database.Database, recrd *domain.Record) {
+	changed, err := recrd.Upsert(db)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"record":   recrd.Id.Hex(),
 			"instance": recrd.Instance.Hex(),
+			"name":     recrd.Name,
+			"type":     recrd.Type,
 			"error":    err,
-		}).Error("deploy: Failed to update domain record")
+		}).Error("deploy: Failed to check domain record")
 
 		return
 	}
 
+	if changed {
+		logrus.WithFields(logrus.Fields{
+			"record":   recrd.Id.Hex(),
+			"instance": recrd.Instance.Hex(),
+			"name":     recrd.Name,
+			"type":     recrd.Type,
+			"values":   recrd.Values,
+		}).Warn("deploy: Corrected domain record drift")
+	}
+
 	return
 }
 
@@ -80,6 +166,8 @@ func (d *Domains) remove(db *database.Database, recrd *domain.Record) {
 	logrus.WithFields(logrus.Fields{
 		"record":   recrd.Id.Hex(),
 		"instance": recrd.Instance.Hex(),
+		"name":     recrd.Name,
+		"type":     recrd.Type,
 	}).Info("deploy: Removing domain record")
 
 	err := recrd.Remove(db)
@@ -107,51 +195,87 @@ func (d *Domains) remove(db *database.Database, recrd *domain.Record) {
 	return
 }
 
+func (d *Domains) syncStatic(db *database.Database, refresh time.Duration) {
+	domn, err := domain.ClaimSync(db, refresh)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("deploy: Failed to claim domain sync")
+		return
+	}
+
+	if domn == nil ||
+		(len(domn.Records) == 0 && len(domn.RecordsApplied) == 0) {
+
+		return
+	}
+
+	err = domn.SyncRecords(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"domain": domn.Name,
+			"error":  err,
+		}).Error("deploy: Failed to sync static domain records")
+		return
+	}
+
+	return
+}
+
 func (d *Domains) Deploy() (err error) {
 	db := database.GetDatabase()
 	defer db.Close()
 
+	refresh := time.Duration(
+		settings.Hypervisor.DomainRefresh) * time.Second
 	instances := d.stat.Instances()
 
 	for _, inst := range instances {
-		recrds := d.stat.DomainRecords(inst.Id)
-		if recrds != nil {
-			var curRecrd *domain.Record
-
-			for _, recrd := range recrds {
-				if curRecrd == nil &&
-					inst.Domain == recrd.Domain &&
-					inst.Name == recrd.Name {
-
-					curRecrd = recrd
-				} else {
-					d.remove(db, recrd)
-				}
-			}
+		recrds := d.getRecords(inst)
 
-			if curRecrd != nil {
-				pubAddr6 := ""
-				if inst.PublicIps6 != nil && len(inst.PublicIps6) > 0 {
-					pubAddr6 = inst.PublicIps6[0]
-				}
+		recrdsMap := map[string]*domain.Record{}
+		for _, recrd := range recrds {
+			recrdsMap[recrd.Key()] = recrd
+		}
 
-				if pubAddr6 == "" {
-					d.remove(db, curRecrd)
-					continue
-				} else if pubAddr6 != curRecrd.Address6 {
-					d.update(db, curRecrd, "", pubAddr6)
-					continue
-				}
+		curRecrdsMap := map[string]*domain.Record{}
+		for _, recrd := range d.stat.DomainRecords(inst.Id) {
+			key := recrd.Key()
 
+			if recrdsMap[key] == nil || curRecrdsMap[key] != nil {
+				d.remove(db, recrd)
 				continue
 			}
+
+			curRecrdsMap[key] = recrd
 		}
 
-		if !inst.Domain.IsZero() {
-			d.create(db, inst)
+		for _, recrd := range recrds {
+			curRecrd := curRecrdsMap[recrd.Key()]
+			if curRecrd == nil {
+				d.upsert(db, recrd)
+				continue
+			}
+
+			if curRecrd.Ttl != recrd.Ttl ||
+				strings.Join(curRecrd.Values, ",") !=
+					strings.Join(recrd.Values, ",") {
+
+				curRecrd.Ttl = recrd.Ttl
+				curRecrd.Values = recrd.Values
+				d.upsert(db, curRecrd)
+				continue
+			}
+
+			if time.Since(curRecrd.Timestamp) > refresh {
+				d.check(db, curRecrd)
+				continue
+			}
 		}
 	}
 
+	d.syncStatic(db, refresh)
+
 	return
 }
 
@@ -0,0 +1,115 @@
+package deploy
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/gateway"
+	"github.com/pritunl/pritunl-cloud/interfaces"
+	"github.com/pritunl/pritunl-cloud/nat"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/state"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/sirupsen/logrus"
+)
+
+type Gateways struct {
+	stat *state.State
+}
+
+func (g *Gateways) Deploy() (err error) {
+	db := database.GetDatabase()
+	defer db.Close()
+
+	nodeSelf := g.stat.Node()
+	curNamespaces := set.NewSet()
+	curVirtIfaces := set.NewSet()
+	newState := []*gateway.Gateway{}
+
+	for _, gw := range g.stat.Gateways() {
+		if !gw.IsHost(nodeSelf) {
+			continue
+		}
+
+		if !gw.Node.IsZero() && gw.Node != node.Self.Id &&
+			time.Since(gw.Timestamp) < time.Duration(
+				settings.Hypervisor.GatewayTimeout)*time.Second {
+
+			continue
+		}
+
+		if gw.Node == node.Self.Id {
+			_, err = gw.SyncAddresses(db)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"gateway_id": gw.Id.Hex(),
+					"error":      err,
+				}).Error("deploy: Failed to sync gateway addresses")
+				err = nil
+				continue
+			}
+
+			err = gw.SyncPrivateIp(db)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"gateway_id": gw.Id.Hex(),
+					"error":      err,
+				}).Error("deploy: Failed to sync gateway private address")
+				err = nil
+				continue
+			}
+		}
+
+		curNamespaces.Add(vm.GetGatewayNamespace(gw.Id, 0))
+		curVirtIfaces.Add(vm.GetGatewayIfaceVirt(gw.Id, 0))
+		curVirtIfaces.Add(vm.GetGatewayIfaceVirt(gw.Id, 1))
+
+		newState = append(newState, gw)
+	}
+
+	nat.ApplyState(newState)
+
+	ifaces := g.stat.Interfaces()
+	for _, iface := range ifaces {
+		if len(iface) != 14 || !strings.HasPrefix(iface, "u") {
+			continue
+		}
+
+		if !curVirtIfaces.Contains(iface) {
+			utils.ExecCombinedOutputLogged(
+				nil,
+				"ip", "link", "del", iface,
+			)
+			interfaces.RemoveVirtIface(iface)
+		}
+	}
+
+	namespaces := g.stat.Namespaces()
+	for _, namespace := range namespaces {
+		if len(namespace) != 14 || !strings.HasPrefix(namespace, "g") {
+			continue
+		}
+
+		if !curNamespaces.Contains(namespace) {
+			_, err = utils.ExecCombinedOutputLogged(
+				nil,
+				"ip", "netns", "del", namespace,
+			)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func NewGateways(stat *state.State) *Gateways {
+	return &Gateways{
+		stat: stat,
+	}
+}
@@ -7,12 +7,14 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/cloudinit"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/disk"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/pki"
 	"github.com/pritunl/pritunl-cloud/qemu"
 	"github.com/pritunl/pritunl-cloud/qms"
 	"github.com/pritunl/pritunl-cloud/state"
@@ -317,6 +319,8 @@ func (s *Instances) destroy(inst *instance.Instance) {
 			return
 		}
 
+		cloudinit.ClearGuestCertRenew(inst.Id)
+
 		err = instance.Remove(db, inst.Id)
 		if err != nil {
 			if _, ok := err.(*database.NotFoundError); ok {
@@ -418,6 +422,22 @@ func (s *Instances) check(inst *instance.Instance, namespaces set.Set) (
 	return
 }
 
+func (s *Instances) guestCert(inst *instance.Instance) {
+	go func() {
+		db := database.GetDatabase()
+		defer db.Close()
+
+		err := cloudinit.RenewGuestCert(db, inst)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to renew instance guest certificate")
+			return
+		}
+	}()
+}
+
 func (s *Instances) routes(inst *instance.Instance) (err error) {
 	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
 	if !acquired {
@@ -562,6 +582,115 @@ func (s *Instances) routes(inst *instance.Instance) (err error) {
 		if changed {
 			store.RemRoutes(inst.Id)
 		}
+
+		gatewayIp := ""
+		gw := s.stat.GetSubnetGateway(inst.Vpc, inst.Subnet)
+		if gw != nil && len(inst.PrivateIps) > 0 {
+			gatewayIp = gw.PrivateIp
+		}
+
+		curGatewayIp := ""
+		gatewayStore, ok := store.GetGateway(inst.Id)
+		if !ok {
+			curGatewayIp, err = qemu.GetNatGateway(inst.Id)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"error": err,
+				}).Error("deploy: Failed to deploy instance gateway")
+				return
+			}
+		} else {
+			curGatewayIp = gatewayStore.Gateway
+		}
+
+		if curGatewayIp != gatewayIp {
+			if gatewayIp == "" {
+				err = qemu.ClearNatGateway(inst.Id)
+			} else {
+				err = qemu.SetNatGateway(
+					inst.Id, inst.PrivateIps[0], gatewayIp)
+			}
+			if err != nil {
+				store.RemGateway(inst.Id)
+				logrus.WithFields(logrus.Fields{
+					"error": err,
+				}).Error("deploy: Failed to deploy instance gateway")
+				return
+			}
+		}
+
+		store.SetGateway(inst.Id, gatewayIp)
+
+		floatAddr := ""
+		fip := s.stat.InstanceFloatingIp(inst.Id)
+		if fip != nil && fip.Ip != "" && len(inst.PrivateIps) > 0 {
+			blockIface := s.stat.Node().GetBlockInterface(fip.Block)
+
+			if inst.NoPublicAddress {
+				logrus.WithFields(logrus.Fields{
+					"instance_id":    inst.Id.Hex(),
+					"floating_ip_id": fip.Id.Hex(),
+				}).Warn("deploy: Floating IP on instance without public address")
+			} else if blockIface == "" {
+				logrus.WithFields(logrus.Fields{
+					"instance_id":    inst.Id.Hex(),
+					"floating_ip_id": fip.Id.Hex(),
+				}).Warn("deploy: Floating IP block not attached to node")
+			} else {
+				externalBridge, e := qemu.GetExternalBridge(inst.Id)
+				if e != nil {
+					err = e
+					logrus.WithFields(logrus.Fields{
+						"error": err,
+					}).Error("deploy: Failed to deploy instance floating IP")
+					return
+				}
+
+				if externalBridge == blockIface {
+					floatAddr = fip.Ip
+				} else {
+					logrus.WithFields(logrus.Fields{
+						"instance_id":     inst.Id.Hex(),
+						"floating_ip_id":  fip.Id.Hex(),
+						"external_bridge": externalBridge,
+						"block_interface": blockIface,
+					}).Warn("deploy: Floating IP block not on " +
+						"instance external interface")
+				}
+			}
+		}
+
+		curFloatAddr := ""
+		floatingStore, ok := store.GetFloating(inst.Id)
+		if !ok {
+			curFloatAddr, err = qemu.GetFloatingIp(inst.Id)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"error": err,
+				}).Error("deploy: Failed to deploy instance floating IP")
+				return
+			}
+		} else {
+			curFloatAddr = floatingStore.Address
+		}
+
+		if curFloatAddr != floatAddr {
+			if floatAddr == "" {
+				err = qemu.ClearFloatingIp(inst.Id)
+			} else {
+				err = qemu.SetFloatingIp(
+					inst.Id, inst.PrivateIps[0], floatAddr)
+			}
+			if err != nil {
+				store.RemFloating(inst.Id)
+				logrus.WithFields(logrus.Fields{
+					"error": err,
+				}).Error("deploy: Failed to deploy instance floating IP")
+				return
+			}
+		}
+
+		store.SetFloating(inst.Id, floatAddr)
 	}()
 
 	return
@@ -653,6 +782,12 @@ func (s *Instances) Deploy() (err error) {
 				return
 			}
 
+			if inst.GuestCertificate && pki.Enabled() &&
+				cloudinit.ClaimGuestCertRenew(inst.Id) {
+
+				s.guestCert(inst)
+			}
+
 			break
 		case instance.Cleanup:
 			s.cleanup(inst)
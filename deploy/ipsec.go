@@ -41,7 +41,7 @@ func (t *Ipsec) Deploy() (err error) {
 	vpcs := t.stat.Vpcs()
 	if nodeSelf.IsIpsec() {
 		for _, vc := range vpcs {
-			if vc.LinkUris == nil || len(vc.LinkUris) == 0 {
+			if !vc.HasLinks() {
 				continue
 			}
 
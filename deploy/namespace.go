@@ -76,6 +76,17 @@ func (n *Namespace) Deploy() (err error) {
 		if externalNetwork6 {
 			curExternalIfaces.Add(vm.GetIfaceExternal(inst.Id, 1))
 		}
+
+		for i, iface := range inst.NetworkInterfaces {
+			curNamespaces.Add(vm.GetNamespace(inst.Id, i+1))
+			curVirtIfaces.Add(vm.GetAdapterIfaceVirtInternal(inst.Id, i+1))
+			if iface.PublicAddress {
+				curVirtIfaces.Add(
+					vm.GetAdapterIfaceVirtExternal(inst.Id, i+1))
+				curExternalIfaces.Add(
+					vm.GetAdapterIfaceExternal(inst.Id, i+1))
+			}
+		}
 	}
 
 	for _, iface := range ifaces {
@@ -0,0 +1,102 @@
+package deploy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/resolver"
+	"github.com/pritunl/pritunl-cloud/state"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/sirupsen/logrus"
+)
+
+type Resolvers struct {
+	stat *state.State
+}
+
+func (r *Resolvers) clean(curInsts set.Set) (err error) {
+	items, err := ioutil.ReadDir(paths.GetResolversPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		} else {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "deploy: Failed to read resolvers directory"),
+			}
+		}
+		return
+	}
+
+	for _, item := range items {
+		name := item.Name()
+		if filepath.Ext(name) != ".conf" {
+			continue
+		}
+
+		instId, e := primitive.ObjectIDFromHex(strings.TrimSuffix(
+			name, ".conf"))
+		if e != nil || curInsts.Contains(instId) {
+			continue
+		}
+
+		err = resolver.Remove(instId)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (r *Resolvers) Deploy() (err error) {
+	curInsts := set.NewSet()
+	namespaces := set.NewSet()
+	for _, namespace := range r.stat.Namespaces() {
+		namespaces.Add(namespace)
+	}
+
+	for _, inst := range r.stat.Instances() {
+		virt := r.stat.GetVirt(inst.Id)
+		if virt == nil || virt.State != vm.Running ||
+			inst.NetworkNamespace == "" ||
+			!namespaces.Contains(inst.NetworkNamespace) {
+
+			continue
+		}
+
+		vc := r.stat.Vpc(inst.Vpc)
+		if vc == nil {
+			continue
+		}
+
+		curInsts.Add(inst.Id)
+
+		e := resolver.Update(inst, vc, r.stat.VpcInstances(inst.Vpc))
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance": inst.Id.Hex(),
+				"error":    e,
+			}).Error("deploy: Failed to update instance resolver")
+		}
+	}
+
+	err = r.clean(curInsts)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func NewResolvers(stat *state.State) *Resolvers {
+	return &Resolvers{
+		stat: stat,
+	}
+}
@@ -11,11 +11,11 @@ import (
 	"github.com/pritunl/pritunl-cloud/errortypes"
 )
 
-type awsProvider struct {
+type awsCredentials struct {
 	domain *Domain
 }
 
-func (p *awsProvider) Retrieve() (val credentials.Value, err error) {
+func (p *awsCredentials) Retrieve() (val credentials.Value, err error) {
 	val = credentials.Value{
 		AccessKeyID:     p.domain.AwsId,
 		SecretAccessKey: p.domain.AwsSecret,
@@ -24,12 +24,12 @@ func (p *awsProvider) Retrieve() (val credentials.Value, err error) {
 	return
 }
 
-func (p *awsProvider) IsExpired() bool {
+func (p *awsCredentials) IsExpired() bool {
 	return false
 }
 
 func awsGetSession(domain *Domain) (sess *session.Session, err error) {
-	prov := &awsProvider{
+	prov := &awsCredentials{
 		domain: domain,
 	}
 
@@ -55,15 +55,21 @@ func awsGetSession(domain *Domain) (sess *session.Session, err error) {
 	return
 }
 
-func AwsUpsertDomain(domain *Domain, name, addr, addr6 string) (err error) {
+type route53Provider struct {
+	servc    *route53.Route53
+	zoneId   string
+	zoneName string
+}
+
+func (p *route53Provider) Connect(domain *Domain) (err error) {
 	sess, err := awsGetSession(domain)
 	if err != nil {
 		return
 	}
 
-	servc := route53.New(sess)
+	p.servc = route53.New(sess)
 
-	zones, err := servc.ListHostedZonesByName(nil)
+	zones, err := p.servc.ListHostedZonesByName(nil)
 	if err != nil {
 		err = &errortypes.RequestError{
 			errors.Wrap(err, "domain: Failed to list Route53 zones"),
@@ -71,29 +77,35 @@ func AwsUpsertDomain(domain *Domain, name, addr, addr6 string) (err error) {
 		return
 	}
 
-	zoneId := ""
-	zoneName := ""
 	for _, zone := range zones.HostedZones {
 		if strings.TrimRight(*zone.Name, ".") != domain.Name {
 			continue
 		}
 
-		zoneId = *zone.Id
-		zoneName = *zone.Name
+		p.zoneId = *zone.Id
+		p.zoneName = *zone.Name
 	}
 
-	if zoneId == "" {
+	if p.zoneId == "" {
 		err = &errortypes.RequestError{
-			errors.Wrap(err, "domain: Failed to find Route53 zone"),
+			errors.New("domain: Failed to find Route53 zone"),
 		}
 		return
 	}
 
-	recordName := name + "." + zoneName
+	return
+}
 
-	records, err := servc.ListResourceRecordSets(
+func (p *route53Provider) getRecordSet(fqdn, recordType string) (
+	recordSet *route53.ResourceRecordSet, err error) {
+
+	recordName := fqdn + "."
+
+	records, err := p.servc.ListResourceRecordSets(
 		&route53.ListResourceRecordSetsInput{
-			HostedZoneId: &zoneId,
+			HostedZoneId:    &p.zoneId,
+			StartRecordName: &recordName,
+			StartRecordType: &recordType,
 		},
 	)
 	if err != nil {
@@ -103,145 +115,103 @@ func AwsUpsertDomain(domain *Domain, name, addr, addr6 string) (err error) {
 		return
 	}
 
-	curAddrs := []string{}
-	curAddrs6 := []string{}
 	for _, record := range records.ResourceRecordSets {
-		if (*record.Type != "A" && *record.Type != "AAAA") ||
-			*record.Name != recordName {
-
+		if *record.Type != recordType || *record.Name != recordName {
 			continue
 		}
 
-		for _, resource := range record.ResourceRecords {
-			if *record.Type == "A" {
-				curAddrs = append(curAddrs, *resource.Value)
-			} else if *record.Type == "AAAA" {
-				curAddrs6 = append(curAddrs6, *resource.Value)
-			}
-		}
+		recordSet = record
+		return
 	}
 
-	changes := []*route53.Change{}
-
-	if len(curAddrs) != 1 || curAddrs[0] != addr {
-		if addr == "" && len(curAddrs) > 0 {
-			action := "DELETE"
-			recordSetType := "A"
-			recordSetTtl := int64(60)
-			records := []*route53.ResourceRecord{}
-
-			for _, a := range curAddrs {
-				records = append(records, &route53.ResourceRecord{
-					Value: &a,
-				})
-			}
-
-			recordSet := &route53.ResourceRecordSet{
-				Name:            &recordName,
-				Type:            &recordSetType,
-				TTL:             &recordSetTtl,
-				ResourceRecords: records,
-			}
-
-			change := &route53.Change{
-				Action:            &action,
-				ResourceRecordSet: recordSet,
-			}
-
-			changes = append(changes, change)
-		} else if addr != "" {
-			action := "UPSERT"
-			recordSetType := "A"
-			recordSetTtl := int64(60)
-			records := []*route53.ResourceRecord{
-				&route53.ResourceRecord{
-					Value: &addr,
-				},
-			}
+	return
+}
 
-			recordSet := &route53.ResourceRecordSet{
-				Name:            &recordName,
-				Type:            &recordSetType,
-				TTL:             &recordSetTtl,
-				ResourceRecords: records,
-			}
+func (p *route53Provider) GetRecords(fqdn, recordType string) (
+	values []string, ttl int, err error) {
 
-			change := &route53.Change{
-				Action:            &action,
-				ResourceRecordSet: recordSet,
-			}
+	values = []string{}
 
-			changes = append(changes, change)
-		}
+	recordSet, err := p.getRecordSet(fqdn, recordType)
+	if err != nil || recordSet == nil {
+		return
 	}
 
-	if len(curAddrs6) != 1 || curAddrs6[0] != addr6 {
-		if addr6 == "" && len(curAddrs6) > 0 {
-			action := "DELETE"
-			recordSetType := "AAAA"
-			recordSetTtl := int64(60)
-			records := []*route53.ResourceRecord{}
-
-			for _, a6 := range curAddrs6 {
-				records = append(records, &route53.ResourceRecord{
-					Value: &a6,
-				})
-			}
-
-			recordSet := &route53.ResourceRecordSet{
-				Name:            &recordName,
-				Type:            &recordSetType,
-				TTL:             &recordSetTtl,
-				ResourceRecords: records,
-			}
-
-			change := &route53.Change{
-				Action:            &action,
-				ResourceRecordSet: recordSet,
-			}
-
-			changes = append(changes, change)
-		} else if addr6 != "" {
-			action := "UPSERT"
-			recordSetType := "AAAA"
-			recordSetTtl := int64(60)
-			records := []*route53.ResourceRecord{
-				&route53.ResourceRecord{
-					Value: &addr6,
-				},
-			}
-
-			recordSet := &route53.ResourceRecordSet{
-				Name:            &recordName,
-				Type:            &recordSetType,
-				TTL:             &recordSetTtl,
-				ResourceRecords: records,
-			}
-
-			change := &route53.Change{
-				Action:            &action,
-				ResourceRecordSet: recordSet,
-			}
+	if recordSet.TTL != nil {
+		ttl = int(*recordSet.TTL)
+	}
 
-			changes = append(changes, change)
-		}
+	for _, resource := range recordSet.ResourceRecords {
+		values = append(values, parseValue(recordType, *resource.Value))
 	}
 
-	if len(changes) > 0 {
-		_, err = servc.ChangeResourceRecordSets(
-			&route53.ChangeResourceRecordSetsInput{
-				HostedZoneId: &zoneId,
-				ChangeBatch: &route53.ChangeBatch{
-					Changes: changes,
+	return
+}
+
+func (p *route53Provider) change(action string,
+	recordSet *route53.ResourceRecordSet) (err error) {
+
+	_, err = p.servc.ChangeResourceRecordSets(
+		&route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: &p.zoneId,
+			ChangeBatch: &route53.ChangeBatch{
+				Changes: []*route53.Change{
+					&route53.Change{
+						Action:            &action,
+						ResourceRecordSet: recordSet,
+					},
 				},
 			},
-		)
-		if err != nil {
-			err = &errortypes.RequestError{
-				errors.Wrap(err, "domain: Failed to list Route53 records"),
-			}
-			return
+		},
+	)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: Failed to update Route53 records"),
 		}
+		return
+	}
+
+	return
+}
+
+func (p *route53Provider) SetRecords(fqdn, recordType string, ttl int,
+	values []string) (err error) {
+
+	recordName := fqdn + "."
+	recordTtl := int64(ttl)
+	records := []*route53.ResourceRecord{}
+
+	for _, value := range values {
+		val := formatValue(recordType, value)
+		records = append(records, &route53.ResourceRecord{
+			Value: &val,
+		})
+	}
+
+	err = p.change("UPSERT", &route53.ResourceRecordSet{
+		Name:            &recordName,
+		Type:            &recordType,
+		TTL:             &recordTtl,
+		ResourceRecords: records,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *route53Provider) DeleteRecords(fqdn, recordType string) (
+	err error) {
+
+	recordSet, err := p.getRecordSet(fqdn, recordType)
+	if err != nil || recordSet == nil {
+		return
+	}
+
+	err = p.change("DELETE", recordSet)
+	if err != nil {
+		return
 	}
 
 	return
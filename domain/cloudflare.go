@@ -0,0 +1,318 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+const cloudflareApi = "https://api.cloudflare.com/client/v4"
+
+var (
+	cloudflareClient = &http.Client{
+		Timeout: providerTimeout,
+	}
+)
+
+type cloudflareError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type cloudflareResponse struct {
+	Success bool               `json:"success"`
+	Errors  []*cloudflareError `json:"errors"`
+	Result  json.RawMessage    `json:"result"`
+}
+
+type cloudflareZone struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type cloudflareSrvData struct {
+	Priority int    `json:"priority"`
+	Weight   int    `json:"weight"`
+	Port     int    `json:"port"`
+	Target   string `json:"target"`
+}
+
+type cloudflareRecord struct {
+	Id       string             `json:"id,omitempty"`
+	Type     string             `json:"type"`
+	Name     string             `json:"name"`
+	Content  string             `json:"content,omitempty"`
+	Priority *int               `json:"priority,omitempty"`
+	Data     *cloudflareSrvData `json:"data,omitempty"`
+	Ttl      int                `json:"ttl"`
+}
+
+func (r *cloudflareRecord) value() string {
+	if (r.Type == "MX" || r.Type == "SRV") && r.Priority != nil {
+		return fmt.Sprintf("%d %s", *r.Priority, r.Content)
+	}
+	return r.Content
+}
+
+func newCloudflareRecord(fqdn, recordType string, ttl int,
+	value string) (record *cloudflareRecord, err error) {
+
+	record = &cloudflareRecord{
+		Type: recordType,
+		Name: fqdn,
+		Ttl:  ttl,
+	}
+
+	fields := strings.Fields(value)
+	switch recordType {
+	case "MX":
+		if len(fields) != 2 {
+			break
+		}
+
+		priority, e := strconv.Atoi(fields[0])
+		if e != nil {
+			break
+		}
+
+		record.Priority = &priority
+		record.Content = strings.TrimSuffix(fields[1], ".")
+		return
+	case "SRV":
+		if len(fields) != 4 {
+			break
+		}
+
+		nums := []int{}
+		for _, field := range fields[:3] {
+			num, e := strconv.Atoi(field)
+			if e != nil {
+				break
+			}
+			nums = append(nums, num)
+		}
+		if len(nums) != 3 {
+			break
+		}
+
+		record.Data = &cloudflareSrvData{
+			Priority: nums[0],
+			Weight:   nums[1],
+			Port:     nums[2],
+			Target:   strings.TrimSuffix(fields[3], "."),
+		}
+		return
+	default:
+		record.Content = formatValue(recordType, value)
+		return
+	}
+
+	err = &errortypes.ParseError{
+		errors.Newf("domain: Invalid %s record value '%s'",
+			recordType, value),
+	}
+	return
+}
+
+type cloudflareProvider struct {
+	token  string
+	zoneId string
+}
+
+func (p *cloudflareProvider) request(method, path string, input,
+	output interface{}) (err error) {
+
+	var body *bytes.Buffer
+	if input != nil {
+		data, e := json.Marshal(input)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "domain: Failed to marshal Cloudflare request"),
+			}
+			return
+		}
+		body = bytes.NewBuffer(data)
+	} else {
+		body = &bytes.Buffer{}
+	}
+
+	req, err := http.NewRequest(method, cloudflareApi+path, body)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: Cloudflare request failed"),
+		}
+		return
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.token)
+
+	resp, err := cloudflareClient.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: Cloudflare request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "domain: Failed to read Cloudflare response"),
+		}
+		return
+	}
+
+	cfResp := &cloudflareResponse{}
+	err = json.Unmarshal(data, cfResp)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrapf(err, "domain: Cloudflare bad response %d",
+				resp.StatusCode),
+		}
+		return
+	}
+
+	if resp.StatusCode != 200 || !cfResp.Success {
+		msgs := []string{}
+		for _, cfErr := range cfResp.Errors {
+			msgs = append(msgs, fmt.Sprintf("%d %s",
+				cfErr.Code, cfErr.Message))
+		}
+
+		err = &errortypes.RequestError{
+			errors.Newf("domain: Cloudflare request bad status %d '%s'",
+				resp.StatusCode, strings.Join(msgs, ", ")),
+		}
+		return
+	}
+
+	if output != nil {
+		err = json.Unmarshal(cfResp.Result, output)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "domain: Failed to parse Cloudflare result"),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func (p *cloudflareProvider) Connect(domain *Domain) (err error) {
+	p.token = domain.CloudflareToken
+
+	zones := []*cloudflareZone{}
+	err = p.request("GET", "/zones?name="+url.QueryEscape(domain.Name),
+		nil, &zones)
+	if err != nil {
+		return
+	}
+
+	for _, zone := range zones {
+		if zone.Name == domain.Name {
+			p.zoneId = zone.Id
+			break
+		}
+	}
+
+	if p.zoneId == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("domain: Failed to find Cloudflare zone"),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *cloudflareProvider) getRecords(fqdn, recordType string) (
+	records []*cloudflareRecord, err error) {
+
+	query := url.Values{}
+	query.Set("type", recordType)
+	query.Set("name", fqdn)
+	query.Set("per_page", "100")
+
+	records = []*cloudflareRecord{}
+	err = p.request("GET", fmt.Sprintf("/zones/%s/dns_records?%s",
+		p.zoneId, query.Encode()), nil, &records)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *cloudflareProvider) GetRecords(fqdn, recordType string) (
+	values []string, ttl int, err error) {
+
+	values = []string{}
+
+	records, err := p.getRecords(fqdn, recordType)
+	if err != nil {
+		return
+	}
+
+	for _, record := range records {
+		ttl = record.Ttl
+		values = append(values, parseValue(recordType, record.value()))
+	}
+
+	return
+}
+
+func (p *cloudflareProvider) SetRecords(fqdn, recordType string, ttl int,
+	values []string) (err error) {
+
+	err = p.DeleteRecords(fqdn, recordType)
+	if err != nil {
+		return
+	}
+
+	for _, value := range values {
+		record, e := newCloudflareRecord(fqdn, recordType, ttl, value)
+		if e != nil {
+			err = e
+			return
+		}
+
+		err = p.request("POST", fmt.Sprintf("/zones/%s/dns_records",
+			p.zoneId), record, nil)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (p *cloudflareProvider) DeleteRecords(fqdn, recordType string) (
+	err error) {
+
+	records, err := p.getRecords(fqdn, recordType)
+	if err != nil {
+		return
+	}
+
+	for _, record := range records {
+		err = p.request("DELETE", fmt.Sprintf("/zones/%s/dns_records/%s",
+			p.zoneId, record.Id), nil, nil)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -1,5 +1,33 @@
 package domain
 
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+)
+
 const (
-	Route53 = "route_53"
+	Route53     = "route_53"
+	Cloudflare  = "cloudflare"
+	GoogleCloud = "google_cloud"
+	PowerDns    = "powerdns"
+	Rfc2136     = "rfc2136"
+
+	DefaultTtl = 60
+	MinTtl     = 30
+	MaxTtl     = 86400
+
+	Rfc2136HmacSha1   = "hmac-sha1"
+	Rfc2136HmacSha256 = "hmac-sha256"
+	Rfc2136HmacSha512 = "hmac-sha512"
+
+	providerTimeout = 20 * time.Second
+)
+
+var (
+	StaticTypes = set.NewSet(
+		"TXT",
+		"MX",
+		"SRV",
+	)
 )
@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"strings"
+	"time"
+
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
@@ -9,13 +12,26 @@ import (
 )
 
 type Domain struct {
-	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name         string             `bson:"name" json:"name"`
-	Comment      string             `bson:"comment" json:"comment"`
-	Organization primitive.ObjectID `bson:"organization,omitempty" json:"organization"`
-	Type         string             `bson:"type" json:"type"`
-	AwsId        string             `bson:"aws_id" json:"aws_id"`
-	AwsSecret    string             `bson:"aws_secret" json:"aws_secret"`
+	Id               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name             string             `bson:"name" json:"name"`
+	Comment          string             `bson:"comment" json:"comment"`
+	Organization     primitive.ObjectID `bson:"organization,omitempty" json:"organization"`
+	Type             string             `bson:"type" json:"type"`
+	AwsId            string             `bson:"aws_id" json:"aws_id"`
+	AwsSecret        string             `bson:"aws_secret" json:"aws_secret"`
+	CloudflareToken  string             `bson:"cloudflare_token" json:"cloudflare_token"`
+	GoogleProject    string             `bson:"google_project" json:"google_project"`
+	GoogleKey        string             `bson:"google_key" json:"google_key"`
+	PowerDnsUrl      string             `bson:"powerdns_url" json:"powerdns_url"`
+	PowerDnsKey      string             `bson:"powerdns_key" json:"powerdns_key"`
+	PowerDnsServer   string             `bson:"powerdns_server" json:"powerdns_server"`
+	Rfc2136Server    string             `bson:"rfc2136_server" json:"rfc2136_server"`
+	Rfc2136KeyName   string             `bson:"rfc2136_key_name" json:"rfc2136_key_name"`
+	Rfc2136Secret    string             `bson:"rfc2136_secret" json:"rfc2136_secret"`
+	Rfc2136Algorithm string             `bson:"rfc2136_algorithm" json:"rfc2136_algorithm"`
+	Records          []*StaticRecord    `bson:"records" json:"records"`
+	RecordsApplied   []string           `bson:"records_applied" json:"-"`
+	RecordsChecked   time.Time          `bson:"records_checked" json:"-"`
 }
 
 func (d *Domain) Validate(db *database.Database) (
@@ -29,10 +45,131 @@ func (d *Domain) Validate(db *database.Database) (
 		return
 	}
 
-	if d.Type != Route53 {
+	d.Name = strings.ToLower(strings.TrimSuffix(
+		strings.TrimSpace(d.Name), "."))
+	if d.Name == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "name_required",
+			Message: "Missing required domain name",
+		}
+		return
+	}
+
+	if d.Type == "" {
 		d.Type = Route53
 	}
 
+	if d.Type != Route53 {
+		d.AwsId = ""
+		d.AwsSecret = ""
+	}
+	if d.Type != Cloudflare {
+		d.CloudflareToken = ""
+	}
+	if d.Type != GoogleCloud {
+		d.GoogleProject = ""
+		d.GoogleKey = ""
+	}
+	if d.Type != PowerDns {
+		d.PowerDnsUrl = ""
+		d.PowerDnsKey = ""
+		d.PowerDnsServer = ""
+	}
+	if d.Type != Rfc2136 {
+		d.Rfc2136Server = ""
+		d.Rfc2136KeyName = ""
+		d.Rfc2136Secret = ""
+		d.Rfc2136Algorithm = ""
+	}
+
+	switch d.Type {
+	case Route53:
+		break
+	case Cloudflare:
+		if d.CloudflareToken == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "cloudflare_token_required",
+				Message: "Missing required Cloudflare API token",
+			}
+			return
+		}
+		break
+	case GoogleCloud:
+		if d.GoogleProject == "" || d.GoogleKey == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "google_credentials_required",
+				Message: "Missing required Google project or service key",
+			}
+			return
+		}
+		break
+	case PowerDns:
+		if d.PowerDnsUrl == "" || d.PowerDnsKey == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "powerdns_credentials_required",
+				Message: "Missing required PowerDNS API URL or key",
+			}
+			return
+		}
+		break
+	case Rfc2136:
+		if d.Rfc2136Server == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "rfc2136_server_required",
+				Message: "Missing required RFC 2136 server",
+			}
+			return
+		}
+
+		if d.Rfc2136KeyName == "" {
+			d.Rfc2136Secret = ""
+			d.Rfc2136Algorithm = ""
+		} else {
+			if d.Rfc2136Secret == "" {
+				errData = &errortypes.ErrorData{
+					Error:   "rfc2136_secret_required",
+					Message: "Missing required RFC 2136 TSIG secret",
+				}
+				return
+			}
+
+			switch d.Rfc2136Algorithm {
+			case "":
+				d.Rfc2136Algorithm = Rfc2136HmacSha256
+				break
+			case Rfc2136HmacSha1, Rfc2136HmacSha256, Rfc2136HmacSha512:
+				break
+			default:
+				errData = &errortypes.ErrorData{
+					Error:   "rfc2136_algorithm_invalid",
+					Message: "Invalid RFC 2136 TSIG algorithm",
+				}
+				return
+			}
+		}
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "type_invalid",
+			Message: "Invalid domain type",
+		}
+		return
+	}
+
+	errData = d.ValidateRecords()
+	if errData != nil {
+		return
+	}
+
+	e := d.ValidateProvider()
+	if e != nil {
+		errData = &errortypes.ErrorData{
+			Error:   "provider_credentials_invalid",
+			Message: "Failed to verify DNS provider, " + e.Error(),
+		}
+		return
+	}
+
 	return
 }
 
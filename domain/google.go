@@ -0,0 +1,178 @@
+package domain
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/dns/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+type googleProvider struct {
+	servc   *dns.Service
+	project string
+	zone    string
+}
+
+func (p *googleProvider) Connect(domain *Domain) (err error) {
+	p.project = domain.GoogleProject
+
+	conf, err := google.JWTConfigFromJSON(
+		[]byte(domain.GoogleKey),
+		dns.NdevClouddnsReadwriteScope,
+	)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "domain: Failed to parse Google key"),
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(
+		context.Background(), providerTimeout)
+	defer cancel()
+
+	client := conf.Client(context.Background())
+	client.Timeout = providerTimeout
+
+	p.servc, err = dns.NewService(ctx, option.WithHTTPClient(client))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "domain: Failed to create Google DNS client"),
+		}
+		return
+	}
+
+	zones, err := p.servc.ManagedZones.List(p.project).DnsName(
+		domain.Name + ".").Context(ctx).Do()
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: Failed to list Google DNS zones"),
+		}
+		return
+	}
+
+	for _, zone := range zones.ManagedZones {
+		if zone.DnsName == domain.Name+"." {
+			p.zone = zone.Name
+			break
+		}
+	}
+
+	if p.zone == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("domain: Failed to find Google DNS zone"),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *googleProvider) getRecordSet(fqdn, recordType string) (
+	recordSet *dns.ResourceRecordSet, err error) {
+
+	recordSet, err = p.servc.ResourceRecordSets.Get(
+		p.project, p.zone, fqdn+".", recordType).Do()
+	if err != nil {
+		if gErr, ok := err.(*googleapi.Error); ok &&
+			gErr.Code == http.StatusNotFound {
+
+			recordSet = nil
+			err = nil
+			return
+		}
+
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: Failed to get Google DNS records"),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *googleProvider) GetRecords(fqdn, recordType string) (
+	values []string, ttl int, err error) {
+
+	values = []string{}
+
+	recordSet, err := p.getRecordSet(fqdn, recordType)
+	if err != nil || recordSet == nil {
+		return
+	}
+
+	ttl = int(recordSet.Ttl)
+	for _, rrdata := range recordSet.Rrdatas {
+		values = append(values, parseValue(recordType, rrdata))
+	}
+
+	return
+}
+
+func (p *googleProvider) SetRecords(fqdn, recordType string, ttl int,
+	values []string) (err error) {
+
+	rrdatas := []string{}
+	for _, value := range values {
+		rrdatas = append(rrdatas, formatValue(recordType, value))
+	}
+
+	recordSet, err := p.getRecordSet(fqdn, recordType)
+	if err != nil {
+		return
+	}
+
+	change := &dns.Change{
+		Additions: []*dns.ResourceRecordSet{
+			&dns.ResourceRecordSet{
+				Name:    fqdn + ".",
+				Type:    recordType,
+				Ttl:     int64(ttl),
+				Rrdatas: rrdatas,
+			},
+		},
+	}
+
+	if recordSet != nil {
+		change.Deletions = []*dns.ResourceRecordSet{
+			recordSet,
+		}
+	}
+
+	_, err = p.servc.Changes.Create(p.project, p.zone, change).Do()
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: Failed to update Google DNS records"),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *googleProvider) DeleteRecords(fqdn, recordType string) (
+	err error) {
+
+	_, err = p.servc.ResourceRecordSets.Delete(
+		p.project, p.zone, fqdn+".", recordType).Do()
+	if err != nil {
+		if gErr, ok := err.(*googleapi.Error); ok &&
+			gErr.Code == http.StatusNotFound {
+
+			err = nil
+			return
+		}
+
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: Failed to remove Google DNS records"),
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,214 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+var (
+	powerDnsClient = &http.Client{
+		Timeout: providerTimeout,
+	}
+)
+
+type powerDnsRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+type powerDnsRrset struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	Ttl        int               `json:"ttl,omitempty"`
+	ChangeType string            `json:"changetype,omitempty"`
+	Records    []*powerDnsRecord `json:"records"`
+}
+
+type powerDnsZone struct {
+	Id     string           `json:"id"`
+	Name   string           `json:"name"`
+	Rrsets []*powerDnsRrset `json:"rrsets"`
+}
+
+type powerDnsPatch struct {
+	Rrsets []*powerDnsRrset `json:"rrsets"`
+}
+
+type powerDnsProvider struct {
+	zoneUrl string
+	key     string
+}
+
+func (p *powerDnsProvider) request(method string, input,
+	output interface{}) (err error) {
+
+	body := &bytes.Buffer{}
+	if input != nil {
+		data, e := json.Marshal(input)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "domain: Failed to marshal PowerDNS request"),
+			}
+			return
+		}
+		body = bytes.NewBuffer(data)
+	}
+
+	req, err := http.NewRequest(method, p.zoneUrl, body)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: PowerDNS request failed"),
+		}
+		return
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", p.key)
+
+	resp, err := powerDnsClient.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: PowerDNS request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body := ""
+		data, _ := ioutil.ReadAll(resp.Body)
+		if data != nil {
+			body = strings.TrimSpace(string(data))
+		}
+
+		err = &errortypes.RequestError{
+			errors.Newf("domain: PowerDNS request bad status %d '%s'",
+				resp.StatusCode, body),
+		}
+		return
+	}
+
+	if output != nil {
+		err = json.NewDecoder(resp.Body).Decode(output)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "domain: Failed to parse PowerDNS response"),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func (p *powerDnsProvider) Connect(domain *Domain) (err error) {
+	apiUrl, err := url.Parse(strings.TrimRight(domain.PowerDnsUrl, "/"))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "domain: Failed to parse PowerDNS URL"),
+		}
+		return
+	}
+
+	serverId := domain.PowerDnsServer
+	if serverId == "" {
+		serverId = "localhost"
+	}
+
+	p.key = domain.PowerDnsKey
+	p.zoneUrl = apiUrl.String() + "/api/v1/servers/" +
+		url.PathEscape(serverId) + "/zones/" +
+		url.PathEscape(domain.Name+".")
+
+	zone := &powerDnsZone{}
+	err = p.request("GET", nil, zone)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *powerDnsProvider) GetRecords(fqdn, recordType string) (
+	values []string, ttl int, err error) {
+
+	values = []string{}
+
+	zone := &powerDnsZone{}
+	err = p.request("GET", nil, zone)
+	if err != nil {
+		return
+	}
+
+	for _, rrset := range zone.Rrsets {
+		if rrset.Type != recordType || rrset.Name != fqdn+"." {
+			continue
+		}
+
+		ttl = rrset.Ttl
+		for _, record := range rrset.Records {
+			if record.Disabled {
+				continue
+			}
+			values = append(values, parseValue(recordType, record.Content))
+		}
+	}
+
+	return
+}
+
+func (p *powerDnsProvider) SetRecords(fqdn, recordType string, ttl int,
+	values []string) (err error) {
+
+	records := []*powerDnsRecord{}
+	for _, value := range values {
+		records = append(records, &powerDnsRecord{
+			Content: formatValue(recordType, value),
+		})
+	}
+
+	err = p.request("PATCH", &powerDnsPatch{
+		Rrsets: []*powerDnsRrset{
+			&powerDnsRrset{
+				Name:       fqdn + ".",
+				Type:       recordType,
+				Ttl:        ttl,
+				ChangeType: "REPLACE",
+				Records:    records,
+			},
+		},
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *powerDnsProvider) DeleteRecords(fqdn, recordType string) (
+	err error) {
+
+	err = p.request("PATCH", &powerDnsPatch{
+		Rrsets: []*powerDnsRrset{
+			&powerDnsRrset{
+				Name:       fqdn + ".",
+				Type:       recordType,
+				ChangeType: "DELETE",
+				Records:    []*powerDnsRecord{},
+			},
+		},
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,174 @@
+package domain
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type provider interface {
+	Connect(domain *Domain) (err error)
+	GetRecords(fqdn, recordType string) (
+		values []string, ttl int, err error)
+	SetRecords(fqdn, recordType string, ttl int, values []string) (err error)
+	DeleteRecords(fqdn, recordType string) (err error)
+}
+
+func newProvider(domain *Domain) (prov provider, err error) {
+	switch domain.Type {
+	case Route53:
+		prov = &route53Provider{}
+		break
+	case Cloudflare:
+		prov = &cloudflareProvider{}
+		break
+	case GoogleCloud:
+		prov = &googleProvider{}
+		break
+	case PowerDns:
+		prov = &powerDnsProvider{}
+		break
+	case Rfc2136:
+		prov = &rfc2136Provider{}
+		break
+	default:
+		err = &errortypes.UnknownError{
+			errors.New("domain: Unknown domain type"),
+		}
+		return
+	}
+
+	err = prov.Connect(domain)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func formatValue(recordType, value string) string {
+	if recordType == "TXT" {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+func parseValue(recordType, value string) string {
+	switch recordType {
+	case "TXT":
+		val, err := strconv.Unquote(value)
+		if err == nil {
+			return val
+		}
+		break
+	case "CNAME", "PTR", "MX", "SRV":
+		value = strings.ToLower(value)
+		if !strings.HasSuffix(value, ".") {
+			value += "."
+		}
+		break
+	}
+	return value
+}
+
+func recordsEqual(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+
+	x = append([]string{}, x...)
+	y = append([]string{}, y...)
+	sort.Strings(x)
+	sort.Strings(y)
+
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (d *Domain) fqdn(name string) string {
+	zone := strings.TrimSuffix(d.Name, ".")
+	if name == "" || name == "@" {
+		return zone
+	}
+	return name + "." + zone
+}
+
+func (d *Domain) ValidateProvider() (err error) {
+	_, err = newProvider(d)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (d *Domain) SetRecords(name, recordType string, ttl int,
+	values []string) (changed bool, err error) {
+
+	prov, err := newProvider(d)
+	if err != nil {
+		return
+	}
+
+	fqdn := d.fqdn(name)
+
+	curValues, curTtl, err := prov.GetRecords(fqdn, recordType)
+	if err != nil {
+		return
+	}
+
+	if len(values) == 0 {
+		if len(curValues) > 0 {
+			err = prov.DeleteRecords(fqdn, recordType)
+			if err != nil {
+				return
+			}
+			changed = true
+		}
+		return
+	}
+
+	if curTtl == ttl && recordsEqual(curValues, values) {
+		return
+	}
+
+	err = prov.SetRecords(fqdn, recordType, ttl, values)
+	if err != nil {
+		return
+	}
+	changed = true
+
+	return
+}
+
+func (d *Domain) UpsertAddress(name, addr, addr6 string) (err error) {
+	addrs := []string{}
+	if addr != "" {
+		addrs = append(addrs, addr)
+	}
+
+	addrs6 := []string{}
+	if addr6 != "" {
+		addrs6 = append(addrs6, addr6)
+	}
+
+	_, err = d.SetRecords(name, "A", DefaultTtl, addrs)
+	if err != nil {
+		return
+	}
+
+	_, err = d.SetRecords(name, "AAAA", DefaultTtl, addrs6)
+	if err != nil {
+		return
+	}
+
+	return
+}
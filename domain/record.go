@@ -18,34 +18,40 @@ type Record struct {
 	Instance     primitive.ObjectID `bson:"instance" json:"instance"`
 	Timestamp    time.Time          `bson:"timestamp" json:"timestamp"`
 	Name         string             `bson:"name" json:"name"`
-	Address      string             `bson:"address" json:"address"`
-	Address6     string             `bson:"address6" json:"address6"`
+	Type         string             `bson:"type" json:"type"`
+	Ttl          int                `bson:"ttl" json:"ttl"`
+	Values       []string           `bson:"values" json:"values"`
+}
+
+func (r *Record) Key() string {
+	return r.Domain.Hex() + ":" + r.Name + ":" + r.Type
 }
 
 func (r *Record) Remove(db *database.Database) (err error) {
 	domn, err := GetOrg(db, r.Organization, r.Domain)
 	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		}
 		return
 	}
 
-	if domn.Type == Route53 {
-		err = AwsUpsertDomain(domn, r.Name, "", "")
+	if r.Type == "" {
+		err = domn.UpsertAddress(r.Name, "", "")
 		if err != nil {
 			return
 		}
 	} else {
-		err = &errortypes.UnknownError{
-			errors.New("domain: Unknown domain type"),
+		_, err = domn.SetRecords(r.Name, r.Type, 0, nil)
+		if err != nil {
+			return
 		}
-		return
 	}
 
 	return
 }
 
-func (r *Record) Upsert(db *database.Database, addr, addr6 string) (
-	err error) {
-
+func (r *Record) Upsert(db *database.Database) (changed bool, err error) {
 	domn, err := GetOrg(db, r.Organization, r.Domain)
 	if err != nil {
 		return
@@ -60,23 +66,13 @@ func (r *Record) Upsert(db *database.Database, addr, addr6 string) (
 		}
 	}
 
-	if domn.Type == Route53 {
-		err = AwsUpsertDomain(domn, r.Name, addr, addr6)
-		if err != nil {
-			return
-		}
-	} else {
-		err = &errortypes.UnknownError{
-			errors.New("domain: Unknown domain type"),
-		}
+	changed, err = domn.SetRecords(r.Name, r.Type, r.Ttl, r.Values)
+	if err != nil {
 		return
 	}
 
-	r.Address = addr
-	r.Address6 = addr6
-
 	err = r.CommitFields(
-		db, set.NewSet("timestamp", "address", "address6"))
+		db, set.NewSet("timestamp", "ttl", "values"))
 	if err != nil {
 		return
 	}
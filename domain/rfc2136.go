@@ -0,0 +1,216 @@
+package domain
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/miekg/dns"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type rfc2136Provider struct {
+	server    string
+	zone      string
+	keyName   string
+	secret    string
+	algorithm string
+}
+
+func (p *rfc2136Provider) client() (client *dns.Client) {
+	client = &dns.Client{
+		Net:     "tcp",
+		Timeout: providerTimeout,
+	}
+
+	if p.keyName != "" {
+		client.TsigSecret = map[string]string{
+			p.keyName: p.secret,
+		}
+	}
+
+	return
+}
+
+func (p *rfc2136Provider) exchange(msg *dns.Msg) (resp *dns.Msg, err error) {
+	if p.keyName != "" {
+		msg.SetTsig(p.keyName, p.algorithm, 300, time.Now().Unix())
+	}
+
+	resp, _, err = p.client().Exchange(msg, p.server)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "domain: RFC 2136 request failed"),
+		}
+		return
+	}
+
+	if resp.Rcode != dns.RcodeSuccess && !(msg.Opcode == dns.OpcodeQuery &&
+		resp.Rcode == dns.RcodeNameError) {
+
+		err = &errortypes.RequestError{
+			errors.Newf("domain: RFC 2136 request bad rcode '%s'",
+				dns.RcodeToString[resp.Rcode]),
+		}
+		return
+	}
+
+	return
+}
+
+func (p *rfc2136Provider) Connect(domain *Domain) (err error) {
+	p.server = domain.Rfc2136Server
+	if _, _, e := net.SplitHostPort(p.server); e != nil {
+		p.server = net.JoinHostPort(p.server, "53")
+	}
+
+	p.zone = dns.Fqdn(domain.Name)
+	if domain.Rfc2136KeyName != "" {
+		p.keyName = dns.Fqdn(domain.Rfc2136KeyName)
+		p.secret = domain.Rfc2136Secret
+
+		switch domain.Rfc2136Algorithm {
+		case Rfc2136HmacSha1:
+			p.algorithm = dns.HmacSHA1
+			break
+		case Rfc2136HmacSha512:
+			p.algorithm = dns.HmacSHA512
+			break
+		default:
+			p.algorithm = dns.HmacSHA256
+			break
+		}
+	}
+
+	msg := &dns.Msg{}
+	msg.SetQuestion(p.zone, dns.TypeSOA)
+
+	resp, err := p.exchange(msg)
+	if err != nil {
+		return
+	}
+
+	for _, rr := range resp.Answer {
+		if _, ok := rr.(*dns.SOA); ok {
+			return
+		}
+	}
+
+	err = &errortypes.NotFoundError{
+		errors.New("domain: RFC 2136 server not authoritative for zone"),
+	}
+	return
+}
+
+func (p *rfc2136Provider) GetRecords(fqdn, recordType string) (
+	values []string, ttl int, err error) {
+
+	values = []string{}
+
+	rrType, ok := dns.StringToType[recordType]
+	if !ok {
+		err = &errortypes.UnknownError{
+			errors.Newf("domain: Unknown record type '%s'", recordType),
+		}
+		return
+	}
+
+	msg := &dns.Msg{}
+	msg.SetQuestion(dns.Fqdn(fqdn), rrType)
+
+	resp, err := p.exchange(msg)
+	if err != nil {
+		return
+	}
+
+	for _, rr := range resp.Answer {
+		hdr := rr.Header()
+		if hdr.Rrtype != rrType {
+			continue
+		}
+
+		ttl = int(hdr.Ttl)
+		value := strings.TrimPrefix(rr.String(), hdr.String())
+		values = append(values, parseValue(recordType, value))
+	}
+
+	return
+}
+
+func (p *rfc2136Provider) SetRecords(fqdn, recordType string, ttl int,
+	values []string) (err error) {
+
+	rrType, ok := dns.StringToType[recordType]
+	if !ok {
+		err = &errortypes.UnknownError{
+			errors.Newf("domain: Unknown record type '%s'", recordType),
+		}
+		return
+	}
+
+	rrs := []dns.RR{}
+	for _, value := range values {
+		rr, e := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(fqdn),
+			ttl, recordType, formatValue(recordType, value)))
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "domain: Failed to parse RFC 2136 record"),
+			}
+			return
+		}
+		rrs = append(rrs, rr)
+	}
+
+	msg := &dns.Msg{}
+	msg.SetUpdate(p.zone)
+	msg.RemoveRRset([]dns.RR{
+		&dns.ANY{
+			Hdr: dns.RR_Header{
+				Name:   dns.Fqdn(fqdn),
+				Rrtype: rrType,
+				Class:  dns.ClassINET,
+			},
+		},
+	})
+	msg.Insert(rrs)
+
+	_, err = p.exchange(msg)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (p *rfc2136Provider) DeleteRecords(fqdn, recordType string) (
+	err error) {
+
+	rrType, ok := dns.StringToType[recordType]
+	if !ok {
+		err = &errortypes.UnknownError{
+			errors.Newf("domain: Unknown record type '%s'", recordType),
+		}
+		return
+	}
+
+	msg := &dns.Msg{}
+	msg.SetUpdate(p.zone)
+	msg.RemoveRRset([]dns.RR{
+		&dns.ANY{
+			Hdr: dns.RR_Header{
+				Name:   dns.Fqdn(fqdn),
+				Rrtype: rrType,
+				Class:  dns.ClassINET,
+			},
+		},
+	})
+
+	_, err = p.exchange(msg)
+	if err != nil {
+		return
+	}
+
+	return
+}
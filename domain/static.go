@@ -0,0 +1,328 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+type StaticRecord struct {
+	Name   string   `bson:"name" json:"name"`
+	Type   string   `bson:"type" json:"type"`
+	Ttl    int      `bson:"ttl" json:"ttl"`
+	Values []string `bson:"values" json:"values"`
+}
+
+func (r *StaticRecord) Key() string {
+	return r.Name + ":" + r.Type
+}
+
+func ValidateName(name string, wildcard bool) (string, bool) {
+	name = strings.ToLower(strings.Trim(strings.TrimSpace(name), "."))
+	if name == "" || len(name) > 253 {
+		return "", false
+	}
+
+	for i, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return "", false
+		}
+
+		if label == "*" && i == 0 && wildcard {
+			continue
+		}
+
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') &&
+				c != '-' && c != '_' {
+
+				return "", false
+			}
+		}
+	}
+
+	return name, true
+}
+
+func validateTarget(target string) (string, bool) {
+	target, ok := ValidateName(target, false)
+	if !ok {
+		return "", false
+	}
+	return target + ".", true
+}
+
+func validateValue(recordType, value string) (string, bool) {
+	switch recordType {
+	case "TXT":
+		if value == "" || len(value) > 255 {
+			return "", false
+		}
+		return value, true
+	case "MX":
+		fields := strings.Fields(value)
+		if len(fields) != 2 {
+			return "", false
+		}
+
+		pref, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil {
+			return "", false
+		}
+
+		target, ok := validateTarget(fields[1])
+		if !ok {
+			return "", false
+		}
+
+		return fmt.Sprintf("%d %s", pref, target), true
+	case "SRV":
+		fields := strings.Fields(value)
+		if len(fields) != 4 {
+			return "", false
+		}
+
+		nums := []uint64{}
+		for _, field := range fields[:3] {
+			num, err := strconv.ParseUint(field, 10, 16)
+			if err != nil {
+				return "", false
+			}
+			nums = append(nums, num)
+		}
+
+		target, ok := validateTarget(fields[3])
+		if !ok {
+			return "", false
+		}
+
+		return fmt.Sprintf("%d %d %d %s",
+			nums[0], nums[1], nums[2], target), true
+	case "CNAME", "PTR":
+		return validateTarget(value)
+	}
+
+	return "", false
+}
+
+func ValidTtl(ttl int) bool {
+	return ttl >= MinTtl && ttl <= MaxTtl
+}
+
+func (d *Domain) validateRecords() (errData *errortypes.ErrorData) {
+	if d.Records == nil {
+		d.Records = []*StaticRecord{}
+	}
+
+	keys := set.NewSet()
+	for _, recrd := range d.Records {
+		if recrd.Name == "" || recrd.Name == "@" {
+			recrd.Name = "@"
+		} else {
+			name, ok := ValidateName(recrd.Name, true)
+			if !ok {
+				errData = &errortypes.ErrorData{
+					Error:   "record_name_invalid",
+					Message: "Invalid static record name",
+				}
+				return
+			}
+			recrd.Name = name
+		}
+
+		recrd.Type = strings.ToUpper(strings.TrimSpace(recrd.Type))
+		if !StaticTypes.Contains(recrd.Type) {
+			errData = &errortypes.ErrorData{
+				Error:   "record_type_invalid",
+				Message: "Static record type must be TXT, MX or SRV",
+			}
+			return
+		}
+
+		if keys.Contains(recrd.Key()) {
+			errData = &errortypes.ErrorData{
+				Error:   "record_duplicate",
+				Message: "Duplicate static record name and type",
+			}
+			return
+		}
+		keys.Add(recrd.Key())
+
+		if recrd.Ttl == 0 {
+			recrd.Ttl = DefaultTtl
+		}
+		if !ValidTtl(recrd.Ttl) {
+			errData = &errortypes.ErrorData{
+				Error:   "record_ttl_invalid",
+				Message: "Invalid static record TTL",
+			}
+			return
+		}
+
+		values := []string{}
+		valuesSet := set.NewSet()
+		for _, value := range recrd.Values {
+			if recrd.Type != "TXT" {
+				value = strings.TrimSpace(value)
+			}
+			if value == "" {
+				continue
+			}
+
+			val, ok := validateValue(recrd.Type, value)
+			if !ok {
+				errData = &errortypes.ErrorData{
+					Error:   "record_value_invalid",
+					Message: "Invalid static record value",
+				}
+				return
+			}
+
+			if valuesSet.Contains(val) {
+				continue
+			}
+			valuesSet.Add(val)
+
+			values = append(values, val)
+		}
+
+		if len(values) == 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "record_value_required",
+				Message: "Missing required static record value",
+			}
+			return
+		}
+		recrd.Values = values
+	}
+
+	return
+}
+
+func (d *Domain) ValidateRecords() (errData *errortypes.ErrorData) {
+	errData = d.validateRecords()
+	if errData != nil {
+		return
+	}
+	d.RecordsChecked = time.Time{}
+
+	return
+}
+
+func (d *Domain) SyncRecords(db *database.Database) (err error) {
+	current := set.NewSet()
+	for _, recrd := range d.Records {
+		current.Add(recrd.Key())
+
+		changed, e := d.SetRecords(recrd.Name, recrd.Type,
+			recrd.Ttl, recrd.Values)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if changed {
+			logrus.WithFields(logrus.Fields{
+				"domain": d.Name,
+				"name":   recrd.Name,
+				"type":   recrd.Type,
+				"values": recrd.Values,
+			}).Info("domain: Updated static domain record")
+		}
+	}
+
+	applied := []string{}
+	for _, key := range d.RecordsApplied {
+		if current.Contains(key) {
+			continue
+		}
+
+		keys := strings.SplitN(key, ":", 2)
+		if len(keys) != 2 {
+			continue
+		}
+
+		_, e := d.SetRecords(keys[0], keys[1], 0, nil)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"domain": d.Name,
+				"name":   keys[0],
+				"type":   keys[1],
+				"error":  e,
+			}).Error("domain: Failed to remove static domain record")
+
+			applied = append(applied, key)
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"domain": d.Name,
+			"name":   keys[0],
+			"type":   keys[1],
+		}).Info("domain: Removed static domain record")
+	}
+
+	for _, recrd := range d.Records {
+		applied = append(applied, recrd.Key())
+	}
+	d.RecordsApplied = applied
+
+	err = d.CommitFields(db, set.NewSet("records_applied"))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func ClaimSync(db *database.Database, interval time.Duration) (
+	domn *Domain, err error) {
+
+	coll := db.Domains()
+	domn = &Domain{}
+
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetReturnDocument(options.After)
+
+	err = coll.FindOneAndUpdate(
+		db,
+		&bson.M{
+			"$or": []*bson.M{
+				&bson.M{
+					"records_checked": &bson.M{
+						"$lt": time.Now().Add(-interval),
+					},
+				},
+				&bson.M{
+					"records_checked": &bson.M{
+						"$exists": false,
+					},
+				},
+			},
+		},
+		&bson.M{
+			"$set": &bson.M{
+				"records_checked": time.Now(),
+			},
+		},
+		opts,
+	).Decode(domn)
+	if err != nil {
+		err = database.ParseError(err)
+		if _, ok := err.(*database.NotFoundError); ok {
+			domn = nil
+			err = nil
+		}
+		return
+	}
+
+	return
+}
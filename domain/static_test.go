@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	name, ok := ValidateName(" WWW.Example.COM. ", false)
+	if !ok || name != "www.example.com" {
+		t.Errorf("name %q not normalized", name)
+	}
+
+	for _, name := range []string{
+		"_dmarc.example.com",
+		"host-1.example.com",
+		strings.Repeat("a", 63) + ".com",
+	} {
+		_, ok = ValidateName(name, false)
+		if !ok {
+			t.Errorf("valid name %q rejected", name)
+		}
+	}
+
+	for _, name := range []string{
+		"",
+		".",
+		"www..example.com",
+		"www example.com",
+		"www.exa$mple.com",
+		strings.Repeat("a", 64) + ".com",
+		strings.Repeat("a.", 127) + "a",
+	} {
+		_, ok = ValidateName(name, false)
+		if ok {
+			t.Errorf("invalid name %q accepted", name)
+		}
+	}
+
+	_, ok = ValidateName("*.example.com", true)
+	if !ok {
+		t.Errorf("wildcard name rejected")
+	}
+	_, ok = ValidateName("*.example.com", false)
+	if ok {
+		t.Errorf("wildcard name accepted without wildcard")
+	}
+	_, ok = ValidateName("www.*.example.com", true)
+	if ok {
+		t.Errorf("inner wildcard label accepted")
+	}
+}
+
+func TestValidateValue(t *testing.T) {
+	value, ok := validateValue("MX", " 010  Mail.Example.com. ")
+	if !ok || value != "10 mail.example.com." {
+		t.Errorf("mx value %q not normalized", value)
+	}
+
+	value, ok = validateValue("SRV", "10 5 5060 sip.example.com")
+	if !ok || value != "10 5 5060 sip.example.com." {
+		t.Errorf("srv value %q not normalized", value)
+	}
+
+	value, ok = validateValue("TXT", "v=spf1 -all")
+	if !ok || value != "v=spf1 -all" {
+		t.Errorf("txt value %q modified", value)
+	}
+
+	invalid := [][2]string{
+		{"TXT", ""},
+		{"TXT", strings.Repeat("a", 256)},
+		{"MX", "mail.example.com"},
+		{"MX", "70000 mail.example.com"},
+		{"MX", "-1 mail.example.com"},
+		{"MX", "10 mail..example.com"},
+		{"SRV", "10 5 sip.example.com"},
+		{"SRV", "10 5 70000 sip.example.com"},
+		{"SRV", "10 a 5060 sip.example.com"},
+		{"A", "10.0.0.1"},
+	}
+	for _, val := range invalid {
+		_, ok = validateValue(val[0], val[1])
+		if ok {
+			t.Errorf("invalid %s value %q accepted", val[0], val[1])
+		}
+	}
+}
+
+func TestValidateRecords(t *testing.T) {
+	domn := &Domain{
+		Records: []*StaticRecord{
+			{
+				Name: "",
+				Type: " mx ",
+				Values: []string{
+					"10 mail.example.com",
+					"010 mail.example.com.",
+					"",
+				},
+			},
+			{
+				Name:   "*.Example.com",
+				Type:   "TXT",
+				Ttl:    300,
+				Values: []string{" v=spf1 -all"},
+			},
+		},
+	}
+
+	errData := domn.validateRecords()
+	if errData != nil {
+		t.Fatalf("validate error: %s", errData.Message)
+	}
+
+	mx := domn.Records[0]
+	if mx.Name != "@" || mx.Type != "MX" || mx.Ttl != DefaultTtl {
+		t.Errorf("record %s %s %d not normalized", mx.Name, mx.Type, mx.Ttl)
+	}
+	if len(mx.Values) != 1 || mx.Values[0] != "10 mail.example.com." {
+		t.Errorf("record values %v not deduplicated", mx.Values)
+	}
+
+	txt := domn.Records[1]
+	if txt.Name != "*.example.com" || txt.Ttl != 300 {
+		t.Errorf("record %s %d not normalized", txt.Name, txt.Ttl)
+	}
+	if txt.Values[0] != " v=spf1 -all" {
+		t.Errorf("txt value %q modified", txt.Values[0])
+	}
+
+	domn.Records = append(domn.Records, &StaticRecord{
+		Name:   "@",
+		Type:   "MX",
+		Values: []string{"20 backup.example.com"},
+	})
+	errData = domn.validateRecords()
+	if errData == nil || errData.Error != "record_duplicate" {
+		t.Errorf("duplicate record accepted")
+	}
+
+	domn.Records = []*StaticRecord{
+		{Name: "www", Type: "A", Values: []string{"10.0.0.1"}},
+	}
+	errData = domn.validateRecords()
+	if errData == nil || errData.Error != "record_type_invalid" {
+		t.Errorf("unsupported record type accepted")
+	}
+
+	domn.Records = []*StaticRecord{
+		{Name: "www", Type: "TXT", Ttl: 10, Values: []string{"a"}},
+	}
+	errData = domn.validateRecords()
+	if errData == nil || errData.Error != "record_ttl_invalid" {
+		t.Errorf("record ttl below minimum accepted")
+	}
+
+	domn.Records = []*StaticRecord{
+		{Name: "www", Type: "TXT", Values: []string{""}},
+	}
+	errData = domn.validateRecords()
+	if errData == nil || errData.Error != "record_value_required" {
+		t.Errorf("record without values accepted")
+	}
+}
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"strings"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/miekg/dns"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+func (d *Domain) RecordName(fqdn string) (name string, ok bool) {
+	fqdn = strings.ToLower(strings.TrimSuffix(fqdn, "."))
+	zone := strings.ToLower(strings.TrimSuffix(d.Name, "."))
+
+	if zone == "" || !strings.HasSuffix(fqdn, "."+zone) {
+		return
+	}
+
+	name = strings.TrimSuffix(fqdn, "."+zone)
+	ok = name != ""
+	return
+}
+
+func (d *Domain) UpsertTxt(name string, values []string) (err error) {
+	_, err = d.SetRecords(name, "TXT", DefaultTtl, values)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (d *Domain) RemoveTxt(name string) (err error) {
+	_, err = d.SetRecords(name, "TXT", 0, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Match(domns []*Domain, fqdn string) (domn *Domain, name string) {
+	for _, dmn := range domns {
+		recordName, ok := dmn.RecordName(fqdn)
+		if !ok {
+			continue
+		}
+
+		if domn == nil || len(dmn.Name) > len(domn.Name) {
+			domn = dmn
+			name = recordName
+		}
+	}
+
+	return
+}
+
+func ReverseName(addr string) (fqdn string, ok bool) {
+	fqdn, err := dns.ReverseAddr(addr)
+	if err != nil {
+		return
+	}
+
+	fqdn = strings.TrimSuffix(fqdn, ".")
+	ok = true
+	return
+}
+
+func FindOrg(db *database.Database, orgId primitive.ObjectID,
+	fqdn string) (domn *Domain, name string, err error) {
+
+	domns, err := GetAll(db, &bson.M{
+		"organization": orgId,
+	})
+	if err != nil {
+		return
+	}
+
+	domn, name = Match(domns, fqdn)
+	if domn == nil {
+		err = &errortypes.NotFoundError{
+			errors.Newf("domain: No managed domain for '%s'", fqdn),
+		}
+		return
+	}
+
+	return
+}
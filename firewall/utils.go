@@ -421,8 +421,13 @@ func GetAllIngress(db *database.Database, nodeSelf *node.Node,
 		for i := range inst.Virt.NetworkAdapters {
 			namespace := vm.GetNamespace(inst.Id, i)
 
+			networkRoles := inst.NetworkRoles
+			if i > 0 && len(inst.NetworkInterfaces) >= i {
+				networkRoles = inst.NetworkInterfaces[i-1].NetworkRoles
+			}
+
 			fires, e := GetOrgRoles(db,
-				inst.Organization, inst.NetworkRoles)
+				inst.Organization, networkRoles)
 			if e != nil {
 				err = e
 				return
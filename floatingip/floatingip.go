@@ -0,0 +1,242 @@
+package floatingip
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/block"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/zone"
+)
+
+type FloatingIp struct {
+	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name         string             `bson:"name" json:"name"`
+	Comment      string             `bson:"comment" json:"comment"`
+	Organization primitive.ObjectID `bson:"organization" json:"organization"`
+	Datacenter   primitive.ObjectID `bson:"datacenter" json:"datacenter"`
+	Zone         primitive.ObjectID `bson:"zone" json:"zone"`
+	Block        primitive.ObjectID `bson:"block" json:"block"`
+	Ip           string             `bson:"ip" json:"ip"`
+	Instance     primitive.ObjectID `bson:"instance,omitempty" json:"instance"`
+}
+
+func (f *FloatingIp) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if f.Organization.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "organization_required",
+			Message: "Missing required organization",
+		}
+		return
+	}
+
+	if f.Zone.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "zone_required",
+			Message: "Missing required zone",
+		}
+		return
+	}
+
+	zne, err := zone.Get(db, f.Zone)
+	if err != nil {
+		return
+	}
+	f.Datacenter = zne.Datacenter
+
+	if f.Block.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "block_required",
+			Message: "Missing required external IP block",
+		}
+		return
+	}
+
+	blck, err := block.Get(db, f.Block)
+	if err != nil {
+		return
+	}
+
+	if blck.Type != block.IPv4 {
+		errData = &errortypes.ErrorData{
+			Error:   "block_type_invalid",
+			Message: "Floating IP block must be IPv4",
+		}
+		return
+	}
+
+	if !f.Instance.IsZero() {
+		inst, e := instance.Get(db, f.Instance)
+		if e != nil {
+			err = e
+			if _, ok := err.(*database.NotFoundError); ok {
+				err = nil
+				errData = &errortypes.ErrorData{
+					Error:   "instance_missing",
+					Message: "Instance does not exist",
+				}
+			}
+			return
+		}
+
+		if inst.Organization != f.Organization {
+			errData = &errortypes.ErrorData{
+				Error:   "instance_organization_invalid",
+				Message: "Instance organization does not match floating IP",
+			}
+			return
+		}
+
+		if inst.Zone != f.Zone {
+			errData = &errortypes.ErrorData{
+				Error:   "instance_zone_invalid",
+				Message: "Instance zone does not match floating IP",
+			}
+			return
+		}
+
+		if inst.NoPublicAddress {
+			errData = &errortypes.ErrorData{
+				Error:   "instance_no_public_address",
+				Message: "Instance without public address cannot have floating IP",
+			}
+			return
+		}
+
+		nde, e := node.Get(db, inst.Node)
+		if e != nil {
+			err = e
+			if _, ok := err.(*database.NotFoundError); ok {
+				err = nil
+				errData = &errortypes.ErrorData{
+					Error:   "instance_node_missing",
+					Message: "Instance node does not exist",
+				}
+			}
+			return
+		}
+
+		if !nde.HasBlock(f.Block) {
+			errData = &errortypes.ErrorData{
+				Error:   "instance_block_invalid",
+				Message: "Floating IP block not attached to instance node",
+			}
+			return
+		}
+
+		coll := db.FloatingIps()
+		count, e := coll.CountDocuments(db, &bson.M{
+			"_id": &bson.M{
+				"$ne": f.Id,
+			},
+			"instance": f.Instance,
+		})
+		if e != nil {
+			err = database.ParseError(e)
+			return
+		}
+
+		if count > 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "instance_conflict",
+				Message: "Instance already has a floating IP attached",
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func (f *FloatingIp) Reserve(db *database.Database) (err error) {
+	if f.Ip != "" {
+		return
+	}
+
+	blck, err := block.Get(db, f.Block)
+	if err != nil {
+		return
+	}
+
+	ip, err := blck.GetIp(db, f.Id, block.Floating)
+	if err != nil {
+		return
+	}
+
+	f.Ip = ip.String()
+	err = f.CommitFields(db, set.NewSet("ip"))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (f *FloatingIp) PreCommit() (curBlock primitive.ObjectID) {
+	curBlock = f.Block
+	return
+}
+
+func (f *FloatingIp) PostCommit(db *database.Database,
+	curBlock primitive.ObjectID) (err error) {
+
+	if curBlock != f.Block {
+		err = block.RemoveInstanceIpsType(db, f.Id, block.Floating)
+		if err != nil {
+			return
+		}
+
+		f.Ip = ""
+	}
+
+	return
+}
+
+func (f *FloatingIp) Commit(db *database.Database) (err error) {
+	coll := db.FloatingIps()
+
+	err = coll.Commit(f.Id, f)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (f *FloatingIp) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.FloatingIps()
+
+	err = coll.CommitFields(f.Id, f, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (f *FloatingIp) Insert(db *database.Database) (err error) {
+	coll := db.FloatingIps()
+
+	if !f.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("floatingip: Floating IP already exists"),
+		}
+		return
+	}
+
+	_, err = coll.InsertOne(db, f)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,294 @@
+package floatingip
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/block"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func Get(db *database.Database, fipId primitive.ObjectID) (
+	fip *FloatingIp, err error) {
+
+	coll := db.FloatingIps()
+	fip = &FloatingIp{}
+
+	err = coll.FindOneId(fipId, fip)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetOrg(db *database.Database, orgId, fipId primitive.ObjectID) (
+	fip *FloatingIp, err error) {
+
+	coll := db.FloatingIps()
+	fip = &FloatingIp{}
+
+	err = coll.FindOne(db, &bson.M{
+		"_id":          fipId,
+		"organization": orgId,
+	}).Decode(fip)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func ExistsOrg(db *database.Database, orgId, fipId primitive.ObjectID) (
+	exists bool, err error) {
+
+	coll := db.FloatingIps()
+
+	n, err := coll.CountDocuments(db, &bson.M{
+		"_id":          fipId,
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if n > 0 {
+		exists = true
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M) (
+	fips []*FloatingIp, err error) {
+
+	coll := db.FloatingIps()
+	fips = []*FloatingIp{}
+
+	cursor, err := coll.Find(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		fip := &FloatingIp{}
+		err = cursor.Decode(fip)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		fips = append(fips, fip)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetInstances(db *database.Database, instIds []primitive.ObjectID) (
+	fips []*FloatingIp, err error) {
+
+	fips, err = GetAll(db, &bson.M{
+		"instance": &bson.M{
+			"$in": instIds,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetAllPaged(db *database.Database, query *bson.M,
+	page, pageCount int64) (fips []*FloatingIp, count int64, err error) {
+
+	coll := db.FloatingIps()
+	fips = []*FloatingIp{}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	page = utils.Min64(page, count/pageCount)
+	skip := utils.Min64(page*pageCount, count)
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"name", 1},
+			},
+			Skip:  &skip,
+			Limit: &pageCount,
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		fip := &FloatingIp{}
+		err = cursor.Decode(fip)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		fips = append(fips, fip)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func release(db *database.Database, fipIds []primitive.ObjectID) (
+	err error) {
+
+	for _, fipId := range fipIds {
+		err = block.RemoveInstanceIpsType(db, fipId, block.Floating)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func Remove(db *database.Database, fipId primitive.ObjectID) (err error) {
+	coll := db.FloatingIps()
+
+	err = release(db, []primitive.ObjectID{fipId})
+	if err != nil {
+		return
+	}
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": fipId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveOrg(db *database.Database, orgId, fipId primitive.ObjectID) (
+	err error) {
+
+	coll := db.FloatingIps()
+
+	exists, err := ExistsOrg(db, orgId, fipId)
+	if err != nil || !exists {
+		return
+	}
+
+	err = release(db, []primitive.ObjectID{fipId})
+	if err != nil {
+		return
+	}
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id":          fipId,
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveMulti(db *database.Database, fipIds []primitive.ObjectID) (
+	err error) {
+
+	coll := db.FloatingIps()
+
+	err = release(db, fipIds)
+	if err != nil {
+		return
+	}
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": fipIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func RemoveMultiOrg(db *database.Database, orgId primitive.ObjectID,
+	fipIds []primitive.ObjectID) (err error) {
+
+	coll := db.FloatingIps()
+
+	fips, err := GetAll(db, &bson.M{
+		"_id": &bson.M{
+			"$in": fipIds,
+		},
+		"organization": orgId,
+	})
+	if err != nil {
+		return
+	}
+
+	orgFipIds := []primitive.ObjectID{}
+	for _, fip := range fips {
+		orgFipIds = append(orgFipIds, fip.Id)
+	}
+
+	err = release(db, orgFipIds)
+	if err != nil {
+		return
+	}
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": orgFipIds,
+		},
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
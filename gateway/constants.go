@@ -0,0 +1,15 @@
+package gateway
+
+const (
+	Active   = "active"
+	Pending  = "pending"
+	Inactive = "inactive"
+
+	RouteTable    = "97"
+	RouteMark     = "0x97"
+	RulePriority  = "96"
+	RulePriority2 = "97"
+	RulePriority3 = "98"
+
+	MaxAddresses = 16
+)
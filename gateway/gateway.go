@@ -0,0 +1,420 @@
+package gateway
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/block"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/pritunl/pritunl-cloud/zone"
+)
+
+type Gateway struct {
+	Id           primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Name         string               `bson:"name" json:"name"`
+	Comment      string               `bson:"comment" json:"comment"`
+	Organization primitive.ObjectID   `bson:"organization" json:"organization"`
+	Datacenter   primitive.ObjectID   `bson:"datacenter" json:"datacenter"`
+	Zone         primitive.ObjectID   `bson:"zone" json:"zone"`
+	Vpc          primitive.ObjectID   `bson:"vpc" json:"vpc"`
+	Subnet       primitive.ObjectID   `bson:"subnet" json:"subnet"`
+	Subnets      []primitive.ObjectID `bson:"subnets" json:"subnets"`
+	Block        primitive.ObjectID   `bson:"block" json:"block"`
+	AddressCount int                  `bson:"address_count" json:"address_count"`
+	PublicIps    []string             `bson:"public_ips" json:"public_ips"`
+	PrivateIp    string               `bson:"private_ip" json:"private_ip"`
+	Node         primitive.ObjectID   `bson:"node,omitempty" json:"node"`
+	Timestamp    time.Time            `bson:"timestamp" json:"timestamp"`
+	Status       string               `bson:"-" json:"status"`
+}
+
+func (g *Gateway) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if g.Organization.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "organization_required",
+			Message: "Missing required organization",
+		}
+		return
+	}
+
+	if g.Zone.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "zone_required",
+			Message: "Missing required zone",
+		}
+		return
+	}
+
+	zne, err := zone.Get(db, g.Zone)
+	if err != nil {
+		return
+	}
+	g.Datacenter = zne.Datacenter
+
+	if g.Vpc.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_required",
+			Message: "Missing required VPC",
+		}
+		return
+	}
+
+	vc, err := vpc.Get(db, g.Vpc)
+	if err != nil {
+		return
+	}
+
+	if vc.Organization != g.Organization {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_organization_invalid",
+			Message: "VPC organization does not match gateway",
+		}
+		return
+	}
+
+	if vc.Datacenter != g.Datacenter {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_datacenter_invalid",
+			Message: "VPC datacenter does not match gateway zone",
+		}
+		return
+	}
+
+	if g.Subnet.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_subnet_required",
+			Message: "Missing required VPC subnet",
+		}
+		return
+	}
+
+	if vc.GetSubnet(g.Subnet) == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_subnet_missing",
+			Message: "VPC subnet does not exist",
+		}
+		return
+	}
+
+	if g.Subnets == nil {
+		g.Subnets = []primitive.ObjectID{}
+	}
+
+	subnets := []primitive.ObjectID{}
+	subnetIds := set.NewSet()
+	for _, subId := range g.Subnets {
+		if subnetIds.Contains(subId) {
+			continue
+		}
+		subnetIds.Add(subId)
+
+		if vc.GetSubnet(subId) == nil {
+			errData = &errortypes.ErrorData{
+				Error:   "vpc_subnet_missing",
+				Message: "Gateway subnet does not exist in VPC",
+			}
+			return
+		}
+
+		subnets = append(subnets, subId)
+	}
+	g.Subnets = subnets
+
+	if len(g.Subnets) > 0 {
+		coll := db.Gateways()
+		count, e := coll.CountDocuments(db, &bson.M{
+			"_id": &bson.M{
+				"$ne": g.Id,
+			},
+			"vpc": g.Vpc,
+			"subnets": &bson.M{
+				"$in": g.Subnets,
+			},
+		})
+		if e != nil {
+			err = database.ParseError(e)
+			return
+		}
+
+		if count > 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "subnet_conflict",
+				Message: "Subnet is already routed through another gateway",
+			}
+			return
+		}
+	}
+
+	if g.Block.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "block_required",
+			Message: "Missing required external IP block",
+		}
+		return
+	}
+
+	blck, err := block.Get(db, g.Block)
+	if err != nil {
+		return
+	}
+
+	if blck.Type != block.IPv4 {
+		errData = &errortypes.ErrorData{
+			Error:   "block_type_invalid",
+			Message: "Gateway IP block must be IPv4",
+		}
+		return
+	}
+
+	if g.AddressCount < 1 {
+		g.AddressCount = 1
+	}
+
+	if g.AddressCount > MaxAddresses {
+		errData = &errortypes.ErrorData{
+			Error:   "address_count_invalid",
+			Message: "Gateway address count exceeds maximum",
+		}
+		return
+	}
+
+	if g.PublicIps == nil {
+		g.PublicIps = []string{}
+	}
+
+	return
+}
+
+func (g *Gateway) Json() {
+	if g.Node.IsZero() {
+		g.Status = Pending
+	} else if time.Since(g.Timestamp) > time.Duration(
+		settings.Hypervisor.GatewayTimeout)*time.Second {
+
+		g.Status = Inactive
+	} else {
+		g.Status = Active
+	}
+}
+
+func (g *Gateway) HasSubnet(subId primitive.ObjectID) bool {
+	for _, sub := range g.Subnets {
+		if sub == subId {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Gateway) IsHost(nde *node.Node) bool {
+	if nde.Zone != g.Zone || !nde.IsHypervisor() {
+		return false
+	}
+
+	return nde.HasBlock(g.Block)
+}
+
+func (g *Gateway) GetInterface(nde *node.Node) string {
+	for _, blckAttch := range nde.Blocks {
+		if blckAttch.Block == g.Block {
+			return blckAttch.Interface
+		}
+	}
+	return ""
+}
+
+func (g *Gateway) SyncAddresses(db *database.Database) (
+	changed bool, err error) {
+
+	blckIps, err := block.GetInstanceIps(db, g.Id, block.Gateway)
+	if err != nil {
+		return
+	}
+
+	addrs := []string{}
+	for _, blckIp := range blckIps {
+		if blckIp.Block != g.Block || len(addrs) >= g.AddressCount {
+			err = block.RemoveIp(db, blckIp.Id)
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		addrs = append(addrs, blckIp.GetIp().String())
+	}
+
+	if len(addrs) < g.AddressCount {
+		blck, e := block.Get(db, g.Block)
+		if e != nil {
+			err = e
+			return
+		}
+
+		for len(addrs) < g.AddressCount {
+			ip, e := blck.GetIp(db, g.Id, block.Gateway)
+			if e != nil {
+				err = e
+				return
+			}
+
+			addrs = append(addrs, ip.String())
+		}
+	}
+
+	if len(addrs) != len(g.PublicIps) {
+		changed = true
+	} else {
+		for i := range addrs {
+			if addrs[i] != g.PublicIps[i] {
+				changed = true
+				break
+			}
+		}
+	}
+
+	if changed {
+		g.PublicIps = addrs
+		err = g.CommitFields(db, set.NewSet("public_ips"))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (g *Gateway) SyncPrivateIp(db *database.Database) (err error) {
+	vc, err := vpc.Get(db, g.Vpc)
+	if err != nil {
+		return
+	}
+
+	addr, _, err := vc.GetIp(db, g.Subnet, g.Id)
+	if err != nil {
+		return
+	}
+
+	if g.PrivateIp != addr.String() {
+		g.PrivateIp = addr.String()
+		err = g.CommitFields(db, set.NewSet("private_ip"))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (g *Gateway) Ping(db *database.Database) (held bool, err error) {
+	coll := db.Gateways()
+
+	query := bson.M{
+		"_id":       g.Id,
+		"timestamp": g.Timestamp,
+	}
+
+	if !g.Node.IsZero() {
+		query["node"] = g.Node
+	}
+
+	timestamp := time.Now()
+
+	_, err = coll.UpdateOne(
+		db,
+		query,
+		&bson.M{
+			"$set": &bson.M{
+				"node":      node.Self.Id,
+				"timestamp": timestamp,
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		} else {
+			return
+		}
+	} else {
+		g.Node = node.Self.Id
+		g.Timestamp = timestamp
+		held = true
+	}
+
+	return
+}
+
+func (g *Gateway) PreCommit() (curVpc, curSubnet primitive.ObjectID) {
+	curVpc = g.Vpc
+	curSubnet = g.Subnet
+	return
+}
+
+func (g *Gateway) PostCommit(db *database.Database,
+	curVpc, curSubnet primitive.ObjectID) (err error) {
+
+	if curVpc != g.Vpc || curSubnet != g.Subnet {
+		err = vpc.RemoveInstanceIp(db, g.Id, curVpc)
+		if err != nil {
+			return
+		}
+
+		g.PrivateIp = ""
+	}
+
+	return
+}
+
+func (g *Gateway) Commit(db *database.Database) (err error) {
+	coll := db.Gateways()
+
+	err = coll.Commit(g.Id, g)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (g *Gateway) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.Gateways()
+
+	err = coll.CommitFields(g.Id, g, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (g *Gateway) Insert(db *database.Database) (err error) {
+	coll := db.Gateways()
+
+	if !g.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("gateway: Gateway already exists"),
+		}
+		return
+	}
+
+	_, err = coll.InsertOne(db, g)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,298 @@
+package gateway
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/block"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vpc"
+)
+
+func Get(db *database.Database, gatewayId primitive.ObjectID) (
+	gw *Gateway, err error) {
+
+	coll := db.Gateways()
+	gw = &Gateway{}
+
+	err = coll.FindOneId(gatewayId, gw)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetOrg(db *database.Database, orgId, gatewayId primitive.ObjectID) (
+	gw *Gateway, err error) {
+
+	coll := db.Gateways()
+	gw = &Gateway{}
+
+	err = coll.FindOne(db, &bson.M{
+		"_id":          gatewayId,
+		"organization": orgId,
+	}).Decode(gw)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func ExistsOrg(db *database.Database, orgId, gatewayId primitive.ObjectID) (
+	exists bool, err error) {
+
+	coll := db.Gateways()
+
+	n, err := coll.CountDocuments(db, &bson.M{
+		"_id":          gatewayId,
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if n > 0 {
+		exists = true
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M) (
+	gws []*Gateway, err error) {
+
+	coll := db.Gateways()
+	gws = []*Gateway{}
+
+	cursor, err := coll.Find(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		gw := &Gateway{}
+		err = cursor.Decode(gw)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		gws = append(gws, gw)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetDatacenter(db *database.Database, dcId primitive.ObjectID) (
+	gws []*Gateway, err error) {
+
+	gws, err = GetAll(db, &bson.M{
+		"datacenter": dcId,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetAllPaged(db *database.Database, query *bson.M,
+	page, pageCount int64) (gws []*Gateway, count int64, err error) {
+
+	coll := db.Gateways()
+	gws = []*Gateway{}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	page = utils.Min64(page, count/pageCount)
+	skip := utils.Min64(page*pageCount, count)
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"name", 1},
+			},
+			Skip:  &skip,
+			Limit: &pageCount,
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		gw := &Gateway{}
+		err = cursor.Decode(gw)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		gws = append(gws, gw)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func release(db *database.Database, gatewayIds []primitive.ObjectID) (
+	err error) {
+
+	for _, gatewayId := range gatewayIds {
+		err = block.RemoveInstanceIps(db, gatewayId)
+		if err != nil {
+			return
+		}
+
+		err = vpc.RemoveInstanceIps(db, gatewayId)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func Remove(db *database.Database, gatewayId primitive.ObjectID) (err error) {
+	coll := db.Gateways()
+
+	err = release(db, []primitive.ObjectID{gatewayId})
+	if err != nil {
+		return
+	}
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": gatewayId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveOrg(db *database.Database, orgId, gatewayId primitive.ObjectID) (
+	err error) {
+
+	coll := db.Gateways()
+
+	exists, err := ExistsOrg(db, orgId, gatewayId)
+	if err != nil || !exists {
+		return
+	}
+
+	err = release(db, []primitive.ObjectID{gatewayId})
+	if err != nil {
+		return
+	}
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id":          gatewayId,
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveMulti(db *database.Database, gatewayIds []primitive.ObjectID) (
+	err error) {
+
+	coll := db.Gateways()
+
+	err = release(db, gatewayIds)
+	if err != nil {
+		return
+	}
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": gatewayIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func RemoveMultiOrg(db *database.Database, orgId primitive.ObjectID,
+	gatewayIds []primitive.ObjectID) (err error) {
+
+	coll := db.Gateways()
+
+	gws, err := GetAll(db, &bson.M{
+		"_id": &bson.M{
+			"$in": gatewayIds,
+		},
+		"organization": orgId,
+	})
+	if err != nil {
+		return
+	}
+
+	orgGatewayIds := []primitive.ObjectID{}
+	for _, gw := range gws {
+		orgGatewayIds = append(orgGatewayIds, gw.Id)
+	}
+
+	err = release(db, orgGatewayIds)
+	if err != nil {
+		return
+	}
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": orgGatewayIds,
+		},
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -11,6 +11,8 @@ const (
 	Cleanup   = "cleanup"
 	Restart   = "restart"
 	Destroy   = "destroy"
+
+	MaxNetworkInterfaces = 3
 )
 
 var (
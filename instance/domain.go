@@ -0,0 +1,157 @@
+package instance
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/domain"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+func validateNames(names []string) (parsed []string, valid bool) {
+	parsed = []string{}
+	parsedSet := set.NewSet()
+
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+
+		name, ok := domain.ValidateName(name, false)
+		if !ok {
+			return
+		}
+
+		if parsedSet.Contains(name) {
+			continue
+		}
+		parsedSet.Add(name)
+
+		parsed = append(parsed, name)
+	}
+
+	valid = true
+	return
+}
+
+func (i *Instance) validateDomain() (errData *errortypes.ErrorData) {
+	if i.Domain.IsZero() {
+		i.DomainNames = []string{}
+		i.DomainAliases = []string{}
+		i.DomainTtl = 0
+		i.DomainPtr = false
+		return
+	}
+
+	names, valid := validateNames(i.DomainNames)
+	if !valid {
+		errData = &errortypes.ErrorData{
+			Error:   "domain_names_invalid",
+			Message: "Invalid instance domain name",
+		}
+		return
+	}
+	i.DomainNames = names
+
+	aliases, valid := validateNames(i.DomainAliases)
+	if !valid {
+		errData = &errortypes.ErrorData{
+			Error:   "domain_aliases_invalid",
+			Message: "Invalid instance domain alias",
+		}
+		return
+	}
+
+	namesSet := set.NewSet()
+	for _, name := range i.GetDomainNames() {
+		namesSet.Add(name)
+	}
+
+	for _, alias := range aliases {
+		if namesSet.Contains(alias) {
+			errData = &errortypes.ErrorData{
+				Error:   "domain_alias_conflict",
+				Message: "Instance domain alias conflicts with domain name",
+			}
+			return
+		}
+	}
+	i.DomainAliases = aliases
+
+	if i.DomainTtl != 0 && !domain.ValidTtl(i.DomainTtl) {
+		errData = &errortypes.ErrorData{
+			Error:   "domain_ttl_invalid",
+			Message: "Invalid instance domain TTL",
+		}
+		return
+	}
+
+	return
+}
+
+func (i *Instance) validateDomainConflict(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if i.Domain.IsZero() {
+		return
+	}
+
+	names := append([]string{}, i.GetDomainNames()...)
+	names = append(names, i.DomainAliases...)
+
+	coll := db.Instances()
+	count, err := coll.CountDocuments(db, &bson.M{
+		"_id": &bson.M{
+			"$ne": i.Id,
+		},
+		"domain": i.Domain,
+		"$or": []*bson.M{
+			&bson.M{
+				"domain_names": &bson.M{
+					"$in": names,
+				},
+			},
+			&bson.M{
+				"domain_aliases": &bson.M{
+					"$in": names,
+				},
+			},
+			&bson.M{
+				"domain_names": &bson.M{
+					"$size": 0,
+				},
+				"name": &bson.M{
+					"$in": names,
+				},
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if count > 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "domain_name_conflict",
+			Message: "Instance domain name or alias used by another instance",
+		}
+		return
+	}
+
+	return
+}
+
+func (i *Instance) GetDomainNames() []string {
+	if len(i.DomainNames) > 0 {
+		return i.DomainNames
+	}
+	return []string{i.Name}
+}
+
+func (i *Instance) GetDomainTtl() int {
+	if i.DomainTtl == 0 {
+		return domain.DefaultTtl
+	}
+	return i.DomainTtl
+}
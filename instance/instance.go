@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
@@ -11,6 +12,7 @@ import (
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/gorilla/websocket"
+	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/block"
 	"github.com/pritunl/pritunl-cloud/database"
@@ -28,49 +30,58 @@ import (
 )
 
 type Instance struct {
-	Id                  primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Organization        primitive.ObjectID `bson:"organization" json:"organization"`
-	Zone                primitive.ObjectID `bson:"zone" json:"zone"`
-	Vpc                 primitive.ObjectID `bson:"vpc" json:"vpc"`
-	Subnet              primitive.ObjectID `bson:"subnet" json:"subnet"`
-	Image               primitive.ObjectID `bson:"image" json:"image"`
-	ImageBacking        bool               `bson:"image_backing" json:"image_backing"`
-	Status              string             `bson:"-" json:"status"`
-	Uptime              string             `bson:"-" json:"uptime"`
-	State               string             `bson:"state" json:"state"`
-	PublicMac           string             `bson:"-" json:"public_mac"`
-	VmState             string             `bson:"vm_state" json:"vm_state"`
-	VmTimestamp         time.Time          `bson:"vm_timestamp" json:"vm_timestamp"`
-	Restart             bool               `bson:"restart" json:"restart"`
-	RestartBlockIp      bool               `bson:"restart_block_ip" json:"restart_block_ip"`
-	DeleteProtection    bool               `bson:"delete_protection" json:"delete_protection"`
-	PublicIps           []string           `bson:"public_ips" json:"public_ips"`
-	PublicIps6          []string           `bson:"public_ips6" json:"public_ips6"`
-	PrivateIps          []string           `bson:"private_ips" json:"private_ips"`
-	PrivateIps6         []string           `bson:"private_ips6" json:"private_ips6"`
-	HostIps             []string           `bson:"host_ips" json:"host_ips"`
-	NetworkNamespace    string             `bson:"network_namespace" json:"network_namespace"`
-	NoPublicAddress     bool               `bson:"no_public_address" json:"no_public_address"`
-	NoHostAddress       bool               `bson:"no_host_address" json:"no_host_address"`
-	Node                primitive.ObjectID `bson:"node" json:"node"`
-	Domain              primitive.ObjectID `bson:"domain,omitempty" json:"domain"`
-	Name                string             `bson:"name" json:"name"`
-	Comment             string             `bson:"comment" json:"comment"`
-	InitDiskSize        int                `bson:"init_disk_size" json:"init_disk_size"`
-	Memory              int                `bson:"memory" json:"memory"`
-	Processors          int                `bson:"processors" json:"processors"`
-	NetworkRoles        []string           `bson:"network_roles" json:"network_roles"`
-	UsbDevices          []*usb.Device      `bson:"usb_devices" json:"usb_devices"`
-	Vnc                 bool               `bson:"vnc" json:"vnc"`
-	VncPassword         string             `bson:"vnc_password" json:"vnc_password"`
-	VncDisplay          int                `bson:"vnc_display,omitempty" json:"vnc_display"`
-	Virt                *vm.VirtualMachine `bson:"-" json:"-"`
-	curVpc              primitive.ObjectID `bson:"-" json:"-"`
-	curSubnet           primitive.ObjectID `bson:"-" json:"-"`
-	curDeleteProtection bool               `bson:"-" json:"-"`
-	curState            string             `bson:"-" json:"-"`
-	curNoPublicAddress  bool               `bson:"-" json:"-"`
-	curNoHostAddress    bool               `bson:"-" json:"-"`
+	Id                  primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	Organization        primitive.ObjectID  `bson:"organization" json:"organization"`
+	Zone                primitive.ObjectID  `bson:"zone" json:"zone"`
+	Vpc                 primitive.ObjectID  `bson:"vpc" json:"vpc"`
+	Subnet              primitive.ObjectID  `bson:"subnet" json:"subnet"`
+	Image               primitive.ObjectID  `bson:"image" json:"image"`
+	ImageBacking        bool                `bson:"image_backing" json:"image_backing"`
+	Status              string              `bson:"-" json:"status"`
+	Uptime              string              `bson:"-" json:"uptime"`
+	State               string              `bson:"state" json:"state"`
+	PublicMac           string              `bson:"-" json:"public_mac"`
+	VmState             string              `bson:"vm_state" json:"vm_state"`
+	VmTimestamp         time.Time           `bson:"vm_timestamp" json:"vm_timestamp"`
+	Restart             bool                `bson:"restart" json:"restart"`
+	RestartBlockIp      bool                `bson:"restart_block_ip" json:"restart_block_ip"`
+	DeleteProtection    bool                `bson:"delete_protection" json:"delete_protection"`
+	PublicIps           []string            `bson:"public_ips" json:"public_ips"`
+	PublicIps6          []string            `bson:"public_ips6" json:"public_ips6"`
+	PrivateIps          []string            `bson:"private_ips" json:"private_ips"`
+	PrivateIps6         []string            `bson:"private_ips6" json:"private_ips6"`
+	PrivateIp           string              `bson:"private_ip" json:"private_ip"`
+	HostIps             []string            `bson:"host_ips" json:"host_ips"`
+	NetworkNamespace    string              `bson:"network_namespace" json:"network_namespace"`
+	NoPublicAddress     bool                `bson:"no_public_address" json:"no_public_address"`
+	NoHostAddress       bool                `bson:"no_host_address" json:"no_host_address"`
+	Node                primitive.ObjectID  `bson:"node" json:"node"`
+	Domain              primitive.ObjectID  `bson:"domain,omitempty" json:"domain"`
+	DomainNames         []string            `bson:"domain_names" json:"domain_names"`
+	DomainAliases       []string            `bson:"domain_aliases" json:"domain_aliases"`
+	DomainTtl           int                 `bson:"domain_ttl" json:"domain_ttl"`
+	DomainPtr           bool                `bson:"domain_ptr" json:"domain_ptr"`
+	GuestCertificate    bool                `bson:"guest_certificate" json:"guest_certificate"`
+	Name                string              `bson:"name" json:"name"`
+	Comment             string              `bson:"comment" json:"comment"`
+	InitDiskSize        int                 `bson:"init_disk_size" json:"init_disk_size"`
+	Memory              int                 `bson:"memory" json:"memory"`
+	Processors          int                 `bson:"processors" json:"processors"`
+	NetworkRoles        []string            `bson:"network_roles" json:"network_roles"`
+	NetworkInterfaces   []*NetworkInterface `bson:"network_interfaces" json:"network_interfaces"`
+	UsbDevices          []*usb.Device       `bson:"usb_devices" json:"usb_devices"`
+	Vnc                 bool                `bson:"vnc" json:"vnc"`
+	VncPassword         string              `bson:"vnc_password" json:"vnc_password"`
+	VncDisplay          int                 `bson:"vnc_display,omitempty" json:"vnc_display"`
+	Virt                *vm.VirtualMachine  `bson:"-" json:"-"`
+	curVpc              primitive.ObjectID  `bson:"-" json:"-"`
+	curSubnet           primitive.ObjectID  `bson:"-" json:"-"`
+	curPrivateIp        string              `bson:"-" json:"-"`
+	curInterfaces       []*NetworkInterface `bson:"-" json:"-"`
+	curDeleteProtection bool                `bson:"-" json:"-"`
+	curState            string              `bson:"-" json:"-"`
+	curNoPublicAddress  bool                `bson:"-" json:"-"`
+	curNoHostAddress    bool                `bson:"-" json:"-"`
 }
 
 func (i *Instance) Validate(db *database.Database) (
@@ -155,6 +166,104 @@ func (i *Instance) Validate(db *database.Database) (
 		return
 	}
 
+	if i.PrivateIp != "" {
+		var privateIp net.IP
+		privateIp, errData, err = vc.ValidateIp(
+			db, i.Subnet, i.Id, i.PrivateIp)
+		if err != nil || errData != nil {
+			return
+		}
+
+		i.PrivateIp = privateIp.String()
+	}
+
+	if i.NetworkInterfaces == nil {
+		i.NetworkInterfaces = []*NetworkInterface{}
+	}
+
+	if len(i.NetworkInterfaces) > MaxNetworkInterfaces {
+		errData = &errortypes.ErrorData{
+			Error:   "network_interfaces_invalid",
+			Message: "Too many network interfaces",
+		}
+		return
+	}
+
+	curInterfaces := map[primitive.ObjectID]*NetworkInterface{}
+	if i.curInterfaces != nil {
+		for _, iface := range i.curInterfaces {
+			curInterfaces[iface.Id] = iface
+		}
+	}
+
+	ifaceVpcs := set.NewSet(i.Vpc)
+	for _, iface := range i.NetworkInterfaces {
+		if iface.Vpc.IsZero() {
+			errData = &errortypes.ErrorData{
+				Error:   "network_interface_vpc_required",
+				Message: "Missing required network interface VPC",
+			}
+			return
+		}
+
+		if ifaceVpcs.Contains(iface.Vpc) {
+			errData = &errortypes.ErrorData{
+				Error:   "network_interface_vpc_duplicate",
+				Message: "Network interfaces must be on different VPCs",
+			}
+			return
+		}
+		ifaceVpcs.Add(iface.Vpc)
+
+		ifaceVc, e := vpc.Get(db, iface.Vpc)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if ifaceVc.Organization != i.Organization {
+			errData = &errortypes.ErrorData{
+				Error:   "network_interface_vpc_invalid",
+				Message: "Network interface VPC invalid",
+			}
+			return
+		}
+
+		if ifaceVc.GetSubnet(iface.Subnet) == nil {
+			errData = &errortypes.ErrorData{
+				Error:   "network_interface_subnet_missing",
+				Message: "Network interface VPC subnet does not exist",
+			}
+			return
+		}
+
+		if iface.NetworkRoles == nil {
+			iface.NetworkRoles = []string{}
+		}
+
+		curIface := curInterfaces[iface.Id]
+		if iface.Id.IsZero() || curIface == nil {
+			iface.Id = primitive.NewObjectID()
+			iface.PublicIp = ""
+			iface.PrivateIp = ""
+			iface.PrivateIp6 = ""
+		} else if curIface.Vpc != iface.Vpc ||
+			curIface.Subnet != iface.Subnet {
+
+			iface.PublicIp = ""
+			iface.PrivateIp = ""
+			iface.PrivateIp6 = ""
+		} else {
+			iface.PublicIp = curIface.PublicIp
+			iface.PrivateIp = curIface.PrivateIp
+			iface.PrivateIp6 = curIface.PrivateIp6
+		}
+
+		if !iface.PublicAddress {
+			iface.PublicIp = ""
+		}
+	}
+
 	if i.InitDiskSize != 0 && i.InitDiskSize < 10 {
 		errData = &errortypes.ErrorData{
 			Error:   "init_disk_size_invalid",
@@ -209,6 +318,35 @@ func (i *Instance) Validate(db *database.Database) (
 		}
 	}
 
+	errData = i.validateDomain()
+	if errData != nil {
+		return
+	}
+
+	errData, err = i.validateDomainConflict(db)
+	if err != nil || errData != nil {
+		return
+	}
+
+	if i.NoPublicAddress && !i.Id.IsZero() {
+		coll := db.FloatingIps()
+		count, e := coll.CountDocuments(db, &bson.M{
+			"instance": i.Id,
+		})
+		if e != nil {
+			err = database.ParseError(e)
+			return
+		}
+
+		if count > 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "floating_ip_no_public_address",
+				Message: "Floating IP must be detached to remove public address",
+			}
+			return
+		}
+	}
+
 	if i.Vnc {
 		if i.VncDisplay == 0 {
 			i.VncDisplay = rand.Intn(9998) + 4101
@@ -335,6 +473,8 @@ func (i *Instance) IsActive() bool {
 func (i *Instance) PreCommit() {
 	i.curVpc = i.Vpc
 	i.curSubnet = i.Subnet
+	i.curPrivateIp = i.PrivateIp
+	i.curInterfaces = i.NetworkInterfaces
 	i.curDeleteProtection = i.DeleteProtection
 	i.curState = i.State
 	i.curNoPublicAddress = i.NoPublicAddress
@@ -353,6 +493,42 @@ func (i *Instance) PostCommit(db *database.Database) (
 		}
 	}
 
+	if i.curInterfaces != nil {
+		newInterfaces := map[primitive.ObjectID]*NetworkInterface{}
+		for _, iface := range i.NetworkInterfaces {
+			newInterfaces[iface.Id] = iface
+		}
+
+		for _, curIface := range i.curInterfaces {
+			iface := newInterfaces[curIface.Id]
+
+			if iface == nil || iface.Vpc != curIface.Vpc ||
+				iface.Subnet != curIface.Subnet {
+
+				err = vpc.RemoveInstanceIp(db, i.Id, curIface.Vpc)
+				if err != nil {
+					return
+				}
+			}
+
+			if iface == nil || !iface.PublicAddress {
+				err = block.RemoveInstanceIps(db, curIface.Id)
+				if err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	if i.PrivateIp != "" && (i.curPrivateIp != i.PrivateIp ||
+		i.curVpc != i.Vpc || i.curSubnet != i.Subnet) {
+
+		err = i.reservePrivateIp(db)
+		if err != nil {
+			return
+		}
+	}
+
 	if i.curDeleteProtection != i.DeleteProtection {
 		dskChange = true
 
@@ -426,6 +602,35 @@ func (i *Instance) Insert(db *database.Database) (err error) {
 		return
 	}
 
+	if i.PrivateIp != "" {
+		err = i.reservePrivateIp(db)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (i *Instance) reservePrivateIp(db *database.Database) (err error) {
+	vc, err := vpc.Get(db, i.Vpc)
+	if err != nil {
+		return
+	}
+
+	privateIp := net.ParseIP(i.PrivateIp)
+	if privateIp == nil {
+		err = &errortypes.ParseError{
+			errors.New("instance: Failed to parse private address"),
+		}
+		return
+	}
+
+	err = vc.SetIp(db, i.Subnet, i.Id, privateIp)
+	if err != nil {
+		return
+	}
+
 	return
 }
 
@@ -451,6 +656,20 @@ func (i *Instance) LoadVirt(disks []*disk.Disk) {
 		UsbDevices:      []*vm.UsbDevice{},
 	}
 
+	if i.NetworkInterfaces != nil {
+		for _, iface := range i.NetworkInterfaces {
+			i.Virt.NetworkAdapters = append(i.Virt.NetworkAdapters,
+				&vm.NetworkAdapter{
+					Type:          vm.Bridge,
+					MacAddress:    vm.GetMacAddr(i.Id, iface.Vpc),
+					Vpc:           iface.Vpc,
+					Subnet:        iface.Subnet,
+					Interface:     iface.Id,
+					PublicAddress: iface.PublicAddress,
+				})
+		}
+	}
+
 	if disks != nil {
 		for _, dsk := range disks {
 			index, err := strconv.Atoi(dsk.Index)
@@ -488,6 +707,12 @@ func (i *Instance) Changed(curVirt *vm.VirtualMachine) bool {
 		return true
 	}
 
+	if i.PrivateIp != "" && len(i.PrivateIps) > 0 &&
+		i.PrivateIps[0] != i.PrivateIp {
+
+		return true
+	}
+
 	for i, adapter := range i.Virt.NetworkAdapters {
 		if len(curVirt.NetworkAdapters) <= i {
 			return true
@@ -500,6 +725,16 @@ func (i *Instance) Changed(curVirt *vm.VirtualMachine) bool {
 		if adapter.Subnet != curVirt.NetworkAdapters[i].Subnet {
 			return true
 		}
+
+		if adapter.PublicAddress !=
+			curVirt.NetworkAdapters[i].PublicAddress {
+
+			return true
+		}
+	}
+
+	if len(i.Virt.NetworkAdapters) != len(curVirt.NetworkAdapters) {
+		return true
 	}
 
 	if i.Virt.UsbDevices != nil {
@@ -0,0 +1,16 @@
+package instance
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+)
+
+type NetworkInterface struct {
+	Id            primitive.ObjectID `bson:"id" json:"id"`
+	Vpc           primitive.ObjectID `bson:"vpc" json:"vpc"`
+	Subnet        primitive.ObjectID `bson:"subnet" json:"subnet"`
+	NetworkRoles  []string           `bson:"network_roles" json:"network_roles"`
+	PublicAddress bool               `bson:"public_address" json:"public_address"`
+	PublicIp      string             `bson:"public_ip" json:"public_ip"`
+	PrivateIp     string             `bson:"private_ip" json:"private_ip"`
+	PrivateIp6    string             `bson:"private_ip6" json:"private_ip6"`
+}
@@ -318,11 +318,37 @@ func Remove(db *database.Database, instId primitive.ObjectID) (err error) {
 		return
 	}
 
+	if inst.NetworkInterfaces != nil {
+		for _, iface := range inst.NetworkInterfaces {
+			err = block.RemoveInstanceIps(db, iface.Id)
+			if err != nil {
+				return
+			}
+		}
+	}
+
 	err = vpc.RemoveInstanceIps(db, instId)
 	if err != nil {
 		return
 	}
 
+	_, err = db.FloatingIps().UpdateMany(db, &bson.M{
+		"instance": instId,
+	}, &bson.M{
+		"$unset": &bson.M{
+			"instance": 1,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
 	_, err = coll.DeleteOne(db, &bson.M{
 		"_id": instId,
 	})
@@ -1,32 +1,51 @@
 package ipsec
 
 import (
-	"html/template"
+	"text/template"
 )
 
 const (
+	wgIface = "wg0"
+
 	confTemplateStr = `conn {{.Id}}
-	ikelifetime=8h
-	keylife=1h
-	rekeymargin=9m
+	ikelifetime={{.IkeLifetime}}s
+	keylife={{.KeyLifetime}}s
+	rekeymargin={{.RekeyMargin}}s
 	keyingtries=%forever
+{{- if .Certificate}}
+	authby=pubkey
+{{- else}}
 	authby=secret
-	keyexchange=ikev2
+{{- end}}
+	keyexchange={{.IkeVersion}}
+{{- if .IkeProposals}}
+	ike={{.IkeProposals}}!
+{{- end}}
+{{- if .EspProposals}}
+	esp={{.EspProposals}}!
+{{- end}}
 	mobike=no
-	dpddelay=5s
-	dpdtimeout=20s
-	dpdaction=restart
+{{- if .DpdDelay}}
+	dpddelay={{.DpdDelay}}s
+	dpdtimeout={{.DpdTimeout}}s
+{{- end}}
+	dpdaction={{.DpdAction}}
 	left=%defaultroute
+{{- if .Certificate}}
+	leftcert={{.Certificate}}
+{{- else}}
 	leftid={{.Left}}
+{{- end}}
 	leftsubnet={{.LeftSubnets}}
 	leftfirewall=yes
 	right={{.Right}}
-	rightid={{.Right}}
+	rightid="{{.RightId}}"
 	rightsubnet={{.RightSubnets}}
 	auto=start
 `
-	secretsTemplateStr = `{{.Left}} {{.Right}} : PSK "{{.PreSharedKey}}"
-`
+	secretsTemplateStr = `{{if .Certificate}}: {{.PrivateKeyType}} {{.Certificate}}
+{{else}}{{.Left}} "{{.RightId}}" : PSK "{{.PreSharedKey}}"
+{{end}}`
 )
 
 var (
@@ -33,6 +33,34 @@ func addRoutes(db *database.Database, vc *vpc.Vpc, states []*link.State,
 		}
 	}
 
+	if vc.WgPeers != nil {
+		for _, peer := range vc.WgPeers {
+			for _, dst := range peer.AllowedIps {
+				if strings.Contains(dst, ":") {
+					routes = append(routes, &vpc.Route{
+						Destination: dst,
+						Target:      addr6,
+						Link:        true,
+					})
+				} else {
+					routes = append(routes, &vpc.Route{
+						Destination: dst,
+						Target:      addr,
+						Link:        true,
+					})
+				}
+			}
+		}
+	}
+
+	if vc.ClientPool != "" {
+		routes = append(routes, &vpc.Route{
+			Destination: vc.ClientPool,
+			Target:      addr,
+			Link:        true,
+		})
+	}
+
 	err = vc.AddLinkRoutes(db, routes)
 	if err != nil {
 		return
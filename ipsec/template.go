@@ -2,6 +2,9 @@ package ipsec
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"path"
@@ -11,16 +14,118 @@ import (
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/link"
+	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/sirupsen/logrus"
 )
 
 type templateData struct {
-	Id           string
-	Left         string
-	LeftSubnets  string
-	Right        string
-	RightSubnets string
-	PreSharedKey string
+	Id             string
+	Left           string
+	LeftSubnets    string
+	Right          string
+	RightId        string
+	RightSubnets   string
+	PreSharedKey   string
+	Certificate    string
+	PrivateKeyType string
+	IkeVersion     string
+	IkeProposals   string
+	EspProposals   string
+	IkeLifetime    int
+	KeyLifetime    int
+	RekeyMargin    int
+	DpdDelay       int
+	DpdTimeout     int
+	DpdAction      string
+}
+
+func getPrivateKeyType(key string) string {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return "RSA"
+	}
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		return "ECDSA"
+	case "PRIVATE KEY":
+		privKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err == nil {
+			if _, ok := privKey.(*ecdsa.PrivateKey); ok {
+				return "ECDSA"
+			}
+		}
+	}
+
+	return "RSA"
+}
+
+func writeCertificate(baseDir, name, cert, key, remoteCa string) (
+	err error) {
+
+	certs := []string{}
+	rest := []byte(cert)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		certs = append(certs, string(pem.EncodeToMemory(block)))
+	}
+
+	if len(certs) == 0 {
+		err = &errortypes.ParseError{
+			errors.New("ipsec: Failed to parse certificate"),
+		}
+		return
+	}
+
+	pth := path.Join(baseDir, "ipsec.d", "certs", name)
+	err = ioutil.WriteFile(pth, []byte(certs[0]), 0644)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "ipsec: Failed to write certificate"),
+		}
+		return
+	}
+
+	if len(certs) > 1 {
+		pth = path.Join(baseDir, "ipsec.d", "cacerts", name)
+		err = ioutil.WriteFile(
+			pth, []byte(strings.Join(certs[1:], "")), 0644)
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "ipsec: Failed to write ca certificate"),
+			}
+			return
+		}
+	}
+
+	if remoteCa != "" {
+		pth = path.Join(baseDir, "ipsec.d", "cacerts", "remote-"+name)
+		err = ioutil.WriteFile(pth, []byte(remoteCa+"\n"), 0644)
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "ipsec: Failed to write remote ca"),
+			}
+			return
+		}
+	}
+
+	pth = path.Join(baseDir, "ipsec.d", "private", name)
+	err = ioutil.WriteFile(pth, []byte(key), 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "ipsec: Failed to write private key"),
+		}
+		return
+	}
+
+	return
 }
 
 func writeTemplates(vpcId primitive.ObjectID,
@@ -32,6 +137,20 @@ func writeTemplates(vpcId primitive.ObjectID,
 	confBuf := &bytes.Buffer{}
 	secretsBuf := &bytes.Buffer{}
 
+	for _, dir := range []string{"certs", "cacerts", "private"} {
+		dirPth := path.Join(baseDir, "ipsec.d", dir)
+
+		err = utils.RemoveAll(dirPth)
+		if err != nil {
+			return
+		}
+
+		err = utils.ExistsMkdir(dirPth, 0700)
+		if err != nil {
+			return
+		}
+	}
+
 	for _, stat := range states {
 		for i, lnk := range stat.Links {
 			leftSubnets := strings.Join(lnk.LeftSubnets, ",")
@@ -44,13 +163,77 @@ func writeTemplates(vpcId primitive.ObjectID,
 				left = stat.PublicAddr
 			}
 
+			if strings.ContainsAny(lnk.RightId+lnk.PreSharedKey,
+				"\"\\\r\n") {
+
+				logrus.WithFields(logrus.Fields{
+					"state_id": stat.Id,
+					"right":    lnk.Right,
+				}).Warn("ipsec: Skipping link with invalid identity or secret")
+				continue
+			}
+
+			rightId := lnk.RightId
+			if rightId == "" {
+				if lnk.Certificate != "" {
+					rightId = "%any"
+				} else {
+					rightId = lnk.Right
+				}
+			}
+
 			data := &templateData{
 				Id:           fmt.Sprintf("%s-%d", stat.Id, i),
 				Left:         left,
 				LeftSubnets:  leftSubnets,
 				Right:        lnk.Right,
+				RightId:      rightId,
 				RightSubnets: rightSubnets,
 				PreSharedKey: lnk.PreSharedKey,
+				IkeVersion:   lnk.IkeVersion,
+				IkeProposals: strings.Join(lnk.IkeProposals, ","),
+				EspProposals: strings.Join(lnk.EspProposals, ","),
+				IkeLifetime:  lnk.IkeLifetime,
+				KeyLifetime:  lnk.KeyLifetime,
+				RekeyMargin:  lnk.RekeyMargin,
+				DpdDelay:     lnk.DpdDelay,
+				DpdTimeout:   lnk.DpdTimeout,
+				DpdAction:    lnk.DpdAction,
+			}
+
+			if data.IkeVersion == "" {
+				data.IkeVersion = vpc.Ikev2
+			}
+			if data.IkeLifetime == 0 {
+				data.IkeLifetime = vpc.DefaultIkeLifetime
+			}
+			if data.KeyLifetime == 0 {
+				data.KeyLifetime = vpc.DefaultKeyLifetime
+			}
+			if data.RekeyMargin == 0 {
+				data.RekeyMargin = vpc.DefaultRekeyMargin
+			}
+			if data.DpdAction == "" {
+				data.DpdAction = vpc.DpdRestart
+			}
+			if data.DpdAction != vpc.DpdNone {
+				if data.DpdDelay == 0 {
+					data.DpdDelay = vpc.DefaultDpdDelay
+				}
+				if data.DpdTimeout == 0 {
+					data.DpdTimeout = vpc.DefaultDpdTimeout
+				}
+			}
+
+			if lnk.Certificate != "" {
+				data.Certificate = fmt.Sprintf("%s.pem", data.Id)
+				data.PrivateKeyType = getPrivateKeyType(lnk.PrivateKey)
+
+				err = writeCertificate(baseDir, data.Certificate,
+					lnk.Certificate, lnk.PrivateKey, lnk.RemoteCa)
+				if err != nil {
+					return
+				}
 			}
 
 			err = confTemplate.Execute(confBuf, data)
@@ -96,6 +96,7 @@ func deployVpc(vc *vpc.Vpc) {
 
 	states := link.GetStates(vc.Id, vc.LinkUris,
 		netAddr.String(), pubAddr, pubAddr6)
+	states = append(states, getVpnStates(db, vc, pubAddr, pubAddr6)...)
 	hsh := md5.New()
 
 	names := set.NewSet()
@@ -141,6 +142,23 @@ func deployVpc(vc *vpc.Vpc) {
 		}).Info("ipsec: Failed to get status")
 	}
 
+	err = updateVpnStatus(db, vc)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"vpc_id": vc.Id.Hex(),
+			"error":  err,
+		}).Error("ipsec: Failed to update vpn status")
+	}
+
+	err = deployWg(db, vc, states, netAddr.String(), netAddr6.String(),
+		pubAddr, pubAddr6)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"vpc_id": vc.Id.Hex(),
+			"error":  err,
+		}).Error("ipsec: Failed to deploy wireguard")
+	}
+
 	if resetLinks != nil && len(resetLinks) != 0 {
 		logrus.WithFields(logrus.Fields{
 			"vpc_id": vc.Id.Hex(),
@@ -168,6 +186,8 @@ func removeVpc(vcId primitive.ObjectID) {
 	delete(link.Hashes, vcId)
 	link.HashesLock.Unlock()
 
+	removeWg(vcId)
+
 	err := destroyIpsec(vcId)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
@@ -0,0 +1,134 @@
+package ipsec
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+
+	"github.com/pritunl/pritunl-cloud/certificate"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/link"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/sirupsen/logrus"
+)
+
+func getVpnStates(db *database.Database, vc *vpc.Vpc,
+	pubAddr, pubAddr6 string) (states []*link.State) {
+
+	states = []*link.State{}
+
+	if vc.VpnConnections == nil {
+		return
+	}
+
+	for _, conn := range vc.VpnConnections {
+		hsh := md5.New()
+		io.WriteString(hsh, conn.Hash())
+
+		lnk := &link.Link{
+			Right:        conn.RemoteGateway,
+			RightId:      conn.RemoteId,
+			LeftSubnets:  conn.LocalSubnets,
+			RightSubnets: conn.RemoteSubnets,
+			IkeVersion:   conn.IkeVersion,
+			IkeProposals: conn.IkeProposals,
+			EspProposals: conn.EspProposals,
+			IkeLifetime:  conn.IkeLifetime,
+			KeyLifetime:  conn.KeyLifetime,
+			RekeyMargin:  conn.RekeyMargin,
+			DpdDelay:     conn.DpdDelay,
+			DpdTimeout:   conn.DpdTimeout,
+			DpdAction:    conn.DpdAction,
+		}
+
+		if conn.AuthMode == vpc.VpnCertificate {
+			cert, err := certificate.GetOrg(
+				db, vc.Organization, conn.Certificate)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"vpc_id":         vc.Id.Hex(),
+					"vpn_id":         conn.Id.Hex(),
+					"certificate_id": conn.Certificate.Hex(),
+					"error":          err,
+				}).Error("ipsec: Failed to get vpn certificate")
+				continue
+			}
+
+			errData := conn.ValidateCertificate(cert)
+			if errData != nil {
+				logrus.WithFields(logrus.Fields{
+					"vpc_id":         vc.Id.Hex(),
+					"vpn_id":         conn.Id.Hex(),
+					"certificate_id": conn.Certificate.Hex(),
+					"error_code":     errData.Error,
+					"error_msg":      errData.Message,
+				}).Error("ipsec: Invalid vpn certificate")
+				continue
+			}
+
+			lnk.Certificate = cert.Certificate
+			lnk.PrivateKey = cert.Key
+			lnk.RemoteCa = conn.RemoteCa
+			io.WriteString(hsh, cert.Hash())
+		} else {
+			lnk.PreSharedKey = conn.PreSharedKey
+		}
+
+		states = append(states, &link.State{
+			Id:          conn.Id.Hex(),
+			VpcId:       vc.Id,
+			Ipv6:        conn.IsIpv6(),
+			Type:        "vpn",
+			Hash:        hex.EncodeToString(hsh.Sum(nil)),
+			Links:       []*link.Link{lnk},
+			PublicAddr:  pubAddr,
+			PublicAddr6: pubAddr6,
+		})
+	}
+
+	return
+}
+
+func updateVpnStatus(db *database.Database, vc *vpc.Vpc) (err error) {
+	if vc.VpnConnections == nil || len(vc.VpnConnections) == 0 {
+		if len(vc.VpnStatus) != 0 {
+			err = vc.UpdateVpnStatus(db, map[string]string{})
+			if err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	link.LinkStatusLock.Lock()
+	linkStatus := link.LinkStatus[vc.Id]
+	link.LinkStatusLock.Unlock()
+
+	changed := false
+	status := map[string]string{}
+	for _, conn := range vc.VpnConnections {
+		connStatus := vpc.VpnDisconnected
+		if linkStatus != nil && linkStatus[conn.Id.Hex()] != nil {
+			connStatus = linkStatus[conn.Id.Hex()]["0"]
+			if connStatus == "" {
+				connStatus = vpc.VpnDisconnected
+			}
+		}
+
+		status[conn.Id.Hex()] = connStatus
+		if vc.VpnStatus == nil || vc.VpnStatus[conn.Id.Hex()] != connStatus {
+			changed = true
+		}
+	}
+
+	if !changed && len(vc.VpnStatus) == len(status) {
+		return
+	}
+
+	err = vc.UpdateVpnStatus(db, status)
+	if err != nil {
+		return
+	}
+
+	return
+}
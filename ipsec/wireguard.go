@@ -0,0 +1,407 @@
+package ipsec
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/link"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/pritunl/pritunl-cloud/vpnclient"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	wgHashes       = map[primitive.ObjectID]string{}
+	wgStatusUpdate = map[primitive.ObjectID]time.Time{}
+	wgLock         = sync.Mutex{}
+)
+
+func getWgPeers(db *database.Database, vc *vpc.Vpc) (
+	peers []*vpc.WgPeer) {
+
+	peers = []*vpc.WgPeer{}
+
+	if vc.WgPeers == nil {
+		return
+	}
+
+	for _, peer := range vc.WgPeers {
+		peer := *peer
+
+		if !peer.Vpc.IsZero() {
+			peerVc, err := vpc.Get(db, peer.Vpc)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"vpc_id":      vc.Id.Hex(),
+					"peer_id":     peer.Id.Hex(),
+					"peer_vpc_id": peer.Vpc.Hex(),
+					"error":       err,
+				}).Error("ipsec: Failed to get wireguard peer vpc")
+				continue
+			}
+
+			if peerVc.WgPublicKey == "" {
+				continue
+			}
+
+			peer.PublicKey = peerVc.WgPublicKey
+			peer.Endpoint = peerVc.WgEndpoint
+			if peer.PersistentKeepalive == 0 {
+				peer.PersistentKeepalive = 25
+			}
+		}
+
+		peers = append(peers, &peer)
+	}
+
+	return
+}
+
+func writeWgConf(vc *vpc.Vpc, peers []*vpc.WgPeer) (
+	pth string, err error) {
+
+	namespace := vm.GetLinkNamespace(vc.Id, 0)
+	pth = path.Join("/", "etc", "netns", namespace, "wireguard.conf")
+
+	confBuf := &bytes.Buffer{}
+	fmt.Fprintf(confBuf, "[Interface]\nPrivateKey = %s\nListenPort = %d\n",
+		vc.WgPrivateKey, vc.WgPort)
+
+	for _, peer := range peers {
+		fmt.Fprintf(confBuf, "\n[Peer]\nPublicKey = %s\n", peer.PublicKey)
+		if peer.PresharedKey != "" {
+			fmt.Fprintf(confBuf, "PresharedKey = %s\n", peer.PresharedKey)
+		}
+		if peer.Endpoint != "" {
+			fmt.Fprintf(confBuf, "Endpoint = %s\n", peer.Endpoint)
+		}
+		fmt.Fprintf(confBuf, "AllowedIPs = %s\n",
+			strings.Join(peer.AllowedIps, ", "))
+		if peer.PersistentKeepalive != 0 {
+			fmt.Fprintf(confBuf, "PersistentKeepalive = %d\n",
+				peer.PersistentKeepalive)
+		}
+	}
+
+	err = ioutil.WriteFile(pth, confBuf.Bytes(), 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "ipsec: Failed to write wireguard conf"),
+		}
+		return
+	}
+
+	return
+}
+
+func deployWg(db *database.Database, vc *vpc.Vpc, states []*link.State,
+	netAddr, netAddr6, pubAddr, pubAddr6 string) (err error) {
+
+	namespace := vm.GetLinkNamespace(vc.Id, 0)
+
+	if len(vc.WgPeers) == 0 && vc.ClientPool == "" {
+		wgLock.Lock()
+		curHash := wgHashes[vc.Id]
+		delete(wgHashes, vc.Id)
+		wgLock.Unlock()
+
+		if curHash != "" {
+			err = destroyWg(vc.Id)
+			if err != nil {
+				return
+			}
+		}
+
+		if len(vc.WgStatus) != 0 {
+			err = vc.UpdateWgStatus(db, map[string]*vpc.WgPeerStatus{})
+			if err != nil {
+				return
+			}
+		}
+
+		return
+	}
+
+	err = vc.UpdateWgEndpoint(db, vc.GetWgEndpoint(pubAddr, pubAddr6))
+	if err != nil {
+		return
+	}
+
+	peers := getWgPeers(db, vc)
+
+	clients := []*vpnclient.VpnClient{}
+	if vc.ClientPool != "" {
+		clients, err = vpnclient.GetActive(db, vc)
+		if err != nil {
+			return
+		}
+	}
+
+	allPeers := []*vpc.WgPeer{}
+	allPeers = append(allPeers, peers...)
+	for _, clnt := range clients {
+		allPeers = append(allPeers, &vpc.WgPeer{
+			Id:         clnt.Id,
+			PublicKey:  clnt.PublicKey,
+			AllowedIps: []string{clnt.Address + "/32"},
+		})
+	}
+
+	hsh := md5.New()
+	io.WriteString(hsh, vc.WgPrivateKey)
+	io.WriteString(hsh, fmt.Sprintf("%d", vc.WgPort))
+	for _, peer := range allPeers {
+		io.WriteString(hsh, peer.Hash())
+	}
+	newHash := hex.EncodeToString(hsh.Sum(nil))
+
+	wgLock.Lock()
+	curHash := wgHashes[vc.Id]
+	wgLock.Unlock()
+
+	if newHash != curHash {
+		logrus.WithFields(logrus.Fields{
+			"vpc_id": vc.Id.Hex(),
+		}).Info("ipsec: Deploying wireguard state")
+
+		confPth, e := writeWgConf(vc, allPeers)
+		if e != nil {
+			err = e
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{"File exists"},
+			"ip", "netns", "exec", namespace,
+			"ip", "link", "add", wgIface, "type", "wireguard",
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"wg", "syncconf", wgIface, confPth,
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "link", "set", "dev", wgIface, "up",
+		)
+		if err != nil {
+			return
+		}
+
+		utils.ExecCombinedOutput("", "ip", "netns", "exec", namespace,
+			"ip", "route", "flush", "dev", wgIface)
+		utils.ExecCombinedOutput("", "ip", "netns", "exec", namespace,
+			"ip", "-6", "route", "flush", "dev", wgIface)
+
+		for _, peer := range allPeers {
+			for _, dst := range peer.AllowedIps {
+				if strings.Contains(dst, ":") {
+					_, err = utils.ExecCombinedOutputLogged(
+						nil,
+						"ip", "netns", "exec", namespace,
+						"ip", "-6", "route", "replace",
+						dst, "dev", wgIface,
+					)
+				} else {
+					_, err = utils.ExecCombinedOutputLogged(
+						nil,
+						"ip", "netns", "exec", namespace,
+						"ip", "route", "replace",
+						dst, "dev", wgIface,
+					)
+				}
+				if err != nil {
+					return
+				}
+			}
+		}
+
+		curVc, e := vpc.Get(db, vc.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		err = addRoutes(db, curVc, states, netAddr, netAddr6)
+		if err != nil {
+			return
+		}
+
+		wgLock.Lock()
+		wgHashes[vc.Id] = newHash
+		wgLock.Unlock()
+	}
+
+	peerStates, err := link.GetWgStatus(vc.Id, wgIface)
+	if err != nil {
+		return
+	}
+
+	err = updateWgStatus(db, vc, peers, peerStates)
+	if err != nil {
+		return
+	}
+
+	err = updateClientStatus(db, clients, peerStates)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func getPeerStatus(peerState *link.WgPeerState) (
+	peerStatus *vpc.WgPeerStatus) {
+
+	peerStatus = &vpc.WgPeerStatus{
+		Status: vpc.VpnDisconnected,
+	}
+
+	if peerState == nil {
+		return
+	}
+
+	peerStatus.Endpoint = peerState.Endpoint
+	peerStatus.LatestHandshake = peerState.LatestHandshake
+	peerStatus.TransferRx = peerState.TransferRx
+	peerStatus.TransferTx = peerState.TransferTx
+
+	if !peerState.LatestHandshake.IsZero() &&
+		time.Since(peerState.LatestHandshake) < time.Duration(
+			vpc.WgHandshakeTimeout)*time.Second {
+
+		peerStatus.Status = vpc.VpnConnected
+	} else if peerState.Endpoint != "" {
+		peerStatus.Status = vpc.VpnConnecting
+	}
+
+	return
+}
+
+func updateClientStatus(db *database.Database,
+	clients []*vpnclient.VpnClient,
+	peerStates map[string]*link.WgPeerState) (err error) {
+
+	for _, clnt := range clients {
+		peerStatus := getPeerStatus(peerStates[clnt.PublicKey])
+
+		if clnt.Status == peerStatus.Status &&
+			clnt.Endpoint == peerStatus.Endpoint &&
+			time.Since(clnt.StatusTimestamp) < 30*time.Second {
+
+			continue
+		}
+
+		clnt.Status = peerStatus.Status
+		clnt.Endpoint = peerStatus.Endpoint
+		clnt.LatestHandshake = peerStatus.LatestHandshake
+		clnt.TransferRx = peerStatus.TransferRx
+		clnt.TransferTx = peerStatus.TransferTx
+		clnt.StatusTimestamp = time.Now()
+
+		err = clnt.CommitFields(db, set.NewSet(
+			"status",
+			"endpoint",
+			"latest_handshake",
+			"transfer_rx",
+			"transfer_tx",
+			"status_timestamp",
+		))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func updateWgStatus(db *database.Database, vc *vpc.Vpc,
+	peers []*vpc.WgPeer, peerStates map[string]*link.WgPeerState) (
+	err error) {
+
+	changed := false
+	status := map[string]*vpc.WgPeerStatus{}
+	for _, peer := range peers {
+		peerStatus := getPeerStatus(peerStates[peer.PublicKey])
+		status[peer.Id.Hex()] = peerStatus
+
+		curStatus := vc.WgStatus[peer.Id.Hex()]
+		if curStatus == nil || curStatus.Status != peerStatus.Status ||
+			curStatus.Endpoint != peerStatus.Endpoint {
+
+			changed = true
+		}
+	}
+
+	if len(vc.WgStatus) != len(status) {
+		changed = true
+	}
+
+	wgLock.Lock()
+	lastUpdate := wgStatusUpdate[vc.Id]
+	wgLock.Unlock()
+
+	if !changed && time.Since(lastUpdate) < 30*time.Second {
+		return
+	}
+
+	err = vc.UpdateWgStatus(db, status)
+	if err != nil {
+		return
+	}
+
+	wgLock.Lock()
+	wgStatusUpdate[vc.Id] = time.Now()
+	wgLock.Unlock()
+
+	return
+}
+
+func destroyWg(vcId primitive.ObjectID) (err error) {
+	namespace := vm.GetLinkNamespace(vcId, 0)
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"Cannot find device",
+			"No such file",
+		},
+		"ip", "netns", "exec", namespace,
+		"ip", "link", "del", wgIface,
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func removeWg(vcId primitive.ObjectID) {
+	wgLock.Lock()
+	delete(wgHashes, vcId)
+	delete(wgStatusUpdate, vcId)
+	wgLock.Unlock()
+}
@@ -736,6 +736,36 @@ func UpdateState(nodeSelf *node.Node, instances []*instance.Instance,
 
 		rules := generateVirt(namespace, iface, ingress)
 		newState.Interfaces[namespace+"-"+iface] = rules
+
+		for i, adapterIface := range inst.NetworkInterfaces {
+			if inst.Virt == nil || len(inst.Virt.NetworkAdapters) <= i+1 {
+				break
+			}
+
+			ifaceNamespace := vm.GetNamespace(inst.Id, i+1)
+			ifaceVirt := vm.GetIface(inst.Id, i+1)
+
+			ifaceIngress := firewalls[ifaceNamespace]
+			if ifaceIngress == nil {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": inst.Id.Hex(),
+					"namespace":   ifaceNamespace,
+				}).Warn("iptables: Failed to load interface firewall rules")
+				continue
+			}
+
+			if externalNetwork && adapterIface.PublicAddress {
+				ifaceExternal := vm.GetAdapterIfaceExternal(inst.Id, i+1)
+
+				rules := generateInternal(ifaceNamespace, ifaceExternal,
+					true, adapterIface.PrivateIp, adapterIface.PublicIp,
+					"", "", ifaceIngress)
+				newState.Interfaces[ifaceNamespace+"-"+ifaceExternal] = rules
+			}
+
+			rules := generateVirt(ifaceNamespace, ifaceVirt, ifaceIngress)
+			newState.Interfaces[ifaceNamespace+"-"+ifaceVirt] = rules
+		}
 	}
 
 	err = applyState(curState, newState, namespaces)
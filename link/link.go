@@ -32,4 +32,17 @@ type Link struct {
 	Right        string   `json:"right"`
 	LeftSubnets  []string `json:"left_subnets"`
 	RightSubnets []string `json:"right_subnets"`
+	RightId      string   `json:"-"`
+	Certificate  string   `json:"-"`
+	PrivateKey   string   `json:"-"`
+	RemoteCa     string   `json:"-"`
+	IkeVersion   string   `json:"-"`
+	IkeProposals []string `json:"-"`
+	EspProposals []string `json:"-"`
+	IkeLifetime  int      `json:"-"`
+	KeyLifetime  int      `json:"-"`
+	RekeyMargin  int      `json:"-"`
+	DpdDelay     int      `json:"-"`
+	DpdTimeout   int      `json:"-"`
+	DpdAction    string   `json:"-"`
 }
@@ -0,0 +1,70 @@
+package link
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+type WgPeerState struct {
+	PublicKey       string
+	Endpoint        string
+	LatestHandshake time.Time
+	TransferRx      int64
+	TransferTx      int64
+}
+
+func GetWgStatus(vpcId primitive.ObjectID, iface string) (
+	states map[string]*WgPeerState, err error) {
+
+	states = map[string]*WgPeerState{}
+	namespace := vm.GetLinkNamespace(vpcId, 0)
+
+	output, err := utils.ExecCombinedOutputLogged(
+		[]string{
+			"No such device",
+			"No such file",
+		},
+		"ip", "netns", "exec", namespace,
+		"wg", "show", iface, "dump",
+	)
+	if err != nil {
+		err = nil
+		return
+	}
+
+	for i, line := range strings.Split(output, "\n") {
+		if i == 0 {
+			continue
+		}
+
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) != 8 {
+			continue
+		}
+
+		state := &WgPeerState{
+			PublicKey: fields[0],
+		}
+
+		if fields[2] != "(none)" {
+			state.Endpoint = fields[2]
+		}
+
+		handshake, _ := strconv.ParseInt(fields[4], 10, 64)
+		if handshake != 0 {
+			state.LatestHandshake = time.Unix(handshake, 0)
+		}
+
+		state.TransferRx, _ = strconv.ParseInt(fields[5], 10, 64)
+		state.TransferTx, _ = strconv.ParseInt(fields[6], 10, 64)
+
+		states[state.PublicKey] = state
+	}
+
+	return
+}
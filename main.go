@@ -24,6 +24,9 @@ Commands:
   default-password  Get default administrator password
   reset-password    Reset administrator password
   disable-policies  Disable all policies
+  pki-key           Generate or set internal CA encryption key, the same
+                    key must be set on every node
+  pki-root          Export internal CA root certificate
   backup            Backup local data
 `
 
@@ -108,6 +111,20 @@ func main() {
 			panic(err)
 		}
 		return
+	case "pki-key":
+		Init()
+		err := cmd.PkiKey()
+		if err != nil {
+			panic(err)
+		}
+		return
+	case "pki-root":
+		Init()
+		err := cmd.PkiRoot()
+		if err != nil {
+			panic(err)
+		}
+		return
 	case "backup":
 		Init()
 		err := cmd.Backup()
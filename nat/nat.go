@@ -0,0 +1,206 @@
+package nat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/gateway"
+	"github.com/pritunl/pritunl-cloud/iptables"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	natLock = utils.NewMultiTimeoutLock(2 * time.Minute)
+)
+
+func getRules(gw *gateway.Gateway, vc *vpc.Vpc) (rules [][]string) {
+	rules = [][]string{}
+	ifaceExternal := vm.GetGatewayIfaceExternal(gw.Id, 0)
+	count := len(gw.PublicIps)
+
+	for _, subId := range gw.Subnets {
+		sub := vc.GetSubnet(subId)
+		if sub == nil {
+			continue
+		}
+
+		for i, addr := range gw.PublicIps {
+			rule := []string{
+				"-s", sub.Network,
+				"-o", ifaceExternal,
+			}
+
+			if i < count-1 {
+				rule = append(rule,
+					"-m", "statistic",
+					"--mode", "nth",
+					"--every", strconv.Itoa(count-i),
+					"--packet", "0",
+				)
+			}
+
+			rule = append(rule,
+				"-m", "comment",
+				"--comment", "pritunl_cloud_nat",
+				"-j", "SNAT",
+				"--to-source", addr,
+			)
+
+			rules = append(rules, rule)
+		}
+	}
+
+	return
+}
+
+func applyRules(namespace string, rules [][]string) (err error) {
+	iptables.Lock()
+	defer iptables.Unlock()
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"iptables",
+		"-t", "nat",
+		"-F", "POSTROUTING",
+	)
+	if err != nil {
+		return
+	}
+
+	for _, rule := range rules {
+		cmd := []string{
+			"ip", "netns", "exec", namespace,
+			"iptables",
+			"-t", "nat",
+			"-A", "POSTROUTING",
+		}
+		cmd = append(cmd, rule...)
+
+		_, err = utils.ExecCombinedOutputLogged(nil, cmd[0], cmd[1:]...)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func getStateKey(gw *gateway.Gateway, rules [][]string) string {
+	key := []string{
+		gw.Vpc.Hex(),
+		gw.Block.Hex(),
+		gw.PrivateIp,
+		strings.Join(gw.PublicIps, ","),
+	}
+
+	for _, rule := range rules {
+		key = append(key, strings.Join(rule, " "))
+	}
+
+	return strings.Join(key, "|")
+}
+
+func deployGateway(gw *gateway.Gateway) {
+	lockId := natLock.Lock(gw.Id.Hex())
+	defer natLock.Unlock(gw.Id.Hex(), lockId)
+
+	err := deploy(gw)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"gateway_id": gw.Id.Hex(),
+			"error":      err,
+		}).Error("nat: Failed to deploy gateway")
+	}
+}
+
+func deploy(gw *gateway.Gateway) (err error) {
+	db := database.GetDatabase()
+	defer db.Close()
+
+	if gw.PrivateIp == "" || len(gw.PublicIps) == 0 {
+		return
+	}
+
+	vc, err := vpc.Get(db, gw.Vpc)
+	if err != nil {
+		return
+	}
+
+	rules := getRules(gw, vc)
+	key := getStateKey(gw, rules)
+
+	networkStatesLock.Lock()
+	curKey := networkStates[gw.Id]
+	networkStatesLock.Unlock()
+
+	if curKey == key {
+		return
+	}
+
+	if curKey != "" {
+		err = networkConfClear(gw.Id)
+		if err != nil {
+			return
+		}
+	}
+
+	err = networkConf(db, gw, vc)
+	if err != nil {
+		return
+	}
+
+	err = applyRules(vm.GetGatewayNamespace(gw.Id, 0), rules)
+	if err != nil {
+		return
+	}
+
+	networkStatesLock.Lock()
+	networkStates[gw.Id] = key
+	networkStatesLock.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"gateway_id": gw.Id.Hex(),
+		"public_ips": strings.Join(gw.PublicIps, ","),
+	}).Info("nat: Deployed gateway")
+
+	return
+}
+
+func removeGateway(gwId primitive.ObjectID) {
+	lockId := natLock.Lock(gwId.Hex())
+	defer natLock.Unlock(gwId.Hex(), lockId)
+
+	activeGatewaysLock.Lock()
+	activeGateways.Remove(gwId)
+	activeGatewaysLock.Unlock()
+
+	namespace := vm.GetGatewayNamespace(gwId, 0)
+	exists, err := utils.Exists(fmt.Sprintf("/etc/netns/%s", namespace))
+	if err != nil {
+		return
+	}
+
+	networkStatesLock.Lock()
+	_, active := networkStates[gwId]
+	networkStatesLock.Unlock()
+
+	if !exists && !active {
+		return
+	}
+
+	err = networkConfClear(gwId)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"gateway_id": gwId.Hex(),
+			"error":      err,
+		}).Error("nat: Failed to remove gateway")
+	}
+}
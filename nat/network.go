@@ -0,0 +1,460 @@
+package nat
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/block"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/gateway"
+	"github.com/pritunl/pritunl-cloud/interfaces"
+	"github.com/pritunl/pritunl-cloud/iproute"
+	"github.com/pritunl/pritunl-cloud/iptables"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/pritunl/pritunl-cloud/zone"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	networkStates     = map[primitive.ObjectID]string{}
+	networkStatesLock = sync.Mutex{}
+)
+
+func networkConf(db *database.Database, gw *gateway.Gateway,
+	vc *vpc.Vpc) (err error) {
+
+	jumboFrames := node.Self.JumboFrames
+	namespace := vm.GetGatewayNamespace(gw.Id, 0)
+	ifaceInternalVirt := vm.GetGatewayIfaceVirt(gw.Id, 0)
+	ifaceExternalVirt := vm.GetGatewayIfaceVirt(gw.Id, 1)
+	ifaceInternal := vm.GetGatewayIfaceInternal(gw.Id, 0)
+	ifaceExternal := vm.GetGatewayIfaceExternal(gw.Id, 0)
+	ifaceVlan := vm.GetGatewayIfaceVlan(gw.Id, 0)
+	namespacePth := fmt.Sprintf("/etc/netns/%s", namespace)
+	gwIdPth := fmt.Sprintf("%s/gateway.id", namespacePth)
+
+	logrus.WithFields(logrus.Fields{
+		"gateway_id": gw.Id.Hex(),
+		"namespace":  namespace,
+	}).Info("nat: Configuring gateway network namespace")
+
+	zne, err := zone.Get(db, node.Self.Zone)
+	if err != nil {
+		return
+	}
+
+	vxlan := false
+	if zne.NetworkMode == zone.VxlanVlan {
+		vxlan = true
+	}
+
+	updateMtuInternal := ""
+	updateMtuExternal := ""
+	if jumboFrames || vxlan {
+		mtuSize := 0
+		if jumboFrames {
+			mtuSize = settings.Hypervisor.JumboMtu
+		} else {
+			mtuSize = settings.Hypervisor.NormalMtu
+		}
+
+		updateMtuExternal = strconv.Itoa(mtuSize)
+
+		if vxlan {
+			mtuSize -= 50
+		}
+
+		updateMtuInternal = strconv.Itoa(mtuSize)
+	}
+
+	sub := vc.GetSubnet(gw.Subnet)
+	if sub == nil {
+		err = &errortypes.NotFoundError{
+			errors.New("nat: Gateway subnet does not exist"),
+		}
+		return
+	}
+
+	subNet, err := sub.GetNetwork()
+	if err != nil {
+		return
+	}
+	subCidr, _ := subNet.Mask.Size()
+
+	blck, err := block.Get(db, gw.Block)
+	if err != nil {
+		return
+	}
+
+	blckGateway := blck.GetGateway()
+	blckMask := blck.GetMask()
+	if blckGateway == nil || blckMask == nil {
+		err = &errortypes.ParseError{
+			errors.New("nat: Invalid block gateway cidr"),
+		}
+		return
+	}
+	blckCidr, _ := blckMask.Size()
+
+	externalIface := gw.GetInterface(node.Self)
+	if externalIface == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("nat: Failed to get external interface"),
+		}
+		return
+	}
+
+	internalIface := interfaces.GetInternal(ifaceInternalVirt, vxlan)
+	if internalIface == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("nat: Failed to get internal interface"),
+		}
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns",
+		"add", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(namespacePth, 0755)
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(gwIdPth, gw.Id.Hex(), 0644)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link",
+		"add", ifaceExternalVirt,
+		"type", "veth",
+		"peer", "name", ifaceExternal,
+		"addr", vm.GetMacAddrExternal(gw.Id, gw.Vpc),
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link",
+		"add", ifaceInternalVirt,
+		"type", "veth",
+		"peer", "name", ifaceInternal,
+		"addr", vm.GetMacAddrInternal(gw.Id, gw.Vpc),
+	)
+	if err != nil {
+		return
+	}
+
+	if updateMtuExternal != "" {
+		for _, iface := range []string{ifaceExternalVirt, ifaceExternal} {
+			_, err = utils.ExecCombinedOutputLogged(
+				nil,
+				"ip", "link",
+				"set", "dev", iface,
+				"mtu", updateMtuExternal,
+			)
+			if err != nil {
+				return
+			}
+		}
+	}
+	if updateMtuInternal != "" {
+		for _, iface := range []string{ifaceInternalVirt, ifaceInternal} {
+			_, err = utils.ExecCombinedOutputLogged(
+				nil,
+				"ip", "link",
+				"set", "dev", iface,
+				"mtu", updateMtuInternal,
+			)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link",
+		"set", "dev", ifaceExternalVirt, "up",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link",
+		"set", "dev", ifaceInternalVirt, "up",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link", "set",
+		ifaceExternalVirt, "master", externalIface,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link", "set",
+		ifaceInternalVirt, "master", internalIface,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "link",
+		"set", "dev", ifaceExternal,
+		"netns", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "link",
+		"set", "dev", ifaceInternal,
+		"netns", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"sysctl", "-w", "net.ipv4.ip_forward=1",
+	)
+	if err != nil {
+		return
+	}
+
+	iptables.Lock()
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"iptables",
+		"-A", "FORWARD",
+		"-i", ifaceExternal,
+		"-m", "conntrack",
+		"--ctstate", "RELATED,ESTABLISHED",
+		"-j", "ACCEPT",
+	)
+	iptables.Unlock()
+	if err != nil {
+		return
+	}
+
+	iptables.Lock()
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"iptables",
+		"-A", "FORWARD",
+		"-i", ifaceExternal,
+		"-j", "DROP",
+	)
+	iptables.Unlock()
+	if err != nil {
+		return
+	}
+
+	for _, iface := range []string{"lo", ifaceExternal, ifaceInternal} {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "link",
+			"set", "dev", iface, "up",
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"add", "link", ifaceInternal,
+		"name", ifaceVlan,
+		"type", "vlan",
+		"id", strconv.Itoa(vc.VpcId),
+	)
+	if err != nil {
+		return
+	}
+
+	if updateMtuInternal != "" {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "link",
+			"set", "dev", ifaceVlan,
+			"mtu", updateMtuInternal,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"set", "dev", ifaceVlan, "up",
+	)
+	if err != nil {
+		return
+	}
+
+	err = iproute.BridgeAdd(namespace, "br0")
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link", "set",
+		ifaceVlan, "master", "br0",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "addr",
+		"add", fmt.Sprintf("%s/%d", gw.PrivateIp, subCidr),
+		"dev", "br0",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"set", "dev", "br0", "up",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "route",
+		"add", vc.Network,
+		"dev", "br0",
+	)
+	if err != nil {
+		return
+	}
+
+	for _, addr := range gw.PublicIps {
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{"File exists"},
+			"ip", "netns", "exec", namespace,
+			"ip", "addr",
+			"add", fmt.Sprintf("%s/%d", addr, blckCidr),
+			"dev", ifaceExternal,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "route",
+		"add", "default",
+		"via", blckGateway.String(),
+	)
+	if err != nil {
+		return
+	}
+
+	for _, addr := range gw.PublicIps {
+		utils.ExecCombinedOutput(
+			"",
+			"ip", "netns", "exec", namespace,
+			"arping", "-U", "-c", "2",
+			"-I", ifaceExternal, addr,
+		)
+	}
+
+	return
+}
+
+func networkConfClear(gwId primitive.ObjectID) (err error) {
+	networkStatesLock.Lock()
+	delete(networkStates, gwId)
+	networkStatesLock.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"gateway_id": gwId.Hex(),
+	}).Info("nat: Removing gateway network namespace")
+
+	namespace := vm.GetGatewayNamespace(gwId, 0)
+	ifaceInternalVirt := vm.GetGatewayIfaceVirt(gwId, 0)
+	ifaceExternalVirt := vm.GetGatewayIfaceVirt(gwId, 1)
+
+	utils.ExecCombinedOutput("", "ip", "link",
+		"set", ifaceExternalVirt, "down")
+	utils.ExecCombinedOutput("", "ip", "link", "del", ifaceExternalVirt)
+	utils.ExecCombinedOutput("", "ip", "link",
+		"set", ifaceInternalVirt, "down")
+	utils.ExecCombinedOutput("", "ip", "link", "del", ifaceInternalVirt)
+
+	interfaces.RemoveVirtIface(ifaceExternalVirt)
+	interfaces.RemoveVirtIface(ifaceInternalVirt)
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"No such file or directory"},
+		"ip", "netns", "del", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	err = os.RemoveAll(fmt.Sprintf("/etc/netns/%s", namespace))
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "nat: Failed to remove namespace directory"),
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,145 @@
+package nat
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/gateway"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	syncState          []*gateway.Gateway
+	syncStateLock      = sync.Mutex{}
+	activeGateways     = set.NewSet()
+	activeGatewaysLock = sync.Mutex{}
+)
+
+func ApplyState(newState []*gateway.Gateway) {
+	syncStateLock.Lock()
+	syncState = newState
+	syncStateLock.Unlock()
+
+	return
+}
+
+func InitState() (err error) {
+	var items []os.FileInfo
+	namespacePth := paths.GetNamespacesPath()
+	exists, err := utils.Exists(namespacePth)
+	if exists {
+		items, err = ioutil.ReadDir(namespacePth)
+		if err != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "nat: Failed to read namespace directory"),
+			}
+			return
+		}
+	} else {
+		items = []os.FileInfo{}
+	}
+
+	newActiveGateways := set.NewSet()
+	for _, item := range items {
+		namespace := item.Name()
+
+		if !item.IsDir() || len(namespace) != 14 ||
+			!strings.HasPrefix(namespace, "g") {
+
+			continue
+		}
+
+		gwPth := filepath.Join("/etc/netns", namespace, "gateway.id")
+		gwExists, e := utils.Exists(gwPth)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if gwExists {
+			gwIdByt, e := ioutil.ReadFile(gwPth)
+			if e != nil {
+				err = &errortypes.ReadError{
+					errors.Wrap(e, "nat: Failed to read gateway id"),
+				}
+				return
+			}
+
+			gwId, e := primitive.ObjectIDFromHex(
+				strings.TrimSpace(string(gwIdByt)))
+			if e != nil {
+				err = &errortypes.ParseError{
+					errors.Wrap(e, "nat: Failed to parse gateway id"),
+				}
+				return
+			}
+
+			newActiveGateways.Add(gwId)
+		}
+	}
+
+	activeGatewaysLock.Lock()
+	activeGateways = newActiveGateways
+	activeGatewaysLock.Unlock()
+
+	return
+}
+
+func SyncState() (err error) {
+	db := database.GetDatabase()
+	defer db.Close()
+
+	syncStateLock.Lock()
+	curState := syncState
+	syncStateLock.Unlock()
+
+	if curState == nil {
+		return
+	}
+
+	activeGatewaysLock.Lock()
+	remGateways := activeGateways.Copy()
+	for _, gw := range curState {
+		activeGateways.Add(gw.Id)
+		remGateways.Remove(gw.Id)
+	}
+	for gwIdInf := range remGateways.Iter() {
+		activeGateways.Remove(gwIdInf)
+	}
+	activeGatewaysLock.Unlock()
+
+	for _, gw := range curState {
+		held, err := gw.Ping(db)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"gateway_id": gw.Id.Hex(),
+				"error":      err,
+			}).Error("nat: Failed to update gateway timestamp")
+			continue
+		}
+
+		if !held {
+			go removeGateway(gw.Id)
+			continue
+		}
+
+		go deployGateway(gw)
+	}
+
+	for gwIdInf := range remGateways.Iter() {
+		gwId := gwIdInf.(primitive.ObjectID)
+		go removeGateway(gwId)
+	}
+
+	return
+}
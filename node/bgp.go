@@ -0,0 +1,247 @@
+package node
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type BgpPeer struct {
+	Address     string `bson:"address" json:"address"`
+	Asn         uint32 `bson:"asn" json:"asn"`
+	Password    string `bson:"password" json:"-"`
+	SetPassword string `bson:"-" json:"set_password"`
+	HasPassword bool   `bson:"has_password" json:"has_password"`
+	Multihop    int    `bson:"multihop" json:"multihop"`
+}
+
+func (p *BgpPeer) IsIpv6() bool {
+	return strings.Contains(p.Address, ":")
+}
+
+func (p *BgpPeer) Hash() string {
+	hash := md5.New()
+
+	io.WriteString(hash, p.Address)
+	io.WriteString(hash, strconv.FormatUint(uint64(p.Asn), 10))
+	io.WriteString(hash, p.Password)
+	io.WriteString(hash, strconv.Itoa(p.Multihop))
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (n *Node) SetBgpPeers(peers []*BgpPeer) {
+	curPasswords := map[string]string{}
+	for _, peer := range n.BgpPeers {
+		curPasswords[peer.Address] = peer.Password
+	}
+
+	for _, peer := range peers {
+		if peer.SetPassword != "" {
+			peer.Password = peer.SetPassword
+		} else if peer.HasPassword {
+			addr := net.ParseIP(strings.TrimSpace(peer.Address))
+			if addr != nil {
+				peer.Password = curPasswords[addr.String()]
+			}
+		} else {
+			peer.Password = ""
+		}
+
+		peer.SetPassword = ""
+		peer.HasPassword = peer.Password != ""
+	}
+
+	n.BgpPeers = peers
+}
+
+func ParseCommunity(community string) (val uint32, err error) {
+	parts := strings.Split(community, ":")
+	if len(parts) != 2 {
+		err = &errortypes.ParseError{
+			errors.Newf("node: Invalid BGP community '%s'", community),
+		}
+		return
+	}
+
+	high, e := strconv.ParseUint(parts[0], 10, 16)
+	if e != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(e, "node: Failed to parse BGP community"),
+		}
+		return
+	}
+
+	low, e := strconv.ParseUint(parts[1], 10, 16)
+	if e != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(e, "node: Failed to parse BGP community"),
+		}
+		return
+	}
+
+	val = uint32(high)<<16 | uint32(low)
+
+	return
+}
+
+func (n *Node) IsBgp() bool {
+	return n.BgpAsn != 0 && len(n.BgpPeers) > 0
+}
+
+func (n *Node) GetBgpRouterId() string {
+	if n.BgpRouterId != "" {
+		return n.BgpRouterId
+	}
+	if len(n.PublicIps) > 0 {
+		return n.PublicIps[0]
+	}
+	return ""
+}
+
+func (n *Node) GetBgpNextHop() string {
+	if n.BgpNextHop != "" {
+		return n.BgpNextHop
+	}
+	if len(n.PublicIps) > 0 {
+		return n.PublicIps[0]
+	}
+	return ""
+}
+
+func (n *Node) GetBgpNextHop6() string {
+	if n.BgpNextHop6 != "" {
+		return n.BgpNextHop6
+	}
+	if len(n.PublicIps6) > 0 {
+		return n.PublicIps6[0]
+	}
+	return ""
+}
+
+func (n *Node) validateBgp() (errData *errortypes.ErrorData) {
+	if n.BgpPeers == nil {
+		n.BgpPeers = []*BgpPeer{}
+	}
+	if n.BgpCommunities == nil {
+		n.BgpCommunities = []string{}
+	}
+
+	if n.BgpAsn == 0 {
+		n.BgpRouterId = ""
+		n.BgpNextHop = ""
+		n.BgpNextHop6 = ""
+		n.BgpLocalPref = 0
+		n.BgpPeers = []*BgpPeer{}
+		n.BgpCommunities = []string{}
+		return
+	}
+
+	n.BgpRouterId = strings.TrimSpace(n.BgpRouterId)
+	if n.BgpRouterId != "" {
+		routerId := net.ParseIP(n.BgpRouterId)
+		if routerId == nil || routerId.To4() == nil {
+			errData = &errortypes.ErrorData{
+				Error:   "bgp_router_id_invalid",
+				Message: "BGP router ID must be an IPv4 address",
+			}
+			return
+		}
+		n.BgpRouterId = routerId.String()
+	}
+
+	n.BgpNextHop = strings.TrimSpace(n.BgpNextHop)
+	if n.BgpNextHop != "" {
+		nextHop := net.ParseIP(n.BgpNextHop)
+		if nextHop == nil || nextHop.To4() == nil {
+			errData = &errortypes.ErrorData{
+				Error:   "bgp_next_hop_invalid",
+				Message: "BGP next hop must be an IPv4 address",
+			}
+			return
+		}
+		n.BgpNextHop = nextHop.String()
+	}
+
+	n.BgpNextHop6 = strings.TrimSpace(n.BgpNextHop6)
+	if n.BgpNextHop6 != "" {
+		nextHop6 := net.ParseIP(n.BgpNextHop6)
+		if nextHop6 == nil || nextHop6.To4() != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "bgp_next_hop6_invalid",
+				Message: "BGP IPv6 next hop must be an IPv6 address",
+			}
+			return
+		}
+		n.BgpNextHop6 = nextHop6.String()
+	}
+
+	communities := []string{}
+	for _, community := range n.BgpCommunities {
+		community = strings.TrimSpace(community)
+		if community == "" {
+			continue
+		}
+
+		val, e := ParseCommunity(community)
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "bgp_community_invalid",
+				Message: "BGP community must be in the format asn:value",
+			}
+			return
+		}
+
+		communities = append(communities,
+			fmt.Sprintf("%d:%d", val>>16, val&0xffff))
+	}
+	n.BgpCommunities = communities
+
+	peerAddrs := set.NewSet()
+	for _, peer := range n.BgpPeers {
+		addr := net.ParseIP(strings.TrimSpace(peer.Address))
+		if addr == nil {
+			errData = &errortypes.ErrorData{
+				Error:   "bgp_peer_address_invalid",
+				Message: "BGP peer address invalid",
+			}
+			return
+		}
+		peer.Address = addr.String()
+
+		if peerAddrs.Contains(peer.Address) {
+			errData = &errortypes.ErrorData{
+				Error:   "bgp_peer_duplicate",
+				Message: "Duplicate BGP peer address",
+			}
+			return
+		}
+		peerAddrs.Add(peer.Address)
+
+		if peer.Asn == 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "bgp_peer_asn_invalid",
+				Message: "BGP peer ASN invalid",
+			}
+			return
+		}
+
+		if peer.Multihop < 0 || peer.Multihop > 255 {
+			errData = &errortypes.ErrorData{
+				Error:   "bgp_peer_multihop_invalid",
+				Message: "BGP peer multihop TTL invalid",
+			}
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,156 @@
+package node
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCommunity(t *testing.T) {
+	val, err := ParseCommunity("65000:100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != 65000<<16|100 {
+		t.Errorf("community value %d expected %d", val, 65000<<16|100)
+	}
+
+	val, err = ParseCommunity("65535:65535")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if val != 0xffffffff {
+		t.Errorf("community value %d expected %d", val, uint32(0xffffffff))
+	}
+
+	for _, community := range []string{
+		"",
+		"65000",
+		"65000:100:1",
+		"65000:",
+		":100",
+		"a:b",
+		"-1:1",
+		"65536:1",
+		"1:65536",
+	} {
+		_, err = ParseCommunity(community)
+		if err == nil {
+			t.Errorf("invalid community %q accepted", community)
+		}
+	}
+}
+
+func TestValidateBgp(t *testing.T) {
+	nde := &Node{
+		BgpAsn:         65000,
+		BgpRouterId:    " 10.0.0.1 ",
+		BgpNextHop6:    "FD00:0::1",
+		BgpCommunities: []string{" 065000:0100 ", "", "1:2"},
+		BgpPeers: []*BgpPeer{
+			{Address: " 10.0.0.2", Asn: 65001},
+			{Address: "fd00::2", Asn: 65001, Multihop: 2},
+		},
+	}
+
+	errData := nde.validateBgp()
+	if errData != nil {
+		t.Fatalf("validate error: %s", errData.Message)
+	}
+
+	if nde.BgpRouterId != "10.0.0.1" {
+		t.Errorf("router id %q not normalized", nde.BgpRouterId)
+	}
+	if nde.BgpNextHop6 != "fd00::1" {
+		t.Errorf("ipv6 next hop %q not normalized", nde.BgpNextHop6)
+	}
+	if strings.Join(nde.BgpCommunities, ",") != "65000:100,1:2" {
+		t.Errorf("communities %v not normalized", nde.BgpCommunities)
+	}
+	if nde.BgpPeers[0].Address != "10.0.0.2" || nde.BgpPeers[0].IsIpv6() {
+		t.Errorf("peer address %q not normalized", nde.BgpPeers[0].Address)
+	}
+	if !nde.BgpPeers[1].IsIpv6() {
+		t.Errorf("ipv6 peer not detected")
+	}
+
+	nde.BgpCommunities = []string{"65000:70000"}
+	errData = nde.validateBgp()
+	if errData == nil || errData.Error != "bgp_community_invalid" {
+		t.Errorf("invalid community accepted")
+	}
+
+	nde.BgpCommunities = []string{}
+	nde.BgpRouterId = "fd00::1"
+	errData = nde.validateBgp()
+	if errData == nil || errData.Error != "bgp_router_id_invalid" {
+		t.Errorf("ipv6 router id accepted")
+	}
+
+	nde.BgpRouterId = ""
+	nde.BgpPeers = append(nde.BgpPeers, &BgpPeer{
+		Address: "10.0.0.2",
+		Asn:     65002,
+	})
+	errData = nde.validateBgp()
+	if errData == nil || errData.Error != "bgp_peer_duplicate" {
+		t.Errorf("duplicate peer accepted")
+	}
+}
+
+func TestValidateBgpDisabled(t *testing.T) {
+	nde := &Node{
+		BgpRouterId:    "10.0.0.1",
+		BgpLocalPref:   200,
+		BgpCommunities: []string{"invalid"},
+		BgpPeers: []*BgpPeer{
+			{Address: "invalid"},
+		},
+	}
+
+	errData := nde.validateBgp()
+	if errData != nil {
+		t.Fatalf("validate error: %s", errData.Message)
+	}
+
+	if nde.BgpRouterId != "" || nde.BgpLocalPref != 0 ||
+		len(nde.BgpCommunities) != 0 || len(nde.BgpPeers) != 0 {
+
+		t.Errorf("bgp settings not cleared without asn")
+	}
+	if nde.IsBgp() {
+		t.Errorf("bgp enabled without asn")
+	}
+}
+
+func TestSetBgpPeers(t *testing.T) {
+	nde := &Node{
+		BgpPeers: []*BgpPeer{
+			{Address: "10.0.0.2", Password: "secret2", HasPassword: true},
+			{Address: "10.0.0.3", Password: "secret3", HasPassword: true},
+		},
+	}
+
+	nde.SetBgpPeers([]*BgpPeer{
+		{Address: " 10.0.0.2 ", HasPassword: true},
+		{Address: "10.0.0.3"},
+		{Address: "10.0.0.4", SetPassword: "secret4"},
+	})
+
+	peers := nde.BgpPeers
+	if peers[0].Password != "secret2" || !peers[0].HasPassword {
+		t.Errorf("blank password did not keep stored password")
+	}
+	if peers[1].Password != "" || peers[1].HasPassword {
+		t.Errorf("cleared password kept stored password")
+	}
+	if peers[2].Password != "secret4" || !peers[2].HasPassword {
+		t.Errorf("new password not set")
+	}
+
+	for _, peer := range peers {
+		if peer.SetPassword != "" {
+			t.Errorf("peer %s write-only password not cleared",
+				peer.Address)
+		}
+	}
+}
@@ -93,6 +93,13 @@ type Node struct {
 	OraclePrivateKey     string               `bson:"oracle_private_key" json:"-"`
 	OraclePublicKey      string               `bson:"oracle_public_key" json:"oracle_public_key"`
 	OracleHostRoute      bool                 `bson:"oracle_host_route" json:"oracle_host_route"`
+	BgpAsn               uint32               `bson:"bgp_asn" json:"bgp_asn"`
+	BgpRouterId          string               `bson:"bgp_router_id" json:"bgp_router_id"`
+	BgpNextHop           string               `bson:"bgp_next_hop" json:"bgp_next_hop"`
+	BgpNextHop6          string               `bson:"bgp_next_hop6" json:"bgp_next_hop6"`
+	BgpLocalPref         uint32               `bson:"bgp_local_pref" json:"bgp_local_pref"`
+	BgpCommunities       []string             `bson:"bgp_communities" json:"bgp_communities"`
+	BgpPeers             []*BgpPeer           `bson:"bgp_peers" json:"bgp_peers"`
 	Operation            string               `bson:"operation" json:"operation"`
 	reqLock              sync.Mutex           `bson:"-" json:"-"`
 	reqCount             *list.List           `bson:"-" json:"-"`
@@ -160,6 +167,13 @@ func (n *Node) Copy() *Node {
 		OraclePrivateKey:     n.OraclePrivateKey,
 		OraclePublicKey:      n.OraclePublicKey,
 		OracleHostRoute:      n.OracleHostRoute,
+		BgpAsn:               n.BgpAsn,
+		BgpRouterId:          n.BgpRouterId,
+		BgpNextHop:           n.BgpNextHop,
+		BgpNextHop6:          n.BgpNextHop6,
+		BgpLocalPref:         n.BgpLocalPref,
+		BgpCommunities:       n.BgpCommunities,
+		BgpPeers:             n.BgpPeers,
 		reqLock:              n.reqLock,
 		reqCount:             n.reqCount,
 	}
@@ -253,6 +267,24 @@ func (n *Node) IsIpsec() bool {
 	return false
 }
 
+func (n *Node) HasBlock(blockId primitive.ObjectID) bool {
+	for _, blckAttch := range n.Blocks {
+		if blckAttch.Block == blockId {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Node) GetBlockInterface(blockId primitive.ObjectID) string {
+	for _, blckAttch := range n.Blocks {
+		if blckAttch.Block == blockId {
+			return blckAttch.Interface
+		}
+	}
+	return ""
+}
+
 func (n *Node) Validate(db *database.Database) (
 	errData *errortypes.ErrorData, err error) {
 
@@ -510,6 +542,11 @@ func (n *Node) Validate(db *database.Database) (
 		n.OracleUser = ""
 	}
 
+	errData = n.validateBgp()
+	if errData != nil {
+		return
+	}
+
 	n.Format()
 
 	return
@@ -785,6 +822,13 @@ func (n *Node) update(db *database.Database) (err error) {
 	n.OraclePrivateKey = nde.OraclePrivateKey
 	n.OraclePublicKey = nde.OraclePublicKey
 	n.OracleHostRoute = nde.OracleHostRoute
+	n.BgpAsn = nde.BgpAsn
+	n.BgpRouterId = nde.BgpRouterId
+	n.BgpNextHop = nde.BgpNextHop
+	n.BgpNextHop6 = nde.BgpNextHop6
+	n.BgpLocalPref = nde.BgpLocalPref
+	n.BgpCommunities = nde.BgpCommunities
+	n.BgpPeers = nde.BgpPeers
 	n.Operation = nde.Operation
 
 	return
@@ -4,6 +4,7 @@ import (
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/block"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
@@ -54,6 +55,39 @@ func GetAll(db *database.Database) (nodes []*Node, err error) {
 	return
 }
 
+func GetZoneBlock(db *database.Database, zoneId primitive.ObjectID) (
+	blockId primitive.ObjectID, err error) {
+
+	ndes, err := GetAll(db)
+	if err != nil {
+		return
+	}
+
+	for _, nde := range ndes {
+		if nde.Zone != zoneId || !nde.IsHypervisor() {
+			continue
+		}
+
+		for _, blckAttch := range nde.Blocks {
+			blck, e := block.Get(db, blckAttch.Block)
+			if e != nil {
+				if _, ok := e.(*database.NotFoundError); ok {
+					continue
+				}
+				err = e
+				return
+			}
+
+			if blck.Type == block.IPv4 {
+				blockId = blck.Id
+				return
+			}
+		}
+	}
+
+	return
+}
+
 func GetAllHypervisors(db *database.Database, query *bson.M) (
 	nodes []*Node, err error) {
 
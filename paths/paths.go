@@ -67,10 +67,39 @@ func GetLeasePath(instId primitive.ObjectID) string {
 		fmt.Sprintf("%s.leases", instId.Hex()))
 }
 
+func GetInterfaceLeasePath(instId primitive.ObjectID, n int) string {
+	return path.Join(GetLeasesPath(),
+		fmt.Sprintf("%s-%d.leases", instId.Hex(), n))
+}
+
 func GetLinkLeasePath() string {
 	return path.Join(GetLeasesPath(), "link.leases")
 }
 
+func GetResolversPath() string {
+	return path.Join(node.Self.GetVirtPath(), "resolvers")
+}
+
+func GetResolverConfPath(instId primitive.ObjectID) string {
+	return path.Join(GetResolversPath(),
+		fmt.Sprintf("%s.conf", instId.Hex()))
+}
+
+func GetResolverHostsPath(instId primitive.ObjectID) string {
+	return path.Join(GetResolversPath(),
+		fmt.Sprintf("%s.hosts", instId.Hex()))
+}
+
+func GetResolverLeasePath(instId primitive.ObjectID) string {
+	return path.Join(GetResolversPath(),
+		fmt.Sprintf("%s.leases", instId.Hex()))
+}
+
+func GetResolverPidPath(instId primitive.ObjectID) string {
+	return path.Join(GetResolversPath(),
+		fmt.Sprintf("%s.pid", instId.Hex()))
+}
+
 func GetUnitName(virtId primitive.ObjectID) string {
 	return fmt.Sprintf("pritunl_cloud_%s.service", virtId.Hex())
 }
@@ -99,6 +128,39 @@ func GetGuestPath(virtId primitive.ObjectID) string {
 		fmt.Sprintf("%s.guest", virtId.Hex()))
 }
 
+func GetSerialPath(virtId primitive.ObjectID) string {
+	return path.Join(settings.Hypervisor.LibPath,
+		fmt.Sprintf("%s.serial", virtId.Hex()))
+}
+
+func GetSerialsPath() string {
+	return path.Join(settings.Hypervisor.LibPath, "serial")
+}
+
+func GetSerialRecordPath(virtId primitive.ObjectID) string {
+	return path.Join(GetSerialsPath(),
+		fmt.Sprintf("%s.record", virtId.Hex()))
+}
+
+func GetSerialRecordOldPath(virtId primitive.ObjectID) string {
+	return path.Join(GetSerialsPath(),
+		fmt.Sprintf("%s.record.1", virtId.Hex()))
+}
+
+func GetConsoleLogPath(virtId primitive.ObjectID, boot int) string {
+	if boot == 0 {
+		return path.Join(GetSerialsPath(),
+			fmt.Sprintf("%s.console", virtId.Hex()))
+	}
+	return path.Join(GetSerialsPath(),
+		fmt.Sprintf("%s.console.%d", virtId.Hex(), boot))
+}
+
+func GetConsoleFifoPath(virtId primitive.ObjectID) string {
+	return path.Join(GetSerialsPath(),
+		fmt.Sprintf("%s.console.fifo", virtId.Hex()))
+}
+
 func GetNamespacesPath() string {
 	return "/etc/netns"
 }
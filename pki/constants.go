@@ -0,0 +1,20 @@
+package pki
+
+import (
+	"time"
+)
+
+const (
+	RootId    = "root"
+	NodeCa    = "node"
+	ServiceCa = "service"
+	GuestCa   = "guest"
+
+	GuestDomain = "guest.pritunl-cloud.internal"
+
+	RootTtl = 87600 * time.Hour
+
+	NodeTtl    = 72 * time.Hour
+	ServiceTtl = 72 * time.Hour
+	GuestTtl   = 72 * time.Hour
+)
@@ -0,0 +1,119 @@
+package pki
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/config"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+func GenerateKey() (key string, err error) {
+	keyByt := make([]byte, 32)
+	_, err = io.ReadFull(rand.Reader, keyByt)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "pki: Failed to generate encryption key"),
+		}
+		return
+	}
+
+	key = base64.StdEncoding.EncodeToString(keyByt)
+	return
+}
+
+func getCipher() (aead cipher.AEAD, err error) {
+	if config.Config.PkiKey == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("pki: Encryption key not configured"),
+		}
+		return
+	}
+
+	key, err := base64.StdEncoding.DecodeString(config.Config.PkiKey)
+	if err != nil || len(key) != 32 {
+		err = &errortypes.ParseError{
+			errors.New("pki: Encryption key must be 32 bytes base64"),
+		}
+		return
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "pki: Failed to create cipher"),
+		}
+		return
+	}
+
+	aead, err = cipher.NewGCM(block)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "pki: Failed to create cipher"),
+		}
+		return
+	}
+
+	return
+}
+
+func Encrypt(data []byte) (output string, err error) {
+	aead, err := getCipher()
+	if err != nil {
+		return
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "pki: Failed to generate nonce"),
+		}
+		return
+	}
+
+	output = base64.StdEncoding.EncodeToString(
+		aead.Seal(nonce, nonce, data, nil))
+	return
+}
+
+func Decrypt(input string) (data []byte, err error) {
+	aead, err := getCipher()
+	if err != nil {
+		return
+	}
+
+	cipData, err := base64.StdEncoding.DecodeString(input)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "pki: Failed to decode encrypted key"),
+		}
+		return
+	}
+
+	if len(cipData) < aead.NonceSize() {
+		err = &errortypes.ParseError{
+			errors.New("pki: Encrypted key too short"),
+		}
+		return
+	}
+
+	nonce := cipData[:aead.NonceSize()]
+	data, err = aead.Open(nil, nonce, cipData[aead.NonceSize():], nil)
+	if err != nil {
+		err = &errortypes.AuthenticationError{
+			errors.Wrap(err, "pki: Failed to decrypt key, invalid pki key"),
+		}
+		return
+	}
+
+	return
+}
+
+func Enabled() bool {
+	return config.Config.PkiKey != ""
+}
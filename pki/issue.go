@@ -0,0 +1,198 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+var (
+	certs     = map[string]*cachedCert{}
+	certsLock sync.Mutex
+)
+
+type cachedCert struct {
+	hash   string
+	cert   *tls.Certificate
+	expire time.Time
+	renew  time.Time
+}
+
+type Request struct {
+	Authority  string
+	CommonName string
+	DnsNames   []string
+	Ips        []string
+	Server     bool
+	Client     bool
+	Ttl        time.Duration
+}
+
+func (r *Request) hash() string {
+	dnsNames := append([]string{}, r.DnsNames...)
+	sort.Strings(dnsNames)
+	ips := append([]string{}, r.Ips...)
+	sort.Strings(ips)
+
+	return strings.Join([]string{
+		r.Authority,
+		r.CommonName,
+		strings.Join(dnsNames, ","),
+		strings.Join(ips, ","),
+		r.Ttl.String(),
+	}, ":")
+}
+
+func Issue(db *database.Database, req *Request) (
+	certPem, keyPem []byte, err error) {
+
+	if req.Authority == "" || req.Authority == RootId {
+		err = &errortypes.UnknownError{
+			errors.New("pki: Certificate request missing authority"),
+		}
+		return
+	}
+
+	a, err := getAuthority(db, req.Authority, true)
+	if err != nil {
+		return
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "pki: Failed to generate private key"),
+		}
+		return
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return
+	}
+
+	ttl := req.Ttl
+	if ttl == 0 {
+		ttl = ServiceTtl
+	}
+
+	extUsage := []x509.ExtKeyUsage{}
+	if req.Server {
+		extUsage = append(extUsage, x509.ExtKeyUsageServerAuth)
+	}
+	if req.Client {
+		extUsage = append(extUsage, x509.ExtKeyUsageClientAuth)
+	}
+
+	ips := []net.IP{}
+	for _, addr := range req.Ips {
+		ip := net.ParseIP(addr)
+		if ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+
+	templ := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"Pritunl Cloud"},
+			CommonName:   req.CommonName,
+		},
+		NotBefore:             time.Now().Add(-5 * time.Minute),
+		NotAfter:              time.Now().Add(ttl),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           extUsage,
+		BasicConstraintsValid: true,
+		DNSNames:              req.DnsNames,
+		IPAddresses:           ips,
+	}
+
+	certByt, err := x509.CreateCertificate(
+		rand.Reader, templ, a.cert, key.Public(), a.key)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "pki: Failed to create certificate"),
+		}
+		return
+	}
+
+	keyPem, err = marshalKey(key)
+	if err != nil {
+		return
+	}
+
+	certPem = pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: certByt,
+	})
+	certPem = append(certPem, []byte(a.certPem)...)
+
+	return
+}
+
+func GetCertificate(db *database.Database, req *Request) (
+	cert *tls.Certificate, err error) {
+
+	hash := req.hash()
+	cacheKey := req.Authority + ":" + req.CommonName
+
+	certsLock.Lock()
+	cached := certs[cacheKey]
+	certsLock.Unlock()
+
+	if cached != nil && cached.hash == hash &&
+		time.Now().Before(cached.renew) {
+
+		cert = cached.cert
+		return
+	}
+
+	certPem, keyPem, err := Issue(db, req)
+	if err != nil {
+		if cached != nil && cached.hash == hash &&
+			time.Now().Before(cached.expire) {
+
+			cert = cached.cert
+			err = nil
+		}
+		return
+	}
+
+	keypair, err := tls.X509KeyPair(certPem, keyPem)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "pki: Failed to load key pair"),
+		}
+		return
+	}
+	cert = &keypair
+
+	ttl := req.Ttl
+	if ttl == 0 {
+		ttl = ServiceTtl
+	}
+
+	certsLock.Lock()
+	certs[cacheKey] = &cachedCert{
+		hash:   hash,
+		cert:   cert,
+		expire: time.Now().Add(ttl),
+		renew:  time.Now().Add(ttl * 2 / 3),
+	}
+	certsLock.Unlock()
+
+	return
+}
@@ -0,0 +1,406 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	authrs    = map[string]*authority{}
+	authrLock sync.Mutex
+)
+
+type Ca struct {
+	Id          string    `bson:"_id"`
+	Certificate string    `bson:"certificate"`
+	Key         string    `bson:"key"`
+	Timestamp   time.Time `bson:"timestamp"`
+}
+
+type authority struct {
+	cert    *x509.Certificate
+	certPem string
+	key     *ecdsa.PrivateKey
+}
+
+func newSerial() (serial *big.Int, err error) {
+	serialLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+	serial, err = rand.Int(rand.Reader, serialLimit)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "pki: Failed to generate certificate serial"),
+		}
+		return
+	}
+
+	return
+}
+
+func marshalKey(key *ecdsa.PrivateKey) (keyPem []byte, err error) {
+	keyByt, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "pki: Failed to marshal private key"),
+		}
+		return
+	}
+
+	keyPem = pem.EncodeToMemory(&pem.Block{
+		Type:  "EC PRIVATE KEY",
+		Bytes: keyByt,
+	})
+	return
+}
+
+func generateRoot() (ca *Ca, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "pki: Failed to generate private key"),
+		}
+		return
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return
+	}
+
+	templ := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"Pritunl Cloud"},
+			CommonName:   "Pritunl Cloud Internal CA",
+		},
+		NotBefore: time.Now().Add(-1 * time.Hour),
+		NotAfter:  time.Now().Add(RootTtl),
+		KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign |
+			x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            1,
+		SignatureAlgorithm:    x509.ECDSAWithSHA384,
+	}
+
+	certByt, err := x509.CreateCertificate(
+		rand.Reader, templ, templ, key.Public(), key)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "pki: Failed to create root certificate"),
+		}
+		return
+	}
+
+	keyPem, err := marshalKey(key)
+	if err != nil {
+		return
+	}
+
+	keyEnc, err := Encrypt(keyPem)
+	if err != nil {
+		return
+	}
+
+	ca = &Ca{
+		Id: RootId,
+		Certificate: string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: certByt,
+		})),
+		Key:       keyEnc,
+		Timestamp: time.Now(),
+	}
+
+	return
+}
+
+func generateIntermediate(root *authority, caId string) (
+	ca *Ca, err error) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "pki: Failed to generate private key"),
+		}
+		return
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return
+	}
+
+	templ := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"Pritunl Cloud"},
+			CommonName:   "Pritunl Cloud Internal " + caId + " CA",
+		},
+		NotBefore: time.Now().Add(-1 * time.Hour),
+		NotAfter:  root.cert.NotAfter,
+		KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign |
+			x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		SignatureAlgorithm:    x509.ECDSAWithSHA384,
+	}
+
+	switch caId {
+	case NodeCa:
+		templ.ExtKeyUsage = []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth,
+		}
+		break
+	case ServiceCa:
+		templ.ExtKeyUsage = []x509.ExtKeyUsage{
+			x509.ExtKeyUsageClientAuth,
+		}
+		break
+	case GuestCa:
+		templ.ExtKeyUsage = []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth,
+		}
+		templ.PermittedDNSDomainsCritical = true
+		templ.PermittedDNSDomains = []string{GuestDomain}
+		break
+	default:
+		err = &errortypes.UnknownError{
+			errors.Newf("pki: Unknown certificate authority '%s'", caId),
+		}
+		return
+	}
+
+	certByt, err := x509.CreateCertificate(
+		rand.Reader, templ, root.cert, key.Public(), root.key)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "pki: Failed to create intermediate certificate"),
+		}
+		return
+	}
+
+	keyPem, err := marshalKey(key)
+	if err != nil {
+		return
+	}
+
+	keyEnc, err := Encrypt(keyPem)
+	if err != nil {
+		return
+	}
+
+	ca = &Ca{
+		Id: caId,
+		Certificate: string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: certByt,
+		})),
+		Key:       keyEnc,
+		Timestamp: time.Now(),
+	}
+
+	return
+}
+
+func loadCa(db *database.Database, caId string, signing bool) (
+	ca *Ca, err error) {
+
+	coll := db.Pki()
+	ca = &Ca{}
+
+	err = coll.FindOneId(caId, ca)
+	if err == nil {
+		return
+	}
+
+	if _, ok := err.(*database.NotFoundError); !ok || !signing {
+		return
+	}
+
+	if caId == RootId {
+		ca, err = generateRoot()
+		if err != nil {
+			return
+		}
+	} else {
+		root, e := loadAuthority(db, RootId, true)
+		if e != nil {
+			err = e
+			return
+		}
+
+		ca, err = generateIntermediate(root, caId)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = coll.InsertOne(db, ca)
+	if err != nil {
+		err = database.ParseError(err)
+		if _, ok := err.(*database.DuplicateKeyError); !ok {
+			return
+		}
+
+		ca = &Ca{}
+		err = coll.FindOneId(caId, ca)
+		if err != nil {
+			return
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"authority": caId,
+	}).Info("pki: Generated internal certificate authority")
+
+	return
+}
+
+func loadAuthority(db *database.Database, caId string, signing bool) (
+	a *authority, err error) {
+
+	a = authrs[caId]
+	if a != nil && (!signing || a.key != nil) {
+		return
+	}
+
+	ca, err := loadCa(db, caId, signing)
+	if err != nil {
+		return
+	}
+
+	a, err = parseCa(ca, signing)
+	if err != nil {
+		return
+	}
+
+	authrs[caId] = a
+
+	return
+}
+
+func parseCa(ca *Ca, signing bool) (a *authority, err error) {
+	certBlock, _ := pem.Decode([]byte(ca.Certificate))
+	if certBlock == nil {
+		err = &errortypes.ParseError{
+			errors.New("pki: Failed to decode authority certificate"),
+		}
+		return
+	}
+
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "pki: Failed to parse authority certificate"),
+		}
+		return
+	}
+
+	a = &authority{
+		cert:    cert,
+		certPem: ca.Certificate,
+	}
+
+	if signing {
+		keyPem, e := Decrypt(ca.Key)
+		if e != nil {
+			err = e
+			return
+		}
+
+		keyBlock, _ := pem.Decode(keyPem)
+		if keyBlock == nil {
+			err = &errortypes.ParseError{
+				errors.New("pki: Failed to decode authority key"),
+			}
+			return
+		}
+
+		a.key, err = x509.ParseECPrivateKey(keyBlock.Bytes)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "pki: Failed to parse authority key"),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func getAuthority(db *database.Database, caId string, signing bool) (
+	a *authority, err error) {
+
+	authrLock.Lock()
+	defer authrLock.Unlock()
+
+	a, err = loadAuthority(db, caId, signing)
+	return
+}
+
+// CheckKey verifies the configured key decrypts the stored root authority.
+// The root is shared by all nodes in the database, each node must be
+// configured with the same key.
+func CheckKey(db *database.Database) (err error) {
+	ca := &Ca{}
+
+	err = db.Pki().FindOneId(RootId, ca)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	_, err = Decrypt(ca.Key)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Root(db *database.Database) (certPem string, err error) {
+	certPem, err = Authority(db, RootId)
+	return
+}
+
+func Authority(db *database.Database, caId string) (
+	certPem string, err error) {
+
+	a, err := getAuthority(db, caId, Enabled())
+	if err != nil {
+		return
+	}
+
+	certPem = a.certPem
+	return
+}
+
+func Pool(db *database.Database, caId string) (
+	pool *x509.CertPool, err error) {
+
+	a, err := getAuthority(db, caId, Enabled())
+	if err != nil {
+		return
+	}
+
+	pool = x509.NewCertPool()
+	pool.AddCert(a.cert)
+	return
+}
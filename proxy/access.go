@@ -0,0 +1,271 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/syslog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/balancer"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	accessLogs chan *accessEntry
+)
+
+type requestInfoKey struct{}
+
+type requestInfo struct {
+	Pool          string
+	Upstream      string
+	UpstreamStart time.Time
+	TooLarge      bool
+}
+
+func getRequestInfo(r *http.Request) *requestInfo {
+	info, _ := r.Context().Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+func setRequestInfo(r *http.Request, info *requestInfo) *http.Request {
+	return r.WithContext(context.WithValue(
+		r.Context(), requestInfoKey{}, info))
+}
+
+type accessWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *accessWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessWriter) Write(data []byte) (n int, err error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err = w.ResponseWriter.Write(data)
+	w.bytes += int64(n)
+	return
+}
+
+func (w *accessWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *accessWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *accessWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+
+	return hijacker.Hijack()
+}
+
+type accessEntry struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Organization string    `json:"organization"`
+	BalancerId   string    `json:"balancer_id"`
+	Balancer     string    `json:"balancer"`
+	ClientIp     string    `json:"client_ip"`
+	Method       string    `json:"method"`
+	Host         string    `json:"host"`
+	Path         string    `json:"path"`
+	Status       int       `json:"status"`
+	Bytes        int64     `json:"bytes"`
+	Upstream     string    `json:"upstream"`
+	Latency      int64     `json:"latency"`
+	UserAgent    string    `json:"user_agent"`
+	dest         string
+	server       string
+}
+
+func logAccess(entry *accessEntry) {
+	if accessLogs == nil {
+		return
+	}
+
+	select {
+	case accessLogs <- entry:
+	default:
+	}
+}
+
+type accessFile struct {
+	path string
+	file *os.File
+	size int64
+}
+
+func (f *accessFile) Write(data []byte) (err error) {
+	path := settings.Router.AccessLogPath
+
+	if f.file != nil && f.path != path {
+		f.file.Close()
+		f.file = nil
+	}
+
+	if f.file != nil && f.size >= int64(settings.Router.AccessLogSize) {
+		f.file.Close()
+		f.file = nil
+
+		os.Remove(path + ".1")
+		err = os.Rename(path, path+".1")
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "proxy: Failed to rotate access log"),
+			}
+			return
+		}
+	}
+
+	if f.file == nil {
+		file, e := os.OpenFile(path,
+			os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+		if e != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(e, "proxy: Failed to open access log"),
+			}
+			return
+		}
+
+		stat, e := file.Stat()
+		if e != nil {
+			file.Close()
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "proxy: Failed to stat access log"),
+			}
+			return
+		}
+
+		f.path = path
+		f.file = file
+		f.size = stat.Size()
+	}
+
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "proxy: Failed to write access log"),
+		}
+		return
+	}
+
+	return
+}
+
+type accessSyslog struct {
+	writers map[string]*syslog.Writer
+}
+
+func (s *accessSyslog) Write(server string, data []byte) (err error) {
+	writer := s.writers[server]
+	if writer == nil {
+		network := ""
+		addr := ""
+		if server != "" {
+			u, e := url.Parse(server)
+			if e != nil {
+				err = &errortypes.ParseError{
+					errors.Wrap(e, "proxy: Failed to parse syslog server"),
+				}
+				return
+			}
+			network = u.Scheme
+			addr = u.Host
+		}
+
+		writer, err = syslog.Dial(network, addr,
+			syslog.LOG_INFO|syslog.LOG_LOCAL0, "pritunl-cloud")
+		if err != nil {
+			err = &errortypes.ConnectionError{
+				errors.Wrap(err, "proxy: Failed to connect to syslog"),
+			}
+			return
+		}
+		s.writers[server] = writer
+	}
+
+	_, err = writer.Write(data)
+	if err != nil {
+		writer.Close()
+		delete(s.writers, server)
+
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "proxy: Failed to write to syslog"),
+		}
+		return
+	}
+
+	return
+}
+
+func runAccessLog(entries chan *accessEntry) {
+	file := &accessFile{}
+	slog := &accessSyslog{
+		writers: map[string]*syslog.Writer{},
+	}
+
+	var lastErr time.Time
+	for entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "proxy: Failed to marshal access log"),
+			}
+		} else {
+			switch entry.dest {
+			case balancer.AccessLogFile:
+				err = file.Write(append(data, '\n'))
+				break
+			case balancer.AccessLogSyslog:
+				err = slog.Write(entry.server, data)
+				break
+			}
+		}
+
+		if err != nil && time.Since(lastErr) > 30*time.Second {
+			lastErr = time.Now()
+
+			logrus.WithFields(logrus.Fields{
+				"balancer_id": entry.BalancerId,
+				"error":       err,
+			}).Error("proxy: Access log error")
+		}
+	}
+}
+
+func initAccessLog() {
+	size := settings.Router.AccessLogBuffer
+	if size < 1 {
+		size = 4096
+	}
+
+	accessLogs = make(chan *accessEntry, size)
+	go runAccessLog(accessLogs)
+}
@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/pritunl/pritunl-cloud/balancer"
+)
+
+type candidate interface {
+	GetKey() string
+	GetWeight() int
+	GetConns() int32
+	IsDraining() bool
+}
+
+func hashScore(value, key string) uint64 {
+	h := fnv.New64a()
+	io.WriteString(h, value)
+	io.WriteString(h, key)
+	return h.Sum64()
+}
+
+func getHashValue(balnc *balancer.Balancer, r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+
+	switch balnc.HashSource {
+	case balancer.HashHeader:
+		return r.Header.Get(balnc.GetHashKey())
+	case balancer.HashCookie:
+		cookie, err := r.Cookie(balnc.GetHashKey())
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+
+	return ""
+}
+
+func selectWeighted(cands []candidate) int {
+	total := 0
+	for _, cand := range cands {
+		total += cand.GetWeight()
+	}
+
+	if total <= 0 {
+		return rand.Intn(len(cands))
+	}
+
+	n := rand.Intn(total)
+	for i, cand := range cands {
+		n -= cand.GetWeight()
+		if n < 0 {
+			return i
+		}
+	}
+
+	return len(cands) - 1
+}
+
+func selectLeastConn(cands []candidate) int {
+	l := len(cands)
+	offset := rand.Intn(l)
+	best := offset
+
+	for i := 1; i < l; i++ {
+		index := (offset + i) % l
+		cand := cands[index]
+		bestCand := cands[best]
+
+		if int64(cand.GetConns())*int64(bestCand.GetWeight()) <
+			int64(bestCand.GetConns())*int64(cand.GetWeight()) {
+
+			best = index
+		}
+	}
+
+	return best
+}
+
+func selectHash(cands []candidate, value string) int {
+	best := 0
+	var bestScore uint64
+
+	for i, cand := range cands {
+		score := hashScore(value, cand.GetKey())
+		if i == 0 || score > bestScore {
+			best = i
+			bestScore = score
+		}
+	}
+
+	return best
+}
+
+func selectCandidate(balnc *balancer.Balancer, counter *uint32,
+	cands []candidate, r *http.Request) int {
+
+	switch balnc.Algorithm {
+	case balancer.RoundRobin:
+		return int(atomic.AddUint32(counter, 1) % uint32(len(cands)))
+	case balancer.Weighted:
+		return selectWeighted(cands)
+	case balancer.LeastConn:
+		return selectLeastConn(cands)
+	case balancer.ConsistentHash:
+		value := getHashValue(balnc, r)
+		if value != "" {
+			return selectHash(cands, value)
+		}
+		return selectWeighted(cands)
+	case balancer.Sticky:
+		return selectWeighted(cands)
+	}
+
+	return rand.Intn(len(cands))
+}
@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pritunl/pritunl-cloud/balancer"
+)
+
+type testCandidate struct {
+	key    string
+	weight int
+	conns  int32
+}
+
+func (c *testCandidate) GetKey() string {
+	return c.key
+}
+
+func (c *testCandidate) GetWeight() int {
+	return c.weight
+}
+
+func (c *testCandidate) GetConns() int32 {
+	return c.conns
+}
+
+func (c *testCandidate) IsDraining() bool {
+	return false
+}
+
+func countSelected(balnc *balancer.Balancer, cands []candidate,
+	r *http.Request, rounds int) map[string]int {
+
+	counts := map[string]int{}
+	counter := uint32(0)
+	for i := 0; i < rounds; i++ {
+		index := selectCandidate(balnc, &counter, cands, r)
+		counts[cands[index].GetKey()] += 1
+	}
+
+	return counts
+}
+
+func TestSelectRoundRobin(t *testing.T) {
+	balnc := &balancer.Balancer{
+		Algorithm: balancer.RoundRobin,
+	}
+	cands := []candidate{
+		&testCandidate{key: "a", weight: 1},
+		&testCandidate{key: "b", weight: 1},
+		&testCandidate{key: "c", weight: 1},
+	}
+
+	counter := uint32(0)
+	order := ""
+	for i := 0; i < 6; i++ {
+		order += cands[selectCandidate(balnc, &counter, cands, nil)].GetKey()
+	}
+
+	if order != "bcabca" {
+		t.Errorf("round robin order %s expected bcabca", order)
+	}
+}
+
+func TestSelectWeighted(t *testing.T) {
+	balnc := &balancer.Balancer{
+		Algorithm: balancer.Weighted,
+	}
+	cands := []candidate{
+		&testCandidate{key: "a", weight: 0},
+		&testCandidate{key: "b", weight: 3},
+		&testCandidate{key: "c", weight: 1},
+	}
+
+	counts := countSelected(balnc, cands, nil, 4000)
+	if counts["a"] != 0 {
+		t.Errorf("zero weight backend selected %d times", counts["a"])
+	}
+	if counts["b"] < counts["c"]*2 {
+		t.Errorf("weight 3 backend selected %d times and weight 1 "+
+			"backend selected %d times", counts["b"], counts["c"])
+	}
+
+	cands = []candidate{
+		&testCandidate{key: "a", weight: 0},
+		&testCandidate{key: "b", weight: 0},
+	}
+
+	counts = countSelected(balnc, cands, nil, 200)
+	if counts["a"] == 0 || counts["b"] == 0 {
+		t.Errorf("zero total weight did not fall back to random")
+	}
+}
+
+func TestSelectLeastConn(t *testing.T) {
+	balnc := &balancer.Balancer{
+		Algorithm: balancer.LeastConn,
+	}
+	cands := []candidate{
+		&testCandidate{key: "a", weight: 1, conns: 5},
+		&testCandidate{key: "b", weight: 1, conns: 1},
+		&testCandidate{key: "c", weight: 1, conns: 3},
+	}
+
+	counts := countSelected(balnc, cands, nil, 50)
+	if counts["b"] != 50 {
+		t.Errorf("least connections selected %v", counts)
+	}
+
+	// Four connections on weight four is less loaded than two on one
+	cands = []candidate{
+		&testCandidate{key: "a", weight: 4, conns: 4},
+		&testCandidate{key: "b", weight: 1, conns: 2},
+	}
+
+	counts = countSelected(balnc, cands, nil, 50)
+	if counts["a"] != 50 {
+		t.Errorf("weighted least connections selected %v", counts)
+	}
+}
+
+func TestSelectConsistentHash(t *testing.T) {
+	balnc := &balancer.Balancer{
+		Algorithm:  balancer.ConsistentHash,
+		HashSource: balancer.HashHeader,
+		HashKey:    "X-Tenant",
+	}
+	cands := []candidate{
+		&testCandidate{key: "a", weight: 1},
+		&testCandidate{key: "b", weight: 1},
+		&testCandidate{key: "c", weight: 1},
+		&testCandidate{key: "d", weight: 1},
+	}
+	reversed := []candidate{cands[3], cands[2], cands[1], cands[0]}
+
+	for _, tenant := range []string{"alpha", "beta", "gamma", "delta"} {
+		r, _ := http.NewRequest("GET", "http://localhost/", nil)
+		r.Header.Set("X-Tenant", tenant)
+
+		counts := countSelected(balnc, cands, r, 10)
+		if len(counts) != 1 {
+			t.Errorf("tenant %s spread across %v", tenant, counts)
+			continue
+		}
+
+		key := cands[selectHash(cands, tenant)].GetKey()
+		if counts[key] != 10 {
+			t.Errorf("tenant %s selected %v expected %s",
+				tenant, counts, key)
+		}
+
+		index := selectCandidate(balnc, nil, reversed, r)
+		if reversed[index].GetKey() != key {
+			t.Errorf("tenant %s hash depends on backend order", tenant)
+		}
+
+		// Removing another backend must not move the tenant
+		remaining := []candidate{}
+		for _, cand := range cands {
+			if cand.GetKey() == key || len(remaining) < 2 {
+				remaining = append(remaining, cand)
+			}
+		}
+		index = selectCandidate(balnc, nil, remaining, r)
+		if remaining[index].GetKey() != key {
+			t.Errorf("tenant %s moved after backend removal", tenant)
+		}
+	}
+
+	// Requests without the hash header fall back to weighted selection
+	cands[0].(*testCandidate).weight = 0
+	cands[1].(*testCandidate).weight = 0
+	cands[2].(*testCandidate).weight = 0
+
+	r, _ := http.NewRequest("GET", "http://localhost/", nil)
+	counts := countSelected(balnc, cands, r, 20)
+	if counts["d"] != 20 {
+		t.Errorf("missing hash value selected %v", counts)
+	}
+}
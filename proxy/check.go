@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-cloud/balancer"
+)
+
+type checker struct {
+	lock      sync.Mutex
+	lastCheck time.Time
+	checks    map[string]*balancer.Check
+}
+
+func (c *checker) Ready(interval time.Duration) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if time.Since(c.lastCheck) < interval {
+		return false
+	}
+	c.lastCheck = time.Now()
+
+	return true
+}
+
+func (c *checker) Record(balnc *balancer.Balancer, key string,
+	result *balancer.CheckResult) (healthy, unhealthy bool) {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	chk := c.checks[key]
+	if chk == nil {
+		chk = &balancer.Check{
+			Key:     key,
+			History: []*balancer.CheckResult{},
+		}
+		c.checks[key] = chk
+	}
+
+	chk.Add(result)
+
+	if result.Success {
+		healthy = chk.Successes >= balnc.GetCheckHealthy()
+	} else {
+		unhealthy = chk.Failures >= balnc.GetCheckUnhealthy()
+	}
+
+	return
+}
+
+func (c *checker) Prune(keys set.Set) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for key := range c.checks {
+		if !keys.Contains(key) {
+			delete(c.checks, key)
+		}
+	}
+}
+
+func (c *checker) Checks() (checks []*balancer.Check) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	checks = []*balancer.Check{}
+	for _, chk := range c.checks {
+		checks = append(checks, chk.Copy())
+	}
+
+	sort.Slice(checks, func(i, j int) bool {
+		return checks[i].Key < checks[j].Key
+	})
+
+	return
+}
+
+func newChecker() *checker {
+	return &checker{
+		checks: map[string]*balancer.Check{},
+	}
+}
+
+func runCheck(balnc *balancer.Balancer, proto, host string) (
+	result *balancer.CheckResult) {
+
+	start := time.Now()
+	timeout := balnc.GetCheckTimeout()
+
+	result = &balancer.CheckResult{
+		Timestamp: start,
+	}
+	defer func() {
+		result.Latency = time.Since(start).Milliseconds()
+	}()
+
+	if balnc.CheckType == balancer.CheckTcp {
+		conn, err := net.DialTimeout("tcp", host, timeout)
+		if err != nil {
+			result.Message = err.Error()
+			return
+		}
+		conn.Close()
+
+		result.Success = true
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	checkUrl := &url.URL{
+		Scheme: proto,
+		Host:   host,
+		Path:   balnc.CheckPath,
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx, "GET", checkUrl.String(), nil)
+	if err != nil {
+		result.Message = err.Error()
+		return
+	}
+
+	if balnc.CheckHost != "" {
+		req.Host = balnc.CheckHost
+	}
+
+	resp, err := checkClient.Do(req)
+	if err != nil {
+		result.Message = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+
+	result.Status = resp.StatusCode
+
+	if !balnc.CheckCodeValid(resp.StatusCode) {
+		result.Message = fmt.Sprintf(
+			"Unexpected status code %d", resp.StatusCode)
+		return
+	}
+
+	if balnc.CheckBody != "" {
+		body, e := ioutil.ReadAll(io.LimitReader(resp.Body, 65536))
+		if e != nil {
+			result.Message = e.Error()
+			return
+		}
+
+		if !strings.Contains(string(body), balnc.CheckBody) {
+			result.Message = "Response body did not match"
+			return
+		}
+	}
+
+	result.Success = true
+
+	return
+}
@@ -3,7 +3,7 @@ package proxy
 import (
 	"crypto/md5"
 	"crypto/tls"
-	"math/rand"
+	"crypto/x509"
 	"net/http"
 	"strconv"
 	"sync"
@@ -13,6 +13,8 @@ import (
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/pritunl/pritunl-cloud/authority"
 	"github.com/pritunl/pritunl-cloud/balancer"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/utils"
 )
 
 var (
@@ -27,7 +29,9 @@ var (
 	}
 	checkClient = &http.Client{
 		Transport: checkTransport,
-		Timeout:   5 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
 	}
 )
 
@@ -39,6 +43,12 @@ type Domain struct {
 	Retries           *int32
 	RetriesPrev       [5]int
 	RetriesTotal      int
+	Counter           *uint32
+	Checker           *checker
+	Metrics           *metrics
+	Filter            *filter
+	Rejections        *rejections
+	Rules             []*rule
 	Lock              sync.Mutex
 	ProxyProto        string
 	ProxyPort         int
@@ -47,6 +57,8 @@ type Domain struct {
 	Domain            *balancer.Domain
 	ClientAuthority   *authority.Authority
 	ClientCertificate *tls.Certificate
+	BackendPool       *x509.CertPool
+	BackendNames      map[string]string
 
 	OnlineWebFirst      []*Handler
 	UnknownHighWebFirst []*Handler
@@ -79,8 +91,43 @@ func (d *Domain) CalculateHash() {
 
 	h.Write([]byte(d.Balancer.Id.Hex()))
 	h.Write([]byte(d.Balancer.Name))
+	h.Write([]byte(d.Balancer.CheckType))
 	h.Write([]byte(d.Balancer.CheckPath))
+	h.Write([]byte(strconv.Itoa(d.Balancer.CheckInterval)))
+	h.Write([]byte(strconv.Itoa(d.Balancer.CheckTimeout)))
+	h.Write([]byte(strconv.Itoa(d.Balancer.CheckHealthy)))
+	h.Write([]byte(strconv.Itoa(d.Balancer.CheckUnhealthy)))
+	for _, code := range d.Balancer.CheckCodes {
+		h.Write([]byte(strconv.Itoa(code)))
+	}
+	h.Write([]byte(d.Balancer.CheckBody))
+	h.Write([]byte(d.Balancer.CheckHost))
 	h.Write([]byte(strconv.FormatBool(d.Balancer.WebSockets)))
+	h.Write([]byte(strconv.FormatBool(d.Balancer.BackendMtls)))
+	h.Write([]byte(d.Balancer.Algorithm))
+	h.Write([]byte(d.Balancer.HashSource))
+	h.Write([]byte(d.Balancer.HashKey))
+	h.Write([]byte(d.Balancer.AccessLog))
+	h.Write([]byte(d.Balancer.AccessLogServer))
+	for _, limit := range d.Balancer.RateLimits {
+		h.Write([]byte(limit.Source))
+		h.Write([]byte(limit.Header))
+		h.Write([]byte(strconv.Itoa(limit.Rate)))
+		h.Write([]byte(strconv.Itoa(limit.Burst)))
+	}
+	for _, cidr := range d.Balancer.AllowCidrs {
+		h.Write([]byte("allow" + cidr))
+	}
+	for _, cidr := range d.Balancer.DenyCidrs {
+		h.Write([]byte("deny" + cidr))
+	}
+	for _, country := range d.Balancer.AllowCountries {
+		h.Write([]byte("allow" + country))
+	}
+	for _, country := range d.Balancer.DenyCountries {
+		h.Write([]byte("deny" + country))
+	}
+	h.Write([]byte(strconv.FormatInt(d.Balancer.MaxBodySize, 10)))
 	h.Write([]byte(d.Domain.Domain))
 	h.Write([]byte(d.Domain.Host))
 
@@ -91,6 +138,28 @@ func (d *Domain) CalculateHash() {
 		h.Write([]byte(backend.Protocol))
 		h.Write([]byte(backend.Hostname))
 		h.Write([]byte(strconv.Itoa(backend.Port)))
+		h.Write([]byte(strconv.Itoa(backend.Weight)))
+		h.Write([]byte(strconv.FormatBool(backend.Draining)))
+		h.Write([]byte(backend.Pool))
+		h.Write([]byte(d.BackendNames[backend.Hostname]))
+	}
+	for _, rle := range d.Balancer.Rules {
+		h.Write([]byte(rle.Match))
+		h.Write([]byte(rle.Key))
+		h.Write([]byte(rle.Value))
+		h.Write([]byte(rle.Action))
+		h.Write([]byte(rle.Pool))
+		h.Write([]byte(rle.RewritePath))
+		for _, header := range rle.AddHeaders {
+			h.Write([]byte(header.Key))
+			h.Write([]byte(header.Value))
+		}
+		for _, header := range rle.RemoveHeaders {
+			h.Write([]byte(header))
+		}
+		h.Write([]byte(strconv.Itoa(rle.Status)))
+		h.Write([]byte(rle.ContentType))
+		h.Write([]byte(rle.Body))
 	}
 
 	d.Hash = h.Sum(nil)
@@ -128,20 +197,45 @@ func (d *Domain) Init() {
 	unknownHighWebSecond := []*Handler{}
 	unknownHighWebThird := []*Handler{}
 
+	if d.Checker == nil {
+		d.Checker = newChecker()
+	}
+	if d.Metrics == nil {
+		d.Metrics = newMetrics()
+	}
+	if d.Rejections == nil {
+		d.Rejections = newRejections()
+	}
+	checkKeys := set.NewSet()
+
 	for i, backend := range d.Balancer.Backends {
+		conns := new(int32)
+
 		hand := NewHandler(i, UnknownHigh, d.ProxyProto, d.ProxyPort, d,
 			backend, d.ResponseHandler, d.ErrorHandlerFirst)
+		hand.Conns = conns
 		unknownHighWebFirst = append(unknownHighWebFirst, hand)
 
 		hand = NewHandler(i, UnknownHigh, d.ProxyProto, d.ProxyPort, d,
 			backend, d.ResponseHandler, d.ErrorHandlerSecond)
+		hand.Conns = conns
 		unknownHighWebSecond = append(unknownHighWebSecond, hand)
 
 		hand = NewHandler(i, UnknownHigh, d.ProxyProto, d.ProxyPort, d,
 			backend, d.ResponseHandler, d.ErrorHandlerThird)
+		hand.Conns = conns
 		unknownHighWebThird = append(unknownHighWebThird, hand)
+
+		checkKeys.Add(hand.Key)
 	}
 
+	d.Checker.Prune(checkKeys)
+	d.Metrics.Prune(checkKeys)
+
+	d.Counter = new(uint32)
+	d.Rules = compileRules(d.Balancer)
+	d.Filter = newFilter(d.Balancer)
+
 	d.OnlineWebFirst = []*Handler{}
 	d.UnknownHighWebFirst = unknownHighWebFirst
 	d.UnknownMidWebFirst = []*Handler{}
@@ -163,136 +257,208 @@ func (d *Domain) Init() {
 	d.WebSocketConns = set.NewSet()
 }
 
-func (d *Domain) ServeHTTPFirst(rw http.ResponseWriter, r *http.Request) {
-	atomic.AddInt32(d.Requests, 1)
+func (d *Domain) pick(hands []*Handler, r *http.Request,
+	draining bool) *Handler {
 
-	onlineWebFirst := d.OnlineWebFirst
-	l := len(onlineWebFirst)
-	if l != 0 {
-		onlineWebFirst[rand.Intn(l)].Serve(rw, r)
-		return
+	if len(hands) == 0 {
+		return nil
 	}
 
-	unknownHighWebFirst := d.UnknownHighWebFirst
-	l = len(unknownHighWebFirst)
-	if l != 0 {
-		unknownHighWebFirst[rand.Intn(l)].Serve(rw, r)
-		return
+	if d.Balancer.Algorithm == balancer.Sticky {
+		stickyId := getHashValue(d.Balancer, r)
+		if stickyId != "" {
+			pool := getPool(r)
+			for _, hand := range hands {
+				if hand.StickyId == stickyId && hand.Pool == pool {
+					return hand
+				}
+			}
+		}
 	}
 
-	unknownMidWebFirst := d.UnknownMidWebFirst
-	l = len(unknownMidWebFirst)
-	if l != 0 {
-		unknownMidWebFirst[rand.Intn(l)].Serve(rw, r)
-		return
-	}
+	pool := getPool(r)
 
-	unknownLowWebFirst := d.UnknownLowWebFirst
-	l = len(unknownLowWebFirst)
-	if l != 0 {
-		unknownLowWebFirst[rand.Intn(l)].Serve(rw, r)
-		return
+	cands := []candidate{}
+	for _, hand := range hands {
+		if hand.Pool != pool || (hand.Draining && !draining) {
+			continue
+		}
+		cands = append(cands, hand)
 	}
 
-	offlineWebFirst := d.OfflineWebFirst
-	l = len(offlineWebFirst)
-	if l != 0 {
-		offlineWebFirst[rand.Intn(l)].Serve(rw, r)
-		return
+	if len(cands) == 0 {
+		return nil
 	}
 
-	rw.WriteHeader(http.StatusBadGateway)
+	return cands[selectCandidate(d.Balancer, d.Counter, cands, r)].(*Handler)
 }
 
-func (d *Domain) ServeHTTPSecond(rw http.ResponseWriter, r *http.Request) {
-	atomic.AddInt32(d.Retries, 1)
+func (d *Domain) serve(rw http.ResponseWriter, r *http.Request,
+	tiers ...[]*Handler) {
 
-	onlineWebSecond := d.OnlineWebSecond
-	l := len(onlineWebSecond)
-	if l != 0 {
-		onlineWebSecond[rand.Intn(l)].Serve(rw, r)
-		return
+	for _, hands := range tiers {
+		hand := d.pick(hands, r, false)
+		if hand != nil {
+			hand.Serve(rw, r)
+			return
+		}
 	}
 
-	unknownHighWebSecond := d.UnknownHighWebSecond
-	l = len(unknownHighWebSecond)
-	if l != 0 {
-		unknownHighWebSecond[rand.Intn(l)].Serve(rw, r)
-		return
+	for _, hands := range tiers {
+		hand := d.pick(hands, r, true)
+		if hand != nil {
+			hand.Serve(rw, r)
+			return
+		}
 	}
 
-	unknownMidWebSecond := d.UnknownMidWebSecond
-	l = len(unknownMidWebSecond)
-	if l != 0 {
-		unknownMidWebSecond[rand.Intn(l)].Serve(rw, r)
-		return
-	}
+	rw.WriteHeader(http.StatusBadGateway)
+}
 
-	unknownLowWebSecond := d.UnknownLowWebSecond
-	l = len(unknownLowWebSecond)
-	if l != 0 {
-		unknownLowWebSecond[rand.Intn(l)].Serve(rw, r)
-		return
+func (d *Domain) route(rw http.ResponseWriter, r *http.Request) bool {
+
+	for _, rle := range d.Rules {
+		if rle.Action == balancer.ActionRedirectHttps &&
+			d.ProxyProto != "http" {
+
+			continue
+		}
+
+		if !rle.Matches(r) {
+			continue
+		}
+
+		pool, handled := rle.Apply(rw, r)
+		if handled {
+			return false
+		}
+
+		getRequestInfo(r).Pool = pool
+		return true
 	}
 
-	offlineWebSecond := d.OfflineWebSecond
-	l = len(offlineWebSecond)
-	if l != 0 {
-		offlineWebSecond[rand.Intn(l)].Serve(rw, r)
-		return
+	return true
+}
+
+func (d *Domain) newAccessEntry(r *http.Request) *accessEntry {
+	return &accessEntry{
+		Timestamp:    time.Now(),
+		Organization: d.Balancer.Organization.Hex(),
+		BalancerId:   d.Balancer.Id.Hex(),
+		Balancer:     d.Balancer.Name,
+		ClientIp:     node.Self.GetRemoteAddr(r),
+		Method:       r.Method,
+		Host:         r.Host,
+		Path:         r.URL.RequestURI(),
+		UserAgent:    r.UserAgent(),
+		dest:         d.Balancer.AccessLog,
+		server:       d.Balancer.AccessLogServer,
 	}
+}
 
-	rw.WriteHeader(http.StatusBadGateway)
+func (d *Domain) finishAccess(entry *accessEntry, aw *accessWriter,
+	info *requestInfo) {
+
+	entry.Status = aw.status
+	entry.Bytes = aw.bytes
+	entry.Upstream = info.Upstream
+	entry.Latency = int64(time.Since(entry.Timestamp) / time.Millisecond)
+
+	logAccess(entry)
 }
 
-func (d *Domain) ServeHTTPThird(rw http.ResponseWriter, r *http.Request) {
-	atomic.AddInt32(d.Retries, 1)
+func (d *Domain) bodyTooLarge(rw http.ResponseWriter,
+	r *http.Request) bool {
 
-	onlineWebThird := d.OnlineWebThird
-	l := len(onlineWebThird)
-	if l != 0 {
-		onlineWebThird[rand.Intn(l)].Serve(rw, r)
-		return
+	info := getRequestInfo(r)
+	if info == nil || !info.TooLarge {
+		return false
 	}
 
-	unknownHighWebThird := d.UnknownHighWebThird
-	l = len(unknownHighWebThird)
-	if l != 0 {
-		unknownHighWebThird[rand.Intn(l)].Serve(rw, r)
+	d.Rejections.Add(http.StatusRequestEntityTooLarge)
+	utils.WriteStatus(rw, http.StatusRequestEntityTooLarge)
+
+	return true
+}
+
+func (d *Domain) recordError(hand *Handler, r *http.Request) {
+	info := getRequestInfo(r)
+	if info == nil {
 		return
 	}
 
-	unknownMidWebThird := d.UnknownMidWebThird
-	l = len(unknownMidWebThird)
-	if l != 0 {
-		unknownMidWebThird[rand.Intn(l)].Serve(rw, r)
-		return
+	d.Metrics.Record(hand.Key, time.Since(info.UpstreamStart), true)
+}
+
+func (d *Domain) ServeHTTPFirst(rw http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(d.Requests, 1)
+
+	info := &requestInfo{}
+	r = setRequestInfo(r, info)
+
+	if d.Balancer.AccessLog != "" {
+		aw := &accessWriter{
+			ResponseWriter: rw,
+		}
+		rw = aw
+
+		defer d.finishAccess(d.newAccessEntry(r), aw, info)
 	}
 
-	unknownLowWebThird := d.UnknownLowWebThird
-	l = len(unknownLowWebThird)
-	if l != 0 {
-		unknownLowWebThird[rand.Intn(l)].Serve(rw, r)
+	status := d.Filter.Check(r, node.Self.GetRemoteAddr(r))
+	if status != 0 {
+		d.Rejections.Add(status)
+		utils.WriteStatus(rw, status)
 		return
 	}
+	d.Filter.LimitBody(r, info)
 
-	offlineWebThird := d.OfflineWebThird
-	l = len(offlineWebThird)
-	if l != 0 {
-		offlineWebThird[rand.Intn(l)].Serve(rw, r)
+	if !d.route(rw, r) {
 		return
 	}
 
-	rw.WriteHeader(http.StatusBadGateway)
+	d.serve(rw, r,
+		d.OnlineWebFirst,
+		d.UnknownHighWebFirst,
+		d.UnknownMidWebFirst,
+		d.UnknownLowWebFirst,
+		d.OfflineWebFirst,
+	)
+}
+
+func (d *Domain) ServeHTTPSecond(rw http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(d.Retries, 1)
+
+	d.serve(rw, r,
+		d.OnlineWebSecond,
+		d.UnknownHighWebSecond,
+		d.UnknownMidWebSecond,
+		d.UnknownLowWebSecond,
+		d.OfflineWebSecond,
+	)
+}
+
+func (d *Domain) ServeHTTPThird(rw http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(d.Retries, 1)
+
+	d.serve(rw, r,
+		d.OnlineWebThird,
+		d.UnknownHighWebThird,
+		d.UnknownMidWebThird,
+		d.UnknownLowWebThird,
+		d.OfflineWebThird,
+	)
 }
 
 func (d *Domain) checkHandler(hand *Handler) {
-	resp, err := checkClient.Get(hand.CheckUrl)
-	if err != nil || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+	result := runCheck(d.Balancer, hand.BackendProto, hand.BackendHost)
+
+	healthy, unhealthy := d.Checker.Record(d.Balancer, hand.Key, result)
+	if unhealthy {
 		if hand.State != Offline {
 			d.offlineHandler(hand)
 		}
-	} else {
+	} else if healthy {
 		if hand.State != Online {
 			d.upgradeHandler(hand)
 		}
@@ -300,6 +466,10 @@ func (d *Domain) checkHandler(hand *Handler) {
 }
 
 func (d *Domain) Check() {
+	if !d.Checker.Ready(d.Balancer.GetCheckInterval()) {
+		return
+	}
+
 	d.Lock.Lock()
 	defer d.Lock.Unlock()
 
@@ -902,6 +1072,25 @@ func (d *Domain) ResponseHandler(hand *Handler, resp *http.Response) error {
 		d.upgradeHandler(hand)
 	}
 
+	info := getRequestInfo(resp.Request)
+	if info != nil {
+		d.Metrics.Record(hand.Key, time.Since(info.UpstreamStart),
+			resp.StatusCode >= 500)
+	}
+
+	if d.Balancer.Algorithm == balancer.Sticky &&
+		getHashValue(d.Balancer, resp.Request) != hand.StickyId {
+
+		cookie := &http.Cookie{
+			Name:     d.Balancer.GetHashKey(),
+			Value:    hand.StickyId,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   d.ProxyProto == "https",
+		}
+		resp.Header.Add("Set-Cookie", cookie.String())
+	}
+
 	return nil
 }
 
@@ -912,6 +1101,11 @@ func (d *Domain) ErrorHandlerFirst(hand *Handler, rw http.ResponseWriter,
 		return
 	}
 
+	if d.bodyTooLarge(rw, r) {
+		return
+	}
+
+	d.recordError(hand, r)
 	d.downgradeHandler(hand)
 	d.ServeHTTPSecond(rw, r)
 }
@@ -923,6 +1117,11 @@ func (d *Domain) ErrorHandlerSecond(hand *Handler, rw http.ResponseWriter,
 		return
 	}
 
+	if d.bodyTooLarge(rw, r) {
+		return
+	}
+
+	d.recordError(hand, r)
 	d.downgradeHandler(hand)
 	d.ServeHTTPThird(rw, r)
 }
@@ -934,6 +1133,11 @@ func (d *Domain) ErrorHandlerThird(hand *Handler, rw http.ResponseWriter,
 		return
 	}
 
+	if d.bodyTooLarge(rw, r) {
+		return
+	}
+
+	d.recordError(hand, r)
 	d.downgradeHandler(hand)
 	rw.WriteHeader(http.StatusBadGateway)
 }
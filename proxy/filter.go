@@ -0,0 +1,408 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/balancer"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/geo"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	geoCacheTtl      = 6 * time.Hour
+	geoLookupTimeout = 500 * time.Millisecond
+	limiterCleanTtl  = 1 * time.Minute
+)
+
+var (
+	errBodyTooLarge = &errortypes.RequestError{
+		errors.New("proxy: Request body too large"),
+	}
+	geoCache     = map[string]*geoCacheEntry{}
+	geoPending   = map[string]chan bool{}
+	geoCacheLock sync.Mutex
+)
+
+type geoCacheEntry struct {
+	country   string
+	timestamp time.Time
+}
+
+func lookupCountry(addr string, done chan bool) {
+	defer func() {
+		geoCacheLock.Lock()
+		delete(geoPending, addr)
+		geoCacheLock.Unlock()
+		close(done)
+	}()
+
+	db := database.GetDatabase()
+	defer db.Close()
+
+	ge, err := geo.Get(db, addr)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"address": addr,
+			"error":   err,
+		}).Warn("proxy: Failed to lookup client country")
+		return
+	}
+
+	if ge == nil {
+		return
+	}
+
+	geoCacheLock.Lock()
+	geoCache[addr] = &geoCacheEntry{
+		country:   strings.ToUpper(ge.CountryCode),
+		timestamp: time.Now(),
+	}
+	geoCacheLock.Unlock()
+}
+
+func getCountry(addr string) (country string, ok bool) {
+	geoCacheLock.Lock()
+	entry := geoCache[addr]
+	if entry != nil && time.Since(entry.timestamp) < geoCacheTtl {
+		geoCacheLock.Unlock()
+		country = entry.country
+		ok = true
+		return
+	}
+
+	done := geoPending[addr]
+	if done == nil {
+		done = make(chan bool)
+		geoPending[addr] = done
+		go lookupCountry(addr, done)
+	}
+	geoCacheLock.Unlock()
+
+	select {
+	case <-done:
+		break
+	case <-time.After(geoLookupTimeout):
+		return
+	}
+
+	geoCacheLock.Lock()
+	entry = geoCache[addr]
+	geoCacheLock.Unlock()
+
+	if entry != nil {
+		country = entry.country
+		ok = true
+	}
+
+	return
+}
+
+func cleanGeoCache() {
+	geoCacheLock.Lock()
+	for key, entry := range geoCache {
+		if time.Since(entry.timestamp) >= geoCacheTtl {
+			delete(geoCache, key)
+		}
+	}
+	geoCacheLock.Unlock()
+}
+
+func runGeoCache() {
+	for {
+		time.Sleep(10 * time.Minute)
+		cleanGeoCache()
+	}
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	limit     *balancer.RateLimit
+	lock      sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastClean time.Time
+}
+
+func (l *rateLimiter) key(r *http.Request, clientIp string) string {
+	switch l.limit.Source {
+	case balancer.LimitHeader:
+		val := r.Header.Get(l.limit.Header)
+		if val != "" {
+			return val
+		}
+		return clientIp
+	case balancer.LimitPath:
+		return r.URL.Path
+	}
+
+	return clientIp
+}
+
+func (l *rateLimiter) Allow(r *http.Request, clientIp string) bool {
+	key := l.key(r, clientIp)
+	rate := float64(l.limit.Rate) / 60
+	burst := float64(l.limit.Burst)
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if now.Sub(l.lastClean) > limiterCleanTtl {
+		l.lastClean = now
+		for k, bucket := range l.buckets {
+			if bucket.tokens+now.Sub(bucket.last).Seconds()*rate >= burst {
+				delete(l.buckets, k)
+			}
+		}
+	}
+
+	bucket := l.buckets[key]
+	if bucket == nil {
+		bucket = &tokenBucket{
+			tokens: burst,
+			last:   now,
+		}
+		l.buckets[key] = bucket
+	} else {
+		bucket.tokens += now.Sub(bucket.last).Seconds() * rate
+		if bucket.tokens > burst {
+			bucket.tokens = burst
+		}
+		bucket.last = now
+	}
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens -= 1
+
+	return true
+}
+
+type filter struct {
+	maxBodySize    int64
+	allowNets      []*net.IPNet
+	denyNets       []*net.IPNet
+	allowCountries set.Set
+	denyCountries  set.Set
+	limiters       []*rateLimiter
+}
+
+func (f *filter) blocked(clientIp string) bool {
+	ip := net.ParseIP(clientIp)
+	if ip == nil {
+		return len(f.allowNets) > 0 || f.allowCountries.Len() > 0
+	}
+
+	for _, network := range f.denyNets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	if len(f.allowNets) > 0 {
+		allowed := false
+		for _, network := range f.allowNets {
+			if network.Contains(ip) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return true
+		}
+	}
+
+	if f.allowCountries.Len() > 0 || f.denyCountries.Len() > 0 {
+		country, ok := getCountry(ip.String())
+		if !ok {
+			return f.allowCountries.Len() > 0
+		}
+
+		if f.denyCountries.Contains(country) {
+			return true
+		}
+
+		if f.allowCountries.Len() > 0 &&
+			!f.allowCountries.Contains(country) {
+
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *filter) Check(r *http.Request, clientIp string) int {
+	if f.blocked(clientIp) {
+		return http.StatusForbidden
+	}
+
+	if f.maxBodySize > 0 && r.ContentLength > f.maxBodySize {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	for _, limiter := range f.limiters {
+		if !limiter.Allow(r, clientIp) {
+			return http.StatusTooManyRequests
+		}
+	}
+
+	return 0
+}
+
+func (f *filter) LimitBody(r *http.Request, info *requestInfo) {
+	if f.maxBodySize <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+
+	r.Body = &limitedBody{
+		ReadCloser: r.Body,
+		remaining:  f.maxBodySize,
+		info:       info,
+	}
+}
+
+func newFilter(balnc *balancer.Balancer) (f *filter) {
+	f = &filter{
+		maxBodySize:    balnc.MaxBodySize,
+		allowNets:      []*net.IPNet{},
+		denyNets:       []*net.IPNet{},
+		allowCountries: set.NewSet(),
+		denyCountries:  set.NewSet(),
+		limiters:       []*rateLimiter{},
+	}
+
+	for _, cidr := range balnc.AllowCidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err == nil {
+			f.allowNets = append(f.allowNets, network)
+		}
+	}
+
+	for _, cidr := range balnc.DenyCidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err == nil {
+			f.denyNets = append(f.denyNets, network)
+		}
+	}
+
+	for _, country := range balnc.AllowCountries {
+		f.allowCountries.Add(country)
+	}
+
+	for _, country := range balnc.DenyCountries {
+		f.denyCountries.Add(country)
+	}
+
+	for _, limit := range balnc.RateLimits {
+		f.limiters = append(f.limiters, &rateLimiter{
+			limit:   limit,
+			buckets: map[string]*tokenBucket{},
+		})
+	}
+
+	return
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	info      *requestInfo
+}
+
+func (b *limitedBody) Read(p []byte) (n int, err error) {
+	if b.remaining <= 0 {
+		b.info.TooLarge = true
+		err = errBodyTooLarge
+		return
+	}
+
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err = b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		b.info.TooLarge = true
+		err = errBodyTooLarge
+		return
+	}
+	b.remaining -= int64(n)
+
+	return
+}
+
+type rejections struct {
+	RateLimited      *int32
+	RateLimitedPrev  [5]int
+	RateLimitedTotal int
+	Blocked          *int32
+	BlockedPrev      [5]int
+	BlockedTotal     int
+	TooLarge         *int32
+	TooLargePrev     [5]int
+	TooLargeTotal    int
+}
+
+func (r *rejections) Add(status int) {
+	switch status {
+	case http.StatusTooManyRequests:
+		atomic.AddInt32(r.RateLimited, 1)
+		break
+	case http.StatusForbidden:
+		atomic.AddInt32(r.Blocked, 1)
+		break
+	case http.StatusRequestEntityTooLarge:
+		atomic.AddInt32(r.TooLarge, 1)
+		break
+	}
+}
+
+func rotateCount(cur *int32, prev *[5]int) (total int) {
+	count := int(atomic.SwapInt32(cur, 0))
+	copy(prev[:], prev[1:])
+	prev[len(prev)-1] = count
+
+	for _, val := range prev {
+		total += val
+	}
+
+	return
+}
+
+func (r *rejections) Rotate() {
+	r.RateLimitedTotal = rotateCount(r.RateLimited, &r.RateLimitedPrev)
+	r.BlockedTotal = rotateCount(r.Blocked, &r.BlockedPrev)
+	r.TooLargeTotal = rotateCount(r.TooLarge, &r.TooLargePrev)
+}
+
+func (r *rejections) GetState(state *balancer.State) {
+	state.RateLimited += r.RateLimitedTotal
+	state.Blocked += r.BlockedTotal
+	state.TooLarge += r.TooLargeTotal
+}
+
+func newRejections() *rejections {
+	return &rejections{
+		RateLimited: new(int32),
+		Blocked:     new(int32),
+		TooLarge:    new(int32),
+	}
+}
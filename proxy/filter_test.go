@@ -0,0 +1,180 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pritunl/pritunl-cloud/balancer"
+)
+
+func newTestRequest(path string) *http.Request {
+	r, _ := http.NewRequest("GET", "http://localhost"+path, nil)
+	return r
+}
+
+func TestFilterNetworks(t *testing.T) {
+	fltr := newFilter(&balancer.Balancer{
+		AllowCidrs: []string{"10.0.0.0/8", "invalid"},
+		DenyCidrs:  []string{"10.1.0.0/16"},
+	})
+	r := newTestRequest("/")
+
+	if status := fltr.Check(r, "10.0.0.1"); status != 0 {
+		t.Errorf("allowed network returned %d", status)
+	}
+	if status := fltr.Check(r, "10.1.0.1"); status != http.StatusForbidden {
+		t.Errorf("denied network returned %d", status)
+	}
+	if status := fltr.Check(r, "192.168.0.1"); status != http.StatusForbidden {
+		t.Errorf("network outside allow list returned %d", status)
+	}
+	if status := fltr.Check(r, "unknown"); status != http.StatusForbidden {
+		t.Errorf("invalid client address returned %d", status)
+	}
+
+	fltr = newFilter(&balancer.Balancer{
+		DenyCidrs: []string{"10.1.0.0/16"},
+	})
+	if status := fltr.Check(r, "192.168.0.1"); status != 0 {
+		t.Errorf("network outside deny list returned %d", status)
+	}
+}
+
+func TestFilterBodySize(t *testing.T) {
+	fltr := newFilter(&balancer.Balancer{
+		MaxBodySize: 10,
+	})
+
+	r := newTestRequest("/")
+	r.ContentLength = 10
+	if status := fltr.Check(r, "10.0.0.1"); status != 0 {
+		t.Errorf("body at limit returned %d", status)
+	}
+
+	r.ContentLength = 11
+	status := fltr.Check(r, "10.0.0.1")
+	if status != http.StatusRequestEntityTooLarge {
+		t.Errorf("body over limit returned %d", status)
+	}
+}
+
+func TestFilterRateLimitIp(t *testing.T) {
+	fltr := newFilter(&balancer.Balancer{
+		RateLimits: []*balancer.RateLimit{
+			{Source: balancer.LimitIp, Rate: 60, Burst: 2},
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		if status := fltr.Check(newTestRequest("/"), "10.0.0.1"); status != 0 {
+			t.Errorf("burst request %d returned %d", i, status)
+		}
+	}
+
+	status := fltr.Check(newTestRequest("/other"), "10.0.0.1")
+	if status != http.StatusTooManyRequests {
+		t.Errorf("request over burst returned %d", status)
+	}
+
+	if status = fltr.Check(newTestRequest("/"), "10.0.0.2"); status != 0 {
+		t.Errorf("other client returned %d", status)
+	}
+}
+
+func TestFilterRateLimitHeader(t *testing.T) {
+	limiter := &rateLimiter{
+		limit: &balancer.RateLimit{
+			Source: balancer.LimitHeader,
+			Header: "X-Api-Key",
+			Rate:   60,
+			Burst:  1,
+		},
+		buckets: map[string]*tokenBucket{},
+	}
+
+	r := newTestRequest("/")
+	r.Header.Set("X-Api-Key", "key1")
+	if !limiter.Allow(r, "10.0.0.1") {
+		t.Errorf("first request for key denied")
+	}
+	if limiter.Allow(r, "10.0.0.2") {
+		t.Errorf("same key from other client allowed")
+	}
+
+	r.Header.Set("X-Api-Key", "key2")
+	if !limiter.Allow(r, "10.0.0.1") {
+		t.Errorf("first request for other key denied")
+	}
+
+	// Requests without the header are limited by client address
+	r = newTestRequest("/")
+	if !limiter.Allow(r, "10.0.0.1") {
+		t.Errorf("first request without key denied")
+	}
+	if limiter.Allow(r, "10.0.0.1") {
+		t.Errorf("second request without key allowed")
+	}
+}
+
+func TestFilterRateLimitPath(t *testing.T) {
+	limiter := &rateLimiter{
+		limit: &balancer.RateLimit{
+			Source: balancer.LimitPath,
+			Rate:   60,
+			Burst:  1,
+		},
+		buckets: map[string]*tokenBucket{},
+	}
+
+	if !limiter.Allow(newTestRequest("/login"), "10.0.0.1") {
+		t.Errorf("first request for path denied")
+	}
+	if limiter.Allow(newTestRequest("/login"), "10.0.0.2") {
+		t.Errorf("same path from other client allowed")
+	}
+	if !limiter.Allow(newTestRequest("/logout"), "10.0.0.2") {
+		t.Errorf("first request for other path denied")
+	}
+}
+
+func TestFilterRateLimitRefill(t *testing.T) {
+	limiter := &rateLimiter{
+		limit: &balancer.RateLimit{
+			Source: balancer.LimitIp,
+			Rate:   60,
+			Burst:  2,
+		},
+		buckets:   map[string]*tokenBucket{},
+		lastClean: time.Now(),
+	}
+	r := newTestRequest("/")
+
+	limiter.Allow(r, "10.0.0.1")
+	limiter.Allow(r, "10.0.0.1")
+	if limiter.Allow(r, "10.0.0.1") {
+		t.Errorf("request over burst allowed")
+	}
+
+	// One request per second at 60 requests per minute
+	limiter.buckets["10.0.0.1"].last = time.Now().Add(
+		-1500 * time.Millisecond)
+	if !limiter.Allow(r, "10.0.0.1") {
+		t.Errorf("request after refill denied")
+	}
+	if limiter.Allow(r, "10.0.0.1") {
+		t.Errorf("request after partial refill allowed")
+	}
+
+	// Full buckets are dropped on the next clean
+	limiter.buckets["10.0.0.1"].last = time.Now().Add(-1 * time.Hour)
+	limiter.lastClean = time.Now().Add(-2 * limiterCleanTtl)
+	limiter.Allow(r, "10.0.0.2")
+
+	if limiter.buckets["10.0.0.1"] != nil {
+		t.Errorf("full bucket not removed")
+	}
+	if limiter.buckets["10.0.0.2"] == nil {
+		t.Errorf("active bucket removed")
+	}
+}
@@ -0,0 +1,666 @@
+package proxy
+
+import (
+	"crypto/md5"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/balancer"
+	"github.com/pritunl/pritunl-cloud/certificate"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	l4DialTimeout = 10 * time.Second
+	l4UdpTimeout  = 60 * time.Second
+	l4Retries     = 3
+)
+
+type L4Backend struct {
+	Key       string
+	Hostname  string
+	Port      int
+	Weight    int
+	Draining  bool
+	Conns     *int32
+	State     int
+	LastState time.Time
+}
+
+func (b *L4Backend) GetKey() string {
+	return b.Key
+}
+
+func (b *L4Backend) GetWeight() int {
+	return b.Weight
+}
+
+func (b *L4Backend) GetConns() int32 {
+	return atomic.LoadInt32(b.Conns)
+}
+
+func (b *L4Backend) IsDraining() bool {
+	return b.Draining
+}
+
+type L4 struct {
+	Hash          []byte
+	Requests      *int32
+	RequestsPrev  [5]int
+	RequestsTotal int
+	Counter       *uint32
+	Checker       *checker
+	Metrics       *metrics
+	Lock          sync.Mutex
+	Balancer      *balancer.Balancer
+	Backends      []*L4Backend
+	certs         []*tls.Certificate
+	certsHash     string
+	closers       []io.Closer
+	conns         set.Set
+	connsLock     sync.Mutex
+	closed        bool
+}
+
+func (l *L4) CalculateHash() {
+	h := md5.New()
+
+	h.Write([]byte(l.Balancer.Id.Hex()))
+	h.Write([]byte(l.Balancer.Name))
+	h.Write([]byte(l.Balancer.Algorithm))
+	h.Write([]byte(l.Balancer.CheckType))
+	h.Write([]byte(l.Balancer.CheckPath))
+	h.Write([]byte(strconv.Itoa(l.Balancer.CheckInterval)))
+	h.Write([]byte(strconv.Itoa(l.Balancer.CheckTimeout)))
+	h.Write([]byte(strconv.Itoa(l.Balancer.CheckHealthy)))
+	h.Write([]byte(strconv.Itoa(l.Balancer.CheckUnhealthy)))
+	for _, code := range l.Balancer.CheckCodes {
+		h.Write([]byte(strconv.Itoa(code)))
+	}
+	h.Write([]byte(l.Balancer.CheckBody))
+	h.Write([]byte(l.Balancer.CheckHost))
+
+	for _, backend := range l.Balancer.Backends {
+		h.Write([]byte(backend.Hostname))
+		h.Write([]byte(strconv.Itoa(backend.Port)))
+		h.Write([]byte(strconv.Itoa(backend.Weight)))
+		h.Write([]byte(strconv.FormatBool(backend.Draining)))
+	}
+	for _, listener := range l.Balancer.Listeners {
+		h.Write([]byte(listener.Protocol))
+		h.Write([]byte(strconv.Itoa(listener.Port)))
+		h.Write([]byte(strconv.Itoa(listener.BackendPort)))
+		h.Write([]byte(listener.Mode))
+		h.Write([]byte(listener.ProxyProtocol))
+	}
+
+	l.Hash = h.Sum(nil)
+}
+
+func (l *L4) UpdateCertificates(db *database.Database) (err error) {
+	hasTerminate := false
+	for _, listener := range l.Balancer.Listeners {
+		if listener.Mode == balancer.Terminate {
+			hasTerminate = true
+			break
+		}
+	}
+	if !hasTerminate {
+		return
+	}
+
+	certs := []*certificate.Certificate{}
+	hash := md5.New()
+	for _, certId := range l.Balancer.Certificates {
+		cert, e := certificate.Get(db, certId)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); ok {
+				continue
+			}
+			err = e
+			return
+		}
+
+		if cert.Organization != l.Balancer.Organization {
+			continue
+		}
+
+		io.WriteString(hash, cert.Hash())
+		certs = append(certs, cert)
+	}
+
+	certsHash := fmt.Sprintf("%x", hash.Sum(nil))
+
+	l.Lock.Lock()
+	curHash := l.certsHash
+	l.Lock.Unlock()
+
+	if certsHash == curHash {
+		return
+	}
+
+	tlsCerts := []*tls.Certificate{}
+	for _, cert := range certs {
+		keypair, e := tls.X509KeyPair(
+			[]byte(cert.Certificate),
+			[]byte(cert.Key),
+		)
+		if e != nil {
+			e = &errortypes.ReadError{
+				errors.Wrap(e, "proxy: Failed to load certificate"),
+			}
+			logrus.WithFields(logrus.Fields{
+				"balancer_id":    l.Balancer.Id.Hex(),
+				"certificate_id": cert.Id.Hex(),
+				"error":          e,
+			}).Error("proxy: Balancer certificate error")
+			continue
+		}
+
+		tlsCerts = append(tlsCerts, &keypair)
+	}
+
+	l.Lock.Lock()
+	l.certs = tlsCerts
+	l.certsHash = certsHash
+	l.Lock.Unlock()
+
+	return
+}
+
+func (l *L4) getCertificate(info *tls.ClientHelloInfo) (
+	cert *tls.Certificate, err error) {
+
+	l.Lock.Lock()
+	certs := l.certs
+	l.Lock.Unlock()
+
+	if len(certs) == 0 {
+		err = &errortypes.NotFoundError{
+			errors.New("proxy: No balancer certificate available"),
+		}
+		return
+	}
+
+	for _, c := range certs {
+		if info.SupportsCertificate(c) == nil {
+			cert = c
+			return
+		}
+	}
+
+	cert = certs[0]
+
+	return
+}
+
+func (l *L4) Init(db *database.Database) {
+	if l.Checker == nil {
+		l.Checker = newChecker()
+	}
+	if l.Metrics == nil {
+		l.Metrics = newMetrics()
+	}
+	checkKeys := set.NewSet()
+
+	backends := []*L4Backend{}
+	for _, backend := range l.Balancer.Backends {
+		weight := backend.Weight
+		if weight == 0 {
+			weight = balancer.DefaultWeight
+		}
+
+		key := fmt.Sprintf("%s:%d", backend.Hostname, backend.Port)
+		checkKeys.Add(key)
+
+		backends = append(backends, &L4Backend{
+			Key:      key,
+			Hostname: backend.Hostname,
+			Port:     backend.Port,
+			Weight:   weight,
+			Draining: backend.Draining,
+			Conns:    new(int32),
+			State:    UnknownHigh,
+		})
+	}
+
+	l.Checker.Prune(checkKeys)
+	l.Metrics.Prune(checkKeys)
+
+	l.Counter = new(uint32)
+
+	l.Lock.Lock()
+	l.Backends = backends
+	l.Lock.Unlock()
+
+	l.conns = set.NewSet()
+
+	err := l.UpdateCertificates(db)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"balancer_id": l.Balancer.Id.Hex(),
+			"error":       err,
+		}).Error("proxy: Failed to load balancer certificates")
+	}
+
+	for _, listener := range l.Balancer.Listeners {
+		addr := ":" + strconv.Itoa(listener.Port)
+
+		switch listener.Protocol {
+		case balancer.Tcp:
+			ln, e := net.Listen("tcp", addr)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"balancer_id": l.Balancer.Id.Hex(),
+					"listener":    listener.Key(),
+					"error":       e,
+				}).Error("proxy: Failed to start balancer listener")
+				continue
+			}
+
+			if listener.Mode == balancer.Terminate {
+				ln = tls.NewListener(ln, &tls.Config{
+					MinVersion:     tls.VersionTLS12,
+					GetCertificate: l.getCertificate,
+				})
+			}
+
+			l.closers = append(l.closers, ln)
+			go l.serveTcp(listener, ln)
+
+			break
+		case balancer.Udp:
+			pc, e := net.ListenPacket("udp", addr)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"balancer_id": l.Balancer.Id.Hex(),
+					"listener":    listener.Key(),
+					"error":       e,
+				}).Error("proxy: Failed to start balancer listener")
+				continue
+			}
+
+			l.closers = append(l.closers, pc)
+			go l.serveUdp(listener, pc)
+
+			break
+		}
+	}
+}
+
+func (l *L4) Close() {
+	l.Lock.Lock()
+	l.closed = true
+	closers := l.closers
+	l.closers = nil
+	l.Lock.Unlock()
+
+	for _, closer := range closers {
+		closer.Close()
+	}
+
+	l.connsLock.Lock()
+	for connInf := range l.conns.Iter() {
+		connInf.(io.Closer).Close()
+	}
+	l.conns = set.NewSet()
+	l.connsLock.Unlock()
+}
+
+func (l *L4) isClosed() bool {
+	l.Lock.Lock()
+	defer l.Lock.Unlock()
+	return l.closed
+}
+
+func (l *L4) trackConn(conn io.Closer) {
+	l.connsLock.Lock()
+	l.conns.Add(conn)
+	l.connsLock.Unlock()
+}
+
+func (l *L4) untrackConn(conn io.Closer) {
+	l.connsLock.Lock()
+	l.conns.Remove(conn)
+	l.connsLock.Unlock()
+}
+
+func (l *L4) pickTier(backends []*L4Backend, draining bool) *L4Backend {
+	cands := []candidate{}
+	for _, backend := range backends {
+		if backend.Draining && !draining {
+			continue
+		}
+		cands = append(cands, backend)
+	}
+
+	if len(cands) == 0 {
+		return nil
+	}
+
+	return cands[selectCandidate(
+		l.Balancer, l.Counter, cands, nil)].(*L4Backend)
+}
+
+func (l *L4) pick(tried set.Set) *L4Backend {
+	l.Lock.Lock()
+	defer l.Lock.Unlock()
+
+	online := []*L4Backend{}
+	unknown := []*L4Backend{}
+	offline := []*L4Backend{}
+
+	for _, backend := range l.Backends {
+		if tried.Contains(backend.Key) {
+			continue
+		}
+
+		switch backend.State {
+		case Online:
+			online = append(online, backend)
+			break
+		case Offline:
+			offline = append(offline, backend)
+			break
+		default:
+			unknown = append(unknown, backend)
+			break
+		}
+	}
+
+	tiers := [][]*L4Backend{online, unknown, offline}
+	for _, draining := range []bool{false, true} {
+		for _, backends := range tiers {
+			backend := l.pickTier(backends, draining)
+			if backend != nil {
+				return backend
+			}
+		}
+	}
+
+	return nil
+}
+
+func (l *L4) setState(backend *L4Backend, state int) {
+	l.Lock.Lock()
+	if backend.State != state {
+		backend.State = state
+		backend.LastState = time.Now()
+	}
+	l.Lock.Unlock()
+}
+
+func (l *L4) checkPort(backend *L4Backend) int {
+	listeners := l.Balancer.Listeners
+	if len(listeners) > 0 && listeners[0].BackendPort != 0 {
+		return listeners[0].BackendPort
+	}
+
+	return backend.Port
+}
+
+func (l *L4) checkBackend(backend *L4Backend) {
+	result := runCheck(l.Balancer, "http",
+		utils.FormatHostPort(backend.Hostname, l.checkPort(backend)))
+
+	healthy, unhealthy := l.Checker.Record(l.Balancer, backend.Key, result)
+	if unhealthy {
+		l.setState(backend, Offline)
+	} else if healthy {
+		l.setState(backend, Online)
+	}
+}
+
+func (l *L4) Check() {
+	if !l.Checker.Ready(l.Balancer.GetCheckInterval()) {
+		return
+	}
+
+	l.Lock.Lock()
+	backends := l.Backends
+	l.Lock.Unlock()
+
+	for _, backend := range backends {
+		go l.checkBackend(backend)
+	}
+}
+
+func (l *L4) GetState(state *balancer.State) {
+	l.Lock.Lock()
+	defer l.Lock.Unlock()
+
+	for _, backend := range l.Backends {
+		switch backend.State {
+		case Online:
+			state.Online = append(state.Online, backend.Key)
+			break
+		case UnknownHigh:
+			state.UnknownHigh = append(state.UnknownHigh, backend.Key)
+			break
+		case UnknownMid:
+			state.UnknownMid = append(state.UnknownMid, backend.Key)
+			break
+		case UnknownLow:
+			state.UnknownLow = append(state.UnknownLow, backend.Key)
+			break
+		case Offline:
+			state.Offline = append(state.Offline, backend.Key)
+			break
+		}
+	}
+}
+
+func (l *L4) dial(network string, listener *balancer.Listener) (
+	conn net.Conn, backend *L4Backend) {
+
+	tried := set.NewSet()
+	for i := 0; i < l4Retries; i++ {
+		backend = l.pick(tried)
+		if backend == nil {
+			return
+		}
+		tried.Add(backend.Key)
+
+		port := backend.Port
+		if listener.BackendPort != 0 {
+			port = listener.BackendPort
+		}
+
+		start := time.Now()
+		c, err := net.DialTimeout(network, net.JoinHostPort(
+			backend.Hostname, strconv.Itoa(port)), l4DialTimeout)
+		l.Metrics.Record(backend.Key, time.Since(start), err != nil)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"balancer_id": l.Balancer.Id.Hex(),
+				"backend":     backend.Key,
+				"error":       err,
+			}).Warn("proxy: Failed to connect to balancer backend")
+			l.setState(backend, Offline)
+			continue
+		}
+
+		conn = c
+		return
+	}
+
+	backend = nil
+
+	return
+}
+
+func closeWrite(conn net.Conn) {
+	if c, ok := conn.(interface{ CloseWrite() error }); ok {
+		c.CloseWrite()
+	} else {
+		conn.Close()
+	}
+}
+
+func (l *L4) serveTcp(listener *balancer.Listener, ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if l.isClosed() {
+				return
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"balancer_id": l.Balancer.Id.Hex(),
+				"listener":    listener.Key(),
+				"error":       err,
+			}).Error("proxy: Balancer listener accept error")
+
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		go l.handleTcp(listener, conn)
+	}
+}
+
+func (l *L4) handleTcp(listener *balancer.Listener, conn net.Conn) {
+	atomic.AddInt32(l.Requests, 1)
+
+	l.trackConn(conn)
+	defer func() {
+		conn.Close()
+		l.untrackConn(conn)
+	}()
+
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		tlsConn.SetDeadline(time.Now().Add(l4DialTimeout))
+		err := tlsConn.Handshake()
+		if err != nil {
+			return
+		}
+		tlsConn.SetDeadline(time.Time{})
+	}
+
+	backConn, backend := l.dial("tcp", listener)
+	if backConn == nil {
+		return
+	}
+
+	atomic.AddInt32(backend.Conns, 1)
+	defer atomic.AddInt32(backend.Conns, -1)
+
+	l.trackConn(backConn)
+	defer func() {
+		backConn.Close()
+		l.untrackConn(backConn)
+	}()
+
+	if listener.ProxyProtocol != "" {
+		_, err := backConn.Write(proxyHeader(listener.ProxyProtocol,
+			conn.RemoteAddr(), conn.LocalAddr()))
+		if err != nil {
+			return
+		}
+	}
+
+	done := make(chan bool, 2)
+	go func() {
+		io.Copy(backConn, conn)
+		closeWrite(backConn)
+		done <- true
+	}()
+	go func() {
+		io.Copy(conn, backConn)
+		closeWrite(conn)
+		done <- true
+	}()
+
+	<-done
+	<-done
+}
+
+func (l *L4) serveUdp(listener *balancer.Listener, pc net.PacketConn) {
+	sessions := map[string]net.Conn{}
+	sessionsLock := sync.Mutex{}
+	buf := make([]byte, 65535)
+
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if l.isClosed() {
+				return
+			}
+
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		key := addr.String()
+
+		sessionsLock.Lock()
+		backConn := sessions[key]
+		sessionsLock.Unlock()
+
+		if backConn == nil {
+			atomic.AddInt32(l.Requests, 1)
+
+			var backend *L4Backend
+			backConn, backend = l.dial("udp", listener)
+			if backConn == nil {
+				continue
+			}
+
+			atomic.AddInt32(backend.Conns, 1)
+
+			l.trackConn(backConn)
+
+			sessionsLock.Lock()
+			sessions[key] = backConn
+			sessionsLock.Unlock()
+
+			go func(backConn net.Conn, backend *L4Backend, addr net.Addr) {
+				defer func() {
+					atomic.AddInt32(backend.Conns, -1)
+					backConn.Close()
+					l.untrackConn(backConn)
+
+					sessionsLock.Lock()
+					if sessions[addr.String()] == backConn {
+						delete(sessions, addr.String())
+					}
+					sessionsLock.Unlock()
+				}()
+
+				replyBuf := make([]byte, 65535)
+				for {
+					backConn.SetReadDeadline(time.Now().Add(l4UdpTimeout))
+					n, e := backConn.Read(replyBuf)
+					if e != nil {
+						return
+					}
+
+					_, e = pc.WriteTo(replyBuf[:n], addr)
+					if e != nil {
+						return
+					}
+				}
+			}(backConn, backend, addr)
+		}
+
+		payload := buf[:n]
+		if listener.ProxyProtocol == balancer.ProxyProtocolV2 {
+			header := proxyHeader(listener.ProxyProtocol,
+				addr, pc.LocalAddr())
+			payload = append(header, payload...)
+		}
+
+		backConn.Write(payload)
+	}
+}
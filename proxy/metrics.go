@@ -0,0 +1,114 @@
+package proxy
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-cloud/balancer"
+)
+
+type metrics struct {
+	lock    sync.Mutex
+	current map[string]*balancer.Metric
+	windows [5]map[string]*balancer.Metric
+}
+
+func (m *metrics) Record(key string, latency time.Duration, failed bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	metric := m.current[key]
+	if metric == nil {
+		metric = balancer.NewMetric(key)
+		m.current[key] = metric
+	}
+
+	metric.Add(int64(latency/time.Millisecond), failed)
+}
+
+func (m *metrics) Rotate() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	copy(m.windows[:], m.windows[1:])
+	m.windows[len(m.windows)-1] = m.current
+	m.current = map[string]*balancer.Metric{}
+}
+
+func (m *metrics) Prune(keys set.Set) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for key := range m.current {
+		if !keys.Contains(key) {
+			delete(m.current, key)
+		}
+	}
+
+	for _, window := range m.windows {
+		for key := range window {
+			if !keys.Contains(key) {
+				delete(window, key)
+			}
+		}
+	}
+}
+
+func (m *metrics) Metrics() (mets []*balancer.Metric) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	merged := map[string]*balancer.Metric{}
+	for _, window := range m.windows {
+		for key, metric := range window {
+			met := merged[key]
+			if met == nil {
+				met = balancer.NewMetric(key)
+				merged[key] = met
+			}
+			met.Merge(metric)
+		}
+	}
+
+	mets = []*balancer.Metric{}
+	for _, met := range merged {
+		mets = append(mets, met)
+	}
+
+	sort.Slice(mets, func(i, j int) bool {
+		return mets[i].Key < mets[j].Key
+	})
+
+	return
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		current: map[string]*balancer.Metric{},
+	}
+}
+
+func mergeMetrics(mets []*balancer.Metric,
+	others []*balancer.Metric) []*balancer.Metric {
+
+	for _, other := range others {
+		merged := false
+		for _, met := range mets {
+			if met.Key == other.Key {
+				met.Merge(other)
+				merged = true
+				break
+			}
+		}
+
+		if !merged {
+			met := balancer.NewMetric(other.Key)
+			met.Merge(other)
+			mets = append(mets, met)
+		}
+	}
+
+	return mets
+}
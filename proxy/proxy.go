@@ -2,21 +2,25 @@ package proxy
 
 import (
 	"bytes"
+	"crypto/x509"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/balancer"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/pki"
 	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
 type Proxy struct {
 	Domains map[string]*Domain
+	L4s     map[primitive.ObjectID]*L4
 	lock    sync.Mutex
 }
 
@@ -43,6 +47,7 @@ func (p *Proxy) Update(db *database.Database, balncs []*balancer.Balancer) (
 	domains := map[string]*Domain{}
 	domainsName := set.NewSet()
 	remDomains := []*Domain{}
+	l4s := map[primitive.ObjectID]*L4{}
 	states := []*balancerState{}
 
 	proxyProto := node.Self.Protocol
@@ -67,6 +72,41 @@ func (p *Proxy) Update(db *database.Database, balncs []*balancer.Balancer) (
 			UnknownMid:  []string{},
 			UnknownLow:  []string{},
 			Offline:     []string{},
+			Checks:      []*balancer.Check{},
+			Metrics:     []*balancer.Metric{},
+		}
+		checksRecorded := set.NewSet()
+
+		if balnc.Type == balancer.L4 {
+			l4s[balnc.Id] = p.updateL4(db, balnc, state)
+
+			states = append(states, &balancerState{
+				Balancer: balnc,
+				State:    state,
+			})
+			continue
+		}
+
+		var backendPool *x509.CertPool
+		var backendNames map[string]string
+		if balnc.BackendMtls && pki.Enabled() {
+			pool, e := pki.Pool(db, pki.GuestCa)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"balancer": balnc.Name,
+					"error":    e,
+				}).Error("proxy: Failed to load internal authority")
+			} else {
+				backendPool = pool
+			}
+
+			backendNames, e = getBackendNames(db, balnc)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"balancer": balnc.Name,
+					"error":    e,
+				}).Error("proxy: Failed to load backend instances")
+			}
 		}
 
 		for _, domain := range balnc.Domains {
@@ -85,13 +125,15 @@ func (p *Proxy) Update(db *database.Database, balncs []*balancer.Balancer) (
 			domainsName.Add(domain.Domain)
 
 			proxyDomain := &Domain{
-				SkipVerify: settings.Router.SkipVerify,
-				ProxyProto: proxyProto,
-				ProxyPort:  proxyPort,
-				Balancer:   balnc,
-				Domain:     domain,
-				Requests:   new(int32),
-				Retries:    new(int32),
+				SkipVerify:   settings.Router.SkipVerify,
+				ProxyProto:   proxyProto,
+				ProxyPort:    proxyPort,
+				Balancer:     balnc,
+				Domain:       domain,
+				BackendPool:  backendPool,
+				BackendNames: backendNames,
+				Requests:     new(int32),
+				Retries:      new(int32),
 			}
 			proxyDomain.CalculateHash()
 
@@ -101,6 +143,17 @@ func (p *Proxy) Update(db *database.Database, balncs []*balancer.Balancer) (
 				state.Retries += curDomain.RetriesTotal
 				state.WebSockets += curDomain.WebSocketConns.Len()
 
+				state.Metrics = mergeMetrics(state.Metrics,
+					curDomain.Metrics.Metrics())
+				curDomain.Rejections.GetState(state)
+
+				for _, chk := range curDomain.Checker.Checks() {
+					if !checksRecorded.Contains(chk.Key) {
+						checksRecorded.Add(chk.Key)
+						state.Checks = append(state.Checks, chk)
+					}
+				}
+
 				curDomain.Lock.Lock()
 				for _, hand := range curDomain.OnlineWebFirst {
 					onlineWeb.Add(hand.Key)
@@ -129,6 +182,9 @@ func (p *Proxy) Update(db *database.Database, balncs []*balancer.Balancer) (
 					proxyDomain.Retries = curDomain.Retries
 					proxyDomain.RetriesPrev = curDomain.RetriesPrev
 					proxyDomain.RetriesTotal = curDomain.RetriesTotal
+					proxyDomain.Checker = curDomain.Checker
+					proxyDomain.Metrics = curDomain.Metrics
+					proxyDomain.Rejections = curDomain.Rejections
 					curDomain.Lock.Unlock()
 
 					remDomains = append(remDomains, curDomain)
@@ -195,7 +251,14 @@ func (p *Proxy) Update(db *database.Database, balncs []*balancer.Balancer) (
 		}
 	}
 
+	for balncId, l4 := range p.L4s {
+		if l4s[balncId] == nil {
+			l4.Close()
+		}
+	}
+
 	p.Domains = domains
+	p.L4s = l4s
 	p.lock.Unlock()
 
 	for _, domain := range remDomains {
@@ -220,6 +283,50 @@ func (p *Proxy) Update(db *database.Database, balncs []*balancer.Balancer) (
 	return
 }
 
+func (p *Proxy) updateL4(db *database.Database, balnc *balancer.Balancer,
+	state *balancer.State) (l4 *L4) {
+
+	l4 = &L4{
+		Balancer: balnc,
+		Requests: new(int32),
+	}
+	l4.CalculateHash()
+
+	curL4 := p.L4s[balnc.Id]
+	if curL4 != nil {
+		state.Requests += curL4.RequestsTotal
+		state.Checks = curL4.Checker.Checks()
+		state.Metrics = curL4.Metrics.Metrics()
+		curL4.GetState(state)
+
+		if bytes.Equal(curL4.Hash, l4.Hash) {
+			curL4.Balancer = balnc
+			err := curL4.UpdateCertificates(db)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"balancer_id": balnc.Id.Hex(),
+					"error":       err,
+				}).Error("proxy: Failed to update balancer certificates")
+			}
+
+			l4 = curL4
+			return
+		}
+
+		l4.Requests = curL4.Requests
+		l4.RequestsPrev = curL4.RequestsPrev
+		l4.RequestsTotal = curL4.RequestsTotal
+		l4.Checker = curL4.Checker
+		l4.Metrics = curL4.Metrics
+
+		curL4.Close()
+	}
+
+	l4.Init(db)
+
+	return
+}
+
 func (p *Proxy) syncCount() {
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -253,6 +360,28 @@ func (p *Proxy) syncCount() {
 		retTotal += int(*ret)
 		dom.RetriesPrev = retPrev
 		dom.RetriesTotal = retTotal
+
+		dom.Metrics.Rotate()
+		dom.Rejections.Rotate()
+	}
+
+	l4s := p.L4s
+	for _, l4 := range l4s {
+		req := l4.Requests
+		l4.Requests = new(int32)
+		reqPrev := l4.RequestsPrev
+		reqTotal := reqPrev[0] + reqPrev[1] + reqPrev[2] +
+			reqPrev[3] + reqPrev[4]
+		reqPrev[0] = reqPrev[1]
+		reqPrev[1] = reqPrev[2]
+		reqPrev[2] = reqPrev[3]
+		reqPrev[3] = reqPrev[4]
+		reqPrev[4] = int(*req)
+		reqTotal += int(*req)
+		l4.RequestsPrev = reqPrev
+		l4.RequestsTotal = reqTotal
+
+		l4.Metrics.Rotate()
 	}
 }
 
@@ -271,17 +400,25 @@ func (p *Proxy) healthCheck() {
 	for _, dom := range domains {
 		dom.Check()
 	}
+
+	l4s := p.L4s
+	for _, l4 := range l4s {
+		l4.Check()
+	}
 }
 
 func (p *Proxy) runHealthCheck() {
 	for {
-		time.Sleep(5 * time.Second)
+		time.Sleep(1 * time.Second)
 		p.healthCheck()
 	}
 }
 
 func (p *Proxy) Init() {
 	p.Domains = map[string]*Domain{}
+	p.L4s = map[primitive.ObjectID]*L4{}
+	initAccessLog()
+	go runGeoCache()
 	go p.runCounter()
 	go p.runHealthCheck()
 }
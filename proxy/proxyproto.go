@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/pritunl/pritunl-cloud/balancer"
+)
+
+var proxyV2Sig = []byte{
+	0x0d, 0x0a, 0x0d, 0x0a, 0x00, 0x0d, 0x0a, 0x51, 0x55, 0x49, 0x54, 0x0a,
+}
+
+func splitAddr(addr net.Addr) (ip net.IP, port int) {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+		port = a.Port
+	case *net.UDPAddr:
+		ip = a.IP
+		port = a.Port
+	}
+	return
+}
+
+func proxyHeaderV1(src, dst net.Addr) []byte {
+	srcIp, srcPort := splitAddr(src)
+	dstIp, dstPort := splitAddr(dst)
+
+	if srcIp == nil || dstIp == nil {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+
+	proto := "TCP6"
+	if srcIp.To4() != nil && dstIp.To4() != nil {
+		proto = "TCP4"
+		srcIp = srcIp.To4()
+		dstIp = dstIp.To4()
+	} else {
+		srcIp = srcIp.To16()
+		dstIp = dstIp.To16()
+	}
+
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n",
+		proto, srcIp.String(), dstIp.String(), srcPort, dstPort))
+}
+
+func proxyHeaderV2(src, dst net.Addr) []byte {
+	srcIp, srcPort := splitAddr(src)
+	dstIp, dstPort := splitAddr(dst)
+
+	buf := &bytes.Buffer{}
+	buf.Write(proxyV2Sig)
+
+	if srcIp == nil || dstIp == nil {
+		buf.WriteByte(0x20)
+		buf.WriteByte(0x00)
+		binary.Write(buf, binary.BigEndian, uint16(0))
+		return buf.Bytes()
+	}
+
+	buf.WriteByte(0x21)
+
+	var fam byte
+	if srcIp.To4() != nil && dstIp.To4() != nil {
+		fam = 0x10
+		srcIp = srcIp.To4()
+		dstIp = dstIp.To4()
+	} else {
+		fam = 0x20
+		srcIp = srcIp.To16()
+		dstIp = dstIp.To16()
+	}
+
+	if _, ok := src.(*net.UDPAddr); ok {
+		fam |= 0x02
+	} else {
+		fam |= 0x01
+	}
+	buf.WriteByte(fam)
+
+	binary.Write(buf, binary.BigEndian, uint16(len(srcIp)*2+4))
+	buf.Write(srcIp)
+	buf.Write(dstIp)
+	binary.Write(buf, binary.BigEndian, uint16(srcPort))
+	binary.Write(buf, binary.BigEndian, uint16(dstPort))
+
+	return buf.Bytes()
+}
+
+func proxyHeader(version string, src, dst net.Addr) []byte {
+	switch version {
+	case balancer.ProxyProtocolV1:
+		return proxyHeaderV1(src, dst)
+	case balancer.ProxyProtocolV2:
+		return proxyHeaderV2(src, dst)
+	}
+	return nil
+}
@@ -1,7 +1,10 @@
 package proxy
 
 import (
+	"crypto/md5"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"net"
@@ -10,15 +13,18 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/dropbox/godropbox/errors"
 	"github.com/gorilla/websocket"
 	"github.com/pritunl/pritunl-cloud/balancer"
+	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/logger"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/pki"
 	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
@@ -41,9 +47,30 @@ type Handler struct {
 	WebSockets         bool
 	WebSocketsUpgrader *websocket.Upgrader
 	ErrorHandler       ErrorHandler
+	Weight             int
+	Draining           bool
+	Pool               string
+	StickyId           string
+	Conns              *int32
 	*httputil.ReverseProxy
 }
 
+func (h *Handler) GetKey() string {
+	return h.Key
+}
+
+func (h *Handler) GetWeight() int {
+	return h.Weight
+}
+
+func (h *Handler) GetConns() int32 {
+	return atomic.LoadInt32(h.Conns)
+}
+
+func (h *Handler) IsDraining() bool {
+	return h.Draining
+}
+
 func (h *Handler) ServeWS(rw http.ResponseWriter, r *http.Request) {
 	header := utils.CloneHeader(r.Header)
 	u := &url.URL{}
@@ -134,6 +161,15 @@ func (h *Handler) ServeWS(rw http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) Serve(rw http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(h.Conns, 1)
+	defer atomic.AddInt32(h.Conns, -1)
+
+	info := getRequestInfo(r)
+	if info != nil {
+		info.Upstream = h.Key
+		info.UpstreamStart = time.Now()
+	}
+
 	if h.WebSockets && strings.ToLower(
 		r.Header.Get("Upgrade")) == "websocket" {
 
@@ -198,7 +234,35 @@ func NewHandler(index, state int, proxyProto string, proxyPort int,
 		MinVersion: tls.VersionTLS12,
 		MaxVersion: tls.VersionTLS13,
 	}
-	if domain.SkipVerify || net.ParseIP(backend.Hostname) != nil {
+	if domain.BackendPool != nil {
+		balncId := domain.Balancer.Id.Hex()
+		backendPool := domain.BackendPool
+		backendName := domain.BackendNames[backend.Hostname]
+
+		// Internal authority backends ignore skip verify and are always
+		// verified against the name of the instance holding the backend
+		// address, an address match would accept any guest certificate
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte,
+			_ [][]*x509.Certificate) error {
+
+			return verifyBackendCertificate(
+				backendPool, backendName, rawCerts)
+		}
+		tlsConfig.GetClientCertificate = func(
+			info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+
+			db := database.GetDatabase()
+			defer db.Close()
+
+			return pki.GetCertificate(db, &pki.Request{
+				Authority:  pki.ServiceCa,
+				CommonName: "balancer-" + balncId,
+				Client:     true,
+				Ttl:        pki.ServiceTtl,
+			})
+		}
+	} else if domain.SkipVerify || net.ParseIP(backend.Hostname) != nil {
 		tlsConfig.InsecureSkipVerify = true
 	}
 
@@ -220,8 +284,16 @@ func NewHandler(index, state int, proxyProto string, proxyPort int,
 		},
 	}
 
+	weight := backend.Weight
+	if weight == 0 {
+		weight = balancer.DefaultWeight
+	}
+
+	key := fmt.Sprintf("%s:%d", backend.Hostname, backend.Port)
+	stickyHash := md5.Sum([]byte(domain.Balancer.Id.Hex() + key))
+
 	hand = &Handler{
-		Key:            fmt.Sprintf("%s:%d", backend.Hostname, backend.Port),
+		Key:            key,
 		Index:          index,
 		State:          state,
 		Domain:         domain,
@@ -235,6 +307,11 @@ func NewHandler(index, state int, proxyProto string, proxyPort int,
 		WebSockets:     domain.Balancer.WebSockets,
 		TlsConfig:      tlsConfig,
 		ErrorHandler:   errHandler,
+		Weight:         weight,
+		Draining:       backend.Draining,
+		Pool:           backend.Pool,
+		StickyId:       hex.EncodeToString(stickyHash[:8]),
+		Conns:          new(int32),
 		ReverseProxy: &httputil.ReverseProxy{
 			Director: func(req *http.Request) {
 				req.Header.Set("X-Forwarded-Host", req.Host)
@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/pritunl/pritunl-cloud/balancer"
+)
+
+type rule struct {
+	*balancer.Rule
+	regex *regexp.Regexp
+}
+
+func (r *rule) Matches(req *http.Request) bool {
+	switch r.Match {
+	case balancer.MatchPathPrefix:
+		return strings.HasPrefix(req.URL.Path, r.Value)
+	case balancer.MatchPathRegex:
+		return r.regex != nil && r.regex.MatchString(req.URL.Path)
+	case balancer.MatchHeader:
+		vals, ok := req.Header[r.Key]
+		if !ok {
+			return false
+		}
+		if r.Value == "" {
+			return true
+		}
+		for _, val := range vals {
+			if val == r.Value {
+				return true
+			}
+		}
+		return false
+	case balancer.MatchMethod:
+		return req.Method == r.Value
+	}
+
+	return false
+}
+
+func (r *rule) rewrite(req *http.Request) {
+	if r.RewritePath == "" {
+		return
+	}
+
+	pth := req.URL.Path
+	switch r.Match {
+	case balancer.MatchPathPrefix:
+		pth = strings.TrimPrefix(pth, r.Value)
+		if pth != "" && !strings.HasPrefix(pth, "/") &&
+			!strings.HasSuffix(r.RewritePath, "/") {
+
+			pth = "/" + pth
+		}
+		pth = r.RewritePath + pth
+		break
+	case balancer.MatchPathRegex:
+		pth = r.regex.ReplaceAllString(pth, r.RewritePath)
+		if !strings.HasPrefix(pth, "/") {
+			pth = "/" + pth
+		}
+		break
+	}
+
+	req.URL.Path = pth
+	req.URL.RawPath = ""
+}
+
+func (r *rule) Apply(rw http.ResponseWriter, req *http.Request) (
+	pool string, handled bool) {
+
+	switch r.Action {
+	case balancer.ActionRedirectHttps:
+		host := req.Host
+		hostname, _, err := net.SplitHostPort(host)
+		if err == nil {
+			host = hostname
+		}
+
+		http.Redirect(rw, req, "https://"+host+req.URL.RequestURI(),
+			r.Status)
+		handled = true
+		return
+	case balancer.ActionFixed:
+		rw.Header().Set("Content-Type", r.ContentType)
+		for _, header := range r.AddHeaders {
+			rw.Header().Set(header.Key, header.Value)
+		}
+		rw.WriteHeader(r.Status)
+		if r.Body != "" && req.Method != http.MethodHead {
+			rw.Write([]byte(r.Body))
+		}
+		handled = true
+		return
+	}
+
+	r.rewrite(req)
+
+	for _, header := range r.RemoveHeaders {
+		req.Header.Del(header)
+	}
+	for _, header := range r.AddHeaders {
+		req.Header.Set(header.Key, header.Value)
+	}
+
+	pool = r.Pool
+	return
+}
+
+func compileRules(balnc *balancer.Balancer) (rules []*rule) {
+	rules = []*rule{}
+
+	for _, rle := range balnc.Rules {
+		rl := &rule{
+			Rule: rle,
+		}
+
+		if rle.Match == balancer.MatchPathRegex {
+			regex, err := regexp.Compile(rle.Value)
+			if err != nil {
+				continue
+			}
+			rl.regex = regex
+		}
+
+		rules = append(rules, rl)
+	}
+
+	return
+}
+
+func getPool(r *http.Request) string {
+	info := getRequestInfo(r)
+	if info == nil {
+		return ""
+	}
+	return info.Pool
+}
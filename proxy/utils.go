@@ -1,10 +1,19 @@
 package proxy
 
 import (
+	"crypto/x509"
+	"net"
 	"net/http"
 
 	"github.com/sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/pritunl-cloud/balancer"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/pki"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
@@ -16,3 +25,113 @@ func WriteError(w http.ResponseWriter, r *http.Request, code int, err error) {
 		"error":  err,
 	}).Error("proxy: Serve error")
 }
+
+// getBackendNames maps backend addresses to the guest certificate name of
+// the organization instance holding that address
+func getBackendNames(db *database.Database, balnc *balancer.Balancer) (
+	names map[string]string, err error) {
+
+	names = map[string]string{}
+
+	addrs := []string{}
+	for _, backend := range balnc.Backends {
+		addr := net.ParseIP(backend.Hostname)
+		if addr != nil {
+			addrs = append(addrs, addr.String())
+		}
+	}
+
+	if len(addrs) == 0 {
+		return
+	}
+
+	insts, err := instance.GetAll(db, &bson.M{
+		"organization": balnc.Organization,
+		"$or": []*bson.M{
+			{"private_ips": &bson.M{"$in": addrs}},
+			{"private_ips6": &bson.M{"$in": addrs}},
+			{"public_ips": &bson.M{"$in": addrs}},
+			{"public_ips6": &bson.M{"$in": addrs}},
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	addrNames := map[string]string{}
+	for _, inst := range insts {
+		name := inst.Id.Hex() + "." + pki.GuestDomain
+
+		instAddrs := []string{}
+		instAddrs = append(instAddrs, inst.PrivateIps...)
+		instAddrs = append(instAddrs, inst.PrivateIps6...)
+		instAddrs = append(instAddrs, inst.PublicIps...)
+		instAddrs = append(instAddrs, inst.PublicIps6...)
+
+		for _, instAddr := range instAddrs {
+			addr := net.ParseIP(instAddr)
+			if addr != nil {
+				addrNames[addr.String()] = name
+			}
+		}
+	}
+
+	for _, backend := range balnc.Backends {
+		addr := net.ParseIP(backend.Hostname)
+		if addr != nil && addrNames[addr.String()] != "" {
+			names[backend.Hostname] = addrNames[addr.String()]
+		}
+	}
+
+	return
+}
+
+func verifyBackendCertificate(pool *x509.CertPool, name string,
+	rawCerts [][]byte) (err error) {
+
+	if name == "" {
+		err = &errortypes.VerificationError{
+			errors.New("proxy: Backend address not assigned to instance"),
+		}
+		return
+	}
+
+	if len(rawCerts) == 0 {
+		err = &errortypes.VerificationError{
+			errors.New("proxy: Backend certificate missing"),
+		}
+		return
+	}
+
+	certs := []*x509.Certificate{}
+	for _, rawCert := range rawCerts {
+		cert, e := x509.ParseCertificate(rawCert)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "proxy: Failed to parse backend certificate"),
+			}
+			return
+		}
+		certs = append(certs, cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err = certs[0].Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		DNSName:       name,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err != nil {
+		err = &errortypes.VerificationError{
+			errors.Wrap(err, "proxy: Backend certificate invalid"),
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestCert(t *testing.T, templ, parent *x509.Certificate,
+	parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parent == nil {
+		parent = templ
+		parentKey = key
+	}
+
+	der, err := x509.CreateCertificate(
+		rand.Reader, templ, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+func TestVerifyBackendCertificate(t *testing.T) {
+	ca, caKey := newTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "guest"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+
+	name := "5f0a1b2c3d4e5f6a7b8c9d0e.guest.pritunl-cloud.internal"
+	leaf, _ := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.2")},
+	}, ca, caKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	rawCerts := [][]byte{leaf.Raw}
+
+	err := verifyBackendCertificate(pool, name, rawCerts)
+	if err != nil {
+		t.Errorf("instance certificate rejected: %s", err)
+	}
+
+	err = verifyBackendCertificate(pool,
+		"6f0a1b2c3d4e5f6a7b8c9d0e.guest.pritunl-cloud.internal", rawCerts)
+	if err == nil {
+		t.Errorf("certificate of another instance accepted")
+	}
+
+	err = verifyBackendCertificate(pool, "", rawCerts)
+	if err == nil {
+		t.Errorf("backend without instance accepted")
+	}
+
+	err = verifyBackendCertificate(x509.NewCertPool(), name, rawCerts)
+	if err == nil {
+		t.Errorf("certificate from unknown authority accepted")
+	}
+
+	err = verifyBackendCertificate(pool, name, nil)
+	if err == nil {
+		t.Errorf("missing certificate accepted")
+	}
+}
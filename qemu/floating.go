@@ -0,0 +1,196 @@
+package qemu
+
+import (
+	"strings"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/iptables"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+func getFloatingRules(namespace string) (rules [][]string, err error) {
+	iptables.Lock()
+	output, err := utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"iptables", "-t", "nat", "-S",
+	)
+	iptables.Unlock()
+	if err != nil {
+		return
+	}
+
+	rules = [][]string{}
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, "pritunl_cloud_floating") {
+			continue
+		}
+
+		rule := strings.Fields(line)
+		if len(rule) < 3 {
+			continue
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return
+}
+
+func GetFloatingIp(instId primitive.ObjectID) (floatAddr string, err error) {
+	namespace := vm.GetNamespace(instId, 0)
+
+	rules, err := getFloatingRules(namespace)
+	if err != nil {
+		return
+	}
+
+	for _, rule := range rules {
+		if rule[1] != "PREROUTING" {
+			continue
+		}
+
+		for i, item := range rule {
+			if item == "-d" && len(rule) > i+1 {
+				floatAddr = strings.Split(rule[i+1], "/")[0]
+				return
+			}
+		}
+	}
+
+	return
+}
+
+// GetExternalBridge returns the host bridge the instance external interface
+// is attached to, a floating IP must be on a block attached to this bridge.
+func GetExternalBridge(instId primitive.ObjectID) (bridge string, err error) {
+	bridge, err = utils.GetInterfaceUpper(vm.GetIfaceVirt(instId, 0))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func SetFloatingIp(instId primitive.ObjectID, addr, floatAddr string) (
+	err error) {
+
+	namespace := vm.GetNamespace(instId, 0)
+	ifaceExternal := vm.GetIfaceExternal(instId, 0)
+
+	err = ClearFloatingIp(instId)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "addr",
+		"add", floatAddr+"/32",
+		"dev", ifaceExternal,
+	)
+	if err != nil {
+		return
+	}
+
+	iptables.Lock()
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"iptables",
+		"-t", "nat",
+		"-I", "PREROUTING", "1",
+		"-d", floatAddr+"/32",
+		"-m", "comment",
+		"--comment", "pritunl_cloud_floating",
+		"-j", "DNAT",
+		"--to-destination", addr,
+	)
+	iptables.Unlock()
+	if err != nil {
+		return
+	}
+
+	iptables.Lock()
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"iptables",
+		"-t", "nat",
+		"-I", "POSTROUTING", "1",
+		"-s", addr+"/32",
+		"-o", ifaceExternal,
+		"-m", "comment",
+		"--comment", "pritunl_cloud_floating",
+		"-j", "SNAT",
+		"--to-source", floatAddr,
+	)
+	iptables.Unlock()
+	if err != nil {
+		return
+	}
+
+	utils.ExecCombinedOutput(
+		"",
+		"ip", "netns", "exec", namespace,
+		"arping", "-U", "-c", "2",
+		"-I", ifaceExternal, floatAddr,
+	)
+
+	return
+}
+
+func ClearFloatingIp(instId primitive.ObjectID) (err error) {
+	namespace := vm.GetNamespace(instId, 0)
+	ifaceExternal := vm.GetIfaceExternal(instId, 0)
+
+	floatAddr, err := GetFloatingIp(instId)
+	if err != nil {
+		return
+	}
+
+	rules, err := getFloatingRules(namespace)
+	if err != nil {
+		return
+	}
+
+	for _, rule := range rules {
+		cmd := []string{
+			"ip", "netns", "exec", namespace,
+			"iptables", "-t", "nat", "-D",
+		}
+		cmd = append(cmd, rule[1:]...)
+
+		iptables.Lock()
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{
+				"matching rule exist",
+				"match by that name",
+			},
+			cmd[0], cmd[1:]...,
+		)
+		iptables.Unlock()
+		if err != nil {
+			return
+		}
+	}
+
+	if floatAddr != "" {
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{
+				"Cannot assign requested address",
+			},
+			"ip", "netns", "exec", namespace,
+			"ip", "addr",
+			"del", floatAddr+"/32",
+			"dev", ifaceExternal,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,202 @@
+package qemu
+
+import (
+	"strings"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/gateway"
+	"github.com/pritunl/pritunl-cloud/iptables"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+func GetNatGateway(instId primitive.ObjectID) (gatewayIp string, err error) {
+	namespace := vm.GetNamespace(instId, 0)
+
+	output, err := utils.ExecCombinedOutputLogged(
+		[]string{
+			"does not exist",
+		},
+		"ip", "netns", "exec", namespace,
+		"ip", "route",
+		"show", "table", gateway.RouteTable,
+	)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != "default" || fields[1] != "via" {
+			continue
+		}
+
+		gatewayIp = fields[2]
+		break
+	}
+
+	return
+}
+
+func natGatewayMangle(namespace, ifaceExternal, action string) (
+	err error) {
+
+	iptables.Lock()
+	defer iptables.Unlock()
+
+	ignore := []string{}
+	if action == "-D" {
+		ignore = []string{
+			"matching rule exist",
+			"match by that name",
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		ignore,
+		"ip", "netns", "exec", namespace,
+		"iptables",
+		"-t", "mangle",
+		action, "PREROUTING",
+		"-i", ifaceExternal,
+		"-m", "comment",
+		"--comment", "pritunl_cloud_gateway",
+		"-j", "CONNMARK",
+		"--set-mark", gateway.RouteMark,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		ignore,
+		"ip", "netns", "exec", namespace,
+		"iptables",
+		"-t", "mangle",
+		action, "PREROUTING",
+		"-i", "br0",
+		"-m", "comment",
+		"--comment", "pritunl_cloud_gateway",
+		"-j", "CONNMARK",
+		"--restore-mark",
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func SetNatGateway(instId primitive.ObjectID, addr, gatewayIp string) (
+	err error) {
+
+	namespace := vm.GetNamespace(instId, 0)
+	ifaceExternal := vm.GetIfaceExternal(instId, 0)
+
+	err = ClearNatGateway(instId)
+	if err != nil {
+		return
+	}
+
+	err = natGatewayMangle(namespace, ifaceExternal, "-A")
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "rule", "add",
+		"from", addr,
+		"fwmark", gateway.RouteMark,
+		"lookup", "main",
+		"priority", gateway.RulePriority,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "rule", "add",
+		"from", addr,
+		"lookup", "main",
+		"suppress_prefixlength", "0",
+		"priority", gateway.RulePriority2,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "rule", "add",
+		"from", addr,
+		"lookup", gateway.RouteTable,
+		"priority", gateway.RulePriority3,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "route", "replace",
+		"default",
+		"via", gatewayIp,
+		"dev", "br0",
+		"onlink",
+		"table", gateway.RouteTable,
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func ClearNatGateway(instId primitive.ObjectID) (err error) {
+	namespace := vm.GetNamespace(instId, 0)
+	ifaceExternal := vm.GetIfaceExternal(instId, 0)
+
+	for _, priority := range []string{
+		gateway.RulePriority,
+		gateway.RulePriority2,
+		gateway.RulePriority3,
+	} {
+		for i := 0; i < 4; i++ {
+			_, e := utils.ExecCombinedOutput(
+				"",
+				"ip", "netns", "exec", namespace,
+				"ip", "rule", "del",
+				"priority", priority,
+			)
+			if e != nil {
+				break
+			}
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{
+			"does not exist",
+			"FIB table does not exist",
+		},
+		"ip", "netns", "exec", namespace,
+		"ip", "route", "flush",
+		"table", gateway.RouteTable,
+	)
+	if err != nil {
+		return
+	}
+
+	err = natGatewayMangle(namespace, ifaceExternal, "-D")
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,511 @@
+package qemu
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/block"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/interfaces"
+	"github.com/pritunl/pritunl-cloud/iproute"
+	"github.com/pritunl/pritunl-cloud/iptables"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/vpc"
+)
+
+type interfaceConf struct {
+	Index             int
+	Vxlan             bool
+	UpdateMtuInternal string
+	UpdateMtuExternal string
+	UpdateMtuInstance string
+}
+
+func networkConfInterface(db *database.Database, virt *vm.VirtualMachine,
+	conf *interfaceConf) (addr, addr6, pubAddr string, err error) {
+
+	n := conf.Index
+	adapter := virt.NetworkAdapters[n]
+	iface := vm.GetIface(virt.Id, n)
+	ifaceInternalVirt := vm.GetAdapterIfaceVirtInternal(virt.Id, n)
+	ifaceExternalVirt := vm.GetAdapterIfaceVirtExternal(virt.Id, n)
+	ifaceInternal := vm.GetIfaceInternal(virt.Id, n)
+	ifaceExternal := vm.GetAdapterIfaceExternal(virt.Id, n)
+	ifaceVlan := vm.GetIfaceVlan(virt.Id, n)
+	namespace := vm.GetNamespace(virt.Id, n)
+	pidPath := fmt.Sprintf("/var/run/dhclient-%s.pid", ifaceExternal)
+	leasePath := paths.GetInterfaceLeasePath(virt.Id, n)
+
+	nodeNetworkMode := node.Self.NetworkMode
+	if nodeNetworkMode == "" {
+		nodeNetworkMode = node.Dhcp
+	}
+
+	externalNetwork := adapter.PublicAddress &&
+		(nodeNetworkMode == node.Static || nodeNetworkMode == node.Dhcp)
+
+	vc, err := vpc.Get(db, adapter.Vpc)
+	if err != nil {
+		return
+	}
+
+	vcNet, err := vc.GetNetwork()
+	if err != nil {
+		return
+	}
+
+	instAddr, gatewayAddr, err := vc.GetIp(db, adapter.Subnet, virt.Id)
+	if err != nil {
+		return
+	}
+
+	instAddr6 := vc.GetIp6(instAddr)
+	gatewayAddr6 := vc.GetIp6(gatewayAddr)
+
+	cidr, _ := vcNet.Mask.Size()
+	gatewayCidr := fmt.Sprintf("%s/%d", gatewayAddr.String(), cidr)
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns",
+		"add", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	networkStopInterfaceDhClient(virt, n)
+
+	for _, virtIface := range []string{ifaceInternalVirt, ifaceExternalVirt} {
+		_, _ = utils.ExecCombinedOutput(
+			"", "ip", "link", "set", virtIface, "down")
+		_, _ = utils.ExecCombinedOutput(
+			"", "ip", "link", "del", virtIface)
+		interfaces.RemoveVirtIface(virtIface)
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link",
+		"add", ifaceInternalVirt,
+		"type", "veth",
+		"peer", "name", ifaceInternal,
+		"addr", vm.GetMacAddrInternal(virt.Id, vc.Id),
+	)
+	if err != nil {
+		return
+	}
+
+	if externalNetwork {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "link",
+			"add", ifaceExternalVirt,
+			"type", "veth",
+			"peer", "name", ifaceExternal,
+			"addr", vm.GetMacAddrExternal(virt.Id, vc.Id),
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	if conf.UpdateMtuInternal != "" {
+		for _, mtuIface := range []string{ifaceInternalVirt, ifaceInternal} {
+			_, err = utils.ExecCombinedOutputLogged(
+				nil,
+				"ip", "link",
+				"set", "dev", mtuIface,
+				"mtu", conf.UpdateMtuInternal,
+			)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	if externalNetwork && conf.UpdateMtuExternal != "" {
+		for _, mtuIface := range []string{ifaceExternalVirt, ifaceExternal} {
+			_, err = utils.ExecCombinedOutputLogged(
+				nil,
+				"ip", "link",
+				"set", "dev", mtuIface,
+				"mtu", conf.UpdateMtuExternal,
+			)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link",
+		"set", "dev", ifaceInternalVirt, "up",
+	)
+	if err != nil {
+		return
+	}
+
+	internalIface := interfaces.GetInternal(ifaceInternalVirt, conf.Vxlan)
+	if internalIface == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("qemu: Failed to get internal interface"),
+		}
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link", "set",
+		ifaceInternalVirt, "master", internalIface,
+	)
+	if err != nil {
+		return
+	}
+
+	var blck *block.Block
+	var staticAddr net.IP
+	var externalIface string
+
+	if externalNetwork {
+		if nodeNetworkMode == node.Static {
+			blck, staticAddr, externalIface, err = node.Self.GetStaticAddr(
+				db, adapter.Interface)
+			if err != nil {
+				return
+			}
+		} else {
+			externalIface = interfaces.GetExternal(ifaceExternalVirt)
+		}
+		if externalIface == "" {
+			err = &errortypes.NotFoundError{
+				errors.New("qemu: Failed to get external interface"),
+			}
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "link",
+			"set", "dev", ifaceExternalVirt, "up",
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "link", "set",
+			ifaceExternalVirt, "master", externalIface,
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			[]string{"File exists"},
+			"ip", "link",
+			"set", "dev", ifaceExternal,
+			"netns", namespace,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "link",
+		"set", "dev", ifaceInternal,
+		"netns", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "link",
+		"set", "dev", iface,
+		"netns", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	for _, sysctl := range []string{
+		"net.ipv6.conf.all.accept_ra=0",
+		"net.ipv6.conf.default.accept_ra=0",
+		"net.ipv4.ip_forward=1",
+		"net.ipv6.conf.all.forwarding=1",
+	} {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"sysctl", "-w", sysctl,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	if externalNetwork {
+		iptables.Lock()
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"iptables",
+			"-I", "FORWARD", "1",
+			"!", "-d", instAddr.String()+"/32",
+			"-i", ifaceExternal,
+			"-j", "DROP",
+		)
+		iptables.Unlock()
+		if err != nil {
+			return
+		}
+	}
+
+	upIfaces := []string{"lo", ifaceInternal}
+	if externalNetwork {
+		upIfaces = append(upIfaces, ifaceExternal)
+	}
+	for _, upIface := range upIfaces {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "link",
+			"set", "dev", upIface, "up",
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	if conf.UpdateMtuInstance != "" {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "link",
+			"set", "dev", iface,
+			"mtu", conf.UpdateMtuInstance,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"set", "dev", iface, "up",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"add", "link", ifaceInternal,
+		"name", ifaceVlan,
+		"type", "vlan",
+		"id", strconv.Itoa(vc.VpcId),
+	)
+	if err != nil {
+		return
+	}
+
+	if conf.UpdateMtuInternal != "" {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "link",
+			"set", "dev", ifaceVlan,
+			"mtu", conf.UpdateMtuInternal,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"set", "dev", ifaceVlan, "up",
+	)
+	if err != nil {
+		return
+	}
+
+	err = iproute.BridgeAdd(namespace, "br0")
+	if err != nil {
+		return
+	}
+
+	for _, brIface := range []string{ifaceVlan, iface} {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "link", "set",
+			brIface, "master", "br0",
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "addr",
+		"add", gatewayCidr,
+		"dev", "br0",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "-6", "addr",
+		"add", gatewayAddr6.String()+"/64",
+		"dev", "br0",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"set", "dev", "br0", "up",
+	)
+	if err != nil {
+		return
+	}
+
+	if externalNetwork {
+		if nodeNetworkMode == node.Static {
+			staticGateway := blck.GetGateway()
+			staticMask := blck.GetMask()
+			if staticGateway == nil || staticMask == nil {
+				err = &errortypes.ParseError{
+					errors.New("qemu: Invalid block gateway cidr"),
+				}
+				return
+			}
+
+			staticSize, _ := staticMask.Size()
+
+			_, err = utils.ExecCombinedOutputLogged(
+				[]string{"File exists"},
+				"ip", "netns", "exec", namespace,
+				"ip", "addr",
+				"add", fmt.Sprintf("%s/%d", staticAddr.String(), staticSize),
+				"dev", ifaceExternal,
+			)
+			if err != nil {
+				return
+			}
+
+			_, err = utils.ExecCombinedOutputLogged(
+				[]string{"File exists"},
+				"ip", "netns", "exec", namespace,
+				"ip", "route",
+				"add", "default",
+				"via", staticGateway.String(),
+			)
+			if err != nil {
+				return
+			}
+		} else {
+			_, err = utils.ExecCombinedOutputLogged(
+				nil,
+				"ip", "netns", "exec", namespace,
+				"dhclient",
+				"-pf", pidPath,
+				"-lf", leasePath,
+				ifaceExternal,
+			)
+			if err != nil {
+				return
+			}
+		}
+
+		for i := 0; i < 60; i++ {
+			address, _, e := iproute.AddressGetIface(
+				namespace, ifaceExternal)
+			if e != nil {
+				err = e
+				return
+			}
+
+			if address != nil {
+				pubAddr = address.Local
+				break
+			}
+
+			time.Sleep(250 * time.Millisecond)
+		}
+
+		if pubAddr == "" {
+			err = &errortypes.NetworkError{
+				errors.New("qemu: Instance interface missing IPv4 address"),
+			}
+			return
+		}
+	}
+
+	addr = instAddr.String()
+	addr6 = instAddr6.String()
+
+	return
+}
+
+func networkStopInterfaceDhClient(virt *vm.VirtualMachine, n int) {
+	ifaceExternal := vm.GetAdapterIfaceExternal(virt.Id, n)
+	pidPath := fmt.Sprintf("/var/run/dhclient-%s.pid", ifaceExternal)
+
+	pid := ""
+	pidData, _ := ioutil.ReadFile(pidPath)
+	if pidData != nil {
+		pid = strings.TrimSpace(string(pidData))
+	}
+
+	if pid != "" {
+		_, _ = utils.ExecCombinedOutput("", "kill", pid)
+	}
+
+	_ = utils.RemoveAll(pidPath)
+}
+
+func networkConfClearInterface(virt *vm.VirtualMachine, n int) {
+	networkStopInterfaceDhClient(virt, n)
+
+	ifaceInternalVirt := vm.GetAdapterIfaceVirtInternal(virt.Id, n)
+	ifaceExternalVirt := vm.GetAdapterIfaceVirtExternal(virt.Id, n)
+
+	for _, virtIface := range []string{ifaceInternalVirt, ifaceExternalVirt} {
+		_, _ = utils.ExecCombinedOutput(
+			"", "ip", "link", "set", virtIface, "down")
+		_, _ = utils.ExecCombinedOutput(
+			"", "ip", "link", "del", virtIface)
+		interfaces.RemoveVirtIface(virtIface)
+	}
+}
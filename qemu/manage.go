@@ -29,6 +29,8 @@ import (
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/paths"
 	"github.com/pritunl/pritunl-cloud/qms"
+	"github.com/pritunl/pritunl-cloud/resolver"
+	"github.com/pritunl/pritunl-cloud/serial"
 	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/store"
 	"github.com/pritunl/pritunl-cloud/systemd"
@@ -1558,22 +1560,46 @@ func NetworkConf(db *database.Database,
 		}
 	}
 
-	store.RemAddress(virt.Id)
-	store.RemRoutes(virt.Id)
-
 	hostIps := []string{}
 	if hostStaticAddr != nil {
 		hostIps = append(hostIps, hostStaticAddr.String())
 	}
 
+	update := bson.M{
+		"private_ips":       []string{addr.String()},
+		"private_ips6":      []string{addr6.String()},
+		"network_namespace": namespace,
+		"host_ips":          hostIps,
+	}
+
+	for i := 1; i < len(virt.NetworkAdapters); i++ {
+		ifaceAddr, ifaceAddr6, ifacePubAddr, e := networkConfInterface(
+			db, virt, &interfaceConf{
+				Index:             i,
+				Vxlan:             vxlan,
+				UpdateMtuInternal: updateMtuInternal,
+				UpdateMtuExternal: updateMtuExternal,
+				UpdateMtuInstance: updateMtuInstance,
+			})
+		if e != nil {
+			err = e
+			return
+		}
+
+		prefix := fmt.Sprintf("network_interfaces.%d.", i-1)
+		update[prefix+"private_ip"] = ifaceAddr
+		update[prefix+"private_ip6"] = ifaceAddr6
+		update[prefix+"public_ip"] = ifacePubAddr
+	}
+
+	store.RemAddress(virt.Id)
+	store.RemRoutes(virt.Id)
+	store.RemGateway(virt.Id)
+	store.RemFloating(virt.Id)
+
 	coll := db.Instances()
 	err = coll.UpdateId(virt.Id, &bson.M{
-		"$set": &bson.M{
-			"private_ips":       []string{addr.String()},
-			"private_ips6":      []string{addr6.String()},
-			"network_namespace": namespace,
-			"host_ips":          hostIps,
-		},
+		"$set": &update,
 	})
 	if err != nil {
 		err = database.ParseError(err)
@@ -1630,6 +1656,11 @@ func NetworkConfClear(db *database.Database,
 		return
 	}
 
+	err = resolver.Stop(virt.Id)
+	if err != nil {
+		return
+	}
+
 	ifaceExternalVirt := vm.GetIfaceVirt(virt.Id, 0)
 	ifaceExternalVirt6 := vm.GetIfaceVirt(virt.Id, 3)
 	ifaceInternalVirt := vm.GetIfaceVirt(virt.Id, 1)
@@ -1656,8 +1687,14 @@ func NetworkConfClear(db *database.Database,
 	interfaces.RemoveVirtIface(ifaceExternalVirt6)
 	interfaces.RemoveVirtIface(ifaceInternalVirt)
 
+	for i := 1; i < len(virt.NetworkAdapters); i++ {
+		networkConfClearInterface(virt, i)
+	}
+
 	store.RemAddress(virt.Id)
 	store.RemRoutes(virt.Id)
+	store.RemGateway(virt.Id)
+	store.RemFloating(virt.Id)
 
 	return
 }
@@ -1665,6 +1702,16 @@ func NetworkConfClear(db *database.Database,
 func writeService(virt *vm.VirtualMachine) (err error) {
 	unitPath := paths.GetUnitPath(virt.Id)
 
+	err = serial.RotateConsoleLog(virt.Id)
+	if err != nil {
+		return
+	}
+
+	err = serial.StartConsoleLog(virt.Id)
+	if err != nil {
+		return
+	}
+
 	qm, err := NewQemu(virt)
 	if err != nil {
 		return
@@ -1812,6 +1859,7 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 	unitPath := paths.GetUnitPath(virt.Id)
 	sockPath := paths.GetSockPath(virt.Id)
 	guestPath := paths.GetGuestPath(virt.Id)
+	serialPath := paths.GetSerialPath(virt.Id)
 	pidPath := paths.GetPidPath(virt.Id)
 
 	logrus.WithFields(logrus.Fields{
@@ -1955,6 +2003,21 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 		return
 	}
 
+	err = utils.RemoveAll(serialPath)
+	if err != nil {
+		return
+	}
+
+	err = serial.RemoveConsoleLogs(virt.Id)
+	if err != nil {
+		return
+	}
+
+	err = serial.RemoveRecord(virt.Id)
+	if err != nil {
+		return
+	}
+
 	err = utils.RemoveAll(pidPath)
 	if err != nil {
 		return
@@ -1979,6 +2042,8 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 	store.RemDisks(virt.Id)
 	store.RemAddress(virt.Id)
 	store.RemRoutes(virt.Id)
+	store.RemGateway(virt.Id)
+	store.RemFloating(virt.Id)
 
 	return
 }
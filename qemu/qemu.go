@@ -149,6 +149,15 @@ func (q *Qemu) Marshal() (output string, err error) {
 	cmd = append(cmd, "-pidfile")
 	cmd = append(cmd, paths.GetPidPath(q.Id))
 
+	cmd = append(cmd, "-chardev")
+	cmd = append(cmd, fmt.Sprintf(
+		"socket,path=%s,server,nowait,id=serial,logfile=%s",
+		paths.GetSerialPath(q.Id),
+		paths.GetConsoleFifoPath(q.Id),
+	))
+	cmd = append(cmd, "-serial")
+	cmd = append(cmd, "chardev:serial")
+
 	guestPath := paths.GetGuestPath(q.Id)
 	cmd = append(cmd, "-chardev")
 	cmd = append(cmd, fmt.Sprintf(
@@ -2,6 +2,7 @@ package qga
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"net"
 	"strings"
@@ -122,3 +123,126 @@ func GetInterfaces(sockPath string) (ifaces *Interfaces, err error) {
 
 	return
 }
+
+type argsCommand struct {
+	Execute   string      `json:"execute"`
+	Arguments interface{} `json:"arguments"`
+}
+
+type response struct {
+	Return json.RawMessage `json:"return"`
+	Error  *struct {
+		Class string `json:"class"`
+		Desc  string `json:"desc"`
+	} `json:"error"`
+}
+
+type fileWrite struct {
+	Handle int    `json:"handle"`
+	Buffer string `json:"buf-b64"`
+}
+
+type fileHandle struct {
+	Handle int `json:"handle"`
+}
+
+type fileOpen struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+}
+
+func execute(enc *json.Encoder, dec *json.Decoder, cmd string,
+	args interface{}, ret interface{}) (err error) {
+
+	err = enc.Encode(&argsCommand{
+		Execute:   cmd,
+		Arguments: args,
+	})
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "qga: Failed to write to guest agent"),
+		}
+		return
+	}
+
+	resp := &response{}
+	err = dec.Decode(resp)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qga: Failed to read from guest agent"),
+		}
+		return
+	}
+
+	if resp.Error != nil {
+		err = &errortypes.RequestError{
+			errors.Newf("qga: Guest agent %s error '%s'",
+				cmd, resp.Error.Desc),
+		}
+		return
+	}
+
+	if ret != nil {
+		err = json.Unmarshal(resp.Return, ret)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "qga: Failed to parse guest agent response"),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func WriteFile(sockPath, pth string, data []byte) (err error) {
+	conn, err := net.DialTimeout(
+		"unix",
+		sockPath,
+		3*time.Second,
+	)
+	if err != nil {
+		err = &errortypes.ConnectionError{
+			errors.Wrap(err, "qga: Failed to connect to guest agent"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err != nil {
+		return
+	}
+
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+
+	handle := 0
+	err = execute(enc, dec, "guest-file-open", &fileOpen{
+		Path: pth,
+		Mode: "w",
+	}, &handle)
+	if err != nil {
+		return
+	}
+
+	err = execute(enc, dec, "guest-file-write", &fileWrite{
+		Handle: handle,
+		Buffer: base64.StdEncoding.EncodeToString(data),
+	}, nil)
+	if err != nil {
+		execute(enc, dec, "guest-file-close", &fileHandle{
+			Handle: handle,
+		}, nil)
+		return
+	}
+
+	err = execute(enc, dec, "guest-file-close", &fileHandle{
+		Handle: handle,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,23 @@
+package resolver
+
+const confTmpl = `pid-file={{.PidPath}}
+dhcp-leasefile={{.LeasePath}}
+addn-hosts={{.HostsPath}}
+no-hosts
+interface=br0
+bind-interfaces
+domain={{.Domain}}
+local=/{{.Domain}}/
+expand-hosts
+dhcp-range={{.Address}},static,{{.Netmask}},1h
+dhcp-range={{.Network6}},static,64,1h
+dhcp-host={{.Mac}},{{.Address}},{{.Hostname}},infinite
+dhcp-host={{.Mac}},[{{.Address6}}],{{.Hostname}},infinite
+dhcp-option=option:router,{{.Gateway}}
+dhcp-option=option:dns-server,{{.Gateway}}
+dhcp-option=option:domain-search,{{.Domain}}
+dhcp-option=option6:dns-server,[{{.Gateway6}}]
+dhcp-option=option6:domain-search,{{.Domain}}
+{{if .Servers}}no-resolv
+{{range .Servers}}server={{.}}
+{{end}}{{end}}`
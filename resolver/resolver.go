@@ -0,0 +1,298 @@
+package resolver
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	conf = template.Must(template.New("resolver").Parse(confTmpl))
+)
+
+type confData struct {
+	PidPath   string
+	LeasePath string
+	HostsPath string
+	Domain    string
+	Mac       string
+	Hostname  string
+	Address   string
+	Address6  string
+	Netmask   string
+	Network6  string
+	Gateway   string
+	Gateway6  string
+	Servers   []string
+}
+
+func GetHostname(inst *instance.Instance) string {
+	name := utils.FilterDomain(inst.Name)
+	if name == "" {
+		name = inst.Id.Hex()
+	}
+
+	return name
+}
+
+func getConf(inst *instance.Instance, vc *vpc.Vpc) (
+	data string, err error) {
+
+	if len(inst.PrivateIps) == 0 || len(inst.PrivateIps6) == 0 {
+		err = &errortypes.NotFoundError{
+			errors.New("resolver: Instance missing private address"),
+		}
+		return
+	}
+
+	vcNet, err := vc.GetNetwork()
+	if err != nil {
+		return
+	}
+
+	vcNet6, err := vc.GetNetwork6()
+	if err != nil {
+		return
+	}
+
+	addr := net.ParseIP(inst.PrivateIps[0])
+	if addr == nil {
+		err = &errortypes.ParseError{
+			errors.New("resolver: Failed to parse private address"),
+		}
+		return
+	}
+
+	gatewayAddr := utils.CopyIpAddress(addr)
+	utils.IncIpAddress(gatewayAddr)
+
+	confBuf := &bytes.Buffer{}
+	err = conf.Execute(confBuf, &confData{
+		PidPath:   paths.GetResolverPidPath(inst.Id),
+		LeasePath: paths.GetResolverLeasePath(inst.Id),
+		HostsPath: paths.GetResolverHostsPath(inst.Id),
+		Domain:    vc.GetDomain(),
+		Mac:       vm.GetMacAddr(inst.Id, inst.Vpc),
+		Hostname:  GetHostname(inst),
+		Address:   inst.PrivateIps[0],
+		Address6:  inst.PrivateIps6[0],
+		Netmask:   net.IP(vcNet.Mask).String(),
+		Network6:  vcNet6.IP.String(),
+		Gateway:   gatewayAddr.String(),
+		Gateway6:  vc.GetIp6(gatewayAddr).String(),
+		Servers:   vc.DnsServers,
+	})
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "resolver: Failed to exec conf template"),
+		}
+		return
+	}
+
+	data = confBuf.String()
+
+	return
+}
+
+func getHosts(vc *vpc.Vpc, insts []*instance.Instance) string {
+	domain := vc.GetDomain()
+	hostsBuf := &bytes.Buffer{}
+
+	for _, inst := range insts {
+		addrs := []string{}
+
+		if inst.Vpc == vc.Id {
+			addrs = append(addrs, inst.PrivateIps...)
+			addrs = append(addrs, inst.PrivateIps6...)
+		}
+
+		for _, iface := range inst.NetworkInterfaces {
+			if iface.Vpc != vc.Id {
+				continue
+			}
+
+			if iface.PrivateIp != "" {
+				addrs = append(addrs, iface.PrivateIp)
+			}
+			if iface.PrivateIp6 != "" {
+				addrs = append(addrs, iface.PrivateIp6)
+			}
+		}
+
+		hostname := GetHostname(inst)
+		fqdn := hostname + "." + domain
+
+		for _, addr := range addrs {
+			hostsBuf.WriteString(
+				fmt.Sprintf("%s %s %s\n", addr, fqdn, hostname))
+		}
+	}
+
+	return hostsBuf.String()
+}
+
+func writeChanged(pth, data string) (changed bool, err error) {
+	curData, e := ioutil.ReadFile(pth)
+	if e == nil && string(curData) == data {
+		return
+	}
+
+	err = utils.CreateWrite(pth, data, 0644)
+	if err != nil {
+		return
+	}
+	changed = true
+
+	return
+}
+
+func getPid(instId primitive.ObjectID) (pid int) {
+	pidData, err := ioutil.ReadFile(paths.GetResolverPidPath(instId))
+	if err != nil {
+		return
+	}
+
+	pid, err = strconv.Atoi(strings.TrimSpace(string(pidData)))
+	if err != nil {
+		pid = 0
+		return
+	}
+
+	exists, _ := utils.Exists(fmt.Sprintf("/proc/%d", pid))
+	if !exists {
+		pid = 0
+	}
+
+	return
+}
+
+func start(inst *instance.Instance) (err error) {
+	logrus.WithFields(logrus.Fields{
+		"instance": inst.Id.Hex(),
+	}).Info("resolver: Starting instance resolver")
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", inst.NetworkNamespace,
+		"dnsmasq",
+		"--conf-file="+paths.GetResolverConfPath(inst.Id),
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Update(inst *instance.Instance, vc *vpc.Vpc,
+	vpcInsts []*instance.Instance) (err error) {
+
+	if inst.NetworkNamespace == "" {
+		return
+	}
+
+	err = utils.ExistsMkdir(paths.GetResolversPath(), 0755)
+	if err != nil {
+		return
+	}
+
+	confData, err := getConf(inst, vc)
+	if err != nil {
+		return
+	}
+
+	confChanged, err := writeChanged(
+		paths.GetResolverConfPath(inst.Id), confData)
+	if err != nil {
+		return
+	}
+
+	hostsChanged, err := writeChanged(
+		paths.GetResolverHostsPath(inst.Id), getHosts(vc, vpcInsts))
+	if err != nil {
+		return
+	}
+
+	pid := getPid(inst.Id)
+	if pid != 0 && confChanged {
+		err = Stop(inst.Id)
+		if err != nil {
+			return
+		}
+		pid = 0
+	}
+
+	if pid == 0 {
+		err = start(inst)
+		if err != nil {
+			return
+		}
+	} else if hostsChanged {
+		err = reload(pid)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func reload(pid int) (err error) {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "resolver: Failed to find process"),
+		}
+		return
+	}
+
+	err = proc.Signal(syscall.SIGHUP)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "resolver: Failed to reload resolver"),
+		}
+		return
+	}
+
+	return
+}
+
+func Stop(instId primitive.ObjectID) (err error) {
+	pid := getPid(instId)
+	if pid != 0 {
+		_, _ = utils.ExecCombinedOutput("", "kill", strconv.Itoa(pid))
+	}
+
+	_ = utils.RemoveAll(paths.GetResolverPidPath(instId))
+
+	return
+}
+
+func Remove(instId primitive.ObjectID) (err error) {
+	err = Stop(instId)
+	if err != nil {
+		return
+	}
+
+	_ = utils.RemoveAll(paths.GetResolverConfPath(instId))
+	_ = utils.RemoveAll(paths.GetResolverHostsPath(instId))
+	_ = utils.RemoveAll(paths.GetResolverLeasePath(instId))
+
+	return
+}
@@ -13,10 +13,12 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/pki"
 )
 
 type Certificates struct {
 	selfCert  *tls.Certificate
+	nodeCert  *tls.Certificate
 	domainMap map[string]*tls.Certificate
 }
 
@@ -63,6 +65,43 @@ func (c *Certificates) loadSelfCert() (err error) {
 	return
 }
 
+func (c *Certificates) loadNodeCert(db *database.Database) {
+	if !pki.Enabled() {
+		c.nodeCert = nil
+		return
+	}
+
+	dnsNames := []string{}
+	if node.Self.Hostname != "" {
+		dnsNames = append(dnsNames, node.Self.Hostname)
+	}
+
+	ips := []string{}
+	ips = append(ips, node.Self.PublicIps...)
+	ips = append(ips, node.Self.PublicIps6...)
+	for _, addr := range node.Self.PrivateIps {
+		ips = append(ips, addr)
+	}
+
+	cert, err := pki.GetCertificate(db, &pki.Request{
+		Authority:  pki.NodeCa,
+		CommonName: node.Self.Name,
+		DnsNames:   dnsNames,
+		Ips:        ips,
+		Server:     true,
+		Client:     true,
+		Ttl:        pki.NodeTtl,
+	})
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("router: Failed to load internal node certificate")
+		return
+	}
+
+	c.nodeCert = cert
+}
+
 func (c *Certificates) GetCertificate(info *tls.ClientHelloInfo) (
 	cert *tls.Certificate, err error) {
 
@@ -72,6 +111,9 @@ func (c *Certificates) GetCertificate(info *tls.ClientHelloInfo) (
 	}
 
 	cert = c.domainMap[name]
+	if cert == nil {
+		cert = c.nodeCert
+	}
 	if cert == nil {
 		cert = c.selfCert
 	}
@@ -82,6 +124,8 @@ func (c *Certificates) GetCertificate(info *tls.ClientHelloInfo) (
 func (c *Certificates) Update(db *database.Database,
 	balncs []*balancer.Balancer) (err error) {
 
+	c.loadNodeCert(db)
+
 	loaded := set.NewSet()
 	certificates := []*certificate.Certificate{}
 
@@ -0,0 +1,284 @@
+package serial
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gorilla/websocket"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/pki"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/sirupsen/logrus"
+)
+
+func getTlsConfig(db *database.Database, nde *node.Node, addr string) (
+	tlsConfig *tls.Config, err error) {
+
+	tlsConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		MaxVersion: tls.VersionTLS13,
+	}
+
+	if pki.Enabled() {
+		pool, e := pki.Pool(db, pki.NodeCa)
+		if e != nil {
+			err = e
+			return
+		}
+
+		tlsConfig.RootCAs = pool
+		tlsConfig.ServerName = addr
+		return
+	}
+
+	// Without the internal certificate authority the node self signed
+	// certificate stored in the database is pinned
+	certBlock, _ := pem.Decode([]byte(nde.SelfCertificate))
+	if certBlock == nil {
+		err = &errortypes.NotFoundError{
+			errors.New("serial: Node missing self certificate"),
+		}
+		return
+	}
+	selfCert := certBlock.Bytes
+
+	tlsConfig.InsecureSkipVerify = true
+	tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte,
+		_ [][]*x509.Certificate) error {
+
+		if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], selfCert) {
+			return &errortypes.VerificationError{
+				errors.New("serial: Node certificate does not match"),
+			}
+		}
+		return nil
+	}
+
+	return
+}
+
+// getNodeAddr returns the address the node serial server is bound to, the
+// public address is only used on nodes without an internal address
+func getNodeAddr(nde *node.Node) string {
+	for _, iface := range nde.InternalInterfaces {
+		addr := nde.PrivateIps[iface]
+		if addr != "" {
+			return addr
+		}
+	}
+
+	if len(nde.PublicIps) != 0 {
+		return nde.PublicIps[0]
+	}
+
+	return ""
+}
+
+func getNodeHost(db *database.Database, ndeId primitive.ObjectID) (
+	host string, tlsConfig *tls.Config, err error) {
+
+	nde, err := node.Get(db, ndeId)
+	if err != nil {
+		return
+	}
+
+	addr := getNodeAddr(nde)
+	if addr == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("serial: Node missing address for serial console"),
+		}
+		return
+	}
+
+	tlsConfig, err = getTlsConfig(db, nde, addr)
+	if err != nil {
+		return
+	}
+
+	host = net.JoinHostPort(addr,
+		strconv.Itoa(settings.Hypervisor.SerialPort))
+	return
+}
+
+func ConsoleLog(db *database.Database, instId, ndeId,
+	userId primitive.ObjectID, boot int) (data []byte, err error) {
+
+	host, tlsConfig, err := getNodeHost(db, ndeId)
+	if err != nil {
+		return
+	}
+
+	tokn, err := NewToken(db, instId, ndeId, userId)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf(
+		"https://%s/console-log/%s?boot=%d",
+		host,
+		instId.Hex(),
+		boot,
+	), nil)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "serial: Console log request error"),
+		}
+		return
+	}
+
+	req.Header.Set("Serial-Token", tokn.Id)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			TLSClientConfig:   tlsConfig,
+		},
+		Timeout: 20 * time.Second,
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "serial: Console log request error"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case 200:
+		break
+	case 404:
+		err = &errortypes.NotFoundError{
+			errors.New("serial: Console log not found"),
+		}
+		return
+	default:
+		err = &errortypes.RequestError{
+			errors.Newf("serial: Console log request bad status %d",
+				resp.StatusCode),
+		}
+		return
+	}
+
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "serial: Failed to read console log"),
+		}
+		return
+	}
+
+	return
+}
+
+func Connect(db *database.Database, instId, ndeId,
+	userId primitive.ObjectID, rw http.ResponseWriter, r *http.Request) (
+	err error) {
+
+	host, tlsConfig, err := getNodeHost(db, ndeId)
+	if err != nil {
+		return
+	}
+
+	tokn, err := NewToken(db, instId, ndeId, userId)
+	if err != nil {
+		return
+	}
+
+	wsUrl := fmt.Sprintf(
+		"wss://%s/serial/%s",
+		host,
+		instId.Hex(),
+	)
+
+	var backConn *websocket.Conn
+	var backResp *http.Response
+
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:  tlsConfig,
+	}
+
+	header := http.Header{}
+	header.Set("Serial-Token", tokn.Id)
+
+	backConn, backResp, err = dialer.Dial(wsUrl, header)
+	if err != nil {
+		if backResp != nil {
+			err = &errortypes.RequestError{
+				errors.Wrapf(err, "serial: WebSocket dial error %d",
+					backResp.StatusCode),
+			}
+		} else {
+			err = &errortypes.RequestError{
+				errors.Wrap(err, "serial: WebSocket dial error"),
+			}
+		}
+		return
+	}
+	defer backConn.Close()
+
+	wsUpgrader := &websocket.Upgrader{
+		HandshakeTimeout: time.Duration(
+			settings.Router.HandshakeTimeout) * time.Second,
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		CheckOrigin: func(r *http.Request) bool {
+			return true
+		},
+	}
+
+	frontConn, err := wsUpgrader.Upgrade(rw, r, nil)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "serial: WebSocket upgrade error"),
+		}
+		return
+	}
+	defer frontConn.Close()
+
+	wait := make(chan bool, 4)
+	go func() {
+		defer func() {
+			rec := recover()
+			if rec != nil {
+				logrus.WithFields(logrus.Fields{
+					"panic": rec,
+				}).Error("serial: WebSocket serial back panic")
+				wait <- true
+			}
+		}()
+		io.Copy(backConn.UnderlyingConn(), frontConn.UnderlyingConn())
+		wait <- true
+	}()
+	go func() {
+		defer func() {
+			rec := recover()
+			if rec != nil {
+				logrus.WithFields(logrus.Fields{
+					"panic": rec,
+				}).Error("serial: WebSocket serial front panic")
+				wait <- true
+			}
+		}()
+		io.Copy(frontConn.UnderlyingConn(), backConn.UnderlyingConn())
+		wait <- true
+	}()
+	<-wait
+
+	return
+}
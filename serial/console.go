@@ -0,0 +1,471 @@
+package serial
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/constants"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	consoleLoggers = map[primitive.ObjectID]*consoleLogger{}
+	consoleLock    = sync.Mutex{}
+)
+
+// consoleLogger is the only writer of the console log, qemu writes the
+// serial output to a fifo which is copied to the log and trimmed once the
+// log exceeds the size limit while the instance is running.
+type consoleLogger struct {
+	logPath string
+	limit   int64
+	size    int64
+	file    *os.File
+	fifo    *os.File
+	done    chan bool
+}
+
+func (l *consoleLogger) open() (err error) {
+	file, err := os.OpenFile(l.logPath,
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "serial: Failed to open console log"),
+		}
+		return
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "serial: Failed to stat console log"),
+		}
+		return
+	}
+
+	l.file = file
+	l.size = info.Size()
+
+	return
+}
+
+func (l *consoleLogger) Write(data []byte) (err error) {
+	if l.file == nil {
+		err = l.open()
+		if err != nil {
+			return
+		}
+	}
+
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "serial: Failed to write console log"),
+		}
+		return
+	}
+
+	if l.limit <= 0 || l.size <= l.limit {
+		return
+	}
+
+	l.Close()
+
+	err = trimConsoleLog(l.logPath, l.limit)
+	if err != nil {
+		return
+	}
+
+	err = l.open()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (l *consoleLogger) Close() {
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+func (l *consoleLogger) run(virtId primitive.ObjectID) {
+	defer close(l.done)
+
+	buf := make([]byte, 4096)
+	for {
+		n, e := l.fifo.Read(buf)
+		if n > 0 {
+			err := l.Write(buf[:n])
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": virtId.Hex(),
+					"error":       err,
+				}).Error("serial: Failed to write console log")
+			}
+		}
+		if e != nil {
+			return
+		}
+	}
+}
+
+func newConsoleLogger(logPath string, limit int64) (
+	logger *consoleLogger, err error) {
+
+	logger = &consoleLogger{
+		logPath: logPath,
+		limit:   limit,
+		done:    make(chan bool),
+	}
+
+	err = logger.open()
+	if err != nil {
+		logger = nil
+		return
+	}
+
+	return
+}
+
+// startConsoleLog must be called with the console lock held, the fifo is
+// only created when starting an instance to prevent recreating the fifo of
+// a removed instance.
+func startConsoleLog(virtId primitive.ObjectID, create bool) (err error) {
+	if consoleLoggers[virtId] != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(paths.GetSerialsPath(), 0700)
+	if err != nil {
+		return
+	}
+
+	fifoPath := paths.GetConsoleFifoPath(virtId)
+
+	info, err := os.Lstat(fifoPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "serial: Failed to stat console fifo"),
+			}
+			return
+		}
+		err = nil
+	} else if info.Mode()&os.ModeNamedPipe == 0 {
+		err = utils.Remove(fifoPath)
+		if err != nil {
+			return
+		}
+		info = nil
+	}
+
+	if info == nil {
+		if !create {
+			return
+		}
+
+		err = syscall.Mkfifo(fifoPath, 0600)
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "serial: Failed to create console fifo"),
+			}
+			return
+		}
+	}
+
+	logger, err := newConsoleLogger(paths.GetConsoleLogPath(virtId, 0),
+		int64(settings.Hypervisor.ConsoleLogSize))
+	if err != nil {
+		return
+	}
+
+	// Opened for reading and writing to prevent the fifo from closing when
+	// qemu exits and to allow qemu to reopen it on the next start
+	fifo, err := os.OpenFile(fifoPath, os.O_RDWR, 0)
+	if err != nil {
+		logger.Close()
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "serial: Failed to open console fifo"),
+		}
+		return
+	}
+	logger.fifo = fifo
+
+	consoleLoggers[virtId] = logger
+	go logger.run(virtId)
+
+	return
+}
+
+// stopConsoleLog must be called with the console lock held
+func stopConsoleLog(virtId primitive.ObjectID) {
+	logger := consoleLoggers[virtId]
+	if logger == nil {
+		return
+	}
+	delete(consoleLoggers, virtId)
+
+	logger.fifo.Close()
+	<-logger.done
+	logger.Close()
+}
+
+// StartConsoleLog starts the console log writer for an instance, it must
+// be running before qemu is started as qemu will block opening the fifo
+// until a reader is present.
+func StartConsoleLog(virtId primitive.ObjectID) (err error) {
+	consoleLock.Lock()
+	defer consoleLock.Unlock()
+
+	err = startConsoleLog(virtId, true)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// RotateConsoleLog stops the console log writer and rotates the console
+// logs, this must only be called while the instance is stopped.
+func RotateConsoleLog(virtId primitive.ObjectID) (err error) {
+	consoleLock.Lock()
+	defer consoleLock.Unlock()
+
+	stopConsoleLog(virtId)
+
+	err = utils.ExistsMkdir(paths.GetSerialsPath(), 0700)
+	if err != nil {
+		return
+	}
+
+	boots := settings.Hypervisor.ConsoleLogBoots
+	if boots < 1 {
+		boots = 1
+	}
+
+	err = utils.RemoveAll(paths.GetConsoleLogPath(virtId, boots))
+	if err != nil {
+		return
+	}
+
+	limit := int64(settings.Hypervisor.ConsoleLogSize)
+	if limit > 0 {
+		err = trimConsoleLog(paths.GetConsoleLogPath(virtId, 0), limit)
+		if err != nil {
+			return
+		}
+	}
+
+	for i := boots - 1; i >= 0; i-- {
+		logPath := paths.GetConsoleLogPath(virtId, i)
+
+		exists, e := utils.Exists(logPath)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if !exists {
+			continue
+		}
+
+		err = os.Rename(logPath, paths.GetConsoleLogPath(virtId, i+1))
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "serial: Failed to rotate console log"),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveConsoleLogs(virtId primitive.ObjectID) (err error) {
+	consoleLock.Lock()
+	defer consoleLock.Unlock()
+
+	stopConsoleLog(virtId)
+
+	err = utils.RemoveAll(paths.GetConsoleFifoPath(virtId))
+	if err != nil {
+		return
+	}
+
+	for i := 0; i <= settings.Hypervisor.ConsoleLogBoots; i++ {
+		err = utils.RemoveAll(paths.GetConsoleLogPath(virtId, i))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func ReadConsoleLog(virtId primitive.ObjectID, boot int) (
+	data []byte, err error) {
+
+	if boot < 0 || boot > settings.Hypervisor.ConsoleLogBoots {
+		err = &errortypes.NotFoundError{
+			errors.New("serial: Console log boot out of range"),
+		}
+		return
+	}
+
+	data, err = ioutil.ReadFile(paths.GetConsoleLogPath(virtId, boot))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = &errortypes.NotFoundError{
+				errors.Wrap(err, "serial: Console log not found"),
+			}
+		} else {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "serial: Failed to read console log"),
+			}
+		}
+		return
+	}
+
+	return
+}
+
+// trimConsoleLog reduces a console log to the most recent data. The trimmed
+// log is written to a temporary file and renamed over the log which must
+// only be done when the console log writer is not holding the log open.
+func trimConsoleLog(logPath string, limit int64) (err error) {
+	file, err := os.Open(logPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "serial: Failed to open console log"),
+		}
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "serial: Failed to stat console log"),
+		}
+		return
+	}
+
+	if info.Size() <= limit {
+		return
+	}
+
+	keep := limit * 3 / 4
+	tail := make([]byte, keep)
+	_, err = file.ReadAt(tail, info.Size()-keep)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "serial: Failed to read console log"),
+		}
+		return
+	}
+
+	index := bytes.IndexByte(tail, '\n')
+	if index >= 0 {
+		tail = tail[index+1:]
+	}
+
+	tempPath := logPath + ".tmp"
+	err = ioutil.WriteFile(tempPath, tail, 0600)
+	if err != nil {
+		os.Remove(tempPath)
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "serial: Failed to write console log"),
+		}
+		return
+	}
+
+	err = os.Rename(tempPath, logPath)
+	if err != nil {
+		os.Remove(tempPath)
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "serial: Failed to replace console log"),
+		}
+		return
+	}
+
+	return
+}
+
+func startConsoleLogs() (err error) {
+	fifoPaths, err := filepath.Glob(
+		filepath.Join(paths.GetSerialsPath(), "*.console.fifo"))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "serial: Failed to list console fifos"),
+		}
+		return
+	}
+
+	for _, fifoPath := range fifoPaths {
+		virtId, ok := utils.ParseObjectId(
+			strings.TrimSuffix(filepath.Base(fifoPath), ".console.fifo"))
+		if !ok {
+			continue
+		}
+
+		consoleLock.Lock()
+		err = startConsoleLog(virtId, false)
+		consoleLock.Unlock()
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func consoleRunner() {
+	for {
+		time.Sleep(1 * time.Second)
+		if constants.Interrupt {
+			return
+		}
+
+		if node.Self.IsHypervisor() {
+			break
+		}
+	}
+
+	// Restores the console log writers of instances started before the
+	// node was restarted
+	for {
+		err := startConsoleLogs()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("serial: Failed to start console logs")
+		}
+
+		time.Sleep(30 * time.Second)
+		if constants.Interrupt {
+			return
+		}
+	}
+}
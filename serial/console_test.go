@@ -0,0 +1,202 @@
+package serial
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+)
+
+func writeTestLog(t *testing.T, data string) (logPath string) {
+	dir, err := ioutil.TempDir("", "console")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logPath = filepath.Join(dir, "test.console")
+	err = ioutil.WriteFile(logPath, []byte(data), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return
+}
+
+func readTestLog(t *testing.T, logPath string) string {
+	data, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestTrimConsoleLog(t *testing.T) {
+	lines := []string{}
+	for i := 0; i < 100; i++ {
+		lines = append(lines, strings.Repeat(string(rune('a'+i%26)), 9))
+	}
+	data := strings.Join(lines, "\n") + "\n"
+
+	logPath := writeTestLog(t, data)
+	defer os.RemoveAll(filepath.Dir(logPath))
+
+	err := trimConsoleLog(logPath, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readTestLog(t, logPath) != data {
+		t.Errorf("log at limit modified")
+	}
+
+	// Keeps the last three quarters of the limit starting at a full line
+	err = trimConsoleLog(logPath, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Join(lines[71:], "\n") + "\n"
+	trimmed := readTestLog(t, logPath)
+	if trimmed != expected {
+		t.Errorf("trimmed log %d bytes expected last %d bytes",
+			len(trimmed), len(expected))
+	}
+
+	info, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("trimmed log mode %o expected 600", info.Mode().Perm())
+	}
+
+	_, err = os.Stat(logPath + ".tmp")
+	if !os.IsNotExist(err) {
+		t.Errorf("temporary log not removed")
+	}
+}
+
+func TestTrimConsoleLogNoNewline(t *testing.T) {
+	logPath := writeTestLog(t, strings.Repeat("x", 1000))
+	defer os.RemoveAll(filepath.Dir(logPath))
+
+	err := trimConsoleLog(logPath, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trimmed := readTestLog(t, logPath)
+	if trimmed != strings.Repeat("x", 75) {
+		t.Errorf("trimmed log %d bytes expected 75", len(trimmed))
+	}
+}
+
+func TestTrimConsoleLogMissing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "console")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = trimConsoleLog(filepath.Join(dir, "missing.console"), 10)
+	if err != nil {
+		t.Errorf("missing log returned error: %s", err)
+	}
+}
+
+func TestConsoleLoggerLimit(t *testing.T) {
+	logPath := writeTestLog(t, "")
+	defer os.RemoveAll(filepath.Dir(logPath))
+
+	logger, err := newConsoleLogger(logPath, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := []string{}
+	for i := 0; i < 100; i++ {
+		line := strings.Repeat(string(rune('a'+i%26)), 9) + "\n"
+		lines = append(lines, line)
+
+		err = logger.Write([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := os.Stat(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 400 {
+			t.Fatalf("log %d bytes exceeds limit", info.Size())
+		}
+		if info.Size() != logger.size {
+			t.Errorf("log %d bytes tracked as %d", info.Size(), logger.size)
+		}
+	}
+	logger.Close()
+
+	data := readTestLog(t, logPath)
+	if !strings.HasSuffix(strings.Join(lines, ""), data) {
+		t.Errorf("log is not the most recent output")
+	}
+	if !strings.HasSuffix(data, lines[99]) {
+		t.Errorf("log missing last line")
+	}
+}
+
+func TestConsoleLoggerFifo(t *testing.T) {
+	logPath := writeTestLog(t, "boot\n")
+	defer os.RemoveAll(filepath.Dir(logPath))
+
+	fifoPath := logPath + ".fifo"
+	err := syscall.Mkfifo(fifoPath, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger, err := newConsoleLogger(logPath, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.fifo, err = os.OpenFile(fifoPath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go logger.run(primitive.NewObjectID())
+
+	// Writers opening and closing the fifo must not stop the logger
+	for _, output := range []string{"first\n", "second\n"} {
+		writer, err := os.OpenFile(fifoPath, os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writer.Write([]byte(output))
+		writer.Close()
+	}
+
+	for i := 0; i < 100; i++ {
+		if readTestLog(t, logPath) == "boot\nfirst\nsecond\n" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	logger.fifo.Close()
+	select {
+	case <-logger.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logger did not stop after fifo closed")
+	}
+	logger.Close()
+
+	data := readTestLog(t, logPath)
+	if data != "boot\nfirst\nsecond\n" {
+		t.Errorf("unexpected log %q", data)
+	}
+}
@@ -0,0 +1,9 @@
+package serial
+
+import (
+	"time"
+)
+
+const (
+	TokenTtl = 30 * time.Second
+)
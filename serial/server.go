@@ -0,0 +1,471 @@
+package serial
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gorilla/websocket"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/constants"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/pki"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/sirupsen/logrus"
+)
+
+type server struct {
+	upgrader *websocket.Upgrader
+}
+
+type recorder struct {
+	file      *os.File
+	remaining int64
+}
+
+func (r *recorder) Write(data []byte) {
+	if r.remaining <= 0 {
+		return
+	}
+
+	if int64(len(data)) > r.remaining {
+		data = data[:r.remaining]
+	}
+	r.remaining -= int64(len(data))
+
+	r.file.Write(data)
+}
+
+func (r *recorder) Close() {
+	r.file.Close()
+}
+
+func (s *server) openRecord(instId primitive.ObjectID) (
+	record *recorder, err error) {
+
+	err = utils.ExistsMkdir(paths.GetSerialsPath(), 0700)
+	if err != nil {
+		return
+	}
+
+	limit := int64(settings.Hypervisor.SerialRecordSize)
+	recordPath := paths.GetSerialRecordPath(instId)
+
+	info, err := os.Stat(recordPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "serial: Failed to stat serial record"),
+			}
+			return
+		}
+		err = nil
+	} else if info.Size() >= limit {
+		err = os.Rename(recordPath, paths.GetSerialRecordOldPath(instId))
+		if err != nil {
+			err = &errortypes.WriteError{
+				errors.Wrap(err, "serial: Failed to rotate serial record"),
+			}
+			return
+		}
+		info = nil
+	}
+
+	file, err := os.OpenFile(recordPath,
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "serial: Failed to open serial record"),
+		}
+		return
+	}
+
+	record = &recorder{
+		file:      file,
+		remaining: limit,
+	}
+	if info != nil {
+		record.remaining -= info.Size()
+	}
+
+	return
+}
+
+func RemoveRecord(virtId primitive.ObjectID) (err error) {
+	err = utils.RemoveAll(paths.GetSerialRecordPath(virtId))
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(paths.GetSerialRecordOldPath(virtId))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (s *server) bridge(rw http.ResponseWriter, r *http.Request,
+	tokn *Token) (err error) {
+
+	serialConn, err := net.DialTimeout(
+		"unix", paths.GetSerialPath(tokn.Instance), 5*time.Second)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "serial: Failed to connect to serial socket"),
+		}
+		return
+	}
+	defer serialConn.Close()
+
+	var record *recorder
+	if settings.Hypervisor.SerialRecord {
+		record, err = s.openRecord(tokn.Instance)
+		if err != nil {
+			return
+		}
+		defer func() {
+			record.Write([]byte(fmt.Sprintf(
+				"\n--- session end %s user %s ---\n",
+				time.Now().Format(time.RFC3339), tokn.User.Hex())))
+			record.Close()
+		}()
+
+		record.Write([]byte(fmt.Sprintf(
+			"\n--- session start %s user %s ---\n",
+			time.Now().Format(time.RFC3339), tokn.User.Hex())))
+	}
+
+	conn, err := s.upgrader.Upgrade(rw, r, nil)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "serial: WebSocket upgrade error"),
+		}
+		return
+	}
+	defer conn.Close()
+
+	// The first side to close ends the session, both sides must exit
+	// before the deferred record end is written
+	waiter := sync.WaitGroup{}
+	waiter.Add(2)
+	wait := make(chan bool, 4)
+	go func() {
+		defer func() {
+			rec := recover()
+			if rec != nil {
+				logrus.WithFields(logrus.Fields{
+					"panic": rec,
+				}).Error("serial: WebSocket serial front panic")
+			}
+			wait <- true
+			waiter.Done()
+		}()
+
+		for {
+			_, data, e := conn.ReadMessage()
+			if e != nil {
+				return
+			}
+
+			_, e = serialConn.Write(data)
+			if e != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer func() {
+			rec := recover()
+			if rec != nil {
+				logrus.WithFields(logrus.Fields{
+					"panic": rec,
+				}).Error("serial: WebSocket serial back panic")
+			}
+			wait <- true
+			waiter.Done()
+		}()
+
+		buf := make([]byte, 4096)
+		for {
+			n, e := serialConn.Read(buf)
+			if n > 0 {
+				if record != nil {
+					record.Write(buf[:n])
+				}
+
+				e2 := conn.WriteMessage(websocket.BinaryMessage, buf[:n])
+				if e2 != nil {
+					return
+				}
+			}
+			if e != nil {
+				return
+			}
+		}
+	}()
+	<-wait
+
+	conn.Close()
+	serialConn.Close()
+	waiter.Wait()
+
+	return
+}
+
+func (s *server) consoleLog(rw http.ResponseWriter, r *http.Request,
+	tokn *Token) (err error) {
+
+	boot := 0
+	bootStr := r.URL.Query().Get("boot")
+	if bootStr != "" {
+		boot, err = strconv.Atoi(bootStr)
+		if err != nil {
+			err = nil
+			rw.WriteHeader(400)
+			return
+		}
+	}
+
+	data, err := ReadConsoleLog(tokn.Instance, boot)
+	if err != nil {
+		if _, ok := err.(*errortypes.NotFoundError); ok {
+			err = nil
+			rw.WriteHeader(404)
+		}
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.WriteHeader(200)
+	rw.Write(data)
+
+	return
+}
+
+func (s *server) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	var instIdStr string
+	consoleLog := false
+	if strings.HasPrefix(r.URL.Path, "/serial/") {
+		instIdStr = strings.TrimPrefix(r.URL.Path, "/serial/")
+	} else if strings.HasPrefix(r.URL.Path, "/console-log/") {
+		instIdStr = strings.TrimPrefix(r.URL.Path, "/console-log/")
+		consoleLog = true
+	} else {
+		rw.WriteHeader(404)
+		return
+	}
+
+	instId, ok := utils.ParseObjectId(instIdStr)
+	if !ok {
+		rw.WriteHeader(400)
+		return
+	}
+
+	db := database.GetDatabase()
+	tokn, err := ClaimToken(db, r.Header.Get("Serial-Token"),
+		instId, node.Self.Id)
+	db.Close()
+	if err != nil {
+		if _, ok := err.(*errortypes.AuthenticationError); ok {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": instId.Hex(),
+				"remote":      r.RemoteAddr,
+			}).Warn("serial: Invalid serial console token")
+			rw.WriteHeader(401)
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("serial: Failed to claim serial console token")
+		rw.WriteHeader(500)
+		return
+	}
+
+	if consoleLog {
+		err = s.consoleLog(rw, r, tokn)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": instId.Hex(),
+				"error":       err,
+			}).Error("serial: Console log read error")
+			rw.WriteHeader(500)
+			return
+		}
+		return
+	}
+
+	err = s.bridge(rw, r, tokn)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": instId.Hex(),
+			"error":       err,
+		}).Error("serial: Serial console bridge error")
+		return
+	}
+}
+
+func (s *server) nodeCert() (cert *tls.Certificate, err error) {
+	db := database.GetDatabase()
+	defer db.Close()
+
+	ips := []string{}
+	ips = append(ips, node.Self.PublicIps...)
+	ips = append(ips, node.Self.PublicIps6...)
+	for _, addr := range node.Self.PrivateIps {
+		ips = append(ips, addr)
+	}
+
+	dnsNames := []string{}
+	if node.Self.Hostname != "" {
+		dnsNames = append(dnsNames, node.Self.Hostname)
+	}
+
+	cert, err = pki.GetCertificate(db, &pki.Request{
+		Authority:  pki.NodeCa,
+		CommonName: node.Self.Name,
+		DnsNames:   dnsNames,
+		Ips:        ips,
+		Server:     true,
+		Client:     true,
+		Ttl:        pki.NodeTtl,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (s *server) run() (err error) {
+	certPem, keyPem, err := node.SelfCert()
+	if err != nil {
+		return
+	}
+
+	keypair, err := tls.X509KeyPair(certPem, keyPem)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "serial: Failed to load self certificate"),
+		}
+		return
+	}
+
+	addr := getNodeAddr(node.Self)
+	if addr == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("serial: Node missing address for serial console"),
+		}
+		return
+	}
+
+	listener, err := tls.Listen("tcp",
+		net.JoinHostPort(addr, strconv.Itoa(settings.Hypervisor.SerialPort)),
+		&tls.Config{
+			MinVersion: tls.VersionTLS12,
+			MaxVersion: tls.VersionTLS13,
+			GetCertificate: func(info *tls.ClientHelloInfo) (
+				*tls.Certificate, error) {
+
+				if !pki.Enabled() {
+					return &keypair, nil
+				}
+
+				return s.nodeCert()
+			},
+		},
+	)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "serial: Failed to listen on serial port"),
+		}
+		return
+	}
+
+	httpServer := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		MaxHeaderBytes:    4096,
+	}
+
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(5 * time.Second):
+			}
+
+			if constants.Interrupt || getNodeAddr(node.Self) != addr {
+				httpServer.Close()
+				return
+			}
+		}
+	}()
+
+	err = httpServer.Serve(listener)
+	if err == http.ErrServerClosed {
+		err = nil
+	} else if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "serial: Serial server error"),
+		}
+		return
+	}
+
+	return
+}
+
+func runner() {
+	for {
+		time.Sleep(1 * time.Second)
+		if constants.Interrupt {
+			return
+		}
+
+		if node.Self.IsHypervisor() {
+			break
+		}
+	}
+
+	srv := &server{
+		upgrader: &websocket.Upgrader{
+			HandshakeTimeout: 10 * time.Second,
+			ReadBufferSize:   4096,
+			WriteBufferSize:  4096,
+		},
+	}
+
+	for {
+		err := srv.run()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("serial: Serial console server error")
+		}
+
+		time.Sleep(3 * time.Second)
+		if constants.Interrupt {
+			return
+		}
+	}
+}
+
+func Init() {
+	go runner()
+	go consoleRunner()
+}
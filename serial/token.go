@@ -0,0 +1,74 @@
+package serial
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type Token struct {
+	Id        string             `bson:"_id"`
+	Instance  primitive.ObjectID `bson:"instance"`
+	Node      primitive.ObjectID `bson:"node"`
+	User      primitive.ObjectID `bson:"user"`
+	Timestamp time.Time          `bson:"timestamp"`
+}
+
+func NewToken(db *database.Database, instId, ndeId,
+	userId primitive.ObjectID) (tokn *Token, err error) {
+
+	tokenId, err := utils.RandStr(48)
+	if err != nil {
+		return
+	}
+
+	tokn = &Token{
+		Id:        tokenId,
+		Instance:  instId,
+		Node:      ndeId,
+		User:      userId,
+		Timestamp: time.Now(),
+	}
+
+	coll := db.SerialTokens()
+
+	_, err = coll.InsertOne(db, tokn)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func ClaimToken(db *database.Database, tokenId string,
+	instId, ndeId primitive.ObjectID) (tokn *Token, err error) {
+
+	coll := db.SerialTokens()
+	tokn = &Token{}
+
+	err = coll.FindOneAndDelete(db, &bson.M{
+		"_id":      tokenId,
+		"instance": instId,
+		"node":     ndeId,
+		"timestamp": &bson.M{
+			"$gt": time.Now().Add(-TokenTtl),
+		},
+	}).Decode(tokn)
+	if err != nil {
+		err = database.ParseError(err)
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = &errortypes.AuthenticationError{
+				errors.New("serial: Invalid serial console token"),
+			}
+		}
+		return
+	}
+
+	return
+}
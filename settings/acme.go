@@ -3,8 +3,12 @@ package settings
 var Acme *acme
 
 type acme struct {
-	Id  string `bson:"_id"`
-	Url string `bson:"url" default:"https://acme-v01.api.letsencrypt.org"`
+	Id             string `bson:"_id"`
+	Url            string `bson:"url" default:"https://acme-v01.api.letsencrypt.org"`
+	Directory      string `bson:"directory" default:"https://acme-v02.api.letsencrypt.org/directory"`
+	EabId          string `bson:"eab_id"`
+	EabKey         string `bson:"eab_key"`
+	PreferredChain string `bson:"preferred_chain"`
 }
 
 func newAcme() interface{} {
@@ -3,17 +3,24 @@ package settings
 var Hypervisor *hypervisor
 
 type hypervisor struct {
-	Id              string `bson:"_id"`
-	SystemdPath     string `bson:"systemd_path" default:"/etc/systemd/system"`
-	LibPath         string `bson:"systemd_path" default:"/var/lib/pritunl-cloud"`
-	NormalMtu       int    `bson:"normal_mtu" default:"1500"`
-	JumboMtu        int    `bson:"jumbo_mtu" default:"9000"`
-	VxlanId         int    `bson:"vxlan_id" default:"9417"`
-	VxlanDestPort   int    `bson:"vxlan_dest_port" default:"4789"`
-	HostNetworkName string `bson:"host_network_name" default:"pritunlhost0"`
-	StartTimeout    int    `bson:"start_timeout" default:"45"`
-	StopTimeout     int    `bson:"stop_timeout" default:"90"`
-	RefreshRate     int    `bson:"refresh_rate" default:"90"`
+	Id               string `bson:"_id"`
+	SystemdPath      string `bson:"systemd_path" default:"/etc/systemd/system"`
+	LibPath          string `bson:"systemd_path" default:"/var/lib/pritunl-cloud"`
+	NormalMtu        int    `bson:"normal_mtu" default:"1500"`
+	JumboMtu         int    `bson:"jumbo_mtu" default:"9000"`
+	VxlanId          int    `bson:"vxlan_id" default:"9417"`
+	VxlanDestPort    int    `bson:"vxlan_dest_port" default:"4789"`
+	HostNetworkName  string `bson:"host_network_name" default:"pritunlhost0"`
+	StartTimeout     int    `bson:"start_timeout" default:"45"`
+	StopTimeout      int    `bson:"stop_timeout" default:"90"`
+	RefreshRate      int    `bson:"refresh_rate" default:"90"`
+	GatewayTimeout   int    `bson:"gateway_timeout" default:"20"`
+	DomainRefresh    int    `bson:"domain_refresh" default:"300"`
+	SerialPort       int    `bson:"serial_port" default:"15800"`
+	SerialRecord     bool   `bson:"serial_record"`
+	SerialRecordSize int    `bson:"serial_record_size" default:"10485760"`
+	ConsoleLogSize   int    `bson:"console_log_size" default:"1048576"`
+	ConsoleLogBoots  int    `bson:"console_log_boots" default:"5"`
 }
 
 func newHypervisor() interface{} {
@@ -16,6 +16,9 @@ type router struct {
 	HandshakeTimeout    int    `bson:"handshake_timeout" default:"10"`
 	ContinueTimeout     int    `bson:"continue_timeout" default:"10"`
 	SkipVerify          bool   `bson:"skip_verify"`
+	AccessLogPath       string `bson:"access_log_path" default:"/var/log/pritunl-cloud-access.log"`
+	AccessLogSize       int    `bson:"access_log_size" default:"50000000"`
+	AccessLogBuffer     int    `bson:"access_log_buffer" default:"4096"`
 }
 
 func newRouter() interface{} {
@@ -13,6 +13,8 @@ import (
 	"github.com/pritunl/pritunl-cloud/domain"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/firewall"
+	"github.com/pritunl/pritunl-cloud/floatingip"
+	"github.com/pritunl/pritunl-cloud/gateway"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/qemu"
@@ -42,8 +44,13 @@ type State struct {
 	instancesMap     map[primitive.ObjectID]*instance.Instance
 	instanceDisks    map[primitive.ObjectID][]*disk.Disk
 	domainRecordsMap map[primitive.ObjectID][]*domain.Record
+	domainsMap       map[primitive.ObjectID]*domain.Domain
+	orgDomainsMap    map[primitive.ObjectID][]*domain.Domain
 	vpcs             []*vpc.Vpc
 	vpcsMap          map[primitive.ObjectID]*vpc.Vpc
+	vpcInstancesMap  map[primitive.ObjectID][]*instance.Instance
+	gateways         []*gateway.Gateway
+	floatingIpsMap   map[primitive.ObjectID]*floatingip.FloatingIp
 	addInstances     set.Set
 	remInstances     set.Set
 	running          []string
@@ -101,6 +108,14 @@ func (s *State) DomainRecords(instId primitive.ObjectID) []*domain.Record {
 	return s.domainRecordsMap[instId]
 }
 
+func (s *State) Domain(domnId primitive.ObjectID) *domain.Domain {
+	return s.domainsMap[domnId]
+}
+
+func (s *State) OrgDomains(orgId primitive.ObjectID) []*domain.Domain {
+	return s.orgDomainsMap[orgId]
+}
+
 func (s *State) Running() []string {
 	return s.running
 }
@@ -121,6 +136,33 @@ func (s *State) Vpcs() []*vpc.Vpc {
 	return s.vpcs
 }
 
+func (s *State) VpcInstances(
+	vpcId primitive.ObjectID) []*instance.Instance {
+
+	return s.vpcInstancesMap[vpcId]
+}
+
+func (s *State) Gateways() []*gateway.Gateway {
+	return s.gateways
+}
+
+func (s *State) GetSubnetGateway(vpcId,
+	subId primitive.ObjectID) *gateway.Gateway {
+
+	for _, gw := range s.gateways {
+		if gw.Vpc == vpcId && gw.HasSubnet(subId) {
+			return gw
+		}
+	}
+	return nil
+}
+
+func (s *State) InstanceFloatingIp(
+	instId primitive.ObjectID) *floatingip.FloatingIp {
+
+	return s.floatingIpsMap[instId]
+}
+
 func (s *State) DiskInUse(instId, dskId primitive.ObjectID) bool {
 	curVirt := s.virtsMap[instId]
 
@@ -244,13 +286,26 @@ func (s *State) init() (err error) {
 	s.instances = instances
 
 	instId := set.NewSet()
+	instIds := []primitive.ObjectID{}
 	instancesMap := map[primitive.ObjectID]*instance.Instance{}
 	for _, inst := range instances {
 		instId.Add(inst.Id)
+		instIds = append(instIds, inst.Id)
 		instancesMap[inst.Id] = inst
 	}
 	s.instancesMap = instancesMap
 
+	floatingIps, err := floatingip.GetInstances(db, instIds)
+	if err != nil {
+		return
+	}
+
+	floatingIpsMap := map[primitive.ObjectID]*floatingip.FloatingIp{}
+	for _, fip := range floatingIps {
+		floatingIpsMap[fip.Instance] = fip
+	}
+	s.floatingIpsMap = floatingIpsMap
+
 	curVirts, err := qemu.GetVms(db, instancesMap)
 	if err != nil {
 		return
@@ -290,6 +345,57 @@ func (s *State) init() (err error) {
 	s.vpcs = vpcs
 	s.vpcsMap = vpcsMap
 
+	vpcIds := []primitive.ObjectID{}
+	vpcIdsSet := set.NewSet()
+	for _, inst := range instances {
+		if !vpcIdsSet.Contains(inst.Vpc) {
+			vpcIdsSet.Add(inst.Vpc)
+			vpcIds = append(vpcIds, inst.Vpc)
+		}
+	}
+
+	vpcInstancesMap := map[primitive.ObjectID][]*instance.Instance{}
+	if len(vpcIds) > 0 {
+		vpcInsts, e := instance.GetAll(db, &bson.M{
+			"$or": []*bson.M{
+				&bson.M{
+					"vpc": &bson.M{
+						"$in": vpcIds,
+					},
+				},
+				&bson.M{
+					"network_interfaces.vpc": &bson.M{
+						"$in": vpcIds,
+					},
+				},
+			},
+		})
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, inst := range vpcInsts {
+			vpcInstancesMap[inst.Vpc] = append(
+				vpcInstancesMap[inst.Vpc], inst)
+
+			for _, iface := range inst.NetworkInterfaces {
+				vpcInstancesMap[iface.Vpc] = append(
+					vpcInstancesMap[iface.Vpc], inst)
+			}
+		}
+	}
+	s.vpcInstancesMap = vpcInstancesMap
+
+	gateways := []*gateway.Gateway{}
+	if !s.nodeDatacenter.IsZero() {
+		gateways, err = gateway.GetDatacenter(db, s.nodeDatacenter)
+		if err != nil {
+			return
+		}
+	}
+	s.gateways = gateways
+
 	recrds, err := domain.GetRecordAll(db, &bson.M{
 		"node": s.nodeSelf.Id,
 	})
@@ -306,6 +412,40 @@ func (s *State) init() (err error) {
 	}
 	s.domainRecordsMap = domainRecordsMap
 
+	domainOrgIds := set.NewSet()
+	for _, inst := range s.instances {
+		if !inst.Domain.IsZero() {
+			domainOrgIds.Add(inst.Organization)
+		}
+	}
+
+	domainsMap := map[primitive.ObjectID]*domain.Domain{}
+	orgDomainsMap := map[primitive.ObjectID][]*domain.Domain{}
+	if domainOrgIds.Len() > 0 {
+		orgIds := []primitive.ObjectID{}
+		for orgIdInf := range domainOrgIds.Iter() {
+			orgIds = append(orgIds, orgIdInf.(primitive.ObjectID))
+		}
+
+		domns, e := domain.GetAll(db, &bson.M{
+			"organization": &bson.M{
+				"$in": orgIds,
+			},
+		})
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, domn := range domns {
+			domainsMap[domn.Id] = domn
+			orgDomainsMap[domn.Organization] = append(
+				orgDomainsMap[domn.Organization], domn)
+		}
+	}
+	s.domainsMap = domainsMap
+	s.orgDomainsMap = orgDomainsMap
+
 	items, err := ioutil.ReadDir("/var/run")
 	if err != nil {
 		err = &errortypes.ReadError{
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+)
+
+var (
+	floatingStores     = map[primitive.ObjectID]FloatingStore{}
+	floatingStoresLock = sync.Mutex{}
+)
+
+type FloatingStore struct {
+	Address   string
+	Timestamp time.Time
+}
+
+func GetFloating(instId primitive.ObjectID) (
+	floatingStore FloatingStore, ok bool) {
+
+	floatingStoresLock.Lock()
+	floatingStore, ok = floatingStores[instId]
+	floatingStoresLock.Unlock()
+
+	return
+}
+
+func SetFloating(instId primitive.ObjectID, floatAddr string) {
+	floatingStoresLock.Lock()
+	floatingStores[instId] = FloatingStore{
+		Address:   floatAddr,
+		Timestamp: time.Now(),
+	}
+	floatingStoresLock.Unlock()
+}
+
+func RemFloating(instId primitive.ObjectID) {
+	floatingStoresLock.Lock()
+	delete(floatingStores, instId)
+	floatingStoresLock.Unlock()
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+)
+
+var (
+	gatewayStores     = map[primitive.ObjectID]GatewayStore{}
+	gatewayStoresLock = sync.Mutex{}
+)
+
+type GatewayStore struct {
+	Gateway   string
+	Timestamp time.Time
+}
+
+func GetGateway(instId primitive.ObjectID) (
+	gatewayStore GatewayStore, ok bool) {
+
+	gatewayStoresLock.Lock()
+	gatewayStore, ok = gatewayStores[instId]
+	gatewayStoresLock.Unlock()
+
+	return
+}
+
+func SetGateway(instId primitive.ObjectID, gateway string) {
+	gatewayStoresLock.Lock()
+	gatewayStores[instId] = GatewayStore{
+		Gateway:   gateway,
+		Timestamp: time.Now(),
+	}
+	gatewayStoresLock.Unlock()
+}
+
+func RemGateway(instId primitive.ObjectID) {
+	gatewayStoresLock.Lock()
+	delete(gatewayStores, instId)
+	gatewayStoresLock.Unlock()
+}
@@ -0,0 +1,39 @@
+package sync
+
+import (
+	"time"
+
+	"github.com/pritunl/pritunl-cloud/nat"
+	"github.com/sirupsen/logrus"
+)
+
+func natRunner() {
+	for {
+		err := nat.InitState()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("sync: Failed to init nat state")
+
+			time.Sleep(2 * time.Second)
+			continue
+		}
+
+		break
+	}
+
+	for {
+		time.Sleep(2 * time.Second)
+
+		err := nat.SyncState()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("sync: Failed to sync nat state")
+		}
+	}
+}
+
+func initNat() {
+	go natRunner()
+}
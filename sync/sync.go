@@ -5,4 +5,5 @@ func Init() {
 	initNode()
 	initVm()
 	initLink()
+	initNat()
 }
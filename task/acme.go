@@ -21,7 +21,7 @@ func acmeRenewHandler(db *database.Database) (err error) {
 	}
 
 	for _, cert := range certs {
-		if cert.Type != certificate.LetsEncrypt {
+		if cert.Type != certificate.LetsEncrypt || !cert.Balancer.IsZero() {
 			continue
 		}
 
@@ -47,6 +47,23 @@ func acmeRenewHandler(db *database.Database) (err error) {
 	return
 }
 
+var acmeBalancer = &Task{
+	Name:    "acme_balancer",
+	Hours:   AllHours,
+	Mins:    FiveMins,
+	Handler: acmeBalancerHandler,
+}
+
+func acmeBalancerHandler(db *database.Database) (err error) {
+	err = acme.SyncBalancers(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func init() {
 	register(acmeRenew)
+	register(acmeBalancer)
 }
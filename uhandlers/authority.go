@@ -9,7 +9,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
 	"github.com/pritunl/pritunl-cloud/authority"
+	"github.com/pritunl/pritunl-cloud/authorizer"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/event"
@@ -25,6 +27,12 @@ type authorityData struct {
 	Key          string             `json:"key"`
 	Roles        []string           `json:"roles"`
 	Certificate  string             `json:"certificate"`
+	Signing      bool               `json:"signing"`
+	Expire       int                `json:"expire"`
+}
+
+type authoritySignData struct {
+	PublicKey string `json:"public_key"`
 }
 
 type authoritiesData struct {
@@ -66,6 +74,8 @@ func authorityPut(c *gin.Context) {
 	fire.Key = data.Key
 	fire.Roles = data.Roles
 	fire.Certificate = data.Certificate
+	fire.Signing = data.Signing
+	fire.Expire = data.Expire
 
 	fields := set.NewSet(
 		"name",
@@ -76,6 +86,9 @@ func authorityPut(c *gin.Context) {
 		"key",
 		"roles",
 		"certificate",
+		"signing",
+		"expire",
+		"private_key",
 	)
 
 	errData, err := fire.Validate(db)
@@ -126,6 +139,8 @@ func authorityPost(c *gin.Context) {
 		Key:          data.Key,
 		Roles:        data.Roles,
 		Certificate:  data.Certificate,
+		Signing:      data.Signing,
+		Expire:       data.Expire,
 	}
 
 	errData, err := fire.Validate(db)
@@ -150,6 +165,72 @@ func authorityPost(c *gin.Context) {
 	c.JSON(200, fire)
 }
 
+func authoritySignPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := &authoritySignData{}
+
+	authorityId, ok := utils.ParseObjectId(c.Param("authority_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	fire, err := authority.GetOrg(db, userOrg, authorityId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	cert, errData, err := fire.Sign(usr.Username, usr.Roles, data.PublicKey)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		audit.UserSshCertificate,
+		audit.Fields{
+			"authority_id": fire.Id,
+			"serial":       strconv.FormatUint(cert.Serial, 10),
+			"fingerprint":  cert.Fingerprint,
+			"principals":   cert.Principals,
+			"expires":      cert.Expires,
+		},
+	)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, cert)
+}
+
 func authorityDelete(c *gin.Context) {
 	if demo.Blocked(c) {
 		return
@@ -18,17 +18,39 @@ import (
 )
 
 type balancerData struct {
-	Id           primitive.ObjectID   `json:"id"`
-	Name         string               `json:"name"`
-	Comment      string               `json:"comment"`
-	State        bool                 `json:"state"`
-	Type         string               `json:"type"`
-	Datacenter   primitive.ObjectID   `json:"datacenter"`
-	Certificates []primitive.ObjectID `json:"certificates"`
-	WebSockets   bool                 `json:"websockets"`
-	Domains      []*balancer.Domain   `json:"domains"`
-	Backends     []*balancer.Backend  `json:"backends"`
-	CheckPath    string               `json:"check_path"`
+	Id             primitive.ObjectID    `json:"id"`
+	Name           string                `json:"name"`
+	Comment        string                `json:"comment"`
+	State          bool                  `json:"state"`
+	Type           string                `json:"type"`
+	Datacenter     primitive.ObjectID    `json:"datacenter"`
+	Certificates   []primitive.ObjectID  `json:"certificates"`
+	AutoCert       bool                  `json:"auto_cert"`
+	WebSockets     bool                  `json:"websockets"`
+	BackendMtls    bool                  `json:"backend_mtls"`
+	Domains        []*balancer.Domain    `json:"domains"`
+	Backends       []*balancer.Backend   `json:"backends"`
+	Listeners      []*balancer.Listener  `json:"listeners"`
+	Rules          []*balancer.Rule      `json:"rules"`
+	CheckType      string                `json:"check_type"`
+	CheckPath      string                `json:"check_path"`
+	CheckInterval  int                   `json:"check_interval"`
+	CheckTimeout   int                   `json:"check_timeout"`
+	CheckHealthy   int                   `json:"check_healthy"`
+	CheckUnhealthy int                   `json:"check_unhealthy"`
+	CheckCodes     []int                 `json:"check_codes"`
+	CheckBody      string                `json:"check_body"`
+	CheckHost      string                `json:"check_host"`
+	Algorithm      string                `json:"algorithm"`
+	HashSource     string                `json:"hash_source"`
+	HashKey        string                `json:"hash_key"`
+	AccessLog      string                `json:"access_log"`
+	RateLimits     []*balancer.RateLimit `json:"rate_limits"`
+	AllowCidrs     []string              `json:"allow_cidrs"`
+	DenyCidrs      []string              `json:"deny_cidrs"`
+	AllowCountries []string              `json:"allow_countries"`
+	DenyCountries  []string              `json:"deny_countries"`
+	MaxBodySize    int64                 `json:"max_body_size"`
 }
 
 type balancersData struct {
@@ -69,10 +91,32 @@ func balancerPut(c *gin.Context) {
 	balnc.Type = data.Type
 	balnc.Datacenter = data.Datacenter
 	balnc.Certificates = data.Certificates
+	balnc.AutoCert = data.AutoCert
 	balnc.WebSockets = data.WebSockets
+	balnc.BackendMtls = data.BackendMtls
 	balnc.Domains = data.Domains
 	balnc.Backends = data.Backends
+	balnc.Listeners = data.Listeners
+	balnc.Rules = data.Rules
+	balnc.CheckType = data.CheckType
 	balnc.CheckPath = data.CheckPath
+	balnc.CheckInterval = data.CheckInterval
+	balnc.CheckTimeout = data.CheckTimeout
+	balnc.CheckHealthy = data.CheckHealthy
+	balnc.CheckUnhealthy = data.CheckUnhealthy
+	balnc.CheckCodes = data.CheckCodes
+	balnc.CheckBody = data.CheckBody
+	balnc.CheckHost = data.CheckHost
+	balnc.Algorithm = data.Algorithm
+	balnc.HashSource = data.HashSource
+	balnc.HashKey = data.HashKey
+	balnc.AccessLog = data.AccessLog
+	balnc.RateLimits = data.RateLimits
+	balnc.AllowCidrs = data.AllowCidrs
+	balnc.DenyCidrs = data.DenyCidrs
+	balnc.AllowCountries = data.AllowCountries
+	balnc.DenyCountries = data.DenyCountries
+	balnc.MaxBodySize = data.MaxBodySize
 
 	exists, err := datacenter.ExistsOrg(db, userOrg, balnc.Datacenter)
 	if err != nil {
@@ -91,10 +135,33 @@ func balancerPut(c *gin.Context) {
 		"type",
 		"datacenter",
 		"certificates",
+		"auto_cert",
 		"websockets",
+		"backend_mtls",
 		"domains",
 		"backends",
+		"listeners",
+		"rules",
+		"check_type",
 		"check_path",
+		"check_interval",
+		"check_timeout",
+		"check_healthy",
+		"check_unhealthy",
+		"check_codes",
+		"check_body",
+		"check_host",
+		"algorithm",
+		"hash_source",
+		"hash_key",
+		"access_log",
+		"access_log_server",
+		"rate_limits",
+		"allow_cidrs",
+		"deny_cidrs",
+		"allow_countries",
+		"deny_countries",
+		"max_body_size",
 	)
 
 	errData, err := balnc.Validate(db)
@@ -139,17 +206,39 @@ func balancerPost(c *gin.Context) {
 	}
 
 	balnc := &balancer.Balancer{
-		Name:         data.Name,
-		Comment:      data.Comment,
-		State:        data.State,
-		Type:         data.Type,
-		Organization: userOrg,
-		Datacenter:   data.Datacenter,
-		Certificates: data.Certificates,
-		WebSockets:   data.WebSockets,
-		Domains:      data.Domains,
-		Backends:     data.Backends,
-		CheckPath:    data.CheckPath,
+		Name:           data.Name,
+		Comment:        data.Comment,
+		State:          data.State,
+		Type:           data.Type,
+		Organization:   userOrg,
+		Datacenter:     data.Datacenter,
+		Certificates:   data.Certificates,
+		AutoCert:       data.AutoCert,
+		WebSockets:     data.WebSockets,
+		BackendMtls:    data.BackendMtls,
+		Domains:        data.Domains,
+		Backends:       data.Backends,
+		Listeners:      data.Listeners,
+		Rules:          data.Rules,
+		CheckType:      data.CheckType,
+		CheckPath:      data.CheckPath,
+		CheckInterval:  data.CheckInterval,
+		CheckTimeout:   data.CheckTimeout,
+		CheckHealthy:   data.CheckHealthy,
+		CheckUnhealthy: data.CheckUnhealthy,
+		CheckCodes:     data.CheckCodes,
+		CheckBody:      data.CheckBody,
+		CheckHost:      data.CheckHost,
+		Algorithm:      data.Algorithm,
+		HashSource:     data.HashSource,
+		HashKey:        data.HashKey,
+		AccessLog:      data.AccessLog,
+		RateLimits:     data.RateLimits,
+		AllowCidrs:     data.AllowCidrs,
+		DenyCidrs:      data.DenyCidrs,
+		AllowCountries: data.AllowCountries,
+		DenyCountries:  data.DenyCountries,
+		MaxBodySize:    data.MaxBodySize,
 	}
 
 	exists, err := datacenter.ExistsOrg(db, userOrg, balnc.Datacenter)
@@ -1,14 +1,100 @@
 package uhandlers
 
 import (
+	"github.com/dropbox/godropbox/container/set"
 	"github.com/gin-gonic/gin"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/domain"
+	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
+type domainRecordsData struct {
+	Records []*domain.StaticRecord `json:"records"`
+}
+
+func domainRecordsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	domainId, ok := utils.ParseObjectId(c.Param("domain_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	domn, err := domain.GetOrg(db, userOrg, domainId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	records := domn.Records
+	if records == nil {
+		records = []*domain.StaticRecord{}
+	}
+
+	c.JSON(200, &domainRecordsData{
+		Records: records,
+	})
+}
+
+func domainRecordsPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := &domainRecordsData{}
+
+	domainId, ok := utils.ParseObjectId(c.Param("domain_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	domn, err := domain.GetOrg(db, userOrg, domainId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	domn.Records = data.Records
+
+	fields := set.NewSet(
+		"records",
+		"records_checked",
+	)
+
+	errData := domn.ValidateRecords()
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = domn.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "domain.change")
+
+	c.JSON(200, &domainRecordsData{
+		Records: domn.Records,
+	})
+}
+
 func domainsGet(c *gin.Context) {
 	db := c.MustGet("db").(*database.Database)
 	userOrg := c.MustGet("organization").(primitive.ObjectID)
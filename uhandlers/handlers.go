@@ -55,6 +55,7 @@ func Register(engine *gin.Engine) {
 	orgGroup.GET("/authority/:authority_id", authorityGet)
 	orgGroup.PUT("/authority/:authority_id", authorityPut)
 	orgGroup.POST("/authority", authorityPost)
+	orgGroup.POST("/authority/:authority_id/sign", authoritySignPost)
 	orgGroup.DELETE("/authority", authoritiesDelete)
 	orgGroup.DELETE("/authority/:authority_id", authorityDelete)
 
@@ -87,6 +88,8 @@ func Register(engine *gin.Engine) {
 	csrfGroup.POST("/device/:device_id/register", deviceU2fRegisterPost)
 
 	orgGroup.GET("/domain", domainsGet)
+	orgGroup.GET("/domain/:domain_id/records", domainRecordsGet)
+	orgGroup.PUT("/domain/:domain_id/records", domainRecordsPut)
 
 	orgGroup.GET("/disk", disksGet)
 	orgGroup.GET("/disk/:disk_id", diskGet)
@@ -105,6 +108,20 @@ func Register(engine *gin.Engine) {
 	orgGroup.DELETE("/firewall", firewallsDelete)
 	orgGroup.DELETE("/firewall/:firewall_id", firewallDelete)
 
+	orgGroup.GET("/floating_ip", floatingIpsGet)
+	orgGroup.GET("/floating_ip/:floating_ip_id", floatingIpGet)
+	orgGroup.PUT("/floating_ip/:floating_ip_id", floatingIpPut)
+	orgGroup.POST("/floating_ip", floatingIpPost)
+	orgGroup.DELETE("/floating_ip", floatingIpsDelete)
+	orgGroup.DELETE("/floating_ip/:floating_ip_id", floatingIpDelete)
+
+	orgGroup.GET("/gateway", gatewaysGet)
+	orgGroup.GET("/gateway/:gateway_id", gatewayGet)
+	orgGroup.PUT("/gateway/:gateway_id", gatewayPut)
+	orgGroup.POST("/gateway", gatewayPost)
+	orgGroup.DELETE("/gateway", gatewaysDelete)
+	orgGroup.DELETE("/gateway/:gateway_id", gatewayDelete)
+
 	orgGroup.GET("/image", imagesGet)
 	orgGroup.GET("/image/:image_id", imageGet)
 	orgGroup.PUT("/image/:image_id", imagePut)
@@ -115,6 +132,8 @@ func Register(engine *gin.Engine) {
 	orgGroup.PUT("/instance", instancesPut)
 	orgGroup.GET("/instance/:instance_id", instanceGet)
 	orgGroup.GET("/instance/:instance_id/vnc", instanceVncGet)
+	orgGroup.GET("/instance/:instance_id/serial", instanceSerialGet)
+	orgGroup.GET("/instance/:instance_id/console-log", instanceConsoleLogGet)
 	orgGroup.PUT("/instance/:instance_id", instancePut)
 	orgGroup.POST("/instance", instancePost)
 	orgGroup.DELETE("/instance", instancesDelete)
@@ -133,6 +152,9 @@ func Register(engine *gin.Engine) {
 	orgGroup.PUT("/vpc/:vpc_id", vpcPut)
 	orgGroup.GET("/vpc/:vpc_id/routes", vpcRoutesGet)
 	orgGroup.PUT("/vpc/:vpc_id/routes", vpcRoutesPut)
+	orgGroup.GET("/vpc/:vpc_id/client", vpcClientsGet)
+	orgGroup.POST("/vpc/:vpc_id/client", vpcClientPost)
+	orgGroup.DELETE("/vpc/:vpc_id/client/:client_id", vpcClientDelete)
 	orgGroup.POST("/vpc", vpcPost)
 	orgGroup.DELETE("/vpc", vpcsDelete)
 	orgGroup.DELETE("/vpc/:vpc_id", vpcDelete)
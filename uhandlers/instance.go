@@ -11,6 +11,8 @@ import (
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/aggregate"
+	"github.com/pritunl/pritunl-cloud/audit"
+	"github.com/pritunl/pritunl-cloud/authorizer"
 	"github.com/pritunl/pritunl-cloud/data"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/datacenter"
@@ -21,6 +23,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/image"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/serial"
 	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/usb"
 	"github.com/pritunl/pritunl-cloud/utils"
@@ -30,27 +33,34 @@ import (
 )
 
 type instanceData struct {
-	Id               primitive.ObjectID `json:"id"`
-	Zone             primitive.ObjectID `json:"zone"`
-	Vpc              primitive.ObjectID `json:"vpc"`
-	Subnet           primitive.ObjectID `json:"subnet"`
-	Node             primitive.ObjectID `json:"node"`
-	Image            primitive.ObjectID `json:"image"`
-	ImageBacking     bool               `json:"image_backing"`
-	Domain           primitive.ObjectID `json:"domain"`
-	Name             string             `json:"name"`
-	Comment          string             `json:"comment"`
-	State            string             `json:"state"`
-	DeleteProtection bool               `json:"delete_protection"`
-	InitDiskSize     int                `json:"init_disk_size"`
-	Memory           int                `json:"memory"`
-	Processors       int                `json:"processors"`
-	NetworkRoles     []string           `json:"network_roles"`
-	UsbDevices       []*usb.Device      `json:"usb_devices"`
-	Vnc              bool               `json:"vnc"`
-	NoPublicAddress  bool               `json:"no_public_address"`
-	NoHostAddress    bool               `json:"no_host_address"`
-	Count            int                `json:"count"`
+	Id                primitive.ObjectID           `json:"id"`
+	Zone              primitive.ObjectID           `json:"zone"`
+	Vpc               primitive.ObjectID           `json:"vpc"`
+	Subnet            primitive.ObjectID           `json:"subnet"`
+	PrivateIp         string                       `json:"private_ip"`
+	Node              primitive.ObjectID           `json:"node"`
+	Image             primitive.ObjectID           `json:"image"`
+	ImageBacking      bool                         `json:"image_backing"`
+	Domain            primitive.ObjectID           `json:"domain"`
+	DomainNames       []string                     `json:"domain_names"`
+	DomainAliases     []string                     `json:"domain_aliases"`
+	DomainTtl         int                          `json:"domain_ttl"`
+	DomainPtr         bool                         `json:"domain_ptr"`
+	GuestCertificate  bool                         `json:"guest_certificate"`
+	Name              string                       `json:"name"`
+	Comment           string                       `json:"comment"`
+	State             string                       `json:"state"`
+	DeleteProtection  bool                         `json:"delete_protection"`
+	InitDiskSize      int                          `json:"init_disk_size"`
+	Memory            int                          `json:"memory"`
+	Processors        int                          `json:"processors"`
+	NetworkRoles      []string                     `json:"network_roles"`
+	NetworkInterfaces []*instance.NetworkInterface `json:"network_interfaces"`
+	UsbDevices        []*usb.Device                `json:"usb_devices"`
+	Vnc               bool                         `json:"vnc"`
+	NoPublicAddress   bool                         `json:"no_public_address"`
+	NoHostAddress     bool                         `json:"no_host_address"`
+	Count             int                          `json:"count"`
 }
 
 type instanceMultiData struct {
@@ -121,6 +131,7 @@ func instancePut(c *gin.Context) {
 	inst.Comment = dta.Comment
 	inst.Vpc = dta.Vpc
 	inst.Subnet = dta.Subnet
+	inst.PrivateIp = dta.PrivateIp
 	if dta.State != "" {
 		inst.State = dta.State
 	}
@@ -128,9 +139,15 @@ func instancePut(c *gin.Context) {
 	inst.Memory = dta.Memory
 	inst.Processors = dta.Processors
 	inst.NetworkRoles = dta.NetworkRoles
+	inst.NetworkInterfaces = dta.NetworkInterfaces
 	inst.UsbDevices = dta.UsbDevices
 	inst.Vnc = dta.Vnc
 	inst.Domain = dta.Domain
+	inst.DomainNames = dta.DomainNames
+	inst.DomainAliases = dta.DomainAliases
+	inst.DomainTtl = dta.DomainTtl
+	inst.DomainPtr = dta.DomainPtr
+	inst.GuestCertificate = dta.GuestCertificate
 	inst.NoPublicAddress = dta.NoPublicAddress
 	inst.NoHostAddress = dta.NoHostAddress
 
@@ -139,6 +156,7 @@ func instancePut(c *gin.Context) {
 		"comment",
 		"vpc",
 		"subnet",
+		"private_ip",
 		"state",
 		"restart",
 		"restart_block_ip",
@@ -146,11 +164,17 @@ func instancePut(c *gin.Context) {
 		"memory",
 		"processors",
 		"network_roles",
+		"network_interfaces",
 		"usb_devices",
 		"vnc",
 		"vnc_display",
 		"vnc_password",
 		"domain",
+		"domain_names",
+		"domain_aliases",
+		"domain_ttl",
+		"domain_ptr",
+		"guest_certificate",
 		"no_public_address",
 		"no_host_address",
 	)
@@ -303,6 +327,15 @@ func instancePost(c *gin.Context) {
 		dta.Count = 1
 	}
 
+	if dta.Count > 1 && dta.PrivateIp != "" {
+		errData := &errortypes.ErrorData{
+			Error:   "private_ip_count_invalid",
+			Message: "Private IP cannot be set with multiple instances",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
 	for i := 0; i < dta.Count; i++ {
 		name := ""
 		if strings.Contains(dta.Name, "%") {
@@ -312,26 +345,33 @@ func instancePost(c *gin.Context) {
 		}
 
 		inst := &instance.Instance{
-			State:            dta.State,
-			Organization:     userOrg,
-			Zone:             dta.Zone,
-			Vpc:              dta.Vpc,
-			Subnet:           dta.Subnet,
-			Node:             dta.Node,
-			Image:            dta.Image,
-			ImageBacking:     dta.ImageBacking,
-			DeleteProtection: dta.DeleteProtection,
-			Name:             name,
-			Comment:          dta.Comment,
-			InitDiskSize:     dta.InitDiskSize,
-			Memory:           dta.Memory,
-			Processors:       dta.Processors,
-			NetworkRoles:     dta.NetworkRoles,
-			UsbDevices:       dta.UsbDevices,
-			Vnc:              dta.Vnc,
-			Domain:           dta.Domain,
-			NoPublicAddress:  dta.NoPublicAddress,
-			NoHostAddress:    dta.NoHostAddress,
+			State:             dta.State,
+			Organization:      userOrg,
+			Zone:              dta.Zone,
+			Vpc:               dta.Vpc,
+			Subnet:            dta.Subnet,
+			PrivateIp:         dta.PrivateIp,
+			Node:              dta.Node,
+			Image:             dta.Image,
+			ImageBacking:      dta.ImageBacking,
+			DeleteProtection:  dta.DeleteProtection,
+			Name:              name,
+			Comment:           dta.Comment,
+			InitDiskSize:      dta.InitDiskSize,
+			Memory:            dta.Memory,
+			Processors:        dta.Processors,
+			NetworkRoles:      dta.NetworkRoles,
+			NetworkInterfaces: dta.NetworkInterfaces,
+			UsbDevices:        dta.UsbDevices,
+			Vnc:               dta.Vnc,
+			Domain:            dta.Domain,
+			DomainNames:       dta.DomainNames,
+			DomainAliases:     dta.DomainAliases,
+			DomainTtl:         dta.DomainTtl,
+			DomainPtr:         dta.DomainPtr,
+			GuestCertificate:  dta.GuestCertificate,
+			NoPublicAddress:   dta.NoPublicAddress,
+			NoHostAddress:     dta.NoHostAddress,
 		}
 
 		errData, err := inst.Validate(db)
@@ -637,3 +677,94 @@ func instanceVncGet(c *gin.Context) {
 		return
 	}
 }
+
+func instanceSerialGet(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	inst, err := instance.GetOrg(db, userOrg, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		audit.UserSerialConsole,
+		audit.Fields{
+			"instance_id": inst.Id,
+		},
+	)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = serial.Connect(db, inst.Id, inst.Node, usr.Id,
+		c.Writer, c.Request)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+}
+
+func instanceConsoleLogGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	boot := 0
+	bootStr := c.Query("boot")
+	if bootStr != "" {
+		boot, _ = strconv.Atoi(bootStr)
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	inst, err := instance.GetOrg(db, userOrg, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data, err := serial.ConsoleLog(db, inst.Id, inst.Node, usr.Id, boot)
+	if err != nil {
+		if _, ok := err.(*errortypes.NotFoundError); ok {
+			utils.AbortWithStatus(c, 404)
+			return
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.Data(200, "text/plain; charset=utf-8", data)
+}
@@ -0,0 +1,159 @@
+package uhandlers
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/authorizer"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/pritunl/pritunl-cloud/vpnclient"
+)
+
+type vpnClientData struct {
+	Name string `json:"name"`
+}
+
+type vpnClientConfData struct {
+	Client *vpnclient.VpnClient `json:"client"`
+	Config string               `json:"config"`
+}
+
+func vpcClientsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	vpcId, ok := utils.ParseObjectId(c.Param("vpc_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	clnts, err := vpnclient.GetAll(db, &bson.M{
+		"organization": userOrg,
+		"vpc":          vpcId,
+		"user":         usr.Id,
+	})
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, clnts)
+}
+
+func vpcClientPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := &vpnClientData{}
+
+	vpcId, ok := utils.ParseObjectId(c.Param("vpc_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "handler: Bind error"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	vc, err := vpc.GetOrg(db, userOrg, vpcId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if !vc.ClientAllowed(usr.Roles) {
+		utils.AbortWithStatus(c, 401)
+		return
+	}
+
+	if vc.WgEndpoint == "" || vc.WgPublicKey == "" {
+		errData := &errortypes.ErrorData{
+			Error:   "client_vpn_unavailable",
+			Message: "VPC client VPN not available",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	clnt, privKey, err := vpnclient.New(db, vc, usr, data.Name)
+	if err != nil {
+		if _, ok := err.(*errortypes.NotFoundError); ok {
+			errData := &errortypes.ErrorData{
+				Error:   "client_pool_full",
+				Message: "VPC client pool full",
+			}
+			c.JSON(400, errData)
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	event.PublishDispatch(db, "vpn_client.change")
+
+	c.JSON(200, &vpnClientConfData{
+		Client: clnt,
+		Config: clnt.GetConfig(vc, privKey),
+	})
+}
+
+func vpcClientDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+
+	clientId, ok := utils.ParseObjectId(c.Param("client_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = vpnclient.RemoveUser(db, usr.Id, clientId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "vpn_client.change")
+
+	c.JSON(200, nil)
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"strings"
+
 	"github.com/dropbox/godropbox/container/set"
 )
 
@@ -99,3 +101,26 @@ func FilterStr(s string, n int) string {
 
 	return ns
 }
+
+func FilterDomain(s string) string {
+	if len(s) == 0 {
+		return ""
+	}
+
+	s = strings.ToLower(s)
+
+	ns := ""
+	for _, c := range s {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			ns += string(c)
+		} else if c == '-' || c == '_' || c == ' ' || c == '.' {
+			ns += "-"
+		}
+	}
+
+	if len(ns) > 63 {
+		ns = ns[:63]
+	}
+
+	return strings.Trim(ns, "-")
+}
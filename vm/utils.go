@@ -157,6 +157,18 @@ func GetNamespace(id primitive.ObjectID, n int) string {
 	return fmt.Sprintf("n%s%d", strings.ToLower(hashSum), n)
 }
 
+func GetAdapterIfaceVirtInternal(id primitive.ObjectID, n int) string {
+	return GetIfaceVirt(id, 3+n)
+}
+
+func GetAdapterIfaceVirtExternal(id primitive.ObjectID, n int) string {
+	return GetIfaceVirt(id, 6+n)
+}
+
+func GetAdapterIfaceExternal(id primitive.ObjectID, n int) string {
+	return GetIfaceExternal(id, 1+n)
+}
+
 func GetLinkIfaceExternal(id primitive.ObjectID, n int) string {
 	hash := md5.New()
 	hash.Write([]byte(id.Hex()))
@@ -198,3 +210,38 @@ func GetHostBridgeIface(parentIface string) string {
 	hashSum := base32.StdEncoding.EncodeToString(hash.Sum(nil))[:12]
 	return fmt.Sprintf("b%s0", strings.ToLower(hashSum))
 }
+
+func GetGatewayNamespace(id primitive.ObjectID, n int) string {
+	hash := md5.New()
+	hash.Write([]byte(id.Hex()))
+	hashSum := base32.StdEncoding.EncodeToString(hash.Sum(nil))[:12]
+	return fmt.Sprintf("g%s%d", strings.ToLower(hashSum), n)
+}
+
+func GetGatewayIfaceExternal(id primitive.ObjectID, n int) string {
+	hash := md5.New()
+	hash.Write([]byte(id.Hex()))
+	hashSum := base32.StdEncoding.EncodeToString(hash.Sum(nil))[:12]
+	return fmt.Sprintf("j%s%d", strings.ToLower(hashSum), n)
+}
+
+func GetGatewayIfaceInternal(id primitive.ObjectID, n int) string {
+	hash := md5.New()
+	hash.Write([]byte(id.Hex()))
+	hashSum := base32.StdEncoding.EncodeToString(hash.Sum(nil))[:12]
+	return fmt.Sprintf("m%s%d", strings.ToLower(hashSum), n)
+}
+
+func GetGatewayIfaceVirt(id primitive.ObjectID, n int) string {
+	hash := md5.New()
+	hash.Write([]byte(id.Hex()))
+	hashSum := base32.StdEncoding.EncodeToString(hash.Sum(nil))[:12]
+	return fmt.Sprintf("u%s%d", strings.ToLower(hashSum), n)
+}
+
+func GetGatewayIfaceVlan(id primitive.ObjectID, n int) string {
+	hash := md5.New()
+	hash.Write([]byte(id.Hex()))
+	hashSum := base32.StdEncoding.EncodeToString(hash.Sum(nil))[:12]
+	return fmt.Sprintf("o%s%d", strings.ToLower(hashSum), n)
+}
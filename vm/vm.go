@@ -56,12 +56,14 @@ func (d *Disk) Copy() (dsk *Disk) {
 }
 
 type NetworkAdapter struct {
-	Type       string             `json:"type"`
-	MacAddress string             `json:"mac_address"`
-	Vpc        primitive.ObjectID `json:"vpc"`
-	Subnet     primitive.ObjectID `json:"subnet"`
-	IpAddress  string             `json:"ip_address,omitempty"`
-	IpAddress6 string             `json:"ip_address6,omitempty"`
+	Type          string             `json:"type"`
+	MacAddress    string             `json:"mac_address"`
+	Vpc           primitive.ObjectID `json:"vpc"`
+	Subnet        primitive.ObjectID `json:"subnet"`
+	Interface     primitive.ObjectID `json:"interface,omitempty"`
+	PublicAddress bool               `json:"public_address,omitempty"`
+	IpAddress     string             `json:"ip_address,omitempty"`
+	IpAddress6    string             `json:"ip_address6,omitempty"`
 }
 
 func (v *VirtualMachine) Commit(db *database.Database) (err error) {
@@ -1,6 +1,93 @@
 package vpc
 
+import (
+	"github.com/dropbox/godropbox/container/set"
+)
+
 const (
 	Instance = "instance"
 	Gateway  = "gateway"
+
+	VpnPsk         = "psk"
+	VpnCertificate = "certificate"
+
+	VpnConnected    = "connected"
+	VpnConnecting   = "connecting"
+	VpnDisconnected = "disconnected"
+
+	Ikev1 = "ikev1"
+	Ikev2 = "ikev2"
+
+	DpdRestart = "restart"
+	DpdClear   = "clear"
+	DpdHold    = "hold"
+	DpdNone    = "none"
+
+	DefaultIkeLifetime = 28800
+	DefaultKeyLifetime = 3600
+	DefaultRekeyMargin = 540
+	DefaultDpdDelay    = 5
+	DefaultDpdTimeout  = 20
+
+	DefaultWgPort = 51820
+
+	WgHandshakeTimeout = 180
+)
+
+var (
+	ValidDpdActions = set.NewSet(
+		DpdRestart,
+		DpdClear,
+		DpdHold,
+		DpdNone,
+	)
+	EncryptionAlgorithms = set.NewSet(
+		"aes128",
+		"aes192",
+		"aes256",
+		"aes128ctr",
+		"aes192ctr",
+		"aes256ctr",
+		"aes128gcm16",
+		"aes192gcm16",
+		"aes256gcm16",
+		"camellia128",
+		"camellia192",
+		"camellia256",
+		"chacha20poly1305",
+	)
+	AeadAlgorithms = set.NewSet(
+		"aes128gcm16",
+		"aes192gcm16",
+		"aes256gcm16",
+		"chacha20poly1305",
+	)
+	IntegrityAlgorithms = set.NewSet(
+		"sha1",
+		"sha256",
+		"sha384",
+		"sha512",
+		"aesxcbc",
+	)
+	PrfAlgorithms = set.NewSet(
+		"prfsha1",
+		"prfsha256",
+		"prfsha384",
+		"prfsha512",
+		"prfaesxcbc",
+	)
+	DhGroups = set.NewSet(
+		"modp1024",
+		"modp1536",
+		"modp2048",
+		"modp3072",
+		"modp4096",
+		"modp6144",
+		"modp8192",
+		"ecp256",
+		"ecp384",
+		"ecp521",
+		"curve25519",
+		"curve448",
+	)
 )
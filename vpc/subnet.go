@@ -2,6 +2,7 @@ package vpc
 
 import (
 	"net"
+	"strings"
 
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
@@ -10,9 +11,19 @@ import (
 )
 
 type Subnet struct {
-	Id      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name    string             `bson:"name" json:"name"`
-	Network string             `bson:"network" json:"network"`
+	Id       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name     string             `bson:"name" json:"name"`
+	Network  string             `bson:"network" json:"network"`
+	Reserved []string           `bson:"reserved" json:"reserved"`
+}
+
+type ReservedRange struct {
+	Start int64
+	Stop  int64
+}
+
+func (r *ReservedRange) Contains(index int64) bool {
+	return index >= r.Start && index <= r.Stop
 }
 
 func (s *Subnet) GetNetwork() (network *net.IPNet, err error) {
@@ -44,3 +55,46 @@ func (s *Subnet) GetIndexRange() (start, stop int64, err error) {
 
 	return
 }
+
+func (s *Subnet) GetReservedRanges() (ranges []*ReservedRange, err error) {
+	ranges = []*ReservedRange{}
+
+	if s.Reserved == nil {
+		return
+	}
+
+	for _, reserved := range s.Reserved {
+		start, stop, e := parseReserved(reserved)
+		if e != nil {
+			err = e
+			return
+		}
+
+		ranges = append(ranges, &ReservedRange{
+			Start: utils.IpAddress2Int(start) / 2,
+			Stop:  utils.IpAddress2Int(stop) / 2,
+		})
+	}
+
+	return
+}
+
+func parseReserved(reserved string) (start, stop net.IP, err error) {
+	parts := strings.SplitN(reserved, "-", 2)
+
+	start = net.ParseIP(strings.TrimSpace(parts[0])).To4()
+	if len(parts) > 1 {
+		stop = net.ParseIP(strings.TrimSpace(parts[1])).To4()
+	} else {
+		stop = start
+	}
+
+	if start == nil || stop == nil {
+		err = &errortypes.ParseError{
+			errors.New("vpc: Failed to parse reserved range"),
+		}
+		return
+	}
+
+	return
+}
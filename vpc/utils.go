@@ -316,6 +316,16 @@ func Remove(db *database.Database, vcId primitive.ObjectID) (err error) {
 		return
 	}
 
+	coll = db.VpnClients()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"vpc": vcId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
 	coll = db.Vpcs()
 
 	_, err = coll.DeleteOne(db, &bson.M{
@@ -347,6 +357,16 @@ func RemoveOrg(db *database.Database, orgId, vcId primitive.ObjectID) (
 		return
 	}
 
+	coll = db.VpnClients()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"vpc": vcId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
 	coll = db.Vpcs()
 
 	_, err = coll.DeleteOne(db, &bson.M{
@@ -379,6 +399,18 @@ func RemoveMulti(db *database.Database, vcIds []primitive.ObjectID) (err error)
 		return
 	}
 
+	coll = db.VpnClients()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"vpc": &bson.M{
+			"$in": vcIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
 	coll = db.Vpcs()
 
 	_, err = coll.DeleteMany(db, &bson.M{
@@ -448,3 +480,13 @@ func RemoveInstanceIp(db *database.Database, instId,
 
 	return
 }
+
+func isReserved(ranges []*ReservedRange, index int64) bool {
+	for _, reservedRange := range ranges {
+		if reservedRange.Contains(index) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -28,20 +28,32 @@ type Route struct {
 }
 
 type Vpc struct {
-	Id            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name          string             `bson:"name" json:"name"`
-	Comment       string             `bson:"comment" json:"comment"`
-	VpcId         int                `bson:"vpc_id" json:"vpc_id"`
-	Network       string             `bson:"network" json:"network"`
-	Network6      string             `bson:"-" json:"network6"`
-	Subnets       []*Subnet          `bson:"subnets" json:"subnets"`
-	Organization  primitive.ObjectID `bson:"organization" json:"organization"`
-	Datacenter    primitive.ObjectID `bson:"datacenter" json:"datacenter"`
-	Routes        []*Route           `bson:"routes" json:"routes"`
-	LinkUris      []string           `bson:"link_uris" json:"link_uris"`
-	LinkNode      primitive.ObjectID `bson:"link_node,omitempty" json:"link_node"`
-	LinkTimestamp time.Time          `bson:"link_timestamp" json:"link_timestamp"`
-	curSubnets    []*Subnet          `bson:"-" json:"-"`
+	Id             primitive.ObjectID       `bson:"_id,omitempty" json:"id"`
+	Name           string                   `bson:"name" json:"name"`
+	Comment        string                   `bson:"comment" json:"comment"`
+	VpcId          int                      `bson:"vpc_id" json:"vpc_id"`
+	Network        string                   `bson:"network" json:"network"`
+	Network6       string                   `bson:"-" json:"network6"`
+	Subnets        []*Subnet                `bson:"subnets" json:"subnets"`
+	Organization   primitive.ObjectID       `bson:"organization" json:"organization"`
+	Datacenter     primitive.ObjectID       `bson:"datacenter" json:"datacenter"`
+	Routes         []*Route                 `bson:"routes" json:"routes"`
+	LinkUris       []string                 `bson:"link_uris" json:"link_uris"`
+	VpnConnections []*VpnConnection         `bson:"vpn_connections" json:"vpn_connections"`
+	VpnStatus      map[string]string        `bson:"vpn_status" json:"vpn_status"`
+	WgPrivateKey   string                   `bson:"wg_private_key" json:"-"`
+	WgPublicKey    string                   `bson:"wg_public_key" json:"wg_public_key"`
+	WgPort         int                      `bson:"wg_port" json:"wg_port"`
+	WgEndpoint     string                   `bson:"wg_endpoint" json:"wg_endpoint"`
+	WgPeers        []*WgPeer                `bson:"wg_peers" json:"wg_peers"`
+	WgStatus       map[string]*WgPeerStatus `bson:"wg_status" json:"wg_status"`
+	ClientPool     string                   `bson:"client_pool" json:"client_pool"`
+	ClientRoles    []string                 `bson:"client_roles" json:"client_roles"`
+	DnsServers     []string                 `bson:"dns_servers" json:"dns_servers"`
+	BgpPrefixes    []string                 `bson:"bgp_prefixes" json:"bgp_prefixes"`
+	LinkNode       primitive.ObjectID       `bson:"link_node,omitempty" json:"link_node"`
+	LinkTimestamp  time.Time                `bson:"link_timestamp" json:"link_timestamp"`
+	curSubnets     []*Subnet                `bson:"-" json:"-"`
 }
 
 func (v *Vpc) Validate(db *database.Database) (
@@ -133,6 +145,50 @@ func (v *Vpc) Validate(db *database.Database) (
 
 		sub.Network = subNetwork.String()
 
+		if sub.Reserved == nil {
+			sub.Reserved = []string{}
+		}
+
+		reservedRanges := []string{}
+		for _, reserved := range sub.Reserved {
+			if strings.TrimSpace(reserved) == "" {
+				continue
+			}
+
+			start, stop, e := parseReserved(reserved)
+			if e != nil {
+				errData = &errortypes.ErrorData{
+					Error:   "subnet_reserved_invalid",
+					Message: "Subnet reserved range invalid",
+				}
+				return
+			}
+
+			if !subNetwork.Contains(start) || !subNetwork.Contains(stop) {
+				errData = &errortypes.ErrorData{
+					Error:   "subnet_reserved_range_invalid",
+					Message: "Subnet reserved range outside of subnet",
+				}
+				return
+			}
+
+			if utils.IpAddress2Int(start) > utils.IpAddress2Int(stop) {
+				errData = &errortypes.ErrorData{
+					Error:   "subnet_reserved_invalid",
+					Message: "Subnet reserved range invalid",
+				}
+				return
+			}
+
+			if start.Equal(stop) {
+				reservedRanges = append(reservedRanges, start.String())
+			} else {
+				reservedRanges = append(reservedRanges,
+					start.String()+"-"+stop.String())
+			}
+		}
+		sub.Reserved = reservedRanges
+
 		if !utils.NetworkContains(network, subNetwork) {
 			errData = &errortypes.ErrorData{
 				Error:   "subnet_network_range_invalid",
@@ -201,6 +257,137 @@ func (v *Vpc) Validate(db *database.Database) (
 	}
 	v.LinkUris = linkUris
 
+	if v.VpnConnections == nil {
+		v.VpnConnections = []*VpnConnection{}
+	}
+
+	vpnIds := set.NewSet()
+	for _, conn := range v.VpnConnections {
+		errData, err = conn.Validate(db, v, network, network6)
+		if err != nil || errData != nil {
+			return
+		}
+
+		if vpnIds.Contains(conn.Id) {
+			errData = &errortypes.ErrorData{
+				Error:   "vpn_connection_duplicate",
+				Message: "Duplicate VPN connection",
+			}
+			return
+		}
+		vpnIds.Add(conn.Id)
+	}
+
+	if v.VpnStatus == nil {
+		v.VpnStatus = map[string]string{}
+	}
+
+	errData, err = v.validateWireGuard(db, network)
+	if err != nil || errData != nil {
+		return
+	}
+
+	v.ClientPool = strings.TrimSpace(v.ClientPool)
+	if v.ClientPool != "" {
+		_, clientPool, e := net.ParseCIDR(v.ClientPool)
+		if e != nil || clientPool.IP.To4() == nil {
+			errData = &errortypes.ErrorData{
+				Error:   "client_pool_invalid",
+				Message: "Client pool network invalid",
+			}
+			return
+		}
+
+		cidr, _ := clientPool.Mask.Size()
+		if cidr < 16 || cidr > 29 {
+			errData = &errortypes.ErrorData{
+				Error:   "client_pool_size_invalid",
+				Message: "Client pool network size invalid",
+			}
+			return
+		}
+
+		if clientPool.Contains(network.IP) ||
+			network.Contains(clientPool.IP) {
+
+			errData = &errortypes.ErrorData{
+				Error:   "client_pool_overlap",
+				Message: "Client pool overlaps VPC network",
+			}
+			return
+		}
+
+		v.ClientPool = clientPool.String()
+	}
+
+	if v.ClientRoles == nil {
+		v.ClientRoles = []string{}
+	}
+
+	clientRoles := []string{}
+	for _, role := range v.ClientRoles {
+		role = strings.TrimSpace(role)
+		if role != "" {
+			clientRoles = append(clientRoles, role)
+		}
+	}
+	v.ClientRoles = clientRoles
+
+	if v.BgpPrefixes == nil {
+		v.BgpPrefixes = []string{}
+	}
+
+	bgpPrefixes := []string{}
+	bgpPrefixesSet := set.NewSet()
+	for _, prefix := range v.BgpPrefixes {
+		prefix = strings.TrimSpace(prefix)
+		if prefix == "" {
+			continue
+		}
+
+		_, prefixNet, e := net.ParseCIDR(prefix)
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "bgp_prefix_invalid",
+				Message: "BGP prefix invalid",
+			}
+			return
+		}
+
+		prefix = prefixNet.String()
+		if bgpPrefixesSet.Contains(prefix) {
+			continue
+		}
+		bgpPrefixesSet.Add(prefix)
+
+		bgpPrefixes = append(bgpPrefixes, prefix)
+	}
+	v.BgpPrefixes = bgpPrefixes
+
+	if v.DnsServers == nil {
+		v.DnsServers = []string{}
+	}
+
+	dnsServers := []string{}
+	for _, dnsServer := range v.DnsServers {
+		dnsServer = strings.TrimSpace(dnsServer)
+		if dnsServer == "" {
+			continue
+		}
+
+		dnsIp := net.ParseIP(dnsServer)
+		if dnsIp == nil {
+			errData = &errortypes.ErrorData{
+				Error:   "dns_server_invalid",
+				Message: "DNS server address invalid",
+			}
+			return
+		}
+
+		dnsServers = append(dnsServers, dnsIp.String())
+	}
+	v.DnsServers = dnsServers
+
 	destinations := set.NewSet()
 	for _, route := range v.Routes {
 		if destinations.Contains(route.Destination) {
@@ -350,6 +537,15 @@ func (v *Vpc) Json() {
 	v.Network6 = ipBuf.String() + "::/64"
 }
 
+func (v *Vpc) GetDomain() string {
+	name := utils.FilterDomain(v.Name)
+	if name == "" {
+		name = v.Id.Hex()
+	}
+
+	return name + ".internal"
+}
+
 func (v *Vpc) GetSubnet(id primitive.ObjectID) (sub *Subnet) {
 	if v.Subnets == nil || id.IsZero() {
 		return
@@ -464,18 +660,38 @@ func (v *Vpc) GetIp(db *database.Database,
 		}
 	}
 
+	reservedRanges, err := subnet.GetReservedRanges()
+	if err != nil {
+		return
+	}
+
 	if vpcIp == nil {
 		vpcIp = &VpcIp{}
 		opts := &options.FindOneAndUpdateOptions{}
 		opts.SetReturnDocument(options.After)
 
+		query := bson.M{
+			"vpc":      v.Id,
+			"subnet":   subId,
+			"instance": nil,
+		}
+
+		if len(reservedRanges) > 0 {
+			reservedQuery := []*bson.M{}
+			for _, reservedRange := range reservedRanges {
+				reservedQuery = append(reservedQuery, &bson.M{
+					"ip": &bson.M{
+						"$gte": reservedRange.Start,
+						"$lte": reservedRange.Stop,
+					},
+				})
+			}
+			query["$nor"] = reservedQuery
+		}
+
 		err = coll.FindOneAndUpdate(
 			db,
-			&bson.M{
-				"vpc":      v.Id,
-				"subnet":   subId,
-				"instance": nil,
-			},
+			&query,
 			&bson.M{
 				"$set": &bson.M{
 					"instance": instId,
@@ -538,6 +754,11 @@ func (v *Vpc) GetIp(db *database.Database,
 				return
 			}
 
+			if isReserved(reservedRanges, curIp) {
+				curIp += 1
+				continue
+			}
+
 			vpcIp = &VpcIp{
 				Vpc:      v.Id,
 				Subnet:   subId,
@@ -566,6 +787,178 @@ func (v *Vpc) GetIp(db *database.Database,
 	return
 }
 
+func (v *Vpc) validateIpRange(subId primitive.ObjectID, addr string) (
+	ip net.IP, index int64, errData *errortypes.ErrorData, err error) {
+
+	subnet := v.GetSubnet(subId)
+	if subnet == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_subnet_missing",
+			Message: "VPC subnet does not exist",
+		}
+		return
+	}
+
+	ip = net.ParseIP(addr).To4()
+	if ip == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "private_ip_invalid",
+			Message: "Private IP address invalid",
+		}
+		return
+	}
+
+	ipInt := utils.IpAddress2Int(ip)
+	if ipInt%2 != 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "private_ip_gateway",
+			Message: "Private IP address reserved for gateway",
+		}
+		return
+	}
+
+	start, stop, err := subnet.GetIndexRange()
+	if err != nil {
+		return
+	}
+
+	index = ipInt / 2
+	if index < start || index > stop {
+		errData = &errortypes.ErrorData{
+			Error:   "private_ip_subnet_invalid",
+			Message: "Private IP address not in VPC subnet range",
+		}
+		return
+	}
+
+	reservedRanges, err := subnet.GetReservedRanges()
+	if err != nil {
+		return
+	}
+
+	if isReserved(reservedRanges, index) {
+		errData = &errortypes.ErrorData{
+			Error:   "private_ip_reserved",
+			Message: "Private IP address in subnet reserved range",
+		}
+		return
+	}
+
+	return
+}
+
+func (v *Vpc) ValidateIp(db *database.Database, subId,
+	instId primitive.ObjectID, addr string) (ip net.IP,
+	errData *errortypes.ErrorData, err error) {
+
+	ip, index, errData, err := v.validateIpRange(subId, addr)
+	if err != nil || errData != nil {
+		return
+	}
+
+	coll := db.VpcsIp()
+	vpcIp := &VpcIp{}
+
+	err = coll.FindOne(db, &bson.M{
+		"vpc":    v.Id,
+		"subnet": subId,
+		"ip":     index,
+	}).Decode(vpcIp)
+	if err != nil {
+		err = database.ParseError(err)
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	if !vpcIp.Instance.IsZero() && vpcIp.Instance != instId {
+		errData = &errortypes.ErrorData{
+			Error:   "private_ip_in_use",
+			Message: "Private IP address in use by another instance",
+		}
+		return
+	}
+
+	return
+}
+
+func (v *Vpc) SetIp(db *database.Database, subId,
+	instId primitive.ObjectID, addr net.IP) (err error) {
+
+	coll := db.VpcsIp()
+	index := utils.IpAddress2Int(addr.To4()) / 2
+
+	vpcIp := &VpcIp{}
+	err = coll.FindOne(db, &bson.M{
+		"vpc":      v.Id,
+		"instance": instId,
+	}).Decode(vpcIp)
+	if err != nil {
+		err = database.ParseError(err)
+		vpcIp = nil
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		} else {
+			return
+		}
+	}
+
+	if vpcIp != nil {
+		if vpcIp.Subnet == subId && vpcIp.Ip == index {
+			return
+		}
+
+		err = RemoveInstanceIp(db, instId, v.Id)
+		if err != nil {
+			return
+		}
+	}
+
+	resp, err := coll.UpdateOne(
+		db,
+		&bson.M{
+			"vpc":      v.Id,
+			"subnet":   subId,
+			"ip":       index,
+			"instance": nil,
+		},
+		&bson.M{
+			"$set": &bson.M{
+				"instance": instId,
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if resp.MatchedCount > 0 {
+		return
+	}
+
+	vpcIp = &VpcIp{
+		Vpc:      v.Id,
+		Subnet:   subId,
+		Ip:       index,
+		Instance: instId,
+	}
+
+	_, err = coll.InsertOne(db, vpcIp)
+	if err != nil {
+		err = database.ParseError(err)
+		if _, ok := err.(*database.DuplicateKeyError); ok {
+			err = &errortypes.NotFoundError{
+				errors.New("vpc: Private address in use"),
+			}
+		}
+		return
+	}
+
+	return
+}
+
 func (v *Vpc) GetIp6(addr net.IP) net.IP {
 	netHash := md5.New()
 	netHash.Write(v.Id[:])
@@ -655,6 +1048,76 @@ func (v *Vpc) AddLinkRoutes(db *database.Database, routes []*Route) (
 	return
 }
 
+func (v *Vpc) HasLinks() bool {
+	return len(v.LinkUris) != 0 || len(v.VpnConnections) != 0 ||
+		len(v.WgPeers) != 0 || v.ClientPool != ""
+}
+
+func (v *Vpc) GetClientPool() (network *net.IPNet, err error) {
+	_, network, err = net.ParseCIDR(v.ClientPool)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "vpc: Failed to parse client pool"),
+		}
+		return
+	}
+
+	return
+}
+
+func (v *Vpc) ClientAllowed(roles []string) bool {
+	if v.ClientPool == "" {
+		return false
+	}
+
+	if len(v.ClientRoles) == 0 {
+		return true
+	}
+
+	clientRoles := set.NewSet()
+	for _, role := range v.ClientRoles {
+		clientRoles.Add(role)
+	}
+
+	for _, role := range roles {
+		if clientRoles.Contains(role) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (v *Vpc) UpdateVpnStatus(db *database.Database,
+	status map[string]string) (err error) {
+
+	coll := db.Vpcs()
+
+	_, err = coll.UpdateOne(
+		db,
+		&bson.M{
+			"_id": v.Id,
+		},
+		&bson.M{
+			"$set": &bson.M{
+				"vpn_status": status,
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		} else {
+			return
+		}
+	}
+
+	v.VpnStatus = status
+
+	return
+}
+
 func (v *Vpc) RemoveSubnet(db *database.Database, subId primitive.ObjectID) (
 	err error) {
 
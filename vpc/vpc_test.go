@@ -0,0 +1,192 @@
+package vpc
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func newTestVpc() (vc *Vpc, subId primitive.ObjectID) {
+	subId = primitive.NewObjectID()
+	vc = &Vpc{
+		Id:      primitive.NewObjectID(),
+		Network: "10.0.0.0/16",
+		Subnets: []*Subnet{
+			{
+				Id:      subId,
+				Name:    "primary",
+				Network: "10.0.0.0/24",
+			},
+		},
+	}
+	return
+}
+
+func TestValidateIpRange(t *testing.T) {
+	vc, subId := newTestVpc()
+
+	for _, addr := range []string{"10.0.0.2", "10.0.0.100", "10.0.0.252"} {
+		ip, index, errData, err := vc.validateIpRange(subId, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errData != nil {
+			t.Errorf("address %s rejected: %s", addr, errData.Message)
+			continue
+		}
+
+		instIp, _ := utils.IpIndex2Ip(index)
+		if ip.String() != addr || instIp.String() != addr {
+			t.Errorf("address %s returned %s at index %d",
+				addr, ip, index)
+		}
+	}
+}
+
+func TestValidateIpRangeInvalid(t *testing.T) {
+	vc, subId := newTestVpc()
+
+	invalid := map[string]string{
+		"10.0.0.3":   "private_ip_gateway",
+		"10.0.0.0":   "private_ip_subnet_invalid",
+		"10.0.0.254": "private_ip_subnet_invalid",
+		"10.0.1.2":   "private_ip_subnet_invalid",
+		"10.0.0":     "private_ip_invalid",
+		"fd00::2":    "private_ip_invalid",
+		"":           "private_ip_invalid",
+	}
+
+	for addr, errCode := range invalid {
+		_, _, errData, err := vc.validateIpRange(subId, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errData == nil || errData.Error != errCode {
+			t.Errorf("address %q expected %s", addr, errCode)
+		}
+
+		// Range errors are returned before the database is used
+		_, errData, err = vc.ValidateIp(nil, subId,
+			primitive.NewObjectID(), addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errData == nil || errData.Error != errCode {
+			t.Errorf("validate address %q expected %s", addr, errCode)
+		}
+	}
+
+	_, _, errData, err := vc.validateIpRange(
+		primitive.NewObjectID(), "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errData == nil || errData.Error != "vpc_subnet_missing" {
+		t.Errorf("missing subnet accepted")
+	}
+}
+
+func TestValidateIpRangeReserved(t *testing.T) {
+	vc, subId := newTestVpc()
+	vc.Subnets[0].Reserved = []string{
+		"10.0.0.10-10.0.0.20",
+		"10.0.0.100",
+	}
+
+	for _, addr := range []string{"10.0.0.10", "10.0.0.16", "10.0.0.20",
+		"10.0.0.100"} {
+
+		_, _, errData, err := vc.validateIpRange(subId, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errData == nil || errData.Error != "private_ip_reserved" {
+			t.Errorf("reserved address %s accepted", addr)
+		}
+	}
+
+	for _, addr := range []string{"10.0.0.8", "10.0.0.22", "10.0.0.102"} {
+		_, _, errData, err := vc.validateIpRange(subId, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errData != nil {
+			t.Errorf("address %s rejected: %s", addr, errData.Message)
+		}
+	}
+}
+
+func TestSetVpnConnections(t *testing.T) {
+	keptId := primitive.NewObjectID()
+	clearedId := primitive.NewObjectID()
+
+	vc := &Vpc{
+		VpnConnections: []*VpnConnection{
+			{Id: keptId, PreSharedKey: "secret1", HasPreSharedKey: true},
+			{Id: clearedId, PreSharedKey: "secret2", HasPreSharedKey: true},
+		},
+	}
+
+	vc.SetVpnConnections([]*VpnConnection{
+		{Id: keptId, HasPreSharedKey: true},
+		{Id: clearedId},
+		{HasPreSharedKey: true, SetPreSharedKey: "secret3"},
+	})
+
+	conns := vc.VpnConnections
+	if conns[0].PreSharedKey != "secret1" || !conns[0].HasPreSharedKey {
+		t.Errorf("blank key did not keep stored key")
+	}
+	if conns[1].PreSharedKey != "" || conns[1].HasPreSharedKey {
+		t.Errorf("cleared key kept stored key")
+	}
+	if conns[2].PreSharedKey != "secret3" || !conns[2].HasPreSharedKey {
+		t.Errorf("new key not set")
+	}
+
+	data, err := json.Marshal(conns[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret1") {
+		t.Errorf("pre shared key exposed in json: %s", data)
+	}
+}
+
+func TestSetWgPeers(t *testing.T) {
+	keptId := primitive.NewObjectID()
+	clearedId := primitive.NewObjectID()
+
+	vc := &Vpc{
+		WgPeers: []*WgPeer{
+			{Id: keptId, PresharedKey: "key1", HasPresharedKey: true},
+			{Id: clearedId, PresharedKey: "key2", HasPresharedKey: true},
+		},
+	}
+
+	vc.SetWgPeers([]*WgPeer{
+		{Id: keptId, HasPresharedKey: true},
+		{Id: clearedId},
+		{SetPresharedKey: "key3"},
+	})
+
+	peers := vc.WgPeers
+	if peers[0].PresharedKey != "key1" || !peers[0].HasPresharedKey {
+		t.Errorf("blank key did not keep stored key")
+	}
+	if peers[1].PresharedKey != "" || peers[1].HasPresharedKey {
+		t.Errorf("cleared key kept stored key")
+	}
+	if peers[2].PresharedKey != "key3" || !peers[2].HasPresharedKey {
+		t.Errorf("new key not set")
+	}
+
+	for _, peer := range peers {
+		if peer.SetPresharedKey != "" {
+			t.Errorf("peer write-only key not cleared")
+		}
+	}
+}
@@ -0,0 +1,519 @@
+package vpc
+
+import (
+	"crypto/md5"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/certificate"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+var (
+	proposalReg = regexp.MustCompile("^[a-z0-9_-]+$")
+	secretReg   = regexp.MustCompile(`^[ !#-\[\]-~]+$`)
+)
+
+type VpnConnection struct {
+	Id              primitive.ObjectID `bson:"id" json:"id"`
+	Name            string             `bson:"name" json:"name"`
+	RemoteGateway   string             `bson:"remote_gateway" json:"remote_gateway"`
+	RemoteId        string             `bson:"remote_id" json:"remote_id"`
+	LocalSubnets    []string           `bson:"local_subnets" json:"local_subnets"`
+	RemoteSubnets   []string           `bson:"remote_subnets" json:"remote_subnets"`
+	AuthMode        string             `bson:"auth_mode" json:"auth_mode"`
+	PreSharedKey    string             `bson:"pre_shared_key" json:"-"`
+	SetPreSharedKey string             `bson:"-" json:"set_pre_shared_key"`
+	HasPreSharedKey bool               `bson:"has_pre_shared_key" json:"has_pre_shared_key"`
+	Certificate     primitive.ObjectID `bson:"certificate,omitempty" json:"certificate"`
+	RemoteCa        string             `bson:"remote_ca" json:"remote_ca"`
+	IkeVersion      string             `bson:"ike_version" json:"ike_version"`
+	IkeProposals    []string           `bson:"ike_proposals" json:"ike_proposals"`
+	EspProposals    []string           `bson:"esp_proposals" json:"esp_proposals"`
+	IkeLifetime     int                `bson:"ike_lifetime" json:"ike_lifetime"`
+	KeyLifetime     int                `bson:"key_lifetime" json:"key_lifetime"`
+	RekeyMargin     int                `bson:"rekey_margin" json:"rekey_margin"`
+	DpdDelay        int                `bson:"dpd_delay" json:"dpd_delay"`
+	DpdTimeout      int                `bson:"dpd_timeout" json:"dpd_timeout"`
+	DpdAction       string             `bson:"dpd_action" json:"dpd_action"`
+}
+
+func (v *Vpc) SetVpnConnections(conns []*VpnConnection) {
+	curKeys := map[primitive.ObjectID]string{}
+	for _, conn := range v.VpnConnections {
+		curKeys[conn.Id] = conn.PreSharedKey
+	}
+
+	for _, conn := range conns {
+		if conn.SetPreSharedKey != "" {
+			conn.PreSharedKey = conn.SetPreSharedKey
+		} else if conn.HasPreSharedKey && !conn.Id.IsZero() {
+			conn.PreSharedKey = curKeys[conn.Id]
+		} else {
+			conn.PreSharedKey = ""
+		}
+
+		conn.SetPreSharedKey = ""
+		conn.HasPreSharedKey = conn.PreSharedKey != ""
+	}
+
+	v.VpnConnections = conns
+}
+
+func (c *VpnConnection) IsIpv6() bool {
+	return strings.Contains(c.RemoteGateway, ":")
+}
+
+func (c *VpnConnection) Hash() string {
+	hash := md5.New()
+
+	io.WriteString(hash, c.Id.Hex())
+	io.WriteString(hash, c.RemoteGateway)
+	io.WriteString(hash, c.RemoteId)
+	io.WriteString(hash, strings.Join(c.LocalSubnets, ","))
+	io.WriteString(hash, strings.Join(c.RemoteSubnets, ","))
+	io.WriteString(hash, c.AuthMode)
+	io.WriteString(hash, c.PreSharedKey)
+	io.WriteString(hash, c.Certificate.Hex())
+	io.WriteString(hash, c.RemoteCa)
+	io.WriteString(hash, c.IkeVersion)
+	io.WriteString(hash, strings.Join(c.IkeProposals, ","))
+	io.WriteString(hash, strings.Join(c.EspProposals, ","))
+	io.WriteString(hash, fmt.Sprintf("%d:%d:%d:%d:%d:%s",
+		c.IkeLifetime, c.KeyLifetime, c.RekeyMargin,
+		c.DpdDelay, c.DpdTimeout, c.DpdAction))
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func parseProposals(proposals []string, ike bool) (
+	parsed []string, valid bool) {
+
+	parsed = []string{}
+
+	for _, proposal := range proposals {
+		proposal = strings.ToLower(strings.TrimSpace(proposal))
+		if proposal == "" {
+			continue
+		}
+
+		if !proposalReg.MatchString(proposal) {
+			return
+		}
+
+		hasEnc := false
+		hasAead := false
+		hasInteg := false
+		hasPrf := false
+		hasDh := false
+		for _, alg := range strings.Split(proposal, "-") {
+			if EncryptionAlgorithms.Contains(alg) {
+				hasEnc = true
+				if AeadAlgorithms.Contains(alg) {
+					hasAead = true
+				}
+			} else if IntegrityAlgorithms.Contains(alg) {
+				hasInteg = true
+			} else if ike && PrfAlgorithms.Contains(alg) {
+				hasPrf = true
+			} else if DhGroups.Contains(alg) {
+				hasDh = true
+			} else {
+				return
+			}
+		}
+
+		if !hasEnc {
+			return
+		}
+
+		if hasAead {
+			if ike && !hasPrf && !hasInteg {
+				return
+			}
+		} else if !hasInteg {
+			return
+		}
+
+		if ike && !hasDh {
+			return
+		}
+
+		parsed = append(parsed, proposal)
+	}
+
+	valid = true
+	return
+}
+
+func (c *VpnConnection) ValidateCertificate(cert *certificate.Certificate) (
+	errData *errortypes.ErrorData) {
+
+	if cert.Key == "" || cert.Certificate == "" {
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_certificate_invalid",
+			Message: "VPN certificate missing certificate or key",
+		}
+		return
+	}
+
+	keypair, e := tls.X509KeyPair(
+		[]byte(cert.Certificate), []byte(cert.Key))
+	if e != nil || len(keypair.Certificate) == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_certificate_invalid",
+			Message: "VPN certificate does not match private key",
+		}
+		return
+	}
+
+	x509Cert, e := x509.ParseCertificate(keypair.Certificate[0])
+	if e != nil {
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_certificate_invalid",
+			Message: "VPN certificate invalid",
+		}
+		return
+	}
+
+	if time.Now().After(x509Cert.NotAfter) {
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_certificate_expired",
+			Message: "VPN certificate expired",
+		}
+		return
+	}
+
+	return
+}
+
+func (c *VpnConnection) validateRemoteCa() (errData *errortypes.ErrorData) {
+	c.RemoteCa = strings.TrimSpace(c.RemoteCa)
+	if c.RemoteCa == "" {
+		return
+	}
+
+	count := 0
+	rest := []byte(c.RemoteCa)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		_, e := x509.ParseCertificate(block.Bytes)
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "vpn_remote_ca_invalid",
+				Message: "VPN remote CA certificate invalid",
+			}
+			return
+		}
+		count += 1
+	}
+
+	if count == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_remote_ca_invalid",
+			Message: "VPN remote CA certificate invalid",
+		}
+		return
+	}
+
+	return
+}
+
+func (c *VpnConnection) Validate(db *database.Database, vc *Vpc,
+	network, network6 *net.IPNet) (
+	errData *errortypes.ErrorData, err error) {
+
+	if c.Id.IsZero() {
+		c.Id = primitive.NewObjectID()
+	}
+
+	c.Name = strings.TrimSpace(c.Name)
+
+	remoteGateway := net.ParseIP(strings.TrimSpace(c.RemoteGateway))
+	if remoteGateway == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_remote_gateway_invalid",
+			Message: "VPN remote gateway address invalid",
+		}
+		return
+	}
+	c.RemoteGateway = remoteGateway.String()
+
+	c.RemoteId = strings.TrimSpace(c.RemoteId)
+	if c.RemoteId != "" && !secretReg.MatchString(c.RemoteId) {
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_remote_id_invalid",
+			Message: "VPN remote ID invalid",
+		}
+		return
+	}
+
+	if c.LocalSubnets == nil || len(c.LocalSubnets) == 0 {
+		c.LocalSubnets = []string{network.String()}
+	}
+
+	localSubnets := []string{}
+	for _, localSubnet := range c.LocalSubnets {
+		localSubnet = strings.TrimSpace(localSubnet)
+		if localSubnet == "" {
+			continue
+		}
+
+		_, localNet, e := net.ParseCIDR(localSubnet)
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "vpn_local_subnet_invalid",
+				Message: "VPN local subnet invalid",
+			}
+			return
+		}
+
+		if !utils.NetworkContains(network, localNet) &&
+			!utils.NetworkContains(network6, localNet) {
+
+			errData = &errortypes.ErrorData{
+				Error:   "vpn_local_subnet_invalid",
+				Message: "VPN local subnet outside of VPC network",
+			}
+			return
+		}
+
+		localSubnets = append(localSubnets, localNet.String())
+	}
+	c.LocalSubnets = localSubnets
+
+	if c.RemoteSubnets == nil {
+		c.RemoteSubnets = []string{}
+	}
+
+	remoteSubnets := []string{}
+	for _, remoteSubnet := range c.RemoteSubnets {
+		remoteSubnet = strings.TrimSpace(remoteSubnet)
+		if remoteSubnet == "" {
+			continue
+		}
+
+		_, remoteNet, e := net.ParseCIDR(remoteSubnet)
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "vpn_remote_subnet_invalid",
+				Message: "VPN remote subnet invalid",
+			}
+			return
+		}
+
+		if remoteNet.Contains(network.IP) || network.Contains(remoteNet.IP) {
+			errData = &errortypes.ErrorData{
+				Error:   "vpn_remote_subnet_overlap",
+				Message: "VPN remote subnet overlaps VPC network",
+			}
+			return
+		}
+
+		remoteSubnets = append(remoteSubnets, remoteNet.String())
+	}
+	c.RemoteSubnets = remoteSubnets
+
+	if len(c.RemoteSubnets) == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_remote_subnet_required",
+			Message: "VPN remote subnet required",
+		}
+		return
+	}
+
+	switch c.AuthMode {
+	case "", VpnPsk:
+		c.AuthMode = VpnPsk
+		c.Certificate = primitive.NilObjectID
+		c.RemoteCa = ""
+
+		if c.PreSharedKey == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "vpn_pre_shared_key_required",
+				Message: "VPN pre shared key required",
+			}
+			return
+		}
+
+		if !secretReg.MatchString(c.PreSharedKey) {
+			errData = &errortypes.ErrorData{
+				Error:   "vpn_pre_shared_key_invalid",
+				Message: "VPN pre shared key invalid",
+			}
+			return
+		}
+		break
+	case VpnCertificate:
+		c.PreSharedKey = ""
+		c.HasPreSharedKey = false
+
+		if c.Certificate.IsZero() {
+			errData = &errortypes.ErrorData{
+				Error:   "vpn_certificate_required",
+				Message: "VPN certificate required",
+			}
+			return
+		}
+
+		cert, e := certificate.GetOrg(db, vc.Organization, c.Certificate)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); ok {
+				errData = &errortypes.ErrorData{
+					Error:   "vpn_certificate_invalid",
+					Message: "VPN certificate does not exist",
+				}
+			} else {
+				err = e
+			}
+			return
+		}
+
+		errData = c.ValidateCertificate(cert)
+		if errData != nil {
+			return
+		}
+
+		errData = c.validateRemoteCa()
+		if errData != nil {
+			return
+		}
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_auth_mode_invalid",
+			Message: "VPN authentication mode invalid",
+		}
+		return
+	}
+
+	switch c.IkeVersion {
+	case "", Ikev2:
+		c.IkeVersion = Ikev2
+		break
+	case Ikev1:
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_ike_version_invalid",
+			Message: "VPN IKE version invalid",
+		}
+		return
+	}
+
+	ikeProposals, valid := parseProposals(c.IkeProposals, true)
+	if !valid {
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_ike_proposal_invalid",
+			Message: "VPN IKE proposal invalid",
+		}
+		return
+	}
+	c.IkeProposals = ikeProposals
+
+	espProposals, valid := parseProposals(c.EspProposals, false)
+	if !valid {
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_esp_proposal_invalid",
+			Message: "VPN ESP proposal invalid",
+		}
+		return
+	}
+	c.EspProposals = espProposals
+
+	if c.IkeLifetime == 0 {
+		c.IkeLifetime = DefaultIkeLifetime
+	}
+	if c.KeyLifetime == 0 {
+		c.KeyLifetime = DefaultKeyLifetime
+	}
+	if c.RekeyMargin == 0 {
+		c.RekeyMargin = DefaultRekeyMargin
+	}
+
+	if c.IkeLifetime < 300 || c.IkeLifetime > 86400 {
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_ike_lifetime_invalid",
+			Message: "VPN IKE lifetime must be between 300 and 86400 seconds",
+		}
+		return
+	}
+
+	if c.KeyLifetime < 300 || c.KeyLifetime > c.IkeLifetime {
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_key_lifetime_invalid",
+			Message: "VPN key lifetime must be between 300 and IKE lifetime",
+		}
+		return
+	}
+
+	if c.RekeyMargin < 0 || c.RekeyMargin >= c.KeyLifetime {
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_rekey_margin_invalid",
+			Message: "VPN rekey margin must be less than key lifetime",
+		}
+		return
+	}
+
+	if c.DpdAction == "" {
+		c.DpdAction = DpdRestart
+	}
+
+	if !ValidDpdActions.Contains(c.DpdAction) {
+		errData = &errortypes.ErrorData{
+			Error:   "vpn_dpd_action_invalid",
+			Message: "VPN dead peer detection action invalid",
+		}
+		return
+	}
+
+	if c.DpdAction == DpdNone {
+		c.DpdDelay = 0
+		c.DpdTimeout = 0
+	} else {
+		if c.DpdDelay == 0 {
+			c.DpdDelay = DefaultDpdDelay
+		}
+		if c.DpdTimeout == 0 {
+			c.DpdTimeout = DefaultDpdTimeout
+		}
+
+		if c.DpdDelay < 1 || c.DpdDelay > 3600 {
+			errData = &errortypes.ErrorData{
+				Error:   "vpn_dpd_delay_invalid",
+				Message: "VPN dead peer detection delay invalid",
+			}
+			return
+		}
+
+		if c.DpdTimeout <= c.DpdDelay || c.DpdTimeout > 7200 {
+			errData = &errortypes.ErrorData{
+				Error:   "vpn_dpd_timeout_invalid",
+				Message: "VPN dead peer detection timeout invalid",
+			}
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,381 @@
+package vpc
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"golang.org/x/crypto/curve25519"
+)
+
+type WgPeer struct {
+	Id                  primitive.ObjectID `bson:"id" json:"id"`
+	Name                string             `bson:"name" json:"name"`
+	Vpc                 primitive.ObjectID `bson:"vpc,omitempty" json:"vpc"`
+	PublicKey           string             `bson:"public_key" json:"public_key"`
+	PresharedKey        string             `bson:"preshared_key" json:"-"`
+	SetPresharedKey     string             `bson:"-" json:"set_preshared_key"`
+	HasPresharedKey     bool               `bson:"has_preshared_key" json:"has_preshared_key"`
+	Endpoint            string             `bson:"endpoint" json:"endpoint"`
+	AllowedIps          []string           `bson:"allowed_ips" json:"allowed_ips"`
+	PersistentKeepalive int                `bson:"persistent_keepalive" json:"persistent_keepalive"`
+}
+
+func (v *Vpc) SetWgPeers(peers []*WgPeer) {
+	curKeys := map[primitive.ObjectID]string{}
+	for _, peer := range v.WgPeers {
+		curKeys[peer.Id] = peer.PresharedKey
+	}
+
+	for _, peer := range peers {
+		if peer.SetPresharedKey != "" {
+			peer.PresharedKey = peer.SetPresharedKey
+		} else if peer.HasPresharedKey && !peer.Id.IsZero() {
+			peer.PresharedKey = curKeys[peer.Id]
+		} else {
+			peer.PresharedKey = ""
+		}
+
+		peer.SetPresharedKey = ""
+		peer.HasPresharedKey = peer.PresharedKey != ""
+	}
+
+	v.WgPeers = peers
+}
+
+type WgPeerStatus struct {
+	Status          string    `bson:"status" json:"status"`
+	Endpoint        string    `bson:"endpoint" json:"endpoint"`
+	LatestHandshake time.Time `bson:"latest_handshake" json:"latest_handshake"`
+	TransferRx      int64     `bson:"transfer_rx" json:"transfer_rx"`
+	TransferTx      int64     `bson:"transfer_tx" json:"transfer_tx"`
+}
+
+func GenerateWgKeys() (privKey, pubKey string, err error) {
+	priv, err := utils.RandBytes(32)
+	if err != nil {
+		return
+	}
+
+	priv[0] &= 248
+	priv[31] &= 127
+	priv[31] |= 64
+
+	pub, err := curve25519.X25519(priv, curve25519.Basepoint)
+	if err != nil {
+		err = &errortypes.UnknownError{
+			errors.Wrap(err, "vpc: Failed to generate wireguard key"),
+		}
+		return
+	}
+
+	privKey = base64.StdEncoding.EncodeToString(priv)
+	pubKey = base64.StdEncoding.EncodeToString(pub)
+
+	return
+}
+
+func validWgKey(key string) bool {
+	keyByt, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(keyByt) != 32 {
+		return false
+	}
+	return true
+}
+
+func (p *WgPeer) Hash() string {
+	hash := md5.New()
+
+	io.WriteString(hash, p.Id.Hex())
+	io.WriteString(hash, p.Vpc.Hex())
+	io.WriteString(hash, p.PublicKey)
+	io.WriteString(hash, p.PresharedKey)
+	io.WriteString(hash, p.Endpoint)
+	io.WriteString(hash, strings.Join(p.AllowedIps, ","))
+	io.WriteString(hash, strconv.Itoa(p.PersistentKeepalive))
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (p *WgPeer) Validate(db *database.Database, vc *Vpc,
+	network *net.IPNet) (errData *errortypes.ErrorData, err error) {
+
+	if p.Id.IsZero() {
+		p.Id = primitive.NewObjectID()
+	}
+
+	p.Name = strings.TrimSpace(p.Name)
+	p.PublicKey = strings.TrimSpace(p.PublicKey)
+	p.PresharedKey = strings.TrimSpace(p.PresharedKey)
+	p.HasPresharedKey = p.PresharedKey != ""
+	p.Endpoint = strings.TrimSpace(p.Endpoint)
+
+	if p.AllowedIps == nil {
+		p.AllowedIps = []string{}
+	}
+
+	if !p.Vpc.IsZero() {
+		if p.Vpc == vc.Id {
+			errData = &errortypes.ErrorData{
+				Error:   "wg_peer_vpc_invalid",
+				Message: "WireGuard peer VPC cannot be the same VPC",
+			}
+			return
+		}
+
+		peerVc, e := GetOrg(db, vc.Organization, p.Vpc)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); ok {
+				errData = &errortypes.ErrorData{
+					Error:   "wg_peer_vpc_invalid",
+					Message: "WireGuard peer VPC does not exist",
+				}
+			} else {
+				err = e
+			}
+			return
+		}
+
+		p.PublicKey = ""
+		p.Endpoint = ""
+
+		if len(p.AllowedIps) == 0 {
+			p.AllowedIps = []string{peerVc.Network}
+		}
+	} else if !validWgKey(p.PublicKey) {
+		errData = &errortypes.ErrorData{
+			Error:   "wg_peer_public_key_invalid",
+			Message: "WireGuard peer public key invalid",
+		}
+		return
+	}
+
+	if p.PresharedKey != "" && !validWgKey(p.PresharedKey) {
+		errData = &errortypes.ErrorData{
+			Error:   "wg_peer_preshared_key_invalid",
+			Message: "WireGuard peer preshared key invalid",
+		}
+		return
+	}
+
+	if p.Endpoint != "" {
+		host, port, e := net.SplitHostPort(p.Endpoint)
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "wg_peer_endpoint_invalid",
+				Message: "WireGuard peer endpoint invalid",
+			}
+			return
+		}
+
+		hostIp := net.ParseIP(host)
+		portNum, e := strconv.Atoi(port)
+		if hostIp == nil || e != nil || portNum < 1 || portNum > 65535 {
+			errData = &errortypes.ErrorData{
+				Error:   "wg_peer_endpoint_invalid",
+				Message: "WireGuard peer endpoint invalid",
+			}
+			return
+		}
+
+		p.Endpoint = net.JoinHostPort(
+			hostIp.String(), strconv.Itoa(portNum))
+	}
+
+	allowedIps := []string{}
+	for _, allowedIp := range p.AllowedIps {
+		allowedIp = strings.TrimSpace(allowedIp)
+		if allowedIp == "" {
+			continue
+		}
+
+		_, allowedNet, e := net.ParseCIDR(allowedIp)
+		if e != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "wg_peer_allowed_ip_invalid",
+				Message: "WireGuard peer allowed IP invalid",
+			}
+			return
+		}
+
+		if allowedNet.Contains(network.IP) ||
+			network.Contains(allowedNet.IP) {
+
+			errData = &errortypes.ErrorData{
+				Error:   "wg_peer_allowed_ip_overlap",
+				Message: "WireGuard peer allowed IP overlaps VPC network",
+			}
+			return
+		}
+
+		allowedIps = append(allowedIps, allowedNet.String())
+	}
+	p.AllowedIps = allowedIps
+
+	if len(p.AllowedIps) == 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "wg_peer_allowed_ip_required",
+			Message: "WireGuard peer allowed IP required",
+		}
+		return
+	}
+
+	if p.PersistentKeepalive < 0 || p.PersistentKeepalive > 65535 {
+		errData = &errortypes.ErrorData{
+			Error:   "wg_peer_persistent_keepalive_invalid",
+			Message: "WireGuard peer persistent keepalive invalid",
+		}
+		return
+	}
+
+	return
+}
+
+func (v *Vpc) GetWgEndpoint(addr, addr6 string) string {
+	if addr != "" {
+		return net.JoinHostPort(addr, strconv.Itoa(v.WgPort))
+	} else if addr6 != "" {
+		return net.JoinHostPort(addr6, strconv.Itoa(v.WgPort))
+	}
+	return ""
+}
+
+func (v *Vpc) UpdateWgEndpoint(db *database.Database, endpoint string) (
+	err error) {
+
+	if v.WgEndpoint == endpoint {
+		return
+	}
+
+	coll := db.Vpcs()
+
+	_, err = coll.UpdateOne(
+		db,
+		&bson.M{
+			"_id": v.Id,
+		},
+		&bson.M{
+			"$set": &bson.M{
+				"wg_endpoint": endpoint,
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		} else {
+			return
+		}
+	}
+
+	v.WgEndpoint = endpoint
+
+	return
+}
+
+func (v *Vpc) UpdateWgStatus(db *database.Database,
+	status map[string]*WgPeerStatus) (err error) {
+
+	coll := db.Vpcs()
+
+	_, err = coll.UpdateOne(
+		db,
+		&bson.M{
+			"_id": v.Id,
+		},
+		&bson.M{
+			"$set": &bson.M{
+				"wg_status": status,
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		} else {
+			return
+		}
+	}
+
+	v.WgStatus = status
+
+	return
+}
+
+func (v *Vpc) validateWireGuard(db *database.Database,
+	network *net.IPNet) (errData *errortypes.ErrorData, err error) {
+
+	if v.WgPrivateKey == "" || v.WgPublicKey == "" {
+		v.WgPrivateKey, v.WgPublicKey, err = GenerateWgKeys()
+		if err != nil {
+			return
+		}
+	}
+
+	if v.WgPort == 0 {
+		v.WgPort = DefaultWgPort
+	}
+
+	if v.WgPort < 1 || v.WgPort > 65535 {
+		errData = &errortypes.ErrorData{
+			Error:   "wg_port_invalid",
+			Message: "WireGuard port invalid",
+		}
+		return
+	}
+
+	if v.WgPeers == nil {
+		v.WgPeers = []*WgPeer{}
+	}
+
+	peerIds := set.NewSet()
+	peerKeys := set.NewSet()
+	for _, peer := range v.WgPeers {
+		errData, err = peer.Validate(db, v, network)
+		if err != nil || errData != nil {
+			return
+		}
+
+		if peerIds.Contains(peer.Id) {
+			errData = &errortypes.ErrorData{
+				Error:   "wg_peer_duplicate",
+				Message: "Duplicate WireGuard peer",
+			}
+			return
+		}
+		peerIds.Add(peer.Id)
+
+		peerKey := peer.PublicKey
+		if !peer.Vpc.IsZero() {
+			peerKey = peer.Vpc.Hex()
+		}
+		if peerKeys.Contains(peerKey) {
+			errData = &errortypes.ErrorData{
+				Error:   "wg_peer_duplicate",
+				Message: "Duplicate WireGuard peer public key",
+			}
+			return
+		}
+		peerKeys.Add(peerKey)
+	}
+
+	if v.WgStatus == nil {
+		v.WgStatus = map[string]*WgPeerStatus{}
+	}
+
+	return
+}
@@ -0,0 +1,9 @@
+package vpnclient
+
+import (
+	"time"
+)
+
+const (
+	Ttl = 24 * time.Hour
+)
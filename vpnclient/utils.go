@@ -0,0 +1,305 @@
+package vpnclient
+
+import (
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/user"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vpc"
+)
+
+func Get(db *database.Database, clientId primitive.ObjectID) (
+	clnt *VpnClient, err error) {
+
+	coll := db.VpnClients()
+	clnt = &VpnClient{}
+
+	err = coll.FindOneId(clientId, clnt)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M) (
+	clnts []*VpnClient, err error) {
+
+	coll := db.VpnClients()
+	clnts = []*VpnClient{}
+
+	cursor, err := coll.Find(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		clnt := &VpnClient{}
+		err = cursor.Decode(clnt)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		clnts = append(clnts, clnt)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func getUsers(db *database.Database, clnts []*VpnClient) (
+	usrs map[primitive.ObjectID]*user.User, err error) {
+
+	usrs = map[primitive.ObjectID]*user.User{}
+
+	userIds := []primitive.ObjectID{}
+	for _, clnt := range clnts {
+		userIds = append(userIds, clnt.User)
+	}
+
+	if len(userIds) == 0 {
+		return
+	}
+
+	allUsrs, _, err := user.GetAll(db, &bson.M{
+		"_id": &bson.M{
+			"$in": userIds,
+		},
+	}, 0, 0)
+	if err != nil {
+		return
+	}
+
+	for _, usr := range allUsrs {
+		usrs[usr.Id] = usr
+	}
+
+	return
+}
+
+func clientAllowed(vc *vpc.Vpc, usr *user.User) bool {
+	if usr == nil || usr.Disabled {
+		return false
+	}
+
+	if !usr.ActiveUntil.IsZero() && usr.ActiveUntil.Before(time.Now()) {
+		return false
+	}
+
+	return vc.ClientAllowed(usr.Roles)
+}
+
+func GetActive(db *database.Database, vc *vpc.Vpc) (
+	clnts []*VpnClient, err error) {
+
+	allClnts, err := GetAll(db, &bson.M{
+		"vpc": vc.Id,
+		"expires": &bson.M{
+			"$gt": time.Now(),
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	usrs, err := getUsers(db, allClnts)
+	if err != nil {
+		return
+	}
+
+	clnts = []*VpnClient{}
+	for _, clnt := range allClnts {
+		if !clientAllowed(vc, usrs[clnt.User]) {
+			continue
+		}
+
+		clnts = append(clnts, clnt)
+	}
+
+	return
+}
+
+func GetAllPaged(db *database.Database, query *bson.M,
+	page, pageCount int64) (clnts []*VpnClient, count int64, err error) {
+
+	coll := db.VpnClients()
+	clnts = []*VpnClient{}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	page = utils.Min64(page, count/pageCount)
+	skip := utils.Min64(page*pageCount, count)
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"name", 1},
+			},
+			Skip:  &skip,
+			Limit: &pageCount,
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		clnt := &VpnClient{}
+		err = cursor.Decode(clnt)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		clnts = append(clnts, clnt)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, clientId primitive.ObjectID) (err error) {
+	coll := db.VpnClients()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": clientId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveUser(db *database.Database, userId, clientId primitive.ObjectID) (
+	err error) {
+
+	coll := db.VpnClients()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id":  clientId,
+		"user": userId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveUsers(db *database.Database, userIds []primitive.ObjectID) (
+	err error) {
+
+	coll := db.VpnClients()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"user": &bson.M{
+			"$in": userIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func RemoveDenied(db *database.Database, vc *vpc.Vpc) (
+	removed bool, err error) {
+
+	clnts, err := GetAll(db, &bson.M{
+		"vpc": vc.Id,
+	})
+	if err != nil {
+		return
+	}
+
+	usrs, err := getUsers(db, clnts)
+	if err != nil {
+		return
+	}
+
+	clientIds := []primitive.ObjectID{}
+	for _, clnt := range clnts {
+		if !clientAllowed(vc, usrs[clnt.User]) {
+			clientIds = append(clientIds, clnt.Id)
+		}
+	}
+
+	if len(clientIds) == 0 {
+		return
+	}
+
+	coll := db.VpnClients()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": clientIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	removed = true
+
+	return
+}
+
+func RemoveExpired(db *database.Database, vpcId primitive.ObjectID) (
+	err error) {
+
+	coll := db.VpnClients()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"vpc": vpcId,
+		"expires": &bson.M{
+			"$lte": time.Now(),
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
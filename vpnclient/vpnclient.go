@@ -0,0 +1,171 @@
+package vpnclient
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/user"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vpc"
+)
+
+type VpnClient struct {
+	Id              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name            string             `bson:"name" json:"name"`
+	Organization    primitive.ObjectID `bson:"organization" json:"organization"`
+	Vpc             primitive.ObjectID `bson:"vpc" json:"vpc"`
+	User            primitive.ObjectID `bson:"user" json:"user"`
+	Username        string             `bson:"username" json:"username"`
+	PublicKey       string             `bson:"public_key" json:"public_key"`
+	Address         string             `bson:"address" json:"address"`
+	Timestamp       time.Time          `bson:"timestamp" json:"timestamp"`
+	Expires         time.Time          `bson:"expires" json:"expires"`
+	Status          string             `bson:"status" json:"status"`
+	Endpoint        string             `bson:"endpoint" json:"endpoint"`
+	LatestHandshake time.Time          `bson:"latest_handshake" json:"latest_handshake"`
+	TransferRx      int64              `bson:"transfer_rx" json:"transfer_rx"`
+	TransferTx      int64              `bson:"transfer_tx" json:"transfer_tx"`
+	StatusTimestamp time.Time          `bson:"status_timestamp" json:"status_timestamp"`
+}
+
+func (c *VpnClient) IsExpired() bool {
+	return time.Now().After(c.Expires)
+}
+
+func (c *VpnClient) GetConfig(vc *vpc.Vpc, privKey string) string {
+	allowedIps := []string{
+		vc.Network,
+	}
+	if vc.ClientPool != "" {
+		allowedIps = append(allowedIps, vc.ClientPool)
+	}
+
+	return fmt.Sprintf(
+		"[Interface]\nPrivateKey = %s\nAddress = %s/32\n\n"+
+			"[Peer]\nPublicKey = %s\nEndpoint = %s\n"+
+			"AllowedIPs = %s\nPersistentKeepalive = 25\n",
+		privKey,
+		c.Address,
+		vc.WgPublicKey,
+		vc.WgEndpoint,
+		strings.Join(allowedIps, ", "),
+	)
+}
+
+func (c *VpnClient) Commit(db *database.Database) (err error) {
+	coll := db.VpnClients()
+
+	err = coll.Commit(c.Id, c)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *VpnClient) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.VpnClients()
+
+	err = coll.CommitFields(c.Id, c, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *VpnClient) Insert(db *database.Database, vc *vpc.Vpc) (
+	err error) {
+
+	coll := db.VpnClients()
+
+	if !c.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("vpnclient: Client already exists"),
+		}
+		return
+	}
+
+	err = RemoveExpired(db, vc.Id)
+	if err != nil {
+		return
+	}
+
+	pool, err := vc.GetClientPool()
+	if err != nil {
+		return
+	}
+
+	start := utils.CopyIpAddress(pool.IP)
+	utils.IncIpAddress(start)
+
+	for curIp := start; pool.Contains(curIp); utils.IncIpAddress(curIp) {
+		nextIp := utils.CopyIpAddress(curIp)
+		utils.IncIpAddress(nextIp)
+		if !pool.Contains(nextIp) {
+			break
+		}
+
+		c.Address = curIp.String()
+
+		_, err = coll.InsertOne(db, c)
+		if err != nil {
+			err = database.ParseError(err)
+			if _, ok := err.(*database.DuplicateKeyError); ok {
+				err = nil
+				continue
+			}
+			return
+		}
+
+		return
+	}
+
+	c.Address = ""
+	err = &errortypes.NotFoundError{
+		errors.New("vpnclient: Client pool full"),
+	}
+
+	return
+}
+
+func New(db *database.Database, vc *vpc.Vpc, usr *user.User,
+	name string) (clnt *VpnClient, privKey string, err error) {
+
+	privKey, pubKey, err := vpc.GenerateWgKeys()
+	if err != nil {
+		return
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = usr.Username
+	}
+
+	clnt = &VpnClient{
+		Name:         name,
+		Organization: vc.Organization,
+		Vpc:          vc.Id,
+		User:         usr.Id,
+		Username:     usr.Username,
+		PublicKey:    pubKey,
+		Timestamp:    time.Now(),
+		Expires:      time.Now().Add(Ttl),
+		Status:       vpc.VpnDisconnected,
+	}
+
+	err = clnt.Insert(db, vc)
+	if err != nil {
+		return
+	}
+
+	return
+}